@@ -5,6 +5,7 @@ import (
 	_ "embed"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/go-redis/redis/v8"
@@ -13,9 +14,13 @@ import (
 //go:embed lua/rate_limit.lua
 var rateLimitScript string
 
+//go:embed lua/token_bucket.lua
+var tokenBucketScript string
+
 type RedisLimiter struct {
-	client         *redis.Client
-	limitScriptSHA string
+	client          *redis.Client
+	limitScriptSHA  string
+	bucketScriptSHA string
 }
 
 var (
@@ -30,9 +35,14 @@ func New(ctx context.Context, r *redis.Client) *RedisLimiter {
 		if err != nil {
 			common.SysLog(fmt.Sprintf("Failed to load rate limit script: %v", err))
 		}
+		bucketSHA, err := r.ScriptLoad(ctx, tokenBucketScript).Result()
+		if err != nil {
+			common.SysLog(fmt.Sprintf("Failed to load token bucket script: %v", err))
+		}
 		instance = &RedisLimiter{
-			client:         r,
-			limitScriptSHA: limitSHA,
+			client:          r,
+			limitScriptSHA:  limitSHA,
+			bucketScriptSHA: bucketSHA,
 		}
 	})
 
@@ -68,11 +78,74 @@ func (rl *RedisLimiter) Allow(ctx context.Context, key string, opts ...Option) (
 	return result == 1, nil
 }
 
+// Take 从每个 Period 补满 Capacity 个令牌的桶中取出 Requested 个令牌，返回取出后桶的状态
+func (rl *RedisLimiter) Take(ctx context.Context, key string, opts ...Option) (*Result, error) {
+	config := newTakeConfig(opts...)
+	values, err := rl.client.EvalSha(
+		ctx,
+		rl.bucketScriptSHA,
+		[]string{key},
+		config.Requested,
+		float64(config.Capacity)/float64(config.Period.Milliseconds()),
+		config.Capacity,
+		boolArg(config.Force),
+	).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("rate limit failed: %w", err)
+	}
+	if len(values) != 3 {
+		return nil, fmt.Errorf("rate limit failed: unexpected result %v", values)
+	}
+	return &Result{
+		Allowed:   values[0] == 1,
+		Limit:     config.Capacity,
+		Remaining: max(values[1], 0),
+		Reset:     time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
+
+func boolArg(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Result 令牌桶的状态
+type Result struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	// 桶重新装满所需的时间
+	Reset time.Duration
+}
+
 // Config 配置选项模式
 type Config struct {
 	Capacity  int64
 	Rate      int64
 	Requested int64
+	// Take 使用：补满桶的周期，以及令牌不足时是否仍然扣除
+	Period time.Duration
+	Force  bool
+}
+
+func newTakeConfig(opts ...Option) *Config {
+	config := &Config{
+		Capacity:  10,
+		Requested: 1,
+		Period:    time.Minute,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.Capacity <= 0 {
+		config.Capacity = 1
+	}
+	if config.Period < time.Millisecond {
+		config.Period = time.Millisecond
+	}
+	return config
 }
 
 type Option func(*Config)
@@ -88,3 +161,12 @@ func WithRate(r int64) Option {
 func WithRequested(n int64) Option {
 	return func(cfg *Config) { cfg.Requested = n }
 }
+
+func WithPeriod(d time.Duration) Option {
+	return func(cfg *Config) { cfg.Period = d }
+}
+
+// WithForce 令牌不足时仍然扣除，令牌数可以为负，用于按实际用量校正
+func WithForce() Option {
+	return func(cfg *Config) { cfg.Force = true }
+}
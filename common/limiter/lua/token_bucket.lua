@@ -0,0 +1,41 @@
+-- 返回剩余令牌数的令牌桶限流器
+-- KEYS[1]: 限流器唯一标识
+-- ARGV[1]: 请求令牌数，为负数时退还令牌
+-- ARGV[2]: 令牌生成速率 (每毫秒)
+-- ARGV[3]: 桶容量
+-- ARGV[4]: 为 1 时无论令牌是否足够都扣除，令牌数可以为负，用于按实际用量校正
+-- 返回: {是否允许, 剩余令牌数, 桶装满所需毫秒数}
+
+local key = KEYS[1]
+local requested = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local capacity = tonumber(ARGV[3])
+local force = ARGV[4] == '1'
+
+-- 获取当前时间（Redis服务器时间，毫秒）
+local now = redis.call('TIME')
+local nowInMillis = tonumber(now[1]) * 1000 + math.floor(tonumber(now[2]) / 1000)
+
+local bucket = redis.call('HMGET', key, 'tokens', 'last_time')
+local tokens = tonumber(bucket[1])
+local last_time = tonumber(bucket[2])
+
+if not tokens or not last_time then
+    tokens = capacity
+else
+    local elapsed = math.max(0, nowInMillis - last_time)
+    tokens = math.min(capacity, tokens + elapsed * rate)
+end
+
+local allowed = false
+if force or tokens >= requested then
+    tokens = math.min(capacity, tokens - requested)
+    allowed = true
+end
+
+redis.call('HMSET', key, 'tokens', tostring(tokens), 'last_time', nowInMillis)
+local reset = math.ceil((capacity - tokens) / rate)
+-- 桶装满后状态与不存在时相同，可以过期
+redis.call('PEXPIRE', key, reset + 1000)
+
+return {allowed and 1 or 0, math.floor(tokens), reset}
@@ -0,0 +1,93 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// MemoryLimiter 未启用 Redis 时使用的进程内令牌桶，语义与 RedisLimiter.Take 相同
+type MemoryLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+}
+
+type memoryBucket struct {
+	tokens   float64
+	last     time.Time
+	expireAt time.Time
+}
+
+var (
+	memoryInstance *MemoryLimiter
+	memoryOnce     sync.Once
+)
+
+func NewMemory() *MemoryLimiter {
+	memoryOnce.Do(func() {
+		memoryInstance = &MemoryLimiter{buckets: make(map[string]*memoryBucket)}
+		go memoryInstance.clearExpired()
+	})
+	return memoryInstance
+}
+
+func (ml *MemoryLimiter) clearExpired() {
+	for {
+		time.Sleep(time.Minute)
+		now := time.Now()
+		ml.mu.Lock()
+		for key, bucket := range ml.buckets {
+			if now.After(bucket.expireAt) {
+				delete(ml.buckets, key)
+			}
+		}
+		ml.mu.Unlock()
+	}
+}
+
+func (ml *MemoryLimiter) Take(key string, opts ...Option) *Result {
+	config := newTakeConfig(opts...)
+	return ml.take(key, config, time.Now())
+}
+
+func (ml *MemoryLimiter) take(key string, config *Config, now time.Time) *Result {
+	capacity := float64(config.Capacity)
+	rate := capacity / float64(config.Period.Milliseconds())
+
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	bucket, ok := ml.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: capacity, last: now}
+		ml.buckets[key] = bucket
+	} else {
+		elapsed := max(now.Sub(bucket.last).Milliseconds(), 0)
+		bucket.tokens = min(capacity, bucket.tokens+float64(elapsed)*rate)
+		bucket.last = now
+	}
+
+	requested := float64(config.Requested)
+	allowed := false
+	if config.Force || bucket.tokens >= requested {
+		bucket.tokens = min(capacity, bucket.tokens-requested)
+		allowed = true
+	}
+	reset := time.Duration((capacity-bucket.tokens)/rate) * time.Millisecond
+	bucket.expireAt = now.Add(reset + time.Second)
+	return &Result{
+		Allowed:   allowed,
+		Limit:     config.Capacity,
+		Remaining: max(int64(bucket.tokens), 0),
+		Reset:     reset,
+	}
+}
+
+// Take 启用 Redis 时使用 Redis 令牌桶，否则使用进程内令牌桶
+func Take(ctx context.Context, key string, opts ...Option) (*Result, error) {
+	if common.RedisEnabled && common.RDB != nil {
+		return New(ctx, common.RDB).Take(ctx, key, opts...)
+	}
+	return NewMemory().Take(key, opts...), nil
+}
@@ -0,0 +1,47 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiterTake(t *testing.T) {
+	ml := &MemoryLimiter{buckets: make(map[string]*memoryBucket)}
+	now := time.Unix(1700000000, 0)
+	config := newTakeConfig(WithCapacity(2), WithPeriod(time.Minute))
+
+	for i := 0; i < 2; i++ {
+		if r := ml.take("k", config, now); !r.Allowed {
+			t.Fatalf("request %d should be allowed", i)
+		}
+	}
+	r := ml.take("k", config, now)
+	if r.Allowed || r.Remaining != 0 {
+		t.Fatalf("expected third request to be limited, got %+v", r)
+	}
+	if r.Reset != time.Minute {
+		t.Fatalf("expected bucket to refill in 1m, got %s", r.Reset)
+	}
+
+	// 30 秒补充 1 个令牌
+	if r := ml.take("k", config, now.Add(30*time.Second)); !r.Allowed {
+		t.Fatalf("expected request to be allowed after refill, got %+v", r)
+	}
+
+	// 校正时令牌数可以为负，之后需要更长时间才能恢复
+	force := newTakeConfig(WithCapacity(2), WithPeriod(time.Minute), WithRequested(3), WithForce())
+	r = ml.take("k", force, now.Add(30*time.Second))
+	if !r.Allowed || r.Remaining != 0 {
+		t.Fatalf("expected forced take to succeed, got %+v", r)
+	}
+	if r.Reset != 150*time.Second {
+		t.Fatalf("expected bucket to refill in 2m30s, got %s", r.Reset)
+	}
+
+	// 退还令牌不超过桶容量
+	refund := newTakeConfig(WithCapacity(2), WithPeriod(time.Minute), WithRequested(-10), WithForce())
+	r = ml.take("k", refund, now.Add(30*time.Second))
+	if r.Remaining != 2 || r.Reset != 0 {
+		t.Fatalf("expected full bucket after refund, got %+v", r)
+	}
+}
@@ -19,6 +19,11 @@ const (
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenCrossGroupRetry   ContextKey = "token_cross_group_retry"
+	ContextKeyTokenResponseCache     ContextKey = "token_response_cache"
+	ContextKeyTokenResponseCacheTTL  ContextKey = "token_response_cache_ttl"
+	ContextKeyTokenSpendLimit        ContextKey = "token_spend_limit"
+	ContextKeyTokenRpmLimit          ContextKey = "token_rpm_limit"
+	ContextKeyTokenTpmLimit          ContextKey = "token_tpm_limit"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -30,6 +35,7 @@ const (
 	ContextKeyChannelOtherSetting      ContextKey = "channel_other_setting"
 	ContextKeyChannelParamOverride     ContextKey = "param_override"
 	ContextKeyChannelHeaderOverride    ContextKey = "header_override"
+	ContextKeyChannelResponseOverride  ContextKey = "response_override"
 	ContextKeyChannelOrganization      ContextKey = "channel_organization"
 	ContextKeyChannelAutoBan           ContextKey = "auto_ban"
 	ContextKeyChannelModelMapping      ContextKey = "model_mapping"
@@ -52,6 +58,8 @@ const (
 	ContextKeyUsingGroup  ContextKey = "group"
 	ContextKeyUserName    ContextKey = "username"
 
+	ContextKeyUserSpendLimit ContextKey = "user_spend_limit"
+
 	ContextKeyLocalCountTokens ContextKey = "local_count_tokens"
 
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
@@ -3,6 +3,9 @@ package constant
 type MultiKeyMode string
 
 const (
-	MultiKeyModeRandom  MultiKeyMode = "random"  // 随机
-	MultiKeyModePolling MultiKeyMode = "polling" // 轮询
+	MultiKeyModeRandom            MultiKeyMode = "random"              // 随机
+	MultiKeyModePolling           MultiKeyMode = "polling"             // 轮询
+	MultiKeyModeLeastRecentlyUsed MultiKeyMode = "least_recently_used" // 最久未使用
+	MultiKeyModeLeastErrors       MultiKeyMode = "least_errors"        // 近期错误最少
+	MultiKeyModeQuotaAware        MultiKeyMode = "quota_aware"         // 上游剩余额度最多
 )
@@ -0,0 +1,220 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+func optionalTimestamp(ts int64) *int64 {
+	if ts == 0 {
+		return nil
+	}
+	return &ts
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func toOpenAIBatch(batch *model.Batch) dto.OpenAIBatch {
+	result := dto.OpenAIBatch{
+		Id:               batch.BatchId,
+		Object:           "batch",
+		Endpoint:         batch.Endpoint,
+		InputFileId:      batch.InputFileId,
+		CompletionWindow: batch.CompletionWindow,
+		Status:           batch.Status,
+		OutputFileId:     optionalString(batch.OutputFileId),
+		ErrorFileId:      optionalString(batch.ErrorFileId),
+		CreatedAt:        batch.CreatedAt,
+		InProgressAt:     optionalTimestamp(batch.InProgressAt),
+		ExpiresAt:        optionalTimestamp(batch.ExpiresAt),
+		FinalizingAt:     optionalTimestamp(batch.FinalizingAt),
+		CompletedAt:      optionalTimestamp(batch.CompletedAt),
+		FailedAt:         optionalTimestamp(batch.FailedAt),
+		ExpiredAt:        optionalTimestamp(batch.ExpiredAt),
+		CancellingAt:     optionalTimestamp(batch.CancellingAt),
+		CancelledAt:      optionalTimestamp(batch.CancelledAt),
+		RequestCounts: dto.OpenAIBatchRequestCounts{
+			Total:     batch.RequestTotal,
+			Completed: batch.RequestCompleted,
+			Failed:    batch.RequestFailed,
+		},
+	}
+	if batch.Errors != "" {
+		var batchErrors []dto.OpenAIBatchError
+		if err := common.UnmarshalJsonStr(batch.Errors, &batchErrors); err == nil && len(batchErrors) > 0 {
+			result.Errors = &dto.OpenAIBatchErrors{Object: "list", Data: batchErrors}
+		}
+	}
+	if batch.Metadata != "" {
+		_ = common.UnmarshalJsonStr(batch.Metadata, &result.Metadata)
+	}
+	return result
+}
+
+// getRequestUserBatch 获取路径参数中的批处理任务，任务不存在或不属于当前用户时已写入错误响应
+func getRequestUserBatch(c *gin.Context) *model.Batch {
+	if !operation_setting.GetBatchSetting().Enabled {
+		RelayNotImplemented(c)
+		return nil
+	}
+	batchId := c.Param("id")
+	batch, err := model.GetUserBatch(c.GetInt("id"), batchId)
+	if err != nil {
+		logger.LogError(c, "get batch failed: "+err.Error())
+		fileError(c, http.StatusInternalServerError, "get_batch_failed", "failed to get batch")
+		return nil
+	}
+	if batch == nil {
+		fileError(c, http.StatusNotFound, "batch_not_found", fmt.Sprintf("No such Batch object: %s", batchId))
+		return nil
+	}
+	return batch
+}
+
+// CreateBatch POST /v1/batches
+func CreateBatch(c *gin.Context) {
+	if !operation_setting.GetBatchSetting().Enabled {
+		RelayNotImplemented(c)
+		return
+	}
+	var req dto.OpenAIBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fileError(c, http.StatusBadRequest, "invalid_request", "invalid request body")
+		return
+	}
+	if !service.IsValidBatchEndpoint(req.Endpoint) {
+		fileError(c, http.StatusBadRequest, "invalid_endpoint", fmt.Sprintf("Unsupported endpoint: %q", req.Endpoint))
+		return
+	}
+	if req.CompletionWindow != service.BatchCompletionWindow {
+		fileError(c, http.StatusBadRequest, "invalid_completion_window", fmt.Sprintf("completion_window must be %q", service.BatchCompletionWindow))
+		return
+	}
+	userId := c.GetInt("id")
+	inputFile, err := model.GetUserFile(userId, req.InputFileId)
+	if err != nil {
+		logger.LogError(c, "get input file failed: "+err.Error())
+		fileError(c, http.StatusInternalServerError, "get_file_failed", "failed to get input file")
+		return
+	}
+	if inputFile == nil {
+		fileError(c, http.StatusNotFound, "file_not_found", fmt.Sprintf("No such File object: %s", req.InputFileId))
+		return
+	}
+	if inputFile.Purpose != "batch" {
+		fileError(c, http.StatusBadRequest, "invalid_input_file", "The input file must be uploaded with purpose 'batch'")
+		return
+	}
+
+	metadata := ""
+	if len(req.Metadata) > 0 {
+		data, err := common.Marshal(req.Metadata)
+		if err != nil {
+			fileError(c, http.StatusBadRequest, "invalid_metadata", "invalid metadata")
+			return
+		}
+		metadata = string(data)
+	}
+	now := common.GetTimestamp()
+	batch := &model.Batch{
+		BatchId:          "batch_" + common.GetRandomString(24),
+		UserId:           userId,
+		TokenId:          c.GetInt("token_id"),
+		ClientIp:         c.ClientIP(),
+		Endpoint:         req.Endpoint,
+		InputFileId:      req.InputFileId,
+		CompletionWindow: req.CompletionWindow,
+		Status:           model.BatchStatusValidating,
+		Metadata:         metadata,
+		CreatedAt:        now,
+		ExpiresAt:        service.BatchExpiresAt(now),
+	}
+	if err := model.InsertBatch(batch); err != nil {
+		logger.LogError(c, "create batch failed: "+err.Error())
+		fileError(c, http.StatusInternalServerError, "create_batch_failed", "failed to create batch")
+		return
+	}
+	c.JSON(http.StatusOK, toOpenAIBatch(batch))
+}
+
+// ListBatches GET /v1/batches
+func ListBatches(c *gin.Context) {
+	if !operation_setting.GetBatchSetting().Enabled {
+		RelayNotImplemented(c)
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	// 多查一条用于判断是否还有更多
+	batches, err := model.ListUserBatches(c.GetInt("id"), c.Query("after"), limit+1)
+	if err != nil {
+		logger.LogError(c, "list batches failed: "+err.Error())
+		fileError(c, http.StatusInternalServerError, "list_batches_failed", "failed to list batches")
+		return
+	}
+	list := dto.OpenAIBatchList{
+		Object:  "list",
+		Data:    make([]dto.OpenAIBatch, 0, len(batches)),
+		HasMore: len(batches) > limit,
+	}
+	if list.HasMore {
+		batches = batches[:limit]
+	}
+	for _, batch := range batches {
+		list.Data = append(list.Data, toOpenAIBatch(batch))
+	}
+	if len(list.Data) > 0 {
+		list.FirstId = list.Data[0].Id
+		list.LastId = list.Data[len(list.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// RetrieveBatch GET /v1/batches/:id
+func RetrieveBatch(c *gin.Context) {
+	batch := getRequestUserBatch(c)
+	if batch == nil {
+		return
+	}
+	c.JSON(http.StatusOK, toOpenAIBatch(batch))
+}
+
+// CancelBatch POST /v1/batches/:id/cancel
+func CancelBatch(c *gin.Context) {
+	batch := getRequestUserBatch(c)
+	if batch == nil {
+		return
+	}
+	if batch.Status != model.BatchStatusValidating && batch.Status != model.BatchStatusInProgress {
+		fileError(c, http.StatusConflict, "batch_not_cancellable", fmt.Sprintf("Cannot cancel a batch with status '%s'", batch.Status))
+		return
+	}
+	if err := model.CancelBatch(batch.Id); err != nil {
+		logger.LogError(c, "cancel batch failed: "+err.Error())
+		fileError(c, http.StatusInternalServerError, "cancel_batch_failed", "failed to cancel batch")
+		return
+	}
+	batch, err := model.GetUserBatch(batch.UserId, batch.BatchId)
+	if err != nil || batch == nil {
+		fileError(c, http.StatusInternalServerError, "get_batch_failed", "failed to get batch")
+		return
+	}
+	c.JSON(http.StatusOK, toOpenAIBatch(batch))
+}
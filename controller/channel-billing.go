@@ -421,6 +421,37 @@ func updateChannelBalance(channel *model.Channel) (float64, error) {
 	return balance, nil
 }
 
+// updateMultiKeyChannelBalance 逐个查询多密钥渠道中启用 key 的余额，供 quota_aware 模式选择 key，
+// 渠道余额记为各 key 余额之和
+func updateMultiKeyChannelBalance(channel *model.Channel) (float64, error) {
+	var total float64
+	var lastErr error
+	updated := 0
+	for i, key := range channel.GetKeys() {
+		if status, ok := channel.ChannelInfo.MultiKeyStatusList[i]; ok && status != common.ChannelStatusEnabled {
+			continue
+		}
+		keyChannel := *channel
+		keyChannel.Key = key
+		balance, err := updateChannelBalance(&keyChannel)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		model.SetChannelKeyBalance(channel.Id, i, balance)
+		total += balance
+		updated++
+	}
+	if updated == 0 {
+		if lastErr == nil {
+			lastErr = errors.New("没有可查询余额的密钥")
+		}
+		return 0, lastErr
+	}
+	channel.UpdateBalance(total)
+	return total, nil
+}
+
 func UpdateChannelBalance(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -432,14 +463,12 @@ func UpdateChannelBalance(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	var balance float64
 	if channel.ChannelInfo.IsMultiKey {
-		c.JSON(http.StatusOK, gin.H{
-			"success": false,
-			"message": "多密钥渠道不支持余额查询",
-		})
-		return
+		balance, err = updateMultiKeyChannelBalance(channel)
+	} else {
+		balance, err = updateChannelBalance(channel)
 	}
-	balance, err := updateChannelBalance(channel)
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -461,7 +490,12 @@ func updateAllChannelsBalance() error {
 			continue
 		}
 		if channel.ChannelInfo.IsMultiKey {
-			continue // skip multi-key channels
+			// 仅 quota_aware 模式需要逐个 key 查询余额，余额不足的 key 会被排在后面而不是禁用整个渠道
+			if channel.ChannelInfo.MultiKeyMode == constant.MultiKeyModeQuotaAware {
+				_, _ = updateMultiKeyChannelBalance(channel)
+				time.Sleep(common.RequestInterval)
+			}
+			continue
 		}
 		// Azure 渠道暂不支持自动余额查询
 		// 原因：Azure OpenAI 使用 Azure 订阅计费，需要 Azure Cost Management API
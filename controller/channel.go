@@ -711,6 +711,9 @@ func AddChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	for i := range channels {
+		service.RecordAudit(c, "create", service.AuditTargetChannel, channels[i].Id, nil, &channels[i])
+	}
 	service.ResetProxyClientCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -722,11 +725,13 @@ func AddChannel(c *gin.Context) {
 func DeleteChannel(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
 	channel := model.Channel{Id: id}
+	origin, _ := model.GetChannelById(id, true)
 	err := channel.Delete()
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	service.RecordAudit(c, "delete", service.AuditTargetChannel, id, origin, nil)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -741,6 +746,7 @@ func DeleteDisabledChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	service.RecordAudit(c, "delete_disabled", service.AuditTargetChannel, "", gin.H{"rows": rows}, nil)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -751,15 +757,16 @@ func DeleteDisabledChannel(c *gin.Context) {
 }
 
 type ChannelTag struct {
-	Tag            string  `json:"tag"`
-	NewTag         *string `json:"new_tag"`
-	Priority       *int64  `json:"priority"`
-	Weight         *uint   `json:"weight"`
-	ModelMapping   *string `json:"model_mapping"`
-	Models         *string `json:"models"`
-	Groups         *string `json:"groups"`
-	ParamOverride  *string `json:"param_override"`
-	HeaderOverride *string `json:"header_override"`
+	Tag              string  `json:"tag"`
+	NewTag           *string `json:"new_tag"`
+	Priority         *int64  `json:"priority"`
+	Weight           *uint   `json:"weight"`
+	ModelMapping     *string `json:"model_mapping"`
+	Models           *string `json:"models"`
+	Groups           *string `json:"groups"`
+	ParamOverride    *string `json:"param_override"`
+	HeaderOverride   *string `json:"header_override"`
+	ResponseOverride *string `json:"response_override"`
 }
 
 func DisableTagChannels(c *gin.Context) {
@@ -777,6 +784,7 @@ func DisableTagChannels(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	service.RecordAudit(c, "disable_tag", service.AuditTargetChannel, "tag:"+channelTag.Tag, nil, channelTag)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -800,6 +808,7 @@ func EnableTagChannels(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	service.RecordAudit(c, "enable_tag", service.AuditTargetChannel, "tag:"+channelTag.Tag, nil, channelTag)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -847,11 +856,23 @@ func EditTagChannels(c *gin.Context) {
 		}
 		channelTag.HeaderOverride = common.GetPointer[string](trimmed)
 	}
-	err = model.EditChannelByTag(channelTag.Tag, channelTag.NewTag, channelTag.ModelMapping, channelTag.Models, channelTag.Groups, channelTag.Priority, channelTag.Weight, channelTag.ParamOverride, channelTag.HeaderOverride)
+	if channelTag.ResponseOverride != nil {
+		trimmed := strings.TrimSpace(*channelTag.ResponseOverride)
+		if trimmed != "" && !json.Valid([]byte(trimmed)) {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "响应覆盖必须是合法的 JSON 格式",
+			})
+			return
+		}
+		channelTag.ResponseOverride = common.GetPointer[string](trimmed)
+	}
+	err = model.EditChannelByTag(channelTag.Tag, channelTag.NewTag, channelTag.ModelMapping, channelTag.Models, channelTag.Groups, channelTag.Priority, channelTag.Weight, channelTag.ParamOverride, channelTag.HeaderOverride, channelTag.ResponseOverride)
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	service.RecordAudit(c, "edit_tag", service.AuditTargetChannel, "tag:"+channelTag.Tag, nil, channelTag)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -880,6 +901,7 @@ func DeleteChannelBatch(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	service.RecordAudit(c, "batch_delete", service.AuditTargetChannel, "", gin.H{"ids": channelBatch.Ids}, nil)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -996,6 +1018,9 @@ func UpdateChannel(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	if updated, err := model.GetChannelById(channel.Id, true); err == nil {
+		service.RecordAudit(c, "update", service.AuditTargetChannel, channel.Id, originChannel, updated)
+	}
 	model.InitChannelCache()
 	service.ResetProxyClientCache()
 	channel.Key = ""
@@ -1126,6 +1151,7 @@ func BatchSetChannelTag(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	service.RecordAudit(c, "batch_set_tag", service.AuditTargetChannel, "", nil, channelBatch)
 	model.InitChannelCache()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -1221,6 +1247,7 @@ func CopyChannel(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
 		return
 	}
+	service.RecordAudit(c, "copy", service.AuditTargetChannel, id, nil, clone)
 	model.InitChannelCache()
 	// success
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "", "data": gin.H{"id": clone.Id}})
@@ -1228,12 +1255,15 @@ func CopyChannel(c *gin.Context) {
 
 // MultiKeyManageRequest represents the request for multi-key management operations
 type MultiKeyManageRequest struct {
-	ChannelId int    `json:"channel_id"`
-	Action    string `json:"action"`              // "disable_key", "enable_key", "delete_key", "delete_disabled_keys", "get_key_status"
-	KeyIndex  *int   `json:"key_index,omitempty"` // for disable_key, enable_key, and delete_key actions
-	Page      int    `json:"page,omitempty"`      // for get_key_status pagination
-	PageSize  int    `json:"page_size,omitempty"` // for get_key_status pagination
-	Status    *int   `json:"status,omitempty"`    // for get_key_status filtering: 1=enabled, 2=manual_disabled, 3=auto_disabled, nil=all
+	ChannelId      int    `json:"channel_id"`
+	Action         string `json:"action"`                    // "disable_key", "enable_key", "delete_key", "delete_disabled_keys", "get_key_status", "set_key_limit"
+	KeyIndex       *int   `json:"key_index,omitempty"`       // for disable_key, enable_key, delete_key and set_key_limit actions
+	RPM            int    `json:"rpm,omitempty"`             // for set_key_limit, 0 means unlimited
+	TPM            int    `json:"tpm,omitempty"`             // for set_key_limit, 0 means unlimited
+	MaxConcurrency int    `json:"max_concurrency,omitempty"` // for set_key_limit, max in-flight requests of the key, 0 means unlimited
+	Page           int    `json:"page,omitempty"`            // for get_key_status pagination
+	PageSize       int    `json:"page_size,omitempty"`       // for get_key_status pagination
+	Status         *int   `json:"status,omitempty"`          // for get_key_status filtering: 1=enabled, 2=manual_disabled, 3=auto_disabled, nil=all
 }
 
 // MultiKeyStatusResponse represents the response for key status query
@@ -1255,6 +1285,9 @@ type KeyStatus struct {
 	DisabledTime int64  `json:"disabled_time,omitempty"`
 	Reason       string `json:"reason,omitempty"`
 	KeyPreview   string `json:"key_preview"` // first 10 chars of key for identification
+	// 运行时用量统计（当前节点）与限流配置
+	Usage *model.ChannelKeyUsage `json:"usage,omitempty"`
+	Limit *model.MultiKeyLimit   `json:"limit,omitempty"`
 }
 
 // ManageMultiKeys handles multi-key management operations
@@ -1305,6 +1338,7 @@ func ManageMultiKeys(c *gin.Context) {
 		var enabledCount, manualDisabledCount, autoDisabledCount int
 
 		// Build all key status data first
+		usages := model.GetChannelKeyUsages(channel.Id)
 		var allKeyStatusList []KeyStatus
 		for i, key := range keys {
 			status := 1 // default enabled
@@ -1342,13 +1376,20 @@ func ManageMultiKeys(c *gin.Context) {
 				keyPreview = key[:10] + "..."
 			}
 
-			allKeyStatusList = append(allKeyStatusList, KeyStatus{
+			keyStatus := KeyStatus{
 				Index:        i,
 				Status:       status,
 				DisabledTime: disabledTime,
 				Reason:       reason,
 				KeyPreview:   keyPreview,
-			})
+			}
+			if usage, ok := usages[i]; ok {
+				keyStatus.Usage = &usage
+			}
+			if limit, ok := channel.ChannelInfo.MultiKeyLimits[i]; ok {
+				keyStatus.Limit = &limit
+			}
+			allKeyStatusList = append(allKeyStatusList, keyStatus)
 		}
 
 		// Apply status filter if specified
@@ -1581,6 +1622,7 @@ func ManageMultiKeys(c *gin.Context) {
 		var newStatusList = make(map[int]int)
 		var newDisabledTime = make(map[int]int64)
 		var newDisabledReason = make(map[int]string)
+		var newLimits = make(map[int]model.MultiKeyLimit)
 
 		newIndex := 0
 		for i, key := range keys {
@@ -1590,6 +1632,9 @@ func ManageMultiKeys(c *gin.Context) {
 			}
 
 			remainingKeys = append(remainingKeys, key)
+			if limit, exists := channel.ChannelInfo.MultiKeyLimits[i]; exists {
+				newLimits[newIndex] = limit
+			}
 
 			// 保留其他密钥的状态信息，重新索引
 			if channel.ChannelInfo.MultiKeyStatusList != nil {
@@ -1624,6 +1669,7 @@ func ManageMultiKeys(c *gin.Context) {
 		channel.ChannelInfo.MultiKeyStatusList = newStatusList
 		channel.ChannelInfo.MultiKeyDisabledTime = newDisabledTime
 		channel.ChannelInfo.MultiKeyDisabledReason = newDisabledReason
+		channel.ChannelInfo.MultiKeyLimits = newLimits
 
 		err = channel.Update()
 		if err != nil {
@@ -1631,6 +1677,8 @@ func ManageMultiKeys(c *gin.Context) {
 			return
 		}
 
+		// 索引已变化，旧的用量统计不再对应
+		model.ResetChannelKeyUsages(channel.Id)
 		model.InitChannelCache()
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
@@ -1645,6 +1693,7 @@ func ManageMultiKeys(c *gin.Context) {
 		var newStatusList = make(map[int]int)
 		var newDisabledTime = make(map[int]int64)
 		var newDisabledReason = make(map[int]string)
+		var newLimits = make(map[int]model.MultiKeyLimit)
 
 		newIndex := 0
 		for i, key := range keys {
@@ -1660,6 +1709,9 @@ func ManageMultiKeys(c *gin.Context) {
 				deletedCount++
 			} else {
 				remainingKeys = append(remainingKeys, key)
+				if limit, exists := channel.ChannelInfo.MultiKeyLimits[i]; exists {
+					newLimits[newIndex] = limit
+				}
 				// 保留非自动禁用密钥的状态信息，重新索引
 				if status != 1 {
 					newStatusList[newIndex] = status
@@ -1692,6 +1744,7 @@ func ManageMultiKeys(c *gin.Context) {
 		channel.ChannelInfo.MultiKeyStatusList = newStatusList
 		channel.ChannelInfo.MultiKeyDisabledTime = newDisabledTime
 		channel.ChannelInfo.MultiKeyDisabledReason = newDisabledReason
+		channel.ChannelInfo.MultiKeyLimits = newLimits
 
 		err = channel.Update()
 		if err != nil {
@@ -1699,6 +1752,7 @@ func ManageMultiKeys(c *gin.Context) {
 			return
 		}
 
+		model.ResetChannelKeyUsages(channel.Id)
 		model.InitChannelCache()
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
@@ -1707,6 +1761,54 @@ func ManageMultiKeys(c *gin.Context) {
 		})
 		return
 
+	case "set_key_limit":
+		if request.KeyIndex == nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "未指定要设置的密钥索引",
+			})
+			return
+		}
+
+		keyIndex := *request.KeyIndex
+		if keyIndex < 0 || keyIndex >= channel.ChannelInfo.MultiKeySize {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "密钥索引超出范围",
+			})
+			return
+		}
+		if request.RPM < 0 || request.TPM < 0 || request.MaxConcurrency < 0 {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "RPM / TPM / 并发数不能为负数",
+			})
+			return
+		}
+
+		if channel.ChannelInfo.MultiKeyLimits == nil {
+			channel.ChannelInfo.MultiKeyLimits = make(map[int]model.MultiKeyLimit)
+		}
+		// 全部为 0 表示取消限制
+		if request.RPM == 0 && request.TPM == 0 && request.MaxConcurrency == 0 {
+			delete(channel.ChannelInfo.MultiKeyLimits, keyIndex)
+		} else {
+			channel.ChannelInfo.MultiKeyLimits[keyIndex] = model.MultiKeyLimit{RPM: request.RPM, TPM: request.TPM, MaxConcurrency: request.MaxConcurrency}
+		}
+
+		err = channel.Update()
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+
+		model.InitChannelCache()
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "密钥限流已更新",
+		})
+		return
+
 	default:
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+)
+
+// GetChannelCircuitBreakers 查看渠道 + 模型维度的熔断状态（仅当前节点）
+// 参数：
+// - channel_id: int (optional)
+func GetChannelCircuitBreakers(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"setting": operation_setting.GetCircuitBreakerSetting(),
+			"items":   model.GetChannelCircuitBreakers(channelId),
+		},
+	})
+}
+
+// ResetChannelCircuitBreakers 手动关闭熔断器
+// 参数：
+// - channel_id: int (optional, 不传时需 all=true)
+// - model_name: string (optional)
+func ResetChannelCircuitBreakers(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	modelName := strings.TrimSpace(c.Query("model_name"))
+	if channelId <= 0 && c.Query("all") != "true" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "缺少参数：channel_id，或使用 all=true 重置全部",
+		})
+		return
+	}
+	deleted := model.ResetChannelCircuitBreakers(channelId, modelName)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"deleted": deleted,
+		},
+	})
+}
+
+var autoProbeCircuitBreakersOnce sync.Once
+
+// AutomaticallyProbeCircuitBreakers 后台对半开状态的熔断器发起测试请求。
+// 熔断状态保存在各节点内存中，因此每个节点都需要运行。
+func AutomaticallyProbeCircuitBreakers() {
+	autoProbeCircuitBreakersOnce.Do(func() {
+		for {
+			time.Sleep(5 * time.Second)
+			setting := operation_setting.GetCircuitBreakerSetting()
+			if !setting.Enabled || !setting.ActiveProbeEnabled {
+				continue
+			}
+			for _, breaker := range model.GetHalfOpenCircuitBreakers() {
+				probeCircuitBreaker(breaker)
+			}
+		}
+	})
+}
+
+func probeCircuitBreaker(breaker model.ChannelCircuitBreaker) {
+	channel, err := model.CacheGetChannel(breaker.ChannelId)
+	if err != nil || channel.Status != common.ChannelStatusEnabled {
+		// 渠道已删除或被整体禁用，熔断器不再有意义
+		model.ResetChannelCircuitBreakers(breaker.ChannelId, "")
+		return
+	}
+	model.MarkCircuitBreakerProbe(breaker.ChannelId, breaker.ModelName)
+	result := testChannel(channel, breaker.ModelName, "")
+	if result.localErr != nil {
+		// 无法测试的渠道类型，等待真实请求探测
+		return
+	}
+	if result.newAPIError != nil {
+		common.SysLog(fmt.Sprintf("circuit breaker probe failed: channel #%d, model %s: %s", breaker.ChannelId, breaker.ModelName, result.newAPIError.Error()))
+		model.RecordCircuitBreakerFailure(breaker.ChannelId, breaker.ModelName, result.newAPIError.MaskSensitiveError())
+		return
+	}
+	model.RecordCircuitBreakerSuccess(breaker.ChannelId, breaker.ModelName)
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+)
+
+// GetChannelCooldowns 查看因上游限流处于冷却中的渠道与 key（仅当前节点）
+// 参数：
+// - channel_id: int (optional)
+func GetChannelCooldowns(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"setting": operation_setting.GetUpstreamCooldownSetting(),
+			"items":   model.GetChannelCooldowns(channelId),
+		},
+	})
+}
+
+// ClearChannelCooldowns 手动解除冷却，不传 channel_id 时解除全部
+func ClearChannelCooldowns(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	deleted := model.ClearChannelCooldowns(channelId)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"deleted": deleted,
+		},
+	})
+}
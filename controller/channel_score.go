@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+)
+
+// GetChannelScores 查看渠道 + 模型维度的实时健康评分（仅当前节点）
+// 参数：
+// - channel_id: int (optional)
+// - model_name: string (optional)
+func GetChannelScores(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	modelName := strings.TrimSpace(c.Query("model_name"))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"setting": operation_setting.GetChannelSelectSetting(),
+			"items":   model.GetChannelScores(channelId, modelName),
+		},
+	})
+}
+
+// ResetChannelScores 清除渠道评分，不传 channel_id 时清除全部
+func ResetChannelScores(c *gin.Context) {
+	channelId, _ := strconv.Atoi(c.Query("channel_id"))
+	deleted := model.ResetChannelScores(channelId)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data": gin.H{
+			"deleted": deleted,
+		},
+	})
+}
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+func serveChannelScoreTestRequest(handler gin.HandlerFunc, method string, path string) gjson.Result {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(method, path, nil)
+	handler(c)
+	return gjson.Parse(recorder.Body.String())
+}
+
+func TestChannelScoresAPI(t *testing.T) {
+	setting := operation_setting.GetChannelSelectSetting()
+	origin := *setting
+	setting.MinSamples, setting.ErrorPenalty, setting.LatencyPenalty, setting.MinHealthFactor, setting.ScoreTTLSeconds = 1, 2, 1, 0.05, 60
+	const healthy, unhealthy = 910001, 910002
+	t.Cleanup(func() {
+		*setting = origin
+		model.ResetChannelScores(healthy)
+		model.ResetChannelScores(unhealthy)
+	})
+	model.RecordChannelScoreSuccess(healthy, "gpt-4o", 100*time.Millisecond, 0)
+	model.RecordChannelScoreSuccess(unhealthy, "gpt-4o", 400*time.Millisecond, 0)
+	model.RecordChannelScoreSuccess(healthy, "claude-sonnet", time.Second, 0)
+
+	tests := []struct {
+		name  string
+		query string
+		want  map[int]float64
+	}{
+		// 同模型下按最快的渠道计算健康系数
+		{name: "by model", query: "?model_name=gpt-4o", want: map[int]float64{healthy: 1, unhealthy: 0.25}},
+		{name: "by channel", query: "?channel_id=910002", want: map[int]float64{unhealthy: 1}},
+		{name: "by channel and model", query: "?channel_id=910001&model_name=claude-sonnet", want: map[int]float64{healthy: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := serveChannelScoreTestRequest(GetChannelScores, http.MethodGet, "/api/channel/scores"+tt.query)
+			items := body.Get("data.items").Array()
+			if !body.Get("success").Bool() || len(items) != len(tt.want) {
+				t.Fatalf("response = %s", body.Raw)
+			}
+			for _, item := range items {
+				if want, ok := tt.want[int(item.Get("channel_id").Int())]; !ok || item.Get("health_factor").Float() != want {
+					t.Fatalf("item = %s, want health factor %v", item.Raw, want)
+				}
+			}
+		})
+	}
+
+	body := serveChannelScoreTestRequest(ResetChannelScores, http.MethodDelete, "/api/channel/scores?channel_id=910001")
+	if body.Get("data.deleted").Int() != 2 {
+		t.Fatalf("reset response = %s", body.Raw)
+	}
+	if items := model.GetChannelScores(healthy, ""); len(items) != 0 {
+		t.Fatalf("scores after reset = %+v", items)
+	}
+}
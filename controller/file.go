@@ -0,0 +1,182 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/filestore"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func fileError(c *gin.Context, statusCode int, code string, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": types.OpenAIError{
+			Message: message,
+			Type:    "invalid_request_error",
+			Code:    code,
+		},
+	})
+}
+
+func toOpenAIFile(file *model.File) dto.OpenAIFile {
+	return dto.OpenAIFile{
+		Id:        file.FileId,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+		Status:    file.Status,
+	}
+}
+
+// getRequestUserFile 获取路径参数中的文件，文件不存在或不属于当前用户时已写入错误响应
+func getRequestUserFile(c *gin.Context) *model.File {
+	if !operation_setting.GetFileSetting().Enabled {
+		RelayNotImplemented(c)
+		return nil
+	}
+	fileId := c.Param("id")
+	file, err := model.GetUserFile(c.GetInt("id"), fileId)
+	if err != nil {
+		logger.LogError(c, "get file failed: "+err.Error())
+		fileError(c, http.StatusInternalServerError, "get_file_failed", "failed to get file")
+		return nil
+	}
+	if file == nil {
+		fileError(c, http.StatusNotFound, "file_not_found", fmt.Sprintf("No such File object: %s", fileId))
+		return nil
+	}
+	return file
+}
+
+// UploadFile POST /v1/files
+func UploadFile(c *gin.Context) {
+	if !operation_setting.GetFileSetting().Enabled {
+		RelayNotImplemented(c)
+		return
+	}
+	purpose := c.PostForm("purpose")
+	if !service.IsValidFilePurpose(purpose) {
+		fileError(c, http.StatusBadRequest, "invalid_purpose", fmt.Sprintf("Invalid purpose: %q", purpose))
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		fileError(c, http.StatusBadRequest, "missing_file", "'file' is a required property")
+		return
+	}
+	file, err := service.CreateUserFile(c.GetInt("id"), c.GetInt("token_id"), header, purpose)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrFileTooLarge):
+			fileError(c, http.StatusRequestEntityTooLarge, "file_too_large", err.Error())
+		case errors.Is(err, service.ErrFileQuotaExceeded):
+			fileError(c, http.StatusForbidden, "file_quota_exceeded", err.Error())
+		default:
+			logger.LogError(c, "create file failed: "+err.Error())
+			fileError(c, http.StatusInternalServerError, "create_file_failed", "failed to save file")
+		}
+		return
+	}
+	c.JSON(http.StatusOK, toOpenAIFile(file))
+}
+
+// ListFiles GET /v1/files
+func ListFiles(c *gin.Context) {
+	if !operation_setting.GetFileSetting().Enabled {
+		RelayNotImplemented(c)
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10000"))
+	if limit <= 0 || limit > 10000 {
+		limit = 10000
+	}
+	// 多查一条用于判断是否还有更多
+	files, err := model.ListUserFiles(c.GetInt("id"), c.Query("purpose"), c.Query("after"), limit+1, c.Query("order") == "asc")
+	if err != nil {
+		logger.LogError(c, "list files failed: "+err.Error())
+		fileError(c, http.StatusInternalServerError, "list_files_failed", "failed to list files")
+		return
+	}
+	list := dto.OpenAIFileList{
+		Object:  "list",
+		Data:    make([]dto.OpenAIFile, 0, len(files)),
+		HasMore: len(files) > limit,
+	}
+	if list.HasMore {
+		files = files[:limit]
+	}
+	for _, file := range files {
+		list.Data = append(list.Data, toOpenAIFile(file))
+	}
+	if len(list.Data) > 0 {
+		list.FirstId = list.Data[0].Id
+		list.LastId = list.Data[len(list.Data)-1].Id
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// RetrieveFile GET /v1/files/:id
+func RetrieveFile(c *gin.Context) {
+	file := getRequestUserFile(c)
+	if file == nil {
+		return
+	}
+	c.JSON(http.StatusOK, toOpenAIFile(file))
+}
+
+// DeleteFile DELETE /v1/files/:id
+func DeleteFile(c *gin.Context) {
+	file := getRequestUserFile(c)
+	if file == nil {
+		return
+	}
+	if err := service.DeleteUserFile(file); err != nil {
+		logger.LogError(c, "delete file failed: "+err.Error())
+		fileError(c, http.StatusInternalServerError, "delete_file_failed", "failed to delete file")
+		return
+	}
+	c.JSON(http.StatusOK, dto.OpenAIFileDeleted{
+		Id:      file.FileId,
+		Object:  "file",
+		Deleted: true,
+	})
+}
+
+// RetrieveFileContent GET /v1/files/:id/content
+func RetrieveFileContent(c *gin.Context) {
+	file := getRequestUserFile(c)
+	if file == nil {
+		return
+	}
+	content, err := service.OpenFileContent(file)
+	if err != nil {
+		if errors.Is(err, filestore.ErrNotFound) {
+			fileError(c, http.StatusNotFound, "file_not_found", "file content not found")
+			return
+		}
+		logger.LogError(c, "open file content failed: "+err.Error())
+		fileError(c, http.StatusInternalServerError, "read_file_failed", "failed to read file")
+		return
+	}
+	defer content.Close()
+	c.Header("Content-Type", file.MimeType)
+	c.Header("Content-Length", strconv.FormatInt(file.Bytes, 10))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Filename}))
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, content); err != nil {
+		logger.LogError(c, "write file content failed: "+err.Error())
+	}
+}
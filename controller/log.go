@@ -167,3 +167,26 @@ func DeleteHistoryLogs(c *gin.Context) {
 	})
 	return
 }
+
+// GetLogRollupStats 按天和模型返回汇总数据，明细已按保留策略删除的日期也能查询
+func GetLogRollupStats(c *gin.Context) {
+	logType, _ := strconv.Atoi(c.Query("type"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	stats, err := model.GetLogRollupStats(model.LogRollupQuery{
+		Type:           logType,
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+		Username:       c.Query("username"),
+		TokenName:      c.Query("token_name"),
+		ModelName:      c.Query("model_name"),
+		Channel:        channel,
+		Group:          c.Query("group"),
+	})
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, stats)
+}
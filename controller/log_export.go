@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const logExportBatchSize = 1000
+
+// ExportAllLogs 管理员流式导出日志，筛选参数与 GetAllLogs 相同
+func ExportAllLogs(c *gin.Context) {
+	filter := parseLogExportFilter(c)
+	filter.Username = c.Query("username")
+	filter.Channel, _ = strconv.Atoi(c.Query("channel"))
+	exportLogs(c, filter)
+}
+
+// ExportUserLogs 用户导出自己的日志
+func ExportUserLogs(c *gin.Context) {
+	filter := parseLogExportFilter(c)
+	filter.UserId = c.GetInt("id")
+	exportLogs(c, filter)
+}
+
+func parseLogExportFilter(c *gin.Context) model.LogExportFilter {
+	logType, _ := strconv.Atoi(c.Query("type"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	return model.LogExportFilter{
+		Type:           logType,
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+		ModelName:      c.Query("model_name"),
+		TokenName:      c.Query("token_name"),
+		Group:          c.Query("group"),
+	}
+}
+
+// exportLogs 参数：format=csv|ndjson，columns 为逗号分隔的列名，gzip=true 时输出 .gz 文件
+func exportLogs(c *gin.Context, filter model.LogExportFilter) {
+	format := c.DefaultQuery("format", service.LogExportFormatCSV)
+	columns, err := service.ParseLogExportColumns(c.Query("columns"))
+	if err != nil {
+		common.ApiErrorMsg(c, err.Error())
+		return
+	}
+	if format != service.LogExportFormatCSV && format != service.LogExportFormatNDJSON {
+		common.ApiErrorMsg(c, "不支持的导出格式: "+format)
+		return
+	}
+	useGzip, _ := strconv.ParseBool(c.Query("gzip"))
+
+	contentType := "text/csv; charset=utf-8"
+	if format == service.LogExportFormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	filename := fmt.Sprintf("logs-%s.%s", time.Now().Format("20060102150405"), format)
+	var out io.Writer = c.Writer
+	if useGzip {
+		contentType = "application/gzip"
+		filename += ".gz"
+		gz := gzip.NewWriter(c.Writer)
+		defer gz.Close()
+		out = gz
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	writer, err := service.NewLogExportWriter(out, format, columns)
+	if err != nil {
+		common.SysError("failed to export logs: " + err.Error())
+		return
+	}
+	err = model.ExportLogs(c.Request.Context(), filter, logExportBatchSize, func(logs []*model.Log) error {
+		if err := writer.Write(logs); err != nil {
+			return err
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		// 响应头已经发出，只能中断输出
+		common.SysError("failed to export logs: " + err.Error())
+		return
+	}
+	if err := writer.Flush(); err != nil {
+		common.SysError("failed to export logs: " + err.Error())
+	}
+}
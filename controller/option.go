@@ -8,6 +8,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/QuantumNous/new-api/setting/console_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
@@ -137,6 +138,15 @@ func UpdateOption(c *gin.Context) {
 			})
 			return
 		}
+	case "BatchGroupRatio":
+		err = ratio_setting.CheckBatchGroupRatio(option.Value.(string))
+		if err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
 	case "ImageRatio":
 		err = ratio_setting.UpdateImageRatioByJSONString(option.Value.(string))
 		if err != nil {
@@ -219,11 +229,15 @@ func UpdateOption(c *gin.Context) {
 			return
 		}
 	}
+	common.OptionMapRWMutex.RLock()
+	oldValue := common.OptionMap[option.Key]
+	common.OptionMapRWMutex.RUnlock()
 	err = model.UpdateOption(option.Key, option.Value.(string))
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	service.RecordOptionAudit(c, option.Key, oldValue, option.Value.(string))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -12,6 +12,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 
 	"github.com/gin-gonic/gin"
 )
@@ -194,6 +195,7 @@ func AddRedemption(c *gin.Context) {
 		}
 		keys = append(keys, key)
 	}
+	service.RecordAudit(c, "create", service.AuditTargetRedemption, "", nil, req)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -206,11 +208,13 @@ func AddRedemption(c *gin.Context) {
 
 func DeleteRedemption(c *gin.Context) {
 	id, _ := strconv.Atoi(c.Param("id"))
+	origin, _ := model.GetRedemptionById(id)
 	err := model.DeleteRedemptionById(id)
 	if err != nil {
 		common.ApiError(c, err)
 		return
 	}
+	service.RecordAudit(c, "delete", service.AuditTargetRedemption, id, origin, nil)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -231,6 +235,7 @@ func UpdateRedemption(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	originRedemption := *cleanRedemption
 	if statusOnly == "" {
 		if err := validateExpiredTime(redemption.ExpiredTime); err != nil {
 			c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
@@ -249,6 +254,7 @@ func UpdateRedemption(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	service.RecordAudit(c, "update", service.AuditTargetRedemption, cleanRedemption.Id, originRedemption, cleanRedemption)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -263,6 +269,7 @@ func DeleteInvalidRedemption(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	service.RecordAudit(c, "delete_invalid", service.AuditTargetRedemption, "", gin.H{"rows": rows}, nil)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -8,13 +8,15 @@ import (
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
-	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/metrics"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/relay"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
@@ -28,6 +30,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 func relayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIError {
@@ -115,6 +119,12 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	// 后台模式的 Responses 请求在预扣费前校验
+	backgroundRequest, newAPIError := validateBackgroundResponsesRequest(request)
+	if newAPIError != nil {
+		return
+	}
+
 	// Keep an original snapshot so we can re-apply per-channel role mappings on retries.
 	roleSnapshot := service.SnapshotRequestRoles(request)
 
@@ -124,11 +134,20 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	defer func() {
+		status := c.Writer.Status()
+		if newAPIError != nil {
+			status = newAPIError.StatusCode
+		}
+		metrics.ObserveRelayRequest(relayInfo.OriginModelName, relayInfo.ChannelId, relayInfo.UsingGroup, string(relayFormat), status, time.Since(relayInfo.StartTime))
+	}()
+
 	needSensitiveCheck := setting.ShouldCheckPromptSensitive()
+	needModeration := operation_setting.GetModerationSetting().ActionForGroup(relayInfo.UsingGroup) != ""
 	needCountToken := constant.CountToken
 	// Avoid building huge CombineText (strings.Join) when token counting and sensitive check are both disabled.
 	var meta *types.TokenCountMeta
-	if needSensitiveCheck || needCountToken {
+	if needSensitiveCheck || needModeration || needCountToken {
 		meta = request.GetTokenCountMeta()
 	} else {
 		meta = fastTokenCountMetaForPricing(request)
@@ -143,7 +162,18 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}
 
+	// 审核模型在关键词检查之后调用，避免已被关键词拦截的请求产生审核费用
+	if needModeration && meta != nil {
+		newAPIError = relay.ModeratePrompt(c, relayInfo, meta.CombineText)
+		if newAPIError != nil {
+			return
+		}
+	}
+
+	_, estimateSpan := tracing.StartSpan(c.Request.Context(), "estimate_tokens", tracing.AttrModel.String(relayInfo.OriginModelName))
 	tokens, err := service.EstimateRequestToken(c, meta, relayInfo)
+	estimateSpan.SetAttributes(attribute.Int("newapi.prompt_tokens", tokens))
+	estimateSpan.End()
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeCountTokenFailed)
 		return
@@ -151,7 +181,21 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 	relayInfo.SetEstimatePromptTokens(tokens)
 
+	newAPIError = service.ReserveTokenTPM(c, relayInfo, tokens)
+	if newAPIError != nil {
+		return
+	}
+	defer func() {
+		// 请求失败时退还预占的每分钟 token 数
+		if newAPIError != nil {
+			service.ReconcileTokenTPM(relayInfo, 0)
+		}
+	}()
+
+	_, pricingSpan := tracing.StartSpan(c.Request.Context(), "pricing", tracing.AttrModel.String(relayInfo.OriginModelName))
 	priceData, err := helper.ModelPriceHelper(c, relayInfo, tokens, meta)
+	pricingSpan.SetAttributes(attribute.Int("newapi.quota_to_pre_consume", priceData.QuotaToPreConsume))
+	pricingSpan.End()
 	if err != nil {
 		newAPIError = types.NewError(err, types.ErrorCodeModelPriceError)
 		return
@@ -175,6 +219,25 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}()
 
+	// 后台模式的请求在服务端异步执行，立即返回 queued 状态的响应
+	if backgroundRequest != nil {
+		newAPIError = submitBackgroundResponse(c, relayInfo, relayFormat, backgroundRequest, roleSnapshot)
+		return
+	}
+
+	// 命中响应缓存时不请求上游
+	if relay.ServeResponseCache(c, relayInfo) {
+		return
+	}
+	newAPIError = relayWithRetry(c, relayInfo, relayFormat, request, roleSnapshot)
+}
+
+// relayWithRetry 安装响应处理链后依次尝试渠道，直到成功或不再重试
+func relayWithRetry(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat, request dto.Request, roleSnapshot *service.RequestRoleSnapshot) (newAPIError *types.NewAPIError) {
+	// 响应先按渠道规则改写，再经过内容审核。响应覆盖的 writer 在选定渠道后安装在最外层
+	relay.ServeOutputModeration(c, relayInfo)
+	defer relay.FinishResponseWriters(c)
+
 	retryParam := &service.RetryParam{
 		Ctx:        c,
 		TokenGroup: relayInfo.TokenGroup,
@@ -182,19 +245,38 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		Retry:      common.GetPointer(0),
 	}
 
+	// 渠道并发已满时换渠道重新选择的次数，不计入重试次数，超过后在当前渠道排队等待
+	saturatedReselects := 0
+	reselect := false
 	for ; retryParam.GetRetry() <= common.RetryTimes; retryParam.IncreaseRetry() {
-		channel, channelErr := getChannel(c, relayInfo, retryParam)
+		channel, channelErr := getChannel(c, relayInfo, retryParam, reselect)
 		if channelErr != nil {
 			logger.LogError(c, channelErr.Error())
 			newAPIError = channelErr
 			break
 		}
+		reselect = false
+
+		lease, leaseErr := service.AcquireChannelConcurrency(c, channel.Id, saturatedReselects >= maxSaturatedReselects)
+		if leaseErr != nil {
+			if leaseErr.GetErrorCode() == types.ErrorCodeChannelSaturated && !types.IsSkipRetryError(leaseErr) && saturatedReselects < maxSaturatedReselects {
+				saturatedReselects++
+				reselect = true
+				retryParam.ResetRetryNextTry()
+				continue
+			}
+			newAPIError = leaseErr
+			break
+		}
+		// 正常情况下在本次尝试结束后立即释放，这里兜底处理 panic
+		defer lease.Release()
 
 		// Restore original roles then apply per-channel mappings (channel settings are available after selection).
 		service.RestoreRequestRoles(request, roleSnapshot)
 		service.ApplyModelRoleMappingsToRequest(c, request)
 
 		addUsedChannel(c, channel.Id)
+		relay.ServeResponseOverride(c, relayInfo)
 		requestBody, bodyErr := common.GetRequestBody(c)
 		if bodyErr != nil {
 			// Ensure consistent 413 for oversized bodies even when error occurs later (e.g., retry path)
@@ -212,6 +294,16 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 		c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 
+		attemptStartTime := time.Now()
+		// 本次尝试内的上游请求和流式响应 span 都挂在 attempt span 下
+		requestCtx := c.Request.Context()
+		attemptCtx, attemptSpan := tracing.StartSpan(requestCtx, "relay_attempt",
+			tracing.AttrRetryIndex.Int(retryParam.GetRetry()),
+			tracing.AttrChannelId.Int(channel.Id),
+			tracing.AttrModel.String(relayInfo.OriginModelName),
+			tracing.AttrGroup.String(relayInfo.UsingGroup),
+		)
+		c.Request = c.Request.WithContext(attemptCtx)
 		switch relayFormat {
 		case types.RelayFormatOpenAIRealtime:
 			newAPIError = relay.WssHelper(c, relayInfo)
@@ -222,16 +314,49 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		default:
 			newAPIError = relayHandler(c, relayInfo)
 		}
+		if newAPIError != nil {
+			attemptSpan.SetStatus(codes.Error, newAPIError.Error())
+			attemptSpan.SetAttributes(attribute.Int("http.response.status_code", newAPIError.StatusCode))
+		}
+		attemptSpan.End()
+		c.Request = c.Request.WithContext(requestCtx)
+
+		lease.Release()
+
+		// 对冲请求由另一个渠道胜出时，按实际处理请求的渠道记录结果
+		if relayInfo.ChannelMeta != nil && relayInfo.ChannelId != channel.Id {
+			if hedgeChannel, err := model.CacheGetChannel(relayInfo.ChannelId); err == nil && hedgeChannel != nil {
+				channel = hedgeChannel
+			}
+		}
 
 		if newAPIError == nil {
+			recordChannelModelSuccess(relayInfo, channel.Id, attemptStartTime)
 			return
 		}
 
-		processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+		// 后台响应被取消时不再重试，也不计入渠道错误
+		if relayInfo.BackgroundResponseId != "" && c.Request.Context().Err() != nil {
+			break
+		}
+
+		if newAPIError.GetErrorCode() == types.ErrorCodeStreamContinuationUnsupported {
+			// 渠道不支持预填充，没有请求上游，之后的尝试不再选择该渠道
+			retryParam.ExcludeChannelIds = append(retryParam.ExcludeChannelIds, channel.Id)
+		} else {
+			processChannelError(c, *types.NewChannelError(channel.Id, channel.Type, channel.Name, channel.ChannelInfo.IsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), channel.GetAutoBan()), newAPIError)
+		}
 
 		if !shouldRetry(c, newAPIError, common.RetryTimes-retryParam.GetRetry()) {
 			break
 		}
+		metrics.IncRelayRetry(relayInfo.OriginModelName, relayInfo.UsingGroup)
+	}
+
+	// 续写失败时客户端已收到部分内容，结束响应流并按已发送的内容计费，不再返回错误
+	if newAPIError != nil && relay.FinishInterruptedStream(c, relayInfo) {
+		logger.LogError(c, fmt.Sprintf("stream failover failed: %s", newAPIError.Error()))
+		newAPIError = nil
 	}
 
 	useChannel := c.GetStringSlice("use_channel")
@@ -239,6 +364,7 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		retryLogStr := fmt.Sprintf("重试：%s", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(useChannel)), "->"), "[]"))
 		logger.LogInfo(c, retryLogStr)
 	}
+	return newAPIError
 }
 
 var upgrader = websocket.Upgrader{
@@ -281,8 +407,12 @@ func fastTokenCountMetaForPricing(request dto.Request) *types.TokenCountMeta {
 	return meta
 }
 
-func getChannel(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service.RetryParam) (*model.Channel, *types.NewAPIError) {
-	if info.ChannelMeta == nil {
+// maxSaturatedReselects 渠道并发已满时最多换几次渠道，之后排队等待
+const maxSaturatedReselects = 3
+
+// getChannel 首次尝试直接使用 distributor 选好的渠道，reselect 为 true 时强制重新选择
+func getChannel(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service.RetryParam, reselect bool) (*model.Channel, *types.NewAPIError) {
+	if info.ChannelMeta == nil && !reselect {
 		autoBan := c.GetBool("auto_ban")
 		autoBanInt := 1
 		if !autoBan {
@@ -306,13 +436,23 @@ func getChannel(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service
 		return nil, types.NewError(fmt.Errorf("分组 %s 下模型 %s 的可用渠道不存在（retry）", selectGroup, info.OriginModelName), types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
 	}
 
-	newAPIError := middleware.SetupContextForSelectedChannel(c, channel, info.OriginModelName)
+	newAPIError := service.SetupContextForSelectedChannel(c, channel, info.OriginModelName)
 	if newAPIError != nil {
 		return nil, newAPIError
 	}
 	return channel, nil
 }
 
+// recordChannelModelSuccess 将本次尝试的耗时与首字时间写入渠道评分，并更新熔断器
+func recordChannelModelSuccess(info *relaycommon.RelayInfo, channelId int, attemptStartTime time.Time) {
+	var ttft time.Duration
+	if info.HasSendResponse() && info.FirstResponseTime.After(attemptStartTime) {
+		ttft = info.FirstResponseTime.Sub(attemptStartTime)
+	}
+	model.RecordChannelScoreSuccess(channelId, info.OriginModelName, time.Since(attemptStartTime), ttft)
+	model.RecordCircuitBreakerSuccess(channelId, info.OriginModelName)
+}
+
 func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) bool {
 	if openaiErr == nil {
 		return false
@@ -329,6 +469,12 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	if _, ok := c.Get("specific_channel_id"); ok {
 		return false
 	}
+	// 首字超时时客户端尚未收到任何内容，504 默认不在重试状态码中，这里单独放行；
+	// 响应流中途断开时由下一个渠道续写，选中的渠道不支持续写时换一个渠道
+	switch openaiErr.GetErrorCode() {
+	case types.ErrorCodeFirstTokenTimeout, types.ErrorCodeStreamInterrupted, types.ErrorCodeStreamContinuationUnsupported:
+		return true
+	}
 	return operation_setting.ShouldRetryByStatusCode(openaiErr.StatusCode)
 }
 
@@ -337,14 +483,36 @@ func processChannelError(c *gin.Context, channelError types.ChannelError, err *t
 
 	service.RecentCallsCache().UpsertErrorByContext(c, err.MaskSensitiveError(), fmt.Sprint(err.GetErrorType()), fmt.Sprint(err.GetErrorCode()), err.StatusCode)
 
+	// 上游限流（429）按响应头临时冷却对应 key 或渠道，冷却到期自动恢复，不计入自动禁用与熔断
+	keyIndex := 0
+	if channelError.IsMultiKey {
+		keyIndex = common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+	}
+	coolingDown := service.ApplyUpstreamCooldown(channelError, keyIndex, err)
+
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
 	// do not use context to get channel info, there may be inconsistent channel info when processing asynchronously
-	if service.ShouldDisableChannel(channelError.ChannelType, err) && channelError.AutoBan {
+	if !coolingDown && service.ShouldDisableChannel(channelError.ChannelType, err) && channelError.AutoBan {
 		gopool.Go(func() {
 			service.DisableChannel(channelError, err.Error())
 		})
 	}
 
+	// 只统计渠道侧原因导致的失败，避免用户请求错误拉低渠道评分或触发熔断。
+	// 模型不存在、不支持等错误不会自动禁用渠道，即使状态码是 400 也要计入该模型的熔断
+	firstTokenTimeout := err.GetErrorCode() == types.ErrorCodeFirstTokenTimeout
+	streamInterrupted := err.GetErrorCode() == types.ErrorCodeStreamInterrupted
+	if types.IsChannelError(err) || firstTokenTimeout || streamInterrupted || operation_setting.ShouldRetryByStatusCode(err.StatusCode) || service.IsModelScopedChannelError(err) {
+		modelName := c.GetString("original_model")
+		model.RecordChannelScoreFailure(channelError.ChannelId, modelName)
+		if channelError.IsMultiKey {
+			model.RecordChannelKeyError(channelError.ChannelId, keyIndex, err.MaskSensitiveError())
+		}
+		if !coolingDown {
+			model.RecordCircuitBreakerFailure(channelError.ChannelId, modelName, err.MaskSensitiveError())
+		}
+	}
+
 	if constant.ErrorLogEnabled && types.IsRecordErrorLog(err) {
 		// 保存错误日志到mysql中
 		userId := c.GetInt("id")
@@ -372,6 +540,13 @@ func processChannelError(c *gin.Context, channelError types.ChannelError, err *t
 		}
 		other["admin_info"] = adminInfo
 		model.RecordErrorLog(c, userId, channelId, modelName, tokenName, err.MaskSensitiveError(), tokenId, 0, false, userGroup, other)
+	} else if firstTokenTimeout {
+		// 未记录错误日志时也将首字超时计入模型健康度
+		model.RecordModelHealthEventAsync(c, &model.ModelHealthEvent{
+			ModelName: c.GetString("original_model"),
+			CreatedAt: common.GetTimestamp(),
+			IsError:   true,
+		})
 	}
 
 }
@@ -419,6 +594,59 @@ func RelayMidjourney(c *gin.Context) {
 	}
 }
 
+// RelayCountTokens 处理 Claude count_tokens 与 Gemini countTokens 请求，不扣费、不记录日志
+func RelayCountTokens(c *gin.Context, relayFormat types.RelayFormat) {
+	var (
+		request     dto.Request
+		relayInfo   *relaycommon.RelayInfo
+		newAPIError *types.NewAPIError
+		err         error
+	)
+	defer func() {
+		if newAPIError == nil {
+			return
+		}
+		logger.LogError(c, fmt.Sprintf("count tokens error: %s", newAPIError.Error()))
+		newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), c.GetString(common.RequestIdKey)))
+		if relayFormat == types.RelayFormatClaude {
+			c.JSON(newAPIError.StatusCode, gin.H{
+				"type":  "error",
+				"error": newAPIError.ToClaudeError(),
+			})
+		} else {
+			c.JSON(newAPIError.StatusCode, gin.H{
+				"error": newAPIError.ToOpenAIError(),
+			})
+		}
+	}()
+
+	if relayFormat == types.RelayFormatClaude {
+		request, err = helper.GetAndValidateClaudeRequest(c)
+	} else {
+		request, err = helper.GetAndValidateGeminiCountTokensRequest(c)
+	}
+	if err != nil {
+		newAPIError = types.NewError(err, types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+		return
+	}
+
+	if relayFormat == types.RelayFormatClaude {
+		relayInfo = relaycommon.GenRelayInfoClaude(c, request)
+	} else {
+		relayInfo = relaycommon.GenRelayInfoGemini(c, request)
+	}
+
+	tokens, newAPIError := relay.CountTokensHelper(c, relayInfo)
+	if newAPIError != nil {
+		return
+	}
+	if relayFormat == types.RelayFormatClaude {
+		c.JSON(http.StatusOK, dto.ClaudeCountTokensResponse{InputTokens: tokens})
+	} else {
+		c.JSON(http.StatusOK, dto.GeminiCountTokensResponse{TotalTokens: tokens})
+	}
+}
+
 func RelayNotImplemented(c *gin.Context) {
 	err := types.OpenAIError{
 		Message: "API not implemented",
@@ -462,7 +690,7 @@ func RelayTask(c *gin.Context) {
 		Retry:      common.GetPointer(0),
 	}
 	for ; shouldRetryTaskRelay(c, channelId, taskErr, retryTimes) && retryParam.GetRetry() < retryTimes; retryParam.IncreaseRetry() {
-		channel, newAPIError := getChannel(c, relayInfo, retryParam)
+		channel, newAPIError := getChannel(c, relayInfo, retryParam, false)
 		if newAPIError != nil {
 			logger.LogError(c, fmt.Sprintf("CacheGetRandomSatisfiedChannel failed: %s", newAPIError.Error()))
 			taskErr = service.TaskErrorWrapperLocal(newAPIError.Err, "get_channel_failed", http.StatusInternalServerError)
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestProcessChannelErrorCircuitBreaker(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	breakerSetting := operation_setting.GetCircuitBreakerSetting()
+	originBreakerSetting := *breakerSetting
+	breakerSetting.Enabled, breakerSetting.FailureThreshold, breakerSetting.OpenSeconds = true, 1, 60
+	originErrorLogEnabled := constant.ErrorLogEnabled
+	constant.ErrorLogEnabled = false
+	const modelScoped, userError = 930001, 930002
+	t.Cleanup(func() {
+		*breakerSetting = originBreakerSetting
+		constant.ErrorLogEnabled = originErrorLogEnabled
+		for _, channelId := range []int{modelScoped, userError} {
+			model.ResetChannelCircuitBreakers(channelId, "")
+			model.ResetChannelScores(channelId)
+		}
+	})
+
+	tests := []struct {
+		name      string
+		channelId int
+		err       *types.NewAPIError
+		want      bool
+	}{
+		// 上游用 400 拒绝模型时不会自动禁用渠道，需要由熔断把该模型摘除
+		{name: "400 model not found", channelId: modelScoped, err: types.WithOpenAIError(types.OpenAIError{Message: "The model gpt-4o does not exist"}, http.StatusBadRequest), want: true},
+		{name: "400 user error", channelId: userError, err: types.WithOpenAIError(types.OpenAIError{Message: "messages is required"}, http.StatusBadRequest), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			c.Set("original_model", "gpt-4o")
+			processChannelError(c, types.ChannelError{ChannelId: tt.channelId}, tt.err)
+
+			breakers := model.GetChannelCircuitBreakers(tt.channelId)
+			if opened := len(breakers) == 1 && breakers[0].State == model.CircuitStateOpen; opened != tt.want {
+				t.Fatalf("breakers = %+v, want open = %v", breakers, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/sjson"
+)
+
+// backgroundResponseCancels 本节点正在执行的后台响应，取消时立即中断上游请求
+var backgroundResponseCancels sync.Map
+
+// getRequestUserResponse 获取路径参数中网关保存的响应，响应不存在或不属于当前用户时已写入错误响应
+func getRequestUserResponse(c *gin.Context) *model.StoredResponse {
+	if !service.IsResponsesStoreEnabled() {
+		RelayNotImplemented(c)
+		return nil
+	}
+	responseId := c.Param("id")
+	response, err := model.GetUserStoredResponse(c.GetInt("id"), responseId)
+	if err != nil {
+		logger.LogError(c, "get response failed: "+err.Error())
+		fileError(c, http.StatusInternalServerError, "get_response_failed", "failed to get response")
+		return nil
+	}
+	if response == nil {
+		fileError(c, http.StatusNotFound, "response_not_found", fmt.Sprintf("Response with id '%s' not found.", responseId))
+		return nil
+	}
+	return response
+}
+
+// RetrieveResponse GET /v1/responses/:id
+func RetrieveResponse(c *gin.Context) {
+	response := getRequestUserResponse(c)
+	if response == nil {
+		return
+	}
+	c.Data(http.StatusOK, "application/json", response.Response)
+}
+
+// DeleteResponse DELETE /v1/responses/:id
+func DeleteResponse(c *gin.Context) {
+	response := getRequestUserResponse(c)
+	if response == nil {
+		return
+	}
+	// 未结束的后台响应先取消并退还预扣的额度
+	if response.Status == dto.ResponsesStatusQueued || response.Status == dto.ResponsesStatusInProgress {
+		if _, err := cancelBackgroundResponse(c, response); err != nil {
+			logger.LogError(c, "cancel response failed: "+err.Error())
+			fileError(c, http.StatusInternalServerError, "delete_response_failed", "failed to delete response")
+			return
+		}
+	}
+	if err := model.DeleteStoredResponse(response); err != nil {
+		logger.LogError(c, "delete response failed: "+err.Error())
+		fileError(c, http.StatusInternalServerError, "delete_response_failed", "failed to delete response")
+		return
+	}
+	c.JSON(http.StatusOK, dto.ResponsesDeleted{
+		Id:      response.ResponseId,
+		Object:  "response",
+		Deleted: true,
+	})
+}
+
+// CancelResponse POST /v1/responses/:id/cancel
+func CancelResponse(c *gin.Context) {
+	response := getRequestUserResponse(c)
+	if response == nil {
+		return
+	}
+	switch response.Status {
+	case dto.ResponsesStatusQueued, dto.ResponsesStatusInProgress:
+		cancelled, err := cancelBackgroundResponse(c, response)
+		if err != nil {
+			logger.LogError(c, "cancel response failed: "+err.Error())
+			fileError(c, http.StatusInternalServerError, "cancel_response_failed", "failed to cancel response")
+			return
+		}
+		// 取消前已执行完成时返回最新结果
+		if !cancelled {
+			if response = getRequestUserResponse(c); response == nil {
+				return
+			}
+		}
+	case dto.ResponsesStatusCancelled:
+	default:
+		fileError(c, http.StatusConflict, "response_not_cancellable", fmt.Sprintf("Cannot cancel a response with status '%s'", response.Status))
+		return
+	}
+	c.Data(http.StatusOK, "application/json", response.Response)
+}
+
+// cancelBackgroundResponse 取消未结束的后台响应并中断本节点上的执行，响应已结束时返回 false。
+// 状态已更新但退还额度失败时只记录日志
+func cancelBackgroundResponse(c *gin.Context, response *model.StoredResponse) (bool, error) {
+	cancelled, err := service.CancelBackgroundResponse(response)
+	if !cancelled {
+		return false, err
+	}
+	if err != nil {
+		logger.LogError(c, err.Error())
+	}
+	if cancel, ok := backgroundResponseCancels.Load(response.ResponseId); ok {
+		cancel.(context.CancelFunc)()
+	}
+	return true, nil
+}
+
+// ListResponseInputItems GET /v1/responses/:id/input_items
+func ListResponseInputItems(c *gin.Context) {
+	response := getRequestUserResponse(c)
+	if response == nil {
+		return
+	}
+	items, err := service.GetStoredResponseInputItems(response)
+	if err != nil {
+		logger.LogError(c, "parse response input items failed: "+err.Error())
+		fileError(c, http.StatusInternalServerError, "get_response_failed", "failed to get response input items")
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	// 默认按时间倒序返回
+	if c.Query("order") != "asc" {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	if after := c.Query("after"); after != "" {
+		for i, item := range items {
+			if common.Interface2String(item["id"]) == after {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+
+	list := dto.ResponsesInputItemList{
+		Object:  "list",
+		Data:    items,
+		HasMore: len(items) > limit,
+	}
+	if list.HasMore {
+		list.Data = items[:limit]
+	}
+	if list.Data == nil {
+		list.Data = []map[string]any{}
+	}
+	if len(list.Data) > 0 {
+		list.FirstId = common.Interface2String(list.Data[0]["id"])
+		list.LastId = common.Interface2String(list.Data[len(list.Data)-1]["id"])
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// validateBackgroundResponsesRequest 返回后台模式的 Responses 请求，不是后台请求时返回 nil
+func validateBackgroundResponsesRequest(request dto.Request) (*dto.OpenAIResponsesRequest, *types.NewAPIError) {
+	responsesRequest, ok := request.(*dto.OpenAIResponsesRequest)
+	if !ok || !responsesRequest.IsBackground() {
+		return nil, nil
+	}
+	var err error
+	switch {
+	case !service.IsResponsesStoreEnabled():
+		err = errors.New("background mode is not enabled")
+	case responsesRequest.Stream:
+		err = errors.New("stream is not supported in background mode")
+	case strings.TrimSpace(string(responsesRequest.Store)) == "false":
+		err = errors.New("background mode requires store=true")
+	default:
+		_, err = service.ResponsesInputItems(responsesRequest.Input)
+	}
+	if err != nil {
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	return responsesRequest, nil
+}
+
+// submitBackgroundResponse 保存 queued 状态的响应并返回给用户，请求在独立的 context 中按重试策略执行
+func submitBackgroundResponse(c *gin.Context, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat, request *dto.OpenAIResponsesRequest, roleSnapshot *service.RequestRoleSnapshot) *types.NewAPIError {
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeReadRequestBodyFailed, types.ErrOptionWithSkipRetry())
+	}
+	// 上游按普通请求执行，透传请求体时同样去掉 background
+	body, err = sjson.DeleteBytes(body, "background")
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+	}
+	stored, response, err := service.SubmitBackgroundResponse(relayInfo, request)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
+	}
+	request.Background = nil
+
+	// 请求结束后仍在执行，使用独立的 gin context 和 RelayInfo
+	info := *relayInfo
+	info.BackgroundResponseId = stored.ResponseId
+	ctx, cancel := context.WithCancel(context.WithoutCancel(c.Request.Context()))
+	recorder := httptest.NewRecorder()
+	bc, _ := gin.CreateTestContext(recorder)
+	bc.Request = c.Request.Clone(ctx)
+	bc.Keys = c.Copy().Keys
+	bc.Set(common.KeyRequestBody, body)
+	backgroundResponseCancels.Store(stored.ResponseId, cancel)
+	gopool.Go(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				common.SysError(fmt.Sprintf("background response %s panic: %v", stored.ResponseId, r))
+			}
+			cancel()
+			backgroundResponseCancels.Delete(stored.ResponseId)
+		}()
+		runBackgroundResponse(bc, recorder, cancel, &info, relayFormat, request, roleSnapshot, stored)
+	})
+
+	c.JSON(http.StatusOK, response)
+	return nil
+}
+
+// runBackgroundResponse 执行后台响应并保存结果。预扣的额度由结束响应的一方结算，
+// 被取消或被判定中断时已由对方退还
+func runBackgroundResponse(c *gin.Context, recorder *httptest.ResponseRecorder, cancel context.CancelFunc, relayInfo *relaycommon.RelayInfo, relayFormat types.RelayFormat, request dto.Request, roleSnapshot *service.RequestRoleSnapshot, stored *model.StoredResponse) {
+	started, err := service.StartBackgroundResponse(stored)
+	if err != nil {
+		logger.LogError(c, "start background response failed: "+err.Error())
+		if _, err := service.FailBackgroundResponse(stored, types.OpenAIError{Message: "failed to start background response"}); err != nil {
+			logger.LogError(c, "fail background response failed: "+err.Error())
+		}
+	}
+	if !started {
+		service.ReconcileTokenTPM(relayInfo, 0)
+		return
+	}
+
+	// 定期刷新更新时间，其他节点取消或删除响应后中断执行
+	gopool.Go(func() {
+		ticker := time.NewTicker(service.BackgroundResponseHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-ticker.C:
+				if active, err := service.TouchBackgroundResponse(stored); err == nil && !active {
+					cancel()
+				}
+			}
+		}
+	})
+
+	newAPIError := relayWithRetry(c, relayInfo, relayFormat, request, roleSnapshot)
+	if newAPIError != nil {
+		logger.LogError(c, fmt.Sprintf("background response %s failed: %s", stored.ResponseId, newAPIError.Error()))
+		service.ReconcileTokenTPM(relayInfo, 0)
+		if _, err := service.FailBackgroundResponse(stored, newAPIError.ToOpenAIError()); err != nil {
+			logger.LogError(c, "fail background response failed: "+err.Error())
+		}
+		return
+	}
+	if err := service.FinishBackgroundResponse(stored, recorder.Body.Bytes()); err != nil {
+		logger.LogError(c, "save background response failed: "+err.Error())
+	}
+}
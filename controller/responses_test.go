@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/model/modeltest"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+func TestValidateBackgroundResponsesRequest(t *testing.T) {
+	storeSetting := operation_setting.GetResponsesStoreSetting()
+	enabled := storeSetting.Enabled
+	storeSetting.Enabled = true
+	t.Cleanup(func() {
+		storeSetting.Enabled = enabled
+	})
+
+	tests := []struct {
+		name       string
+		body       string
+		background bool
+		wantErr    bool
+	}{
+		{name: "foreground", body: `{"model":"gpt-5","input":"hi"}`},
+		{name: "background false", body: `{"model":"gpt-5","input":"hi","background":false}`},
+		{name: "background", body: `{"model":"gpt-5","input":"hi","background":true}`, background: true},
+		{name: "stream", body: `{"model":"gpt-5","input":"hi","background":true,"stream":true}`, wantErr: true},
+		{name: "store false", body: `{"model":"gpt-5","input":"hi","background":true,"store":false}`, wantErr: true},
+		{name: "invalid input", body: `{"model":"gpt-5","input":1,"background":true}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &dto.OpenAIResponsesRequest{}
+			if err := common.Unmarshal([]byte(tt.body), request); err != nil {
+				t.Fatal(err)
+			}
+			got, apiErr := validateBackgroundResponsesRequest(request)
+			if (apiErr != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", apiErr, tt.wantErr)
+			}
+			if (got != nil) != tt.background {
+				t.Fatalf("background request = %v, want %v", got != nil, tt.background)
+			}
+		})
+	}
+
+	storeSetting.Enabled = false
+	request := &dto.OpenAIResponsesRequest{Background: []byte("true")}
+	if _, apiErr := validateBackgroundResponsesRequest(request); apiErr == nil {
+		t.Fatal("background request should be rejected when responses store is disabled")
+	}
+}
+
+// serveResponsesTestRequest 以 userId 的身份请求保存的响应接口
+func serveResponsesTestRequest(userId int, method string, path string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("id", userId)
+	})
+	router.GET("/v1/responses/:id", RetrieveResponse)
+	router.DELETE("/v1/responses/:id", DeleteResponse)
+	router.GET("/v1/responses/:id/input_items", ListResponseInputItems)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+	return recorder
+}
+
+func TestStoredResponsesScopedToUser(t *testing.T) {
+	modeltest.SetupDB(t, &model.StoredResponse{})
+	storeSetting := operation_setting.GetResponsesStoreSetting()
+	enabled := storeSetting.Enabled
+	storeSetting.Enabled = true
+	t.Cleanup(func() {
+		storeSetting.Enabled = enabled
+	})
+	if err := model.InsertStoredResponse(&model.StoredResponse{
+		ResponseId: "resp_owner",
+		UserId:     1,
+		Status:     dto.ResponsesStatusCompleted,
+		InputItems: []byte(`[{"id":"msg_1","type":"message","role":"user","content":"hi"}]`),
+		Response:   []byte(`{"id":"resp_owner","object":"response","status":"completed"}`),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/v1/responses/resp_owner"},
+		{http.MethodGet, "/v1/responses/resp_owner/input_items"},
+		{http.MethodDelete, "/v1/responses/resp_owner"},
+	}
+	// 其他用户的请求与响应不存在时相同
+	for _, request := range requests {
+		recorder := serveResponsesTestRequest(2, request.method, request.path)
+		if recorder.Code != http.StatusNotFound || gjson.Get(recorder.Body.String(), "error.code").String() != "response_not_found" {
+			t.Fatalf("%s %s by other user = %d %s", request.method, request.path, recorder.Code, recorder.Body.String())
+		}
+	}
+
+	if recorder := serveResponsesTestRequest(1, http.MethodGet, "/v1/responses/resp_owner"); recorder.Code != http.StatusOK || gjson.Get(recorder.Body.String(), "id").String() != "resp_owner" {
+		t.Fatalf("get = %d %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := serveResponsesTestRequest(1, http.MethodGet, "/v1/responses/resp_owner/input_items"); recorder.Code != http.StatusOK || gjson.Get(recorder.Body.String(), "data.0.id").String() != "msg_1" {
+		t.Fatalf("input items = %d %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := serveResponsesTestRequest(1, http.MethodDelete, "/v1/responses/resp_owner"); recorder.Code != http.StatusOK || !gjson.Get(recorder.Body.String(), "deleted").Bool() {
+		t.Fatalf("delete = %d %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := serveResponsesTestRequest(1, http.MethodGet, "/v1/responses/resp_owner"); recorder.Code != http.StatusNotFound {
+		t.Fatalf("get after delete = %d", recorder.Code)
+	}
+
+	storeSetting.Enabled = false
+	if recorder := serveResponsesTestRequest(1, http.MethodGet, "/v1/responses/resp_owner"); recorder.Code != http.StatusNotImplemented {
+		t.Fatalf("get with store disabled = %d", recorder.Code)
+	}
+}
@@ -5,14 +5,40 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/gin-gonic/gin"
 )
 
+// tokenWithSpendUsage 令牌列表项，附带设置了消费上限的时间窗口的当前用量
+type tokenWithSpendUsage struct {
+	*model.Token
+	SpendUsage []service.SpendWindow `json:"spend_usage,omitempty"`
+}
+
+func withSpendUsage(tokens []*model.Token) []tokenWithSpendUsage {
+	now := time.Now()
+	items := make([]tokenWithSpendUsage, 0, len(tokens))
+	for _, token := range tokens {
+		item := tokenWithSpendUsage{Token: token}
+		if limit := token.GetSpendLimit(); limit.Enabled() {
+			windows, err := service.GetSpendWindows(model.QuotaWindowSubjectToken, token.Id, limit, now)
+			if err != nil {
+				common.SysError(fmt.Sprintf("failed to get token %d spend usage: %s", token.Id, err.Error()))
+			} else {
+				item.SpendUsage = windows
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
 func GetAllTokens(c *gin.Context) {
 	userId := c.GetInt("id")
 	pageInfo := common.GetPageQuery(c)
@@ -23,7 +49,7 @@ func GetAllTokens(c *gin.Context) {
 	}
 	total, _ := model.CountUserTokens(userId)
 	pageInfo.SetTotal(int(total))
-	pageInfo.SetItems(tokens)
+	pageInfo.SetItems(withSpendUsage(tokens))
 	common.ApiSuccess(c, pageInfo)
 	return
 }
@@ -40,7 +66,7 @@ func SearchTokens(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
-		"data":    tokens,
+		"data":    withSpendUsage(tokens),
 	})
 	return
 }
@@ -205,6 +231,14 @@ func AddToken(c *gin.Context) {
 		AllowIps:           token.AllowIps,
 		Group:              token.Group,
 		CrossGroupRetry:    token.CrossGroupRetry,
+		ResponseCache:      token.ResponseCache,
+		ResponseCacheTTL:   token.ResponseCacheTTL,
+		DailyQuotaLimit:    token.DailyQuotaLimit,
+		WeeklyQuotaLimit:   token.WeeklyQuotaLimit,
+		MonthlyQuotaLimit:  token.MonthlyQuotaLimit,
+		QuotaLimitWindow:   token.QuotaLimitWindow,
+		RpmLimit:           token.RpmLimit,
+		TpmLimit:           token.TpmLimit,
 	}
 	err = cleanToken.Insert()
 	if err != nil {
@@ -300,6 +334,14 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.AllowIps = token.AllowIps
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		cleanToken.ResponseCache = token.ResponseCache
+		cleanToken.ResponseCacheTTL = token.ResponseCacheTTL
+		cleanToken.DailyQuotaLimit = token.DailyQuotaLimit
+		cleanToken.WeeklyQuotaLimit = token.WeeklyQuotaLimit
+		cleanToken.MonthlyQuotaLimit = token.MonthlyQuotaLimit
+		cleanToken.QuotaLimitWindow = token.QuotaLimitWindow
+		cleanToken.RpmLimit = token.RpmLimit
+		cleanToken.TpmLimit = token.TpmLimit
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -715,6 +715,9 @@ func UpdateUser(c *gin.Context) {
 	if originUser.Quota != updatedUser.Quota {
 		model.RecordLog(originUser.Id, model.LogTypeManage, fmt.Sprintf("管理员将用户额度从 %s修改为 %s", logger.LogQuota(originUser.Quota), logger.LogQuota(updatedUser.Quota)))
 	}
+	if user, err := model.GetUserById(updatedUser.Id, false); err == nil {
+		service.RecordAudit(c, "update", service.AuditTargetUser, updatedUser.Id, originUser, user)
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -871,6 +874,7 @@ func DeleteUser(c *gin.Context) {
 		})
 		return
 	}
+	service.RecordAudit(c, "delete", service.AuditTargetUser, id, originUser, nil)
 }
 
 func DeleteSelf(c *gin.Context) {
@@ -937,6 +941,7 @@ func CreateUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	service.RecordAudit(c, "create", service.AuditTargetUser, cleanUser.Id, nil, cleanUser)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -983,6 +988,7 @@ func ManageUser(c *gin.Context) {
 		})
 		return
 	}
+	originUser := user
 	switch req.Action {
 	case "disable":
 		if user.Role == common.RoleRootUser {
@@ -1082,6 +1088,11 @@ func ManageUser(c *gin.Context) {
 			return
 		}
 	}
+	if req.Action == "delete" {
+		service.RecordAudit(c, "delete", service.AuditTargetUser, user.Id, originUser, nil)
+	} else {
+		service.RecordAudit(c, req.Action, service.AuditTargetUser, user.Id, originUser, user)
+	}
 	clearUser := model.User{
 		Role:   user.Role,
 		Status: user.Status,
@@ -92,6 +92,9 @@ type ChannelOtherSettings struct {
 	DisableStore          bool          `json:"disable_store,omitempty"`           // 是否禁用 store 透传（默认允许透传，禁用后可能导致 Codex 无法使用）
 	AllowSafetyIdentifier bool          `json:"allow_safety_identifier,omitempty"` // 是否允许 safety_identifier 透传（默认过滤以保护用户隐私）
 	AwsKeyType            AwsKeyType    `json:"aws_key_type,omitempty"`
+	MaxConcurrency        int           `json:"max_concurrency,omitempty"` // 渠道最大并发请求数，0 表示不限制
+	// 流式请求首字超时秒数，0 表示使用全局配置
+	FirstTokenTimeoutSeconds int `json:"first_token_timeout_seconds,omitempty"`
 }
 
 func (s *ChannelOtherSettings) IsOpenRouterEnterprise() bool {
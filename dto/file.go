@@ -0,0 +1,26 @@
+package dto
+
+// OpenAIFile Files API 返回的文件对象
+type OpenAIFile struct {
+	Id        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+	Status    string `json:"status"`
+}
+
+type OpenAIFileList struct {
+	Object  string       `json:"object"`
+	Data    []OpenAIFile `json:"data"`
+	FirstId string       `json:"first_id,omitempty"`
+	LastId  string       `json:"last_id,omitempty"`
+	HasMore bool         `json:"has_more"`
+}
+
+type OpenAIFileDeleted struct {
+	Id      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
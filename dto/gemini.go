@@ -159,6 +159,53 @@ func (r *GeminiChatRequest) SetTools(tools []GeminiChatTool) {
 	r.Tools = data
 }
 
+// GeminiCountTokensRequest countTokens 接口请求，contents 与 generateContentRequest 二选一
+type GeminiCountTokensRequest struct {
+	Contents               []GeminiChatContent `json:"contents,omitempty"`
+	GenerateContentRequest *GeminiChatRequest  `json:"generateContentRequest,omitempty"`
+}
+
+// ChatRequest 返回待统计的完整请求，仅传 contents 时视为只有对话内容的请求
+func (r *GeminiCountTokensRequest) ChatRequest() *GeminiChatRequest {
+	if r.GenerateContentRequest != nil {
+		return r.GenerateContentRequest
+	}
+	return &GeminiChatRequest{Contents: r.Contents}
+}
+
+func (r *GeminiCountTokensRequest) GetTokenCountMeta() *types.TokenCountMeta {
+	request := r.ChatRequest()
+	meta := request.GetTokenCountMeta()
+	// 上游计数包含系统指令与工具定义，本地估算时一并统计
+	var texts []string
+	if request.SystemInstructions != nil {
+		for _, part := range request.SystemInstructions.Parts {
+			if part.Text != "" {
+				texts = append(texts, part.Text)
+			}
+		}
+	}
+	if len(request.Tools) > 0 {
+		texts = append(texts, string(request.Tools))
+	}
+	if len(texts) > 0 {
+		meta.CombineText = strings.Join(append(texts, meta.CombineText), "\n")
+	}
+	return meta
+}
+
+func (r *GeminiCountTokensRequest) IsStream(c *gin.Context) bool {
+	return false
+}
+
+func (r *GeminiCountTokensRequest) SetModelName(modelName string) {
+	// 模型名在请求路径中，请求体不需要修改
+}
+
+type GeminiCountTokensResponse struct {
+	TotalTokens int `json:"totalTokens"`
+}
+
 type GeminiThinkingConfig struct {
 	IncludeThoughts bool `json:"includeThoughts,omitempty"`
 	ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
@@ -367,11 +414,12 @@ type GeminiChatResponse struct {
 }
 
 type GeminiUsageMetadata struct {
-	PromptTokenCount     int                         `json:"promptTokenCount"`
-	CandidatesTokenCount int                         `json:"candidatesTokenCount"`
-	TotalTokenCount      int                         `json:"totalTokenCount"`
-	ThoughtsTokenCount   int                         `json:"thoughtsTokenCount"`
-	PromptTokensDetails  []GeminiPromptTokensDetails `json:"promptTokensDetails"`
+	PromptTokenCount        int                         `json:"promptTokenCount"`
+	CandidatesTokenCount    int                         `json:"candidatesTokenCount"`
+	TotalTokenCount         int                         `json:"totalTokenCount"`
+	ThoughtsTokenCount      int                         `json:"thoughtsTokenCount"`
+	CachedContentTokenCount int                         `json:"cachedContentTokenCount,omitempty"`
+	PromptTokensDetails     []GeminiPromptTokensDetails `json:"promptTokensDetails"`
 }
 
 type GeminiPromptTokensDetails struct {
@@ -817,6 +817,8 @@ type OpenAIResponsesRequest struct {
 	User                 string          `json:"user,omitempty"`
 	MaxToolCalls         uint            `json:"max_tool_calls,omitempty"`
 	Prompt               json.RawMessage `json:"prompt,omitempty"`
+	// 后台模式，请求在网关异步执行，通过 GET /v1/responses/{id} 轮询结果
+	Background json.RawMessage `json:"background,omitempty"`
 }
 
 func (r *OpenAIResponsesRequest) GetTokenCountMeta() *types.TokenCountMeta {
@@ -882,6 +884,10 @@ func (r *OpenAIResponsesRequest) IsStream(c *gin.Context) bool {
 	return r.Stream
 }
 
+func (r *OpenAIResponsesRequest) IsBackground() bool {
+	return strings.TrimSpace(string(r.Background)) == "true"
+}
+
 func (r *OpenAIResponsesRequest) SetModelName(modelName string) {
 	if modelName != "" {
 		r.Model = modelName
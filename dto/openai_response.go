@@ -263,6 +263,14 @@ type OutputTokenDetails struct {
 	ReasoningTokens int `json:"reasoning_tokens"`
 }
 
+const (
+	ResponsesStatusQueued     = "queued"
+	ResponsesStatusInProgress = "in_progress"
+	ResponsesStatusCompleted  = "completed"
+	ResponsesStatusFailed     = "failed"
+	ResponsesStatusCancelled  = "cancelled"
+)
+
 type OpenAIResponsesResponse struct {
 	ID                 string             `json:"id"`
 	Object             string             `json:"object"`
@@ -278,6 +286,7 @@ type OpenAIResponsesResponse struct {
 	PreviousResponseID string             `json:"previous_response_id"`
 	Reasoning          *Reasoning         `json:"reasoning"`
 	Store              bool               `json:"store"`
+	Background         bool               `json:"background,omitempty"`
 	Temperature        float64            `json:"temperature"`
 	ToolChoice         string             `json:"tool_choice"`
 	Tools              []map[string]any   `json:"tools"`
@@ -330,20 +339,21 @@ func (o *OpenAIResponsesResponse) GetSize() string {
 }
 
 type IncompleteDetails struct {
-	Reasoning string `json:"reasoning"`
+	Reason string `json:"reason"`
 }
 
 type ResponsesOutput struct {
-	Type      string                   `json:"type"`
-	ID        string                   `json:"id"`
-	Status    string                   `json:"status"`
-	Role      string                   `json:"role"`
-	Content   []ResponsesOutputContent `json:"content"`
-	Quality   string                   `json:"quality"`
-	Size      string                   `json:"size"`
-	CallId    string                   `json:"call_id,omitempty"`
-	Name      string                   `json:"name,omitempty"`
-	Arguments string                   `json:"arguments,omitempty"`
+	Type      string                          `json:"type"`
+	ID        string                          `json:"id"`
+	Status    string                          `json:"status,omitempty"`
+	Role      string                          `json:"role,omitempty"`
+	Content   []ResponsesOutputContent        `json:"content,omitempty"`
+	Summary   []ResponsesReasoningSummaryPart `json:"summary,omitempty"`
+	Quality   string                          `json:"quality,omitempty"`
+	Size      string                          `json:"size,omitempty"`
+	CallId    string                          `json:"call_id,omitempty"`
+	Name      string                          `json:"name,omitempty"`
+	Arguments string                          `json:"arguments,omitempty"`
 }
 
 type ResponsesOutputContent struct {
@@ -377,6 +387,9 @@ type ResponsesStreamResponse struct {
 	Response *OpenAIResponsesResponse `json:"response,omitempty"`
 	Delta    string                   `json:"delta,omitempty"`
 	Item     *ResponsesOutput         `json:"item,omitempty"`
+	// output_text.done、function_call_arguments.done 等事件的完整内容
+	Text      string `json:"text,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
 	// Fields for function_call_arguments and reasoning_summary events
 	OutputIndex  *int                           `json:"output_index,omitempty"`
 	ContentIndex *int                           `json:"content_index,omitempty"`
@@ -426,3 +439,18 @@ func GetOpenAIError(errorField any) *types.OpenAIError {
 		}
 	}
 }
+
+// ResponsesInputItemList GET /v1/responses/{id}/input_items 的响应
+type ResponsesInputItemList struct {
+	Object  string           `json:"object"`
+	Data    []map[string]any `json:"data"`
+	FirstId string           `json:"first_id,omitempty"`
+	LastId  string           `json:"last_id,omitempty"`
+	HasMore bool             `json:"has_more"`
+}
+
+type ResponsesDeleted struct {
+	Id      string `json:"id"`
+	Object  string `json:"object"`
+	Deleted bool   `json:"deleted"`
+}
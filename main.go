@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"embed"
 	"fmt"
 	"log"
@@ -17,6 +18,7 @@ import (
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/router"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
@@ -102,6 +104,8 @@ func main() {
 
 	go controller.AutomaticallyTestChannels()
 
+	go controller.AutomaticallyProbeCircuitBreakers()
+
 	if common.IsMasterNode && constant.UpdateTask {
 		gopool.Go(func() {
 			controller.UpdateMidjourneyTaskBulk()
@@ -110,6 +114,16 @@ func main() {
 			controller.UpdateTaskBulk()
 		})
 	}
+	if common.IsMasterNode {
+		// 清理过期的时间窗口消费记录
+		go service.StartQuotaWindowCleanup()
+		// 按保留策略汇总、归档并清理过期日志
+		go service.StartLogRetention()
+		// 清理过期的 Responses 响应
+		go service.StartResponsesStoreCleanup()
+		// 将执行节点已退出的后台响应标记为失败并退还额度
+		go service.StartBackgroundResponsesWatchdog()
+	}
 	if os.Getenv("BATCH_UPDATE_ENABLED") == "true" {
 		common.BatchUpdateEnabled = true
 		common.SysLog("batch update enabled with interval " + strconv.Itoa(common.BatchUpdateInterval) + "s")
@@ -133,6 +147,16 @@ func main() {
 		common.SysError(fmt.Sprintf("start pyroscope error : %v", err))
 	}
 
+	shutdownTracing, err := tracing.Start(context.Background(), tracing.Config{
+		Exporter:    os.Getenv("OTEL_TRACES_EXPORTER"),
+		File:        os.Getenv("OTEL_TRACES_FILE"),
+		ServiceName: common.GetEnvOrDefaultString("OTEL_SERVICE_NAME", "new-api"),
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("start tracing error : %v", err))
+	}
+	defer shutdownTracing(context.Background())
+
 	// Initialize HTTP server
 	server := gin.New()
 	server.Use(gin.CustomRecovery(func(c *gin.Context, err any) {
@@ -147,6 +171,7 @@ func main() {
 	// This will cause SSE not to work!!!
 	//server.Use(gzip.Gzip(gzip.DefaultCompression))
 	server.Use(middleware.RequestId())
+	server.Use(middleware.Tracing())
 	middleware.SetUpLogger(server)
 	// Initialize session store
 	store := cookie.NewStore([]byte(common.SessionSecret))
@@ -164,6 +189,10 @@ func main() {
 
 	// 设置路由
 	router.SetRouter(server, buildFS, indexPage)
+	if common.IsMasterNode {
+		// 批处理任务的每行请求通过路由执行
+		service.StartBatchExecutor(server)
+	}
 	var port = os.Getenv("PORT")
 	if port == "" {
 		port = strconv.Itoa(*common.Port)
@@ -11,6 +11,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 
@@ -244,6 +245,9 @@ func WssAuth(c *gin.Context) {
 
 func TokenAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		_, span := tracing.StartSpan(c.Request.Context(), "auth")
+		// 中途返回时结束 span，通过鉴权时在调用后续处理前结束
+		defer span.End()
 		// 先检测是否为ws
 		if c.Request.Header.Get("Sec-WebSocket-Protocol") != "" {
 			// Sec-WebSocket-Protocol: realtime, openai-insecure-api-key.sk-xxx, openai-beta.realtime-v1
@@ -366,6 +370,8 @@ func TokenAuth() func(c *gin.Context) {
 		if err != nil {
 			return
 		}
+		span.SetAttributes(tracing.AttrTokenId.Int(token.Id), tracing.AttrUserId.Int(token.UserId))
+		span.End()
 		c.Next()
 	}
 }
@@ -390,6 +396,11 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	}
 	common.SetContextKey(c, constant.ContextKeyTokenGroup, token.Group)
 	common.SetContextKey(c, constant.ContextKeyTokenCrossGroupRetry, token.CrossGroupRetry)
+	common.SetContextKey(c, constant.ContextKeyTokenResponseCache, token.ResponseCache)
+	common.SetContextKey(c, constant.ContextKeyTokenResponseCacheTTL, token.ResponseCacheTTL)
+	common.SetContextKey(c, constant.ContextKeyTokenSpendLimit, token.GetSpendLimit())
+	common.SetContextKey(c, constant.ContextKeyTokenRpmLimit, token.RpmLimit)
+	common.SetContextKey(c, constant.ContextKeyTokenTpmLimit, token.TpmLimit)
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
@@ -13,6 +13,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
@@ -28,6 +29,9 @@ type ModelRequest struct {
 
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		_, span := tracing.StartSpan(c.Request.Context(), "distribute")
+		// 中途返回时结束 span，选定渠道后在调用后续处理前结束
+		defer span.End()
 		var channel *model.Channel
 		channelId, ok := common.GetContextKey(c, constant.ContextKeyTokenSpecificChannelId)
 		modelRequest, shouldSelectChannel, err := getModelRequest(c)
@@ -145,7 +149,20 @@ func Distribute() func(c *gin.Context) {
 			}
 		}
 		common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now())
-		SetupContextForSelectedChannel(c, channel, modelRequest.Model)
+		newAPIError := service.SetupContextForSelectedChannel(c, channel, modelRequest.Model)
+		if !ok && shouldSelectChannel {
+			channel, newAPIError = reselectChannelWithAvailableKey(c, channel, modelRequest.Model, newAPIError)
+		}
+		if newAPIError != nil && !types.IsChannelError(newAPIError) {
+			// key 冷却或达到 RPM/TPM 上限等临时状态，直接返回，避免使用空 key 请求上游
+			abortWithOpenAiMessage(c, newAPIError.StatusCode, newAPIError.Error(), string(newAPIError.GetErrorCode()))
+			return
+		}
+		if channel != nil {
+			span.SetAttributes(tracing.AttrChannelId.Int(channel.Id))
+		}
+		span.SetAttributes(tracing.AttrModel.String(modelRequest.Model), tracing.AttrGroup.String(common.GetContextKeyString(c, constant.ContextKeyUsingGroup)))
+		span.End()
 		c.Next()
 		// Channel Affinity: record binding after successful response
 		if channel != nil && c.Writer != nil && c.Writer.Status() < http.StatusBadRequest {
@@ -154,6 +171,36 @@ func Distribute() func(c *gin.Context) {
 	}
 }
 
+// 选中渠道的 key 暂时不可用时最多换几次渠道
+const maxKeyUnavailableReselects = 3
+
+// reselectChannelWithAvailableKey 选中渠道的所有 key 都在冷却或达到 RPM/TPM 上限时，排除该渠道重新选择。
+// 没有其他可用渠道时返回原渠道和错误
+func reselectChannelWithAvailableKey(c *gin.Context, channel *model.Channel, modelName string, newAPIError *types.NewAPIError) (*model.Channel, *types.NewAPIError) {
+	var excludeIds []int
+	for newAPIError != nil && len(excludeIds) < maxKeyUnavailableReselects {
+		switch newAPIError.GetErrorCode() {
+		case types.ErrorCodeKeyRateLimited, types.ErrorCodeUpstreamCoolingDown:
+		default:
+			return channel, newAPIError
+		}
+		excludeIds = append(excludeIds, channel.Id)
+		next, _, err := service.CacheGetRandomSatisfiedChannel(&service.RetryParam{
+			Ctx:               c,
+			ModelName:         modelName,
+			TokenGroup:        common.GetContextKeyString(c, constant.ContextKeyUsingGroup),
+			Retry:             common.GetPointer(0),
+			ExcludeChannelIds: excludeIds,
+		})
+		if err != nil || next == nil {
+			return channel, newAPIError
+		}
+		channel = next
+		newAPIError = service.SetupContextForSelectedChannel(c, channel, modelName)
+	}
+	return channel, newAPIError
+}
+
 // getModelFromRequest 从请求中读取模型信息
 // 根据 Content-Type 自动处理：
 // - application/json
@@ -328,65 +375,6 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	return &modelRequest, shouldSelectChannel, nil
 }
 
-func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, modelName string) *types.NewAPIError {
-	c.Set("original_model", modelName) // for retry
-	if channel == nil {
-		return types.NewError(errors.New("channel is nil"), types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
-	}
-	common.SetContextKey(c, constant.ContextKeyChannelId, channel.Id)
-	common.SetContextKey(c, constant.ContextKeyChannelName, channel.Name)
-	common.SetContextKey(c, constant.ContextKeyChannelType, channel.Type)
-	common.SetContextKey(c, constant.ContextKeyChannelCreateTime, channel.CreatedTime)
-	common.SetContextKey(c, constant.ContextKeyChannelSetting, channel.GetSetting())
-	common.SetContextKey(c, constant.ContextKeyChannelOtherSetting, channel.GetOtherSettings())
-	common.SetContextKey(c, constant.ContextKeyChannelParamOverride, channel.GetParamOverride())
-	common.SetContextKey(c, constant.ContextKeyChannelHeaderOverride, channel.GetHeaderOverride())
-	if nil != channel.OpenAIOrganization && *channel.OpenAIOrganization != "" {
-		common.SetContextKey(c, constant.ContextKeyChannelOrganization, *channel.OpenAIOrganization)
-	}
-	common.SetContextKey(c, constant.ContextKeyChannelAutoBan, channel.GetAutoBan())
-	common.SetContextKey(c, constant.ContextKeyChannelModelMapping, channel.GetModelMapping())
-	common.SetContextKey(c, constant.ContextKeyChannelStatusCodeMapping, channel.GetStatusCodeMapping())
-
-	key, index, newAPIError := channel.GetNextEnabledKey()
-	if newAPIError != nil {
-		return newAPIError
-	}
-	if channel.ChannelInfo.IsMultiKey {
-		common.SetContextKey(c, constant.ContextKeyChannelIsMultiKey, true)
-		common.SetContextKey(c, constant.ContextKeyChannelMultiKeyIndex, index)
-	} else {
-		// 必须设置为 false，否则在重试到单个 key 的时候会导致日志显示错误
-		common.SetContextKey(c, constant.ContextKeyChannelIsMultiKey, false)
-	}
-	// c.Request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", key))
-	common.SetContextKey(c, constant.ContextKeyChannelKey, key)
-	common.SetContextKey(c, constant.ContextKeyChannelBaseUrl, channel.GetBaseURL())
-
-	common.SetContextKey(c, constant.ContextKeySystemPromptOverride, false)
-
-	// TODO: api_version统一
-	switch channel.Type {
-	case constant.ChannelTypeAzure:
-		c.Set("api_version", channel.Other)
-	case constant.ChannelTypeVertexAi:
-		c.Set("region", channel.Other)
-	case constant.ChannelTypeXunfei:
-		c.Set("api_version", channel.Other)
-	case constant.ChannelTypeGemini:
-		c.Set("api_version", channel.Other)
-	case constant.ChannelTypeAli:
-		c.Set("plugin", channel.Other)
-	case constant.ChannelCloudflare:
-		c.Set("api_version", channel.Other)
-	case constant.ChannelTypeMokaAI:
-		c.Set("api_version", channel.Other)
-	case constant.ChannelTypeCoze:
-		c.Set("bot_id", channel.Other)
-	}
-	return nil
-}
-
 // extractModelNameFromGeminiPath 从 Gemini API URL 路径中提取模型名
 // 输入格式: /v1beta/models/gemini-2.0-flash:generateContent
 // 输出: gemini-2.0-flash
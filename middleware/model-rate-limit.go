@@ -10,6 +10,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/common/limiter"
 	"github.com/QuantumNous/new-api/constant"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/setting"
 
 	"github.com/gin-gonic/gin"
@@ -166,7 +167,8 @@ func memoryRateLimitHandler(duration int64, totalMaxCount, successMaxCount int)
 func ModelRequestRateLimit() func(c *gin.Context) {
 	return func(c *gin.Context) {
 		// 在每个请求时检查是否启用限流
-		if !setting.ModelRequestRateLimitEnabled {
+		// 计数请求免费，不占用请求次数
+		if !setting.ModelRequestRateLimitEnabled || relayconstant.IsCountTokensPath(c.Request.URL.Path) {
 			c.Next()
 			return
 		}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TokenRateLimit 令牌每分钟请求数限流中间件，每分钟 token 数在预估请求 token 后检查
+func TokenRateLimit() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		// 计数请求免费，不占用请求次数
+		if relayconstant.IsCountTokensPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		if apiErr := service.TakeTokenRequest(c); apiErr != nil {
+			abortWithOpenAiMessage(c, apiErr.StatusCode, apiErr.Error(), string(apiErr.GetErrorCode()))
+			return
+		}
+		c.Next()
+	}
+}
@@ -3,10 +3,12 @@ package model
 import (
 	"errors"
 	"fmt"
+	"math/rand"
 	"strings"
 	"sync"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/samber/lo"
 	"gorm.io/gorm"
@@ -103,7 +105,7 @@ func getChannelQuery(group string, model string, retry int) (*gorm.DB, error) {
 	return channelQuery, nil
 }
 
-func GetChannel(group string, model string, retry int) (*Channel, error) {
+func GetChannel(group string, model string, retry int, excludeIds ...int) (*Channel, error) {
 	var abilities []Ability
 
 	var err error = nil
@@ -119,8 +121,37 @@ func GetChannel(group string, model string, retry int) (*Channel, error) {
 	if err != nil {
 		return nil, err
 	}
-	channel := Channel{}
 	if len(abilities) > 0 {
+		channelIds := make([]int, len(abilities))
+		for i, ability_ := range abilities {
+			channelIds[i] = ability_.ChannelId
+		}
+		allowed := filterExcludedChannels(filterSaturatedChannels(filterCoolingDownChannels(filterCircuitAllowedChannels(channelIds, model))), excludeIds)
+		abilities = lo.Filter(abilities, func(ability_ Ability, _ int) bool {
+			return lo.Contains(allowed, ability_.ChannelId)
+		})
+	}
+	channel := Channel{}
+	if len(abilities) > 0 && operation_setting.GetChannelSelectMode(group) == operation_setting.ChannelSelectModeAdaptive {
+		channelIds := make([]int, len(abilities))
+		for i, ability_ := range abilities {
+			channelIds[i] = ability_.ChannelId
+		}
+		factors := getChannelHealthFactors(channelIds, model)
+		weightSum := 0.0
+		for i, ability_ := range abilities {
+			weightSum += float64(ability_.Weight+10) * factors[i]
+		}
+		weight := rand.Float64() * weightSum
+		channel.Id = abilities[len(abilities)-1].ChannelId
+		for i, ability_ := range abilities {
+			weight -= float64(ability_.Weight+10) * factors[i]
+			if weight < 0 {
+				channel.Id = ability_.ChannelId
+				break
+			}
+		}
+	} else if len(abilities) > 0 {
 		// Randomly choose one
 		weightSum := uint(0)
 		for _, ability_ := range abilities {
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// AuditLog 管理操作审计记录，只追加不修改
+type AuditLog struct {
+	Id         int    `json:"id"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+	ActorId    int    `json:"actor_id" gorm:"index"`
+	ActorName  string `json:"actor_name" gorm:"type:varchar(64)"`
+	ActorRole  int    `json:"actor_role"`
+	Ip         string `json:"ip" gorm:"type:varchar(64)"`
+	Action     string `json:"action" gorm:"type:varchar(64);index"`
+	TargetType string `json:"target_type" gorm:"type:varchar(32);index:idx_audit_target"`
+	TargetId   string `json:"target_id" gorm:"type:varchar(128);index:idx_audit_target"`
+	Before     string `json:"before" gorm:"type:text"`
+	After      string `json:"after" gorm:"type:text"`
+	// 变化的字段，格式为 {"字段": {"before": 旧值, "after": 新值}}
+	Diff string `json:"diff" gorm:"type:text"`
+}
+
+type AuditLogQuery struct {
+	ActorId        int
+	Action         string
+	TargetType     string
+	TargetId       string
+	Keyword        string
+	StartTimestamp int64
+	EndTimestamp   int64
+}
+
+func (log *AuditLog) Insert() error {
+	return DB.Create(log).Error
+}
+
+// SearchAuditLogs 按条件分页查询审计记录，按时间倒序
+func SearchAuditLogs(query AuditLogQuery, pageInfo *common.PageInfo) ([]*AuditLog, int64, error) {
+	tx := DB.Model(&AuditLog{})
+	if query.ActorId != 0 {
+		tx = tx.Where("actor_id = ?", query.ActorId)
+	}
+	if query.Action != "" {
+		tx = tx.Where("action = ?", query.Action)
+	}
+	if query.TargetType != "" {
+		tx = tx.Where("target_type = ?", query.TargetType)
+	}
+	if query.TargetId != "" {
+		tx = tx.Where("target_id = ?", query.TargetId)
+	}
+	if query.StartTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", query.StartTimestamp)
+	}
+	if query.EndTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", query.EndTimestamp)
+	}
+	if keyword := strings.TrimSpace(query.Keyword); keyword != "" {
+		like := "%" + keyword + "%"
+		tx = tx.Where("actor_name LIKE ? OR target_id LIKE ? OR diff LIKE ?", like, like, like)
+	}
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var logs []*AuditLog
+	err := tx.Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Find(&logs).Error
+	return logs, total, err
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+// Batch Batch API 的批处理任务，由主节点在后台逐行执行
+type Batch struct {
+	Id               int    `json:"-" gorm:"primaryKey;autoIncrement"`
+	BatchId          string `json:"id" gorm:"type:varchar(64);uniqueIndex;not null"`
+	UserId           int    `json:"-" gorm:"index;not null"`
+	TokenId          int    `json:"-" gorm:"not null"`
+	ClientIp         string `json:"-" gorm:"type:varchar(64)"`
+	Endpoint         string `json:"endpoint" gorm:"type:varchar(64)"`
+	InputFileId      string `json:"input_file_id" gorm:"type:varchar(64)"`
+	OutputFileId     string `json:"output_file_id" gorm:"type:varchar(64)"`
+	ErrorFileId      string `json:"error_file_id" gorm:"type:varchar(64)"`
+	CompletionWindow string `json:"completion_window" gorm:"type:varchar(16)"`
+	Status           string `json:"status" gorm:"type:varchar(16);index"`
+	// JSON 格式的 metadata 和校验错误
+	Metadata         string `json:"-" gorm:"type:text"`
+	Errors           string `json:"-" gorm:"type:text"`
+	RequestTotal     int    `json:"-"`
+	RequestCompleted int    `json:"-"`
+	RequestFailed    int    `json:"-"`
+	CreatedAt        int64  `json:"created_at" gorm:"bigint"`
+	InProgressAt     int64  `json:"-" gorm:"bigint"`
+	ExpiresAt        int64  `json:"-" gorm:"bigint"`
+	FinalizingAt     int64  `json:"-" gorm:"bigint"`
+	CompletedAt      int64  `json:"-" gorm:"bigint"`
+	FailedAt         int64  `json:"-" gorm:"bigint"`
+	ExpiredAt        int64  `json:"-" gorm:"bigint"`
+	CancellingAt     int64  `json:"-" gorm:"bigint"`
+	CancelledAt      int64  `json:"-" gorm:"bigint"`
+}
+
+const (
+	BatchStatusValidating = "validating"
+	BatchStatusFailed     = "failed"
+	BatchStatusInProgress = "in_progress"
+	BatchStatusFinalizing = "finalizing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusExpired    = "expired"
+	BatchStatusCancelling = "cancelling"
+	BatchStatusCancelled  = "cancelled"
+)
+
+func (Batch) TableName() string {
+	return "batches"
+}
+
+func InsertBatch(batch *Batch) error {
+	if batch.CreatedAt == 0 {
+		batch.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(batch).Error
+}
+
+// GetUserBatch 获取用户的批处理任务，不存在或不属于该用户时返回 nil
+func GetUserBatch(userId int, batchId string) (*Batch, error) {
+	var batch Batch
+	err := DB.Where("batch_id = ? AND user_id = ?", batchId, userId).First(&batch).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// ListUserBatches 按创建时间倒序分页获取用户的批处理任务，afterBatchId 为上一页最后一个任务的 id
+func ListUserBatches(userId int, afterBatchId string, limit int) ([]*Batch, error) {
+	query := DB.Where("user_id = ?", userId)
+	if afterBatchId != "" {
+		after, err := GetUserBatch(userId, afterBatchId)
+		if err != nil {
+			return nil, err
+		}
+		if after != nil {
+			query = query.Where("id < ?", after.Id)
+		}
+	}
+	var batches []*Batch
+	err := query.Order("id desc").Limit(limit).Find(&batches).Error
+	return batches, err
+}
+
+// GetPendingBatches 获取等待执行的批处理任务，excludeIds 为正在执行的任务
+func GetPendingBatches(limit int, excludeIds []int) ([]*Batch, error) {
+	query := DB.Where("status = ?", BatchStatusValidating)
+	if len(excludeIds) > 0 {
+		query = query.Where("id NOT IN ?", excludeIds)
+	}
+	var batches []*Batch
+	err := query.Order("id asc").Limit(limit).Find(&batches).Error
+	return batches, err
+}
+
+// GetBatchStatus 获取批处理任务的最新状态
+func GetBatchStatus(id int) (string, error) {
+	var batch Batch
+	err := DB.Select("status").Where("id = ?", id).First(&batch).Error
+	return batch.Status, err
+}
+
+// UpdateBatchFields 更新批处理任务，fromStatus 不为空时只在任务处于该状态时更新，返回是否更新成功
+func UpdateBatchFields(id int, fromStatus string, fields map[string]interface{}) (bool, error) {
+	query := DB.Model(&Batch{}).Where("id = ?", id)
+	if fromStatus != "" {
+		query = query.Where("status = ?", fromStatus)
+	}
+	result := query.Updates(fields)
+	return result.RowsAffected > 0, result.Error
+}
+
+// CancelBatch 取消批处理任务：尚未开始执行的任务直接取消，执行中的任务标记为取消中，由执行器结束
+func CancelBatch(id int) error {
+	now := common.GetTimestamp()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Batch{}).Where("id = ? AND status = ?", id, BatchStatusValidating).
+			Updates(map[string]interface{}{"status": BatchStatusCancelled, "cancelling_at": now, "cancelled_at": now}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&Batch{}).Where("id = ? AND status = ?", id, BatchStatusInProgress).
+			Updates(map[string]interface{}{"status": BatchStatusCancelling, "cancelling_at": now}).Error
+	})
+}
+
+// FailInterruptedBatches 把服务重启前未执行完的批处理任务标记为失败，返回受影响的任务数
+func FailInterruptedBatches(errorsJSON string) (int64, error) {
+	result := DB.Model(&Batch{}).
+		Where("status IN ?", []string{BatchStatusInProgress, BatchStatusFinalizing, BatchStatusCancelling}).
+		Updates(map[string]interface{}{
+			"status":    BatchStatusFailed,
+			"failed_at": common.GetTimestamp(),
+			"errors":    errorsJSON,
+		})
+	return result.RowsAffected, result.Error
+}
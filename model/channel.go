@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"net/http"
 	"strings"
 	"sync"
 
@@ -47,6 +48,7 @@ type Channel struct {
 	Setting           *string `json:"setting" gorm:"type:text"` // 渠道额外设置
 	ParamOverride     *string `json:"param_override" gorm:"type:text"`
 	HeaderOverride    *string `json:"header_override" gorm:"type:text"`
+	ResponseOverride  *string `json:"response_override" gorm:"type:text"`
 	Remark            *string `json:"remark" gorm:"type:varchar(255)" validate:"max=255"`
 	// add after v0.8.5
 	ChannelInfo ChannelInfo `json:"channel_info" gorm:"type:json"`
@@ -65,6 +67,14 @@ type ChannelInfo struct {
 	MultiKeyDisabledTime   map[int]int64         `json:"multi_key_disabled_time,omitempty"`   // key禁用时间列表，key index -> time
 	MultiKeyPollingIndex   int                   `json:"multi_key_polling_index"`             // 多Key模式下轮询的key索引
 	MultiKeyMode           constant.MultiKeyMode `json:"multi_key_mode"`
+	MultiKeyLimits         map[int]MultiKeyLimit `json:"multi_key_limits,omitempty"` // key限流配置，key index -> limit
+}
+
+// MultiKeyLimit 多Key模式下单个key的限流上限，0 表示不限制
+type MultiKeyLimit struct {
+	RPM            int `json:"rpm"`
+	TPM            int `json:"tpm"`
+	MaxConcurrency int `json:"max_concurrency"`
 }
 
 // Value implements driver.Valuer interface
@@ -103,6 +113,16 @@ func (channel *Channel) GetKeys() []string {
 }
 
 func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
+	return channel.getNextEnabledKey(true)
+}
+
+// GetNextEnabledKeyWithoutUsage selects a key the same way as GetNextEnabledKey but does not
+// count the request against the key's RPM, for free requests such as count_tokens.
+func (channel *Channel) GetNextEnabledKeyWithoutUsage() (string, int, *types.NewAPIError) {
+	return channel.getNextEnabledKey(false)
+}
+
+func (channel *Channel) getNextEnabledKey(trackUsage bool) (string, int, *types.NewAPIError) {
 	// If not in multi-key mode, return the original key string directly.
 	if !channel.ChannelInfo.IsMultiKey {
 		return channel.Key, 0, nil
@@ -145,17 +165,51 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 		return "", 0, types.NewError(errors.New("no enabled keys"), types.ErrorCodeChannelNoAvailableKey)
 	}
 
+	// Skip keys that are cooling down after an upstream 429. This is temporary, so it must
+	// not be reported as a channel error (which would trigger auto-disable).
+	enabledIdx = filterCoolingDownKeys(channel.Id, enabledIdx)
+	if len(enabledIdx) == 0 {
+		return "", 0, types.NewErrorWithStatusCode(errors.New("all keys are cooling down"), types.ErrorCodeUpstreamCoolingDown, http.StatusTooManyRequests)
+	}
+
+	// Skip keys that have reached their own RPM/TPM ceiling. When every key is saturated the
+	// whole channel cools down until the earliest key recovers, so selection moves elsewhere.
+	enabledIdx, recoverAt := filterChannelKeysByLimit(channel.Id, enabledIdx, channel.ChannelInfo.MultiKeyLimits)
+	if len(enabledIdx) == 0 {
+		applyWholeChannelCooldown(channel.Id, recoverAt*1000, "all keys reached rpm/tpm limit")
+		return "", 0, types.NewErrorWithStatusCode(errors.New("all keys reached rpm/tpm limit"), types.ErrorCodeKeyRateLimited, http.StatusTooManyRequests)
+	}
+	// Prefer keys whose concurrency slots are not full; when all are full keep them all and let
+	// the caller queue for a slot.
+	enabledIdx = filterSaturatedKeys(channel.Id, enabledIdx)
+	availableIdx := make(map[int]struct{}, len(enabledIdx))
+	for _, idx := range enabledIdx {
+		availableIdx[idx] = struct{}{}
+	}
+
+	selectedIdx, err := channel.selectKeyIndex(len(keys), enabledIdx, availableIdx)
+	if err != nil {
+		return "", 0, err
+	}
+	if trackUsage {
+		markChannelKeyUsed(channel.Id, selectedIdx)
+	}
+	return keys[selectedIdx], selectedIdx, nil
+}
+
+// selectKeyIndex picks one of the enabled keys according to the multi-key mode.
+// The caller must hold the channel polling lock.
+func (channel *Channel) selectKeyIndex(keyCount int, enabledIdx []int, availableIdx map[int]struct{}) (int, *types.NewAPIError) {
 	switch channel.ChannelInfo.MultiKeyMode {
 	case constant.MultiKeyModeRandom:
 		// Randomly pick one enabled key
-		selectedIdx := enabledIdx[rand.Intn(len(enabledIdx))]
-		return keys[selectedIdx], selectedIdx, nil
+		return enabledIdx[rand.Intn(len(enabledIdx))], nil
 	case constant.MultiKeyModePolling:
 		// Use channel-specific lock to ensure thread-safe polling
 
 		channelInfo, err := CacheGetChannelInfo(channel.Id)
 		if err != nil {
-			return "", 0, types.NewError(err, types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
+			return 0, types.NewError(err, types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
 		}
 		//println("before polling index:", channel.ChannelInfo.MultiKeyPollingIndex)
 		defer func() {
@@ -170,25 +224,46 @@ func (channel *Channel) GetNextEnabledKey() (string, int, *types.NewAPIError) {
 		}()
 		// Start from the saved polling index and look for the next enabled key
 		start := channelInfo.MultiKeyPollingIndex
-		if start < 0 || start >= len(keys) {
+		if start < 0 || start >= keyCount {
 			start = 0
 		}
-		for i := 0; i < len(keys); i++ {
-			idx := (start + i) % len(keys)
-			if getStatus(idx) == common.ChannelStatusEnabled {
+		for i := 0; i < keyCount; i++ {
+			idx := (start + i) % keyCount
+			if _, ok := availableIdx[idx]; ok {
 				// update polling index for next call (point to the next position)
-				channel.ChannelInfo.MultiKeyPollingIndex = (idx + 1) % len(keys)
-				return keys[idx], idx, nil
+				channel.ChannelInfo.MultiKeyPollingIndex = (idx + 1) % keyCount
+				return idx, nil
 			}
 		}
 		// Fallback – should not happen, but return first enabled key
-		return keys[enabledIdx[0]], enabledIdx[0], nil
+		return enabledIdx[0], nil
+	case constant.MultiKeyModeLeastRecentlyUsed, constant.MultiKeyModeLeastErrors, constant.MultiKeyModeQuotaAware:
+		return pickChannelKeyByUsage(channel.Id, channel.ChannelInfo.MultiKeyMode, enabledIdx), nil
 	default:
 		// Unknown mode, default to first enabled key (or original key string)
-		return keys[enabledIdx[0]], enabledIdx[0], nil
+		return enabledIdx[0], nil
 	}
 }
 
+// getEnabledKeyIndexes returns indexes of keys that are not disabled.
+func (channel *Channel) getEnabledKeyIndexes() []int {
+	if !channel.ChannelInfo.IsMultiKey {
+		return nil
+	}
+	keys := channel.GetKeys()
+	lock := GetChannelPollingLock(channel.Id)
+	lock.Lock()
+	defer lock.Unlock()
+	enabledIdx := make([]int, 0, len(keys))
+	for i := range keys {
+		if status, ok := channel.ChannelInfo.MultiKeyStatusList[i]; ok && status != common.ChannelStatusEnabled {
+			continue
+		}
+		enabledIdx = append(enabledIdx, i)
+	}
+	return enabledIdx
+}
+
 func (channel *Channel) SaveChannelInfo() error {
 	return DB.Model(channel).Update("channel_info", channel.ChannelInfo).Error
 }
@@ -697,7 +772,7 @@ func DisableChannelByTag(tag string) error {
 	return err
 }
 
-func EditChannelByTag(tag string, newTag *string, modelMapping *string, models *string, group *string, priority *int64, weight *uint, paramOverride *string, headerOverride *string) error {
+func EditChannelByTag(tag string, newTag *string, modelMapping *string, models *string, group *string, priority *int64, weight *uint, paramOverride *string, headerOverride *string, responseOverride *string) error {
 	updateData := Channel{}
 	shouldReCreateAbilities := false
 	updatedTag := tag
@@ -729,6 +804,9 @@ func EditChannelByTag(tag string, newTag *string, modelMapping *string, models *
 	if headerOverride != nil {
 		updateData.HeaderOverride = headerOverride
 	}
+	if responseOverride != nil {
+		updateData.ResponseOverride = responseOverride
+	}
 
 	err := DB.Model(&Channel{}).Where("tag = ?", tag).Updates(updateData).Error
 	if err != nil {
@@ -918,6 +996,17 @@ func (channel *Channel) GetHeaderOverride() map[string]interface{} {
 	return headerOverride
 }
 
+func (channel *Channel) GetResponseOverride() map[string]interface{} {
+	responseOverride := make(map[string]interface{})
+	if channel.ResponseOverride != nil && *channel.ResponseOverride != "" {
+		err := common.Unmarshal([]byte(*channel.ResponseOverride), &responseOverride)
+		if err != nil {
+			common.SysLog(fmt.Sprintf("failed to unmarshal response override: channel_id=%d, error=%v", channel.Id, err))
+		}
+	}
+	return responseOverride
+}
+
 func GetChannelsByIds(ids []int) ([]*Channel, error) {
 	var channels []*Channel
 	err := DB.Where("id in (?)", ids).Find(&channels).Error
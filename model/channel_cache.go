@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 )
 
@@ -93,12 +95,24 @@ func SyncChannelCache(frequency int) {
 	}
 }
 
-func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
+// GetRandomSatisfiedChannel excludeIds 为本次请求中不再选择的渠道
+func GetRandomSatisfiedChannel(group string, model string, retry int, excludeIds ...int) (*Channel, error) {
+	var channel *Channel
+	var err error
 	// if memory cache is disabled, get channel directly from database
 	if !common.MemoryCacheEnabled {
-		return GetChannel(group, model, retry)
+		channel, err = GetChannel(group, model, retry, excludeIds...)
+	} else {
+		channel, err = getRandomSatisfiedChannelFromCache(group, model, retry, excludeIds...)
 	}
+	if channel != nil {
+		// 半开熔断器被选中即视为一次探测
+		MarkCircuitBreakerProbe(channel.Id, model)
+	}
+	return channel, err
+}
 
+func getRandomSatisfiedChannelFromCache(group string, model string, retry int, excludeIds ...int) (*Channel, error) {
 	channelSyncLock.RLock()
 	defer channelSyncLock.RUnlock()
 
@@ -111,6 +125,12 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		channels = group2model2channels[group][normalizedModel]
 	}
 
+	// 排除在该模型上处于熔断状态，以及因上游限流处于冷却中的渠道；并发已满的渠道尽量跳过
+	channels = filterCircuitAllowedChannels(channels, model)
+	channels = filterCoolingDownChannels(channels)
+	channels = filterSaturatedChannels(channels)
+	channels = filterExcludedChannels(channels, excludeIds)
+
 	if len(channels) == 0 {
 		return nil, nil
 	}
@@ -173,6 +193,10 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		smoothingFactor = 100
 	}
 
+	if operation_setting.GetChannelSelectMode(group) == operation_setting.ChannelSelectModeAdaptive {
+		return pickChannelByHealth(targetChannels, model, smoothingFactor, smoothingAdjustment), nil
+	}
+
 	// Calculate the total weight of all channels up to endIdx
 	totalWeight := sumWeight * smoothingFactor
 
@@ -190,6 +214,34 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 	return nil, errors.New("channel not found")
 }
 
+// pickChannelByHealth 在静态权重的基础上乘以渠道健康系数后加权随机选择
+func pickChannelByHealth(channels []*Channel, modelName string, smoothingFactor int, smoothingAdjustment int) *Channel {
+	channelIds := make([]int, len(channels))
+	for i, channel := range channels {
+		channelIds[i] = channel.Id
+	}
+	factors := getChannelHealthFactors(channelIds, modelName)
+
+	weights := make([]float64, len(channels))
+	totalWeight := 0.0
+	for i, channel := range channels {
+		weights[i] = float64(channel.GetWeight()*smoothingFactor+smoothingAdjustment) * factors[i]
+		totalWeight += weights[i]
+	}
+	if totalWeight <= 0 {
+		return channels[rand.Intn(len(channels))]
+	}
+
+	randomWeight := rand.Float64() * totalWeight
+	for i, channel := range channels {
+		randomWeight -= weights[i]
+		if randomWeight < 0 {
+			return channel
+		}
+	}
+	return channels[len(channels)-1]
+}
+
 func CacheGetChannel(id int) (*Channel, error) {
 	if !common.MemoryCacheEnabled {
 		return GetChannelById(id, true)
@@ -263,3 +315,17 @@ func CacheUpdateChannel(channel *Channel) {
 	channelsIDM[channel.Id] = channel
 	println("after :", channelsIDM[channel.Id].ChannelInfo.MultiKeyPollingIndex)
 }
+
+// filterExcludedChannels 过滤掉本次请求中不再选择的渠道
+func filterExcludedChannels(channelIds []int, excludeIds []int) []int {
+	if len(excludeIds) == 0 {
+		return channelIds
+	}
+	allowed := make([]int, 0, len(channelIds))
+	for _, channelId := range channelIds {
+		if !slices.Contains(excludeIds, channelId) {
+			allowed = append(allowed, channelId)
+		}
+	}
+	return allowed
+}
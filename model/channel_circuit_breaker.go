@@ -0,0 +1,236 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// 熔断器状态
+const (
+	CircuitStateClosed   = "closed"
+	CircuitStateOpen     = "open"
+	CircuitStateHalfOpen = "half_open"
+)
+
+// ChannelCircuitBreaker 渠道 + 模型（即 ability 行）维度的熔断器，仅保存在当前节点内存中
+type ChannelCircuitBreaker struct {
+	ChannelId           int    `json:"channel_id"`
+	ModelName           string `json:"model_name"`
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	HalfOpenSuccesses   int    `json:"half_open_successes"`
+	TripCount           int    `json:"trip_count"`
+	OpenedAt            int64  `json:"opened_at"`
+	OpenUntil           int64  `json:"open_until"`
+	LastProbeAt         int64  `json:"last_probe_at"`
+	LastError           string `json:"last_error"`
+	UpdatedAt           int64  `json:"updated_at"`
+}
+
+var (
+	circuitBreakers     = make(map[string]*ChannelCircuitBreaker)
+	circuitBreakersLock sync.RWMutex
+)
+
+// currentState 计算当前状态：熔断到期后视为半开
+func (b *ChannelCircuitBreaker) currentState(now int64) string {
+	if b.State == CircuitStateOpen && now >= b.OpenUntil {
+		return CircuitStateHalfOpen
+	}
+	return b.State
+}
+
+func (b *ChannelCircuitBreaker) trip(now int64, reason string) {
+	setting := operation_setting.GetCircuitBreakerSetting()
+	openSeconds := setting.OpenSeconds
+	if openSeconds <= 0 {
+		openSeconds = 60
+	}
+	for i := 0; i < b.TripCount; i++ {
+		openSeconds *= 2
+		if setting.MaxOpenSeconds > 0 && openSeconds >= setting.MaxOpenSeconds {
+			openSeconds = setting.MaxOpenSeconds
+			break
+		}
+	}
+	b.State = CircuitStateOpen
+	b.TripCount++
+	b.HalfOpenSuccesses = 0
+	b.OpenedAt = now
+	b.OpenUntil = now + int64(openSeconds)
+	b.LastError = reason
+	common.SysLog(fmt.Sprintf("circuit breaker opened: channel #%d, model %s, open %ds, reason: %s", b.ChannelId, b.ModelName, openSeconds, reason))
+}
+
+// RecordCircuitBreakerSuccess 记录一次成功请求
+func RecordCircuitBreakerSuccess(channelId int, modelName string) {
+	if !operation_setting.IsCircuitBreakerEnabled() || channelId <= 0 || modelName == "" {
+		return
+	}
+	key := channelScoreKey(channelId, modelName)
+	now := time.Now().Unix()
+
+	circuitBreakersLock.Lock()
+	defer circuitBreakersLock.Unlock()
+	breaker, ok := circuitBreakers[key]
+	if !ok {
+		return
+	}
+	breaker.UpdatedAt = now
+	switch breaker.currentState(now) {
+	case CircuitStateHalfOpen:
+		breaker.State = CircuitStateHalfOpen
+		breaker.HalfOpenSuccesses++
+		if breaker.HalfOpenSuccesses >= operation_setting.GetCircuitBreakerSetting().HalfOpenSuccessThreshold {
+			common.SysLog(fmt.Sprintf("circuit breaker closed: channel #%d, model %s", channelId, modelName))
+			delete(circuitBreakers, key)
+		}
+	case CircuitStateClosed:
+		delete(circuitBreakers, key)
+	}
+}
+
+// RecordCircuitBreakerFailure 记录一次由渠道导致的失败请求
+func RecordCircuitBreakerFailure(channelId int, modelName string, reason string) {
+	if !operation_setting.IsCircuitBreakerEnabled() || channelId <= 0 || modelName == "" {
+		return
+	}
+	key := channelScoreKey(channelId, modelName)
+	now := time.Now().Unix()
+
+	circuitBreakersLock.Lock()
+	defer circuitBreakersLock.Unlock()
+	breaker, ok := circuitBreakers[key]
+	if !ok {
+		breaker = &ChannelCircuitBreaker{ChannelId: channelId, ModelName: modelName, State: CircuitStateClosed}
+		circuitBreakers[key] = breaker
+	}
+	breaker.UpdatedAt = now
+	switch breaker.currentState(now) {
+	case CircuitStateHalfOpen:
+		// 半开探测失败，重新熔断并延长熔断时长
+		breaker.trip(now, reason)
+	case CircuitStateClosed:
+		breaker.ConsecutiveFailures++
+		breaker.LastError = reason
+		threshold := operation_setting.GetCircuitBreakerSetting().FailureThreshold
+		if threshold > 0 && breaker.ConsecutiveFailures >= threshold {
+			breaker.trip(now, reason)
+		}
+	}
+}
+
+func isChannelModelCircuitAllowedLocked(channelId int, modelName string, now int64) bool {
+	breaker, ok := circuitBreakers[channelScoreKey(channelId, modelName)]
+	if !ok {
+		return true
+	}
+	switch breaker.currentState(now) {
+	case CircuitStateOpen:
+		return false
+	case CircuitStateHalfOpen:
+		interval := int64(operation_setting.GetCircuitBreakerSetting().HalfOpenProbeIntervalSeconds)
+		return now-breaker.LastProbeAt >= interval
+	default:
+		return true
+	}
+}
+
+// filterCircuitAllowedChannels 过滤掉熔断中的渠道，返回新切片，不修改入参
+func filterCircuitAllowedChannels(channelIds []int, modelName string) []int {
+	if !operation_setting.IsCircuitBreakerEnabled() {
+		return channelIds
+	}
+	now := time.Now().Unix()
+	circuitBreakersLock.RLock()
+	defer circuitBreakersLock.RUnlock()
+	if len(circuitBreakers) == 0 {
+		return channelIds
+	}
+	allowed := make([]int, 0, len(channelIds))
+	for _, channelId := range channelIds {
+		if isChannelModelCircuitAllowedLocked(channelId, modelName, now) {
+			allowed = append(allowed, channelId)
+		}
+	}
+	return allowed
+}
+
+// MarkCircuitBreakerProbe 渠道被选中或后台探测时调用，半开状态下记录探测时间，限制探测频率
+func MarkCircuitBreakerProbe(channelId int, modelName string) {
+	if !operation_setting.IsCircuitBreakerEnabled() {
+		return
+	}
+	now := time.Now().Unix()
+	circuitBreakersLock.Lock()
+	defer circuitBreakersLock.Unlock()
+	breaker, ok := circuitBreakers[channelScoreKey(channelId, modelName)]
+	if !ok {
+		return
+	}
+	if breaker.currentState(now) == CircuitStateHalfOpen {
+		breaker.State = CircuitStateHalfOpen
+		breaker.LastProbeAt = now
+	}
+}
+
+// GetHalfOpenCircuitBreakers 返回需要探测的半开熔断器，用于后台主动探测
+func GetHalfOpenCircuitBreakers() []ChannelCircuitBreaker {
+	now := time.Now().Unix()
+	interval := int64(operation_setting.GetCircuitBreakerSetting().HalfOpenProbeIntervalSeconds)
+	circuitBreakersLock.RLock()
+	defer circuitBreakersLock.RUnlock()
+	result := make([]ChannelCircuitBreaker, 0)
+	for _, breaker := range circuitBreakers {
+		if breaker.currentState(now) == CircuitStateHalfOpen && now-breaker.LastProbeAt >= interval {
+			result = append(result, *breaker)
+		}
+	}
+	return result
+}
+
+// GetChannelCircuitBreakers 返回熔断器快照（不含已恢复的），channelId <= 0 时返回全部
+func GetChannelCircuitBreakers(channelId int) []ChannelCircuitBreaker {
+	now := time.Now().Unix()
+	circuitBreakersLock.RLock()
+	defer circuitBreakersLock.RUnlock()
+	result := make([]ChannelCircuitBreaker, 0, len(circuitBreakers))
+	for _, breaker := range circuitBreakers {
+		if channelId > 0 && breaker.ChannelId != channelId {
+			continue
+		}
+		snapshot := *breaker
+		snapshot.State = breaker.currentState(now)
+		result = append(result, snapshot)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ChannelId != result[j].ChannelId {
+			return result[i].ChannelId < result[j].ChannelId
+		}
+		return result[i].ModelName < result[j].ModelName
+	})
+	return result
+}
+
+// ResetChannelCircuitBreakers 手动关闭熔断器，modelName 为空时关闭渠道下全部，channelId <= 0 时关闭全部
+func ResetChannelCircuitBreakers(channelId int, modelName string) int {
+	circuitBreakersLock.Lock()
+	defer circuitBreakersLock.Unlock()
+	n := 0
+	for key, breaker := range circuitBreakers {
+		if channelId > 0 && breaker.ChannelId != channelId {
+			continue
+		}
+		if modelName != "" && breaker.ModelName != modelName {
+			continue
+		}
+		delete(circuitBreakers, key)
+		n++
+	}
+	return n
+}
@@ -0,0 +1,113 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+func setCircuitBreakerTestSetting(t *testing.T) {
+	t.Helper()
+	setting := operation_setting.GetCircuitBreakerSetting()
+	origin := *setting
+	setting.Enabled, setting.FailureThreshold, setting.OpenSeconds, setting.MaxOpenSeconds = true, 3, 60, 200
+	setting.HalfOpenSuccessThreshold, setting.HalfOpenProbeIntervalSeconds = 2, 10
+	t.Cleanup(func() { *setting = origin })
+}
+
+// expireCircuitBreakerTest 让熔断立即到期，进入半开状态
+func expireCircuitBreakerTest(channelId int, modelName string) {
+	circuitBreakersLock.Lock()
+	defer circuitBreakersLock.Unlock()
+	breaker := circuitBreakers[channelScoreKey(channelId, modelName)]
+	breaker.OpenUntil = time.Now().Unix()
+	breaker.LastProbeAt = 0
+}
+
+func getCircuitBreakerTest(t *testing.T, channelId int) *ChannelCircuitBreaker {
+	t.Helper()
+	breakers := GetChannelCircuitBreakers(channelId)
+	if len(breakers) == 0 {
+		return nil
+	}
+	return &breakers[0]
+}
+
+func TestCircuitBreakerStateMachine(t *testing.T) {
+	setCircuitBreakerTestSetting(t)
+	const channelId, modelName = 920001, "gpt-4o"
+	t.Cleanup(func() { ResetChannelCircuitBreakers(channelId, "") })
+	allowed := func() bool {
+		return len(filterCircuitAllowedChannels([]int{channelId}, modelName)) == 1
+	}
+
+	// closed：连续失败未达到阈值前不熔断，成功清除失败计数
+	RecordCircuitBreakerFailure(channelId, modelName, "error")
+	RecordCircuitBreakerFailure(channelId, modelName, "error")
+	RecordCircuitBreakerSuccess(channelId, modelName)
+	if breaker := getCircuitBreakerTest(t, channelId); breaker != nil {
+		t.Fatalf("success should reset the breaker, got %+v", breaker)
+	}
+	for i := 0; i < 3; i++ {
+		RecordCircuitBreakerFailure(channelId, modelName, "error")
+	}
+
+	// open：熔断期间不选择该渠道，其他模型不受影响
+	breaker := getCircuitBreakerTest(t, channelId)
+	if breaker == nil || breaker.State != CircuitStateOpen || breaker.OpenUntil-breaker.OpenedAt != 60 || allowed() {
+		t.Fatalf("breaker after threshold = %+v", breaker)
+	}
+	if len(filterCircuitAllowedChannels([]int{channelId}, "other-model")) != 1 {
+		t.Fatal("other models of the channel should not be affected")
+	}
+
+	// half_open：到期后允许探测，并限制探测频率
+	expireCircuitBreakerTest(channelId, modelName)
+	if breaker = getCircuitBreakerTest(t, channelId); breaker.State != CircuitStateHalfOpen || !allowed() {
+		t.Fatalf("expired breaker = %+v", breaker)
+	}
+	if probes := GetHalfOpenCircuitBreakers(); len(probes) == 0 {
+		t.Fatal("half open breaker should be probed")
+	}
+	MarkCircuitBreakerProbe(channelId, modelName)
+	if allowed() {
+		t.Fatal("half open breaker should wait for the probe interval")
+	}
+
+	// 半开探测失败时重新熔断，熔断时长翻倍
+	RecordCircuitBreakerFailure(channelId, modelName, "probe error")
+	if breaker = getCircuitBreakerTest(t, channelId); breaker.State != CircuitStateOpen || breaker.OpenUntil-breaker.OpenedAt != 120 || breaker.LastError != "probe error" {
+		t.Fatalf("breaker after failed probe = %+v", breaker)
+	}
+	// 熔断时长不超过上限
+	expireCircuitBreakerTest(channelId, modelName)
+	RecordCircuitBreakerFailure(channelId, modelName, "probe error")
+	if breaker = getCircuitBreakerTest(t, channelId); breaker.OpenUntil-breaker.OpenedAt != 200 || breaker.TripCount != 3 {
+		t.Fatalf("breaker after second failed probe = %+v", breaker)
+	}
+
+	// 半开状态下连续成功达到阈值后恢复
+	expireCircuitBreakerTest(channelId, modelName)
+	RecordCircuitBreakerSuccess(channelId, modelName)
+	if breaker = getCircuitBreakerTest(t, channelId); breaker == nil || breaker.State != CircuitStateHalfOpen || breaker.HalfOpenSuccesses != 1 {
+		t.Fatalf("breaker after one successful probe = %+v", breaker)
+	}
+	RecordCircuitBreakerSuccess(channelId, modelName)
+	if breaker = getCircuitBreakerTest(t, channelId); breaker != nil || !allowed() {
+		t.Fatalf("breaker should be closed, got %+v", breaker)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	setCircuitBreakerTestSetting(t)
+	operation_setting.GetCircuitBreakerSetting().Enabled = false
+	const channelId = 920002
+	t.Cleanup(func() { ResetChannelCircuitBreakers(channelId, "") })
+	for i := 0; i < 5; i++ {
+		RecordCircuitBreakerFailure(channelId, "gpt-4o", "error")
+	}
+	if breakers := GetChannelCircuitBreakers(channelId); len(breakers) != 0 {
+		t.Fatalf("breakers = %+v", breakers)
+	}
+}
@@ -0,0 +1,197 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// channelCooldownWholeChannel 表示冷却作用于整个渠道而不是某个 key
+const channelCooldownWholeChannel = -1
+
+// ChannelCooldown 上游限流（429）导致的临时冷却，到期后自动恢复，不修改渠道 / key 的状态
+type ChannelCooldown struct {
+	ChannelId int    `json:"channel_id"`
+	KeyIndex  int    `json:"key_index"` // -1 表示整个渠道
+	Until     int64  `json:"until"`     // unix 毫秒
+	Reason    string `json:"reason"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+var (
+	channelCooldowns     = make(map[string]*ChannelCooldown)
+	channelCooldownsLock sync.RWMutex
+)
+
+func channelCooldownKey(channelId int, keyIndex int) string {
+	return fmt.Sprintf("%d:%d", channelId, keyIndex)
+}
+
+func setChannelCooldownLocked(channelId int, keyIndex int, until int64, reason string) {
+	key := channelCooldownKey(channelId, keyIndex)
+	if existing, ok := channelCooldowns[key]; ok && existing.Until >= until {
+		return
+	}
+	channelCooldowns[key] = &ChannelCooldown{
+		ChannelId: channelId,
+		KeyIndex:  keyIndex,
+		Until:     until,
+		Reason:    reason,
+		CreatedAt: time.Now().Unix(),
+	}
+}
+
+func isChannelKeyCoolingDownLocked(channelId int, keyIndex int, nowMs int64) bool {
+	cooldown, ok := channelCooldowns[channelCooldownKey(channelId, keyIndex)]
+	return ok && cooldown.Until > nowMs
+}
+
+// cleanupChannelCooldownsLocked 清理已过期的冷却记录
+func cleanupChannelCooldownsLocked(nowMs int64) {
+	for key, cooldown := range channelCooldowns {
+		if cooldown.Until <= nowMs {
+			delete(channelCooldowns, key)
+		}
+	}
+}
+
+// applyWholeChannelCooldown 让整个渠道冷却到 untilMs（unix 毫秒），不依赖渠道锁，可在持有 polling lock 时调用
+func applyWholeChannelCooldown(channelId int, untilMs int64, reason string) {
+	nowMs := time.Now().UnixMilli()
+	if untilMs <= nowMs {
+		return
+	}
+	channelCooldownsLock.Lock()
+	defer channelCooldownsLock.Unlock()
+	cleanupChannelCooldownsLocked(nowMs)
+	setChannelCooldownLocked(channelId, channelCooldownWholeChannel, untilMs, reason)
+}
+
+// ApplyChannelCooldown 对渠道（或多 key 渠道中的某个 key）设置冷却。
+// 多 key 渠道的所有可用 key 都处于冷却时，整个渠道一同冷却到最早恢复的 key 为止。
+func ApplyChannelCooldown(channelId int, isMultiKey bool, keyIndex int, duration time.Duration, reason string) {
+	if channelId <= 0 || duration <= 0 {
+		return
+	}
+	nowMs := time.Now().UnixMilli()
+	until := nowMs + duration.Milliseconds()
+
+	if !isMultiKey {
+		channelCooldownsLock.Lock()
+		cleanupChannelCooldownsLocked(nowMs)
+		setChannelCooldownLocked(channelId, channelCooldownWholeChannel, until, reason)
+		channelCooldownsLock.Unlock()
+		common.SysLog(fmt.Sprintf("channel #%d cooling down for %s: %s", channelId, duration, reason))
+		return
+	}
+
+	// 先在渠道锁内取出可用 key，避免与冷却锁交叉持有
+	var enabledIdx []int
+	if channel, err := CacheGetChannel(channelId); err == nil {
+		enabledIdx = channel.getEnabledKeyIndexes()
+	}
+
+	channelCooldownsLock.Lock()
+	defer channelCooldownsLock.Unlock()
+	cleanupChannelCooldownsLocked(nowMs)
+	setChannelCooldownLocked(channelId, keyIndex, until, reason)
+	common.SysLog(fmt.Sprintf("channel #%d key #%d cooling down for %s: %s", channelId, keyIndex, duration, reason))
+
+	if len(enabledIdx) == 0 {
+		return
+	}
+	earliest := int64(0)
+	for _, idx := range enabledIdx {
+		cooldown, ok := channelCooldowns[channelCooldownKey(channelId, idx)]
+		if !ok || cooldown.Until <= nowMs {
+			return
+		}
+		if earliest == 0 || cooldown.Until < earliest {
+			earliest = cooldown.Until
+		}
+	}
+	setChannelCooldownLocked(channelId, channelCooldownWholeChannel, earliest, "all keys are cooling down")
+}
+
+// filterCoolingDownChannels 过滤掉整体处于冷却中的渠道，返回新切片，不修改入参
+func filterCoolingDownChannels(channelIds []int) []int {
+	nowMs := time.Now().UnixMilli()
+	channelCooldownsLock.RLock()
+	defer channelCooldownsLock.RUnlock()
+	if len(channelCooldowns) == 0 {
+		return channelIds
+	}
+	allowed := make([]int, 0, len(channelIds))
+	for _, channelId := range channelIds {
+		if !isChannelKeyCoolingDownLocked(channelId, channelCooldownWholeChannel, nowMs) {
+			allowed = append(allowed, channelId)
+		}
+	}
+	return allowed
+}
+
+// filterCoolingDownKeys 过滤掉冷却中的 key 索引
+func filterCoolingDownKeys(channelId int, keyIndexes []int) []int {
+	nowMs := time.Now().UnixMilli()
+	channelCooldownsLock.RLock()
+	defer channelCooldownsLock.RUnlock()
+	if len(channelCooldowns) == 0 {
+		return keyIndexes
+	}
+	allowed := make([]int, 0, len(keyIndexes))
+	for _, idx := range keyIndexes {
+		if !isChannelKeyCoolingDownLocked(channelId, idx, nowMs) {
+			allowed = append(allowed, idx)
+		}
+	}
+	return allowed
+}
+
+// IsChannelCoolingDown 判断渠道整体是否处于冷却中
+func IsChannelCoolingDown(channelId int) bool {
+	channelCooldownsLock.RLock()
+	defer channelCooldownsLock.RUnlock()
+	return isChannelKeyCoolingDownLocked(channelId, channelCooldownWholeChannel, time.Now().UnixMilli())
+}
+
+// GetChannelCooldowns 返回未过期的冷却记录，channelId <= 0 时返回全部
+func GetChannelCooldowns(channelId int) []ChannelCooldown {
+	nowMs := time.Now().UnixMilli()
+	channelCooldownsLock.RLock()
+	defer channelCooldownsLock.RUnlock()
+	result := make([]ChannelCooldown, 0)
+	for _, cooldown := range channelCooldowns {
+		if cooldown.Until <= nowMs {
+			continue
+		}
+		if channelId > 0 && cooldown.ChannelId != channelId {
+			continue
+		}
+		result = append(result, *cooldown)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ChannelId != result[j].ChannelId {
+			return result[i].ChannelId < result[j].ChannelId
+		}
+		return result[i].KeyIndex < result[j].KeyIndex
+	})
+	return result
+}
+
+// ClearChannelCooldowns 手动解除冷却，channelId <= 0 时解除全部，返回删除数量
+func ClearChannelCooldowns(channelId int) int {
+	channelCooldownsLock.Lock()
+	defer channelCooldownsLock.Unlock()
+	n := 0
+	for key, cooldown := range channelCooldowns {
+		if channelId > 0 && cooldown.ChannelId != channelId {
+			continue
+		}
+		delete(channelCooldowns, key)
+		n++
+	}
+	return n
+}
@@ -0,0 +1,276 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/constant"
+)
+
+// 用量滑动窗口（秒），用于计算 RPM / TPM
+const channelKeyUsageWindowSeconds = 60
+
+// 近期错误计数的半衰期
+const channelKeyErrorHalfLife = 5 * time.Minute
+
+type channelKeyUsageBucket struct {
+	Second   int64
+	Requests int
+	Tokens   int
+}
+
+// ChannelKeyUsage 多 key 渠道中单个 key 的运行时用量统计，仅保存在当前节点内存中
+type ChannelKeyUsage struct {
+	ChannelId        int      `json:"channel_id"`
+	KeyIndex         int      `json:"key_index"`
+	Requests         int64    `json:"requests"`
+	Errors           int64    `json:"errors"`
+	RecentErrors     float64  `json:"recent_errors"` // 按半衰期衰减后的错误数
+	Tokens           int64    `json:"tokens"`
+	CurrentRPM       int      `json:"current_rpm"`
+	CurrentTPM       int      `json:"current_tpm"`
+	LastUsedAt       int64    `json:"last_used_at"` // unix 毫秒
+	LastErrorAt      int64    `json:"last_error_at"`
+	LastError        string   `json:"last_error,omitempty"`
+	Balance          *float64 `json:"balance,omitempty"` // 上游剩余额度（USD），未查询时为空
+	BalanceUpdatedAt int64    `json:"balance_updated_at,omitempty"`
+
+	recentErrorsAt int64
+	buckets        [channelKeyUsageWindowSeconds]channelKeyUsageBucket
+}
+
+var (
+	channelKeyUsages     = make(map[int]map[int]*ChannelKeyUsage)
+	channelKeyUsagesLock sync.Mutex
+)
+
+func loadOrCreateChannelKeyUsageLocked(channelId int, keyIndex int) *ChannelKeyUsage {
+	usages, ok := channelKeyUsages[channelId]
+	if !ok {
+		usages = make(map[int]*ChannelKeyUsage)
+		channelKeyUsages[channelId] = usages
+	}
+	usage, ok := usages[keyIndex]
+	if !ok {
+		usage = &ChannelKeyUsage{ChannelId: channelId, KeyIndex: keyIndex}
+		usages[keyIndex] = usage
+	}
+	return usage
+}
+
+func (u *ChannelKeyUsage) bucket(second int64) *channelKeyUsageBucket {
+	b := &u.buckets[second%channelKeyUsageWindowSeconds]
+	if b.Second != second {
+		*b = channelKeyUsageBucket{Second: second}
+	}
+	return b
+}
+
+// windowUsage 返回最近一个窗口内的请求数与 token 数
+func (u *ChannelKeyUsage) windowUsage(nowSecond int64) (requests int, tokens int) {
+	for _, b := range u.buckets {
+		if b.Second > nowSecond-channelKeyUsageWindowSeconds {
+			requests += b.Requests
+			tokens += b.Tokens
+		}
+	}
+	return
+}
+
+// recoverAt 返回窗口内用量降到 limit 以下的时间（unix 秒），value 取出桶内对应的计数
+func (u *ChannelKeyUsage) recoverAt(nowSecond int64, limit int, value func(b channelKeyUsageBucket) int) int64 {
+	buckets := make([]channelKeyUsageBucket, 0, channelKeyUsageWindowSeconds)
+	total := 0
+	for _, b := range u.buckets {
+		if b.Second > nowSecond-channelKeyUsageWindowSeconds {
+			buckets = append(buckets, b)
+			total += value(b)
+		}
+	}
+	if total < limit {
+		return nowSecond
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Second < buckets[j].Second })
+	for _, b := range buckets {
+		total -= value(b)
+		if total < limit {
+			return b.Second + channelKeyUsageWindowSeconds
+		}
+	}
+	return nowSecond + channelKeyUsageWindowSeconds
+}
+
+func (u *ChannelKeyUsage) decayedErrors(nowMs int64) float64 {
+	if u.RecentErrors == 0 || nowMs <= u.recentErrorsAt {
+		return u.RecentErrors
+	}
+	elapsed := float64(nowMs - u.recentErrorsAt)
+	return u.RecentErrors * math.Pow(0.5, elapsed/float64(channelKeyErrorHalfLife.Milliseconds()))
+}
+
+// markChannelKeyUsed 记录 key 被选中，调用方需持有渠道的 polling lock
+func markChannelKeyUsed(channelId int, keyIndex int) {
+	now := time.Now()
+	channelKeyUsagesLock.Lock()
+	defer channelKeyUsagesLock.Unlock()
+	usage := loadOrCreateChannelKeyUsageLocked(channelId, keyIndex)
+	usage.Requests++
+	usage.LastUsedAt = now.UnixMilli()
+	usage.bucket(now.Unix()).Requests++
+}
+
+// RecordChannelKeyTokens 记录 key 实际消耗的 token 数，用于计算 TPM
+func RecordChannelKeyTokens(channelId int, keyIndex int, tokens int) {
+	if channelId <= 0 || keyIndex < 0 || tokens <= 0 {
+		return
+	}
+	now := time.Now()
+	channelKeyUsagesLock.Lock()
+	defer channelKeyUsagesLock.Unlock()
+	usage := loadOrCreateChannelKeyUsageLocked(channelId, keyIndex)
+	usage.Tokens += int64(tokens)
+	usage.bucket(now.Unix()).Tokens += tokens
+}
+
+// RecordChannelKeyError 记录 key 的一次失败请求
+func RecordChannelKeyError(channelId int, keyIndex int, reason string) {
+	if channelId <= 0 || keyIndex < 0 {
+		return
+	}
+	nowMs := time.Now().UnixMilli()
+	channelKeyUsagesLock.Lock()
+	defer channelKeyUsagesLock.Unlock()
+	usage := loadOrCreateChannelKeyUsageLocked(channelId, keyIndex)
+	usage.Errors++
+	usage.RecentErrors = usage.decayedErrors(nowMs) + 1
+	usage.recentErrorsAt = nowMs
+	usage.LastErrorAt = nowMs
+	usage.LastError = reason
+}
+
+// SetChannelKeyBalance 记录 key 的上游剩余额度，供 quota_aware 模式使用
+func SetChannelKeyBalance(channelId int, keyIndex int, balance float64) {
+	if channelId <= 0 || keyIndex < 0 {
+		return
+	}
+	channelKeyUsagesLock.Lock()
+	defer channelKeyUsagesLock.Unlock()
+	usage := loadOrCreateChannelKeyUsageLocked(channelId, keyIndex)
+	usage.Balance = &balance
+	usage.BalanceUpdatedAt = time.Now().Unix()
+}
+
+// GetChannelKeyUsages 返回渠道下各 key 的用量快照，key index -> usage
+func GetChannelKeyUsages(channelId int) map[int]ChannelKeyUsage {
+	now := time.Now()
+	channelKeyUsagesLock.Lock()
+	defer channelKeyUsagesLock.Unlock()
+	result := make(map[int]ChannelKeyUsage, len(channelKeyUsages[channelId]))
+	for idx, usage := range channelKeyUsages[channelId] {
+		snapshot := *usage
+		snapshot.CurrentRPM, snapshot.CurrentTPM = usage.windowUsage(now.Unix())
+		snapshot.RecentErrors = usage.decayedErrors(now.UnixMilli())
+		result[idx] = snapshot
+	}
+	return result
+}
+
+// ResetChannelKeyUsages 清除渠道下的 key 用量统计，key 被删除导致索引变化时需要调用
+func ResetChannelKeyUsages(channelId int) {
+	channelKeyUsagesLock.Lock()
+	defer channelKeyUsagesLock.Unlock()
+	delete(channelKeyUsages, channelId)
+}
+
+// filterChannelKeysByLimit 过滤掉已达到 RPM / TPM 上限的 key。
+// 全部 key 都达到上限时返回空切片，以及最早恢复的时间（unix 秒）
+func filterChannelKeysByLimit(channelId int, keyIndexes []int, limits map[int]MultiKeyLimit) ([]int, int64) {
+	if len(limits) == 0 {
+		return keyIndexes, 0
+	}
+	nowSecond := time.Now().Unix()
+	channelKeyUsagesLock.Lock()
+	defer channelKeyUsagesLock.Unlock()
+	usages := channelKeyUsages[channelId]
+	allowed := make([]int, 0, len(keyIndexes))
+	earliest := int64(0)
+	for _, idx := range keyIndexes {
+		limit, ok := limits[idx]
+		usage := usages[idx]
+		if !ok || usage == nil || (limit.RPM <= 0 && limit.TPM <= 0) {
+			allowed = append(allowed, idx)
+			continue
+		}
+		requests, tokens := usage.windowUsage(nowSecond)
+		if (limit.RPM <= 0 || requests < limit.RPM) && (limit.TPM <= 0 || tokens < limit.TPM) {
+			allowed = append(allowed, idx)
+			continue
+		}
+		recoverSecond := nowSecond
+		if limit.RPM > 0 {
+			recoverSecond = max(recoverSecond, usage.recoverAt(nowSecond, limit.RPM, func(b channelKeyUsageBucket) int { return b.Requests }))
+		}
+		if limit.TPM > 0 {
+			recoverSecond = max(recoverSecond, usage.recoverAt(nowSecond, limit.TPM, func(b channelKeyUsageBucket) int { return b.Tokens }))
+		}
+		if earliest == 0 || recoverSecond < earliest {
+			earliest = recoverSecond
+		}
+	}
+	return allowed, earliest
+}
+
+// pickChannelKeyByUsage 按 least_recently_used / least_errors / quota_aware 模式从候选 key 中选出一个
+func pickChannelKeyByUsage(channelId int, mode constant.MultiKeyMode, keyIndexes []int) int {
+	nowMs := time.Now().UnixMilli()
+	channelKeyUsagesLock.Lock()
+	defer channelKeyUsagesLock.Unlock()
+	usages := channelKeyUsages[channelId]
+
+	lastUsed := func(idx int) int64 {
+		if usage := usages[idx]; usage != nil {
+			return usage.LastUsedAt
+		}
+		return 0
+	}
+	// 没有统计数据的 key 视为最久未使用、零错误；余额未知的 key 排在已知余额的 key 之后
+	less := func(a, b int) bool {
+		ua, ub := usages[a], usages[b]
+		switch mode {
+		case constant.MultiKeyModeLeastErrors:
+			var ea, eb float64
+			if ua != nil {
+				ea = ua.decayedErrors(nowMs)
+			}
+			if ub != nil {
+				eb = ub.decayedErrors(nowMs)
+			}
+			if math.Abs(ea-eb) > 0.01 {
+				return ea < eb
+			}
+		case constant.MultiKeyModeQuotaAware:
+			hasA := ua != nil && ua.Balance != nil
+			hasB := ub != nil && ub.Balance != nil
+			if hasA != hasB {
+				return hasA
+			}
+			if hasA && *ua.Balance != *ub.Balance {
+				return *ua.Balance > *ub.Balance
+			}
+		}
+		if lastUsed(a) != lastUsed(b) {
+			return lastUsed(a) < lastUsed(b)
+		}
+		return a < b
+	}
+
+	best := keyIndexes[0]
+	for _, idx := range keyIndexes[1:] {
+		if less(idx, best) {
+			best = idx
+		}
+	}
+	return best
+}
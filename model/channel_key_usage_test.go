@@ -0,0 +1,155 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/constant"
+)
+
+// setChannelKeyTestUsage 在 secondsAgo 秒前的桶中记录 key 的用量
+func setChannelKeyTestUsage(channelId int, keyIndex int, secondsAgo int64, requests int, tokens int) {
+	channelKeyUsagesLock.Lock()
+	defer channelKeyUsagesLock.Unlock()
+	usage := loadOrCreateChannelKeyUsageLocked(channelId, keyIndex)
+	b := usage.bucket(time.Now().Unix() - secondsAgo)
+	b.Requests += requests
+	b.Tokens += tokens
+}
+
+func TestChannelKeyUsageRecoverAt(t *testing.T) {
+	now := int64(1_000_000)
+	usage := &ChannelKeyUsage{}
+	for _, b := range []channelKeyUsageBucket{
+		{Second: now - 50, Requests: 2},
+		{Second: now - 30, Requests: 3},
+		{Second: now - 10, Requests: 5},
+		// 窗口外的桶不计入
+		{Second: now - 75, Requests: 100},
+	} {
+		*usage.bucket(b.Second) = b
+	}
+	requests := func(b channelKeyUsageBucket) int { return b.Requests }
+
+	tests := []struct {
+		name  string
+		limit int
+		want  int64
+	}{
+		{name: "below limit", limit: 11, want: now},
+		{name: "oldest bucket expires", limit: 10, want: now - 50 + channelKeyUsageWindowSeconds},
+		{name: "two buckets expire", limit: 8, want: now - 30 + channelKeyUsageWindowSeconds},
+		{name: "all buckets expire", limit: 1, want: now - 10 + channelKeyUsageWindowSeconds},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usage.recoverAt(now, tt.limit, requests); got != tt.want {
+				t.Errorf("recoverAt(limit=%d) = %d, want %d", tt.limit, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterChannelKeysByLimit(t *testing.T) {
+	const channelId = -1001
+	t.Cleanup(func() { ResetChannelKeyUsages(channelId) })
+	setChannelKeyTestUsage(channelId, 0, 20, 5, 100)
+	setChannelKeyTestUsage(channelId, 1, 40, 1, 5000)
+	setChannelKeyTestUsage(channelId, 2, 5, 1, 10)
+
+	keys := []int{0, 1, 2, 3}
+	if allowed, recoverAt := filterChannelKeysByLimit(channelId, keys, nil); len(allowed) != 4 || recoverAt != 0 {
+		t.Fatalf("without limits got %v, %d", allowed, recoverAt)
+	}
+
+	limits := map[int]MultiKeyLimit{
+		0: {RPM: 5},
+		1: {TPM: 1000},
+		2: {RPM: 10, TPM: 1000},
+		// 没有用量记录的 key 不受限制
+		3: {RPM: 1},
+	}
+	allowed, recoverAt := filterChannelKeysByLimit(channelId, keys, limits)
+	if len(allowed) != 2 || allowed[0] != 2 || allowed[1] != 3 || recoverAt == 0 {
+		t.Fatalf("allowed = %v, recoverAt = %d", allowed, recoverAt)
+	}
+
+	// 全部达到上限时返回最早恢复的时间：key 1 的用量比 key 0 更早过期
+	allowed, recoverAt = filterChannelKeysByLimit(channelId, []int{0, 1}, limits)
+	if len(allowed) != 0 {
+		t.Fatalf("allowed = %v, want none", allowed)
+	}
+	if want := time.Now().Unix() - 40 + channelKeyUsageWindowSeconds; recoverAt < want-1 || recoverAt > want+1 {
+		t.Errorf("recoverAt = %d, want about %d", recoverAt, want)
+	}
+}
+
+func TestPickChannelKeyByUsage(t *testing.T) {
+	const channelId = -1002
+	t.Cleanup(func() { ResetChannelKeyUsages(channelId) })
+	nowMs := time.Now().UnixMilli()
+	balance := func(v float64) *float64 { return &v }
+
+	channelKeyUsagesLock.Lock()
+	for idx, usage := range map[int]ChannelKeyUsage{
+		0: {LastUsedAt: nowMs - 1000, RecentErrors: 3, recentErrorsAt: nowMs, Balance: balance(5)},
+		1: {LastUsedAt: nowMs - 5000, RecentErrors: 1, recentErrorsAt: nowMs, Balance: balance(20)},
+		2: {LastUsedAt: nowMs - 3000, RecentErrors: 0.5, recentErrorsAt: nowMs},
+	} {
+		stored := loadOrCreateChannelKeyUsageLocked(channelId, idx)
+		stored.LastUsedAt, stored.RecentErrors, stored.recentErrorsAt, stored.Balance = usage.LastUsedAt, usage.RecentErrors, usage.recentErrorsAt, usage.Balance
+	}
+	channelKeyUsagesLock.Unlock()
+
+	tests := []struct {
+		name string
+		mode constant.MultiKeyMode
+		keys []int
+		want int
+	}{
+		{name: "least recently used", mode: constant.MultiKeyModeLeastRecentlyUsed, keys: []int{0, 1, 2}, want: 1},
+		{name: "unused key first", mode: constant.MultiKeyModeLeastRecentlyUsed, keys: []int{0, 1, 2, 3}, want: 3},
+		{name: "least errors", mode: constant.MultiKeyModeLeastErrors, keys: []int{0, 1, 2}, want: 2},
+		{name: "quota aware", mode: constant.MultiKeyModeQuotaAware, keys: []int{0, 1, 2}, want: 1},
+		{name: "unknown balance last", mode: constant.MultiKeyModeQuotaAware, keys: []int{2, 0}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pickChannelKeyByUsage(channelId, tt.mode, tt.keys); got != tt.want {
+				t.Errorf("pickChannelKeyByUsage(%s, %v) = %d, want %d", tt.mode, tt.keys, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChannelKeyDecayedErrors(t *testing.T) {
+	nowMs := time.Now().UnixMilli()
+	usage := &ChannelKeyUsage{RecentErrors: 4, recentErrorsAt: nowMs - channelKeyErrorHalfLife.Milliseconds()}
+	if got := usage.decayedErrors(nowMs); got < 1.99 || got > 2.01 {
+		t.Errorf("decayedErrors after one half-life = %v, want 2", got)
+	}
+}
+
+func TestGetNextEnabledKeyWithoutUsage(t *testing.T) {
+	const channelId = -1003
+	t.Cleanup(func() { ResetChannelKeyUsages(channelId) })
+	channel := &Channel{Id: channelId, Key: "sk-a\nsk-b"}
+	channel.ChannelInfo.IsMultiKey = true
+	channel.ChannelInfo.MultiKeyMode = constant.MultiKeyModeRandom
+
+	// count_tokens 等免费请求选择 key 时不计入用量
+	if _, _, err := channel.GetNextEnabledKeyWithoutUsage(); err != nil {
+		t.Fatal(err)
+	}
+	if usages := GetChannelKeyUsages(channelId); len(usages) != 0 {
+		t.Fatalf("usages = %+v, want none", usages)
+	}
+
+	_, keyIndex, err := channel.GetNextEnabledKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage := GetChannelKeyUsages(channelId)[keyIndex]; usage.Requests != 1 || usage.CurrentRPM != 1 {
+		t.Fatalf("usage = %+v", usage)
+	}
+}
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// 渠道 / key 并发已满的本地标记。并发槽本身由 service 层维护（内存或 Redis），
+// 这里只记录本节点最近一次获取失败的结果，供选择渠道和 key 时优先跳过，标记很快过期后重新尝试。
+var (
+	channelSaturations     = make(map[string]int64)
+	channelSaturationsLock sync.RWMutex
+)
+
+func channelSaturationKey(channelId int, keyIndex int) string {
+	return fmt.Sprintf("%d:%d", channelId, keyIndex)
+}
+
+func markChannelSaturation(channelId int, keyIndex int) {
+	ttl := time.Duration(operation_setting.GetChannelConcurrencySetting().SaturatedRecheckMillis) * time.Millisecond
+	if ttl <= 0 {
+		return
+	}
+	channelSaturationsLock.Lock()
+	defer channelSaturationsLock.Unlock()
+	channelSaturations[channelSaturationKey(channelId, keyIndex)] = time.Now().Add(ttl).UnixMilli()
+}
+
+// MarkChannelSaturated 标记渠道整体并发已满
+func MarkChannelSaturated(channelId int) {
+	markChannelSaturation(channelId, channelCooldownWholeChannel)
+}
+
+// MarkChannelKeySaturated 标记多 key 渠道中的某个 key 并发已满
+func MarkChannelKeySaturated(channelId int, keyIndex int) {
+	markChannelSaturation(channelId, keyIndex)
+}
+
+// ClearChannelSaturated 本节点释放了渠道的并发槽后清除渠道及对应 key 的标记
+func ClearChannelSaturated(channelId int, keyIndex int) {
+	channelSaturationsLock.Lock()
+	defer channelSaturationsLock.Unlock()
+	delete(channelSaturations, channelSaturationKey(channelId, channelCooldownWholeChannel))
+	if keyIndex >= 0 {
+		delete(channelSaturations, channelSaturationKey(channelId, keyIndex))
+	}
+}
+
+func isChannelSaturatedLocked(channelId int, keyIndex int, nowMs int64) bool {
+	until, ok := channelSaturations[channelSaturationKey(channelId, keyIndex)]
+	return ok && until > nowMs
+}
+
+// filterSaturatedChannels 过滤掉并发已满的渠道；全部已满时返回原切片，由调用方排队等待
+func filterSaturatedChannels(channelIds []int) []int {
+	nowMs := time.Now().UnixMilli()
+	channelSaturationsLock.RLock()
+	defer channelSaturationsLock.RUnlock()
+	if len(channelSaturations) == 0 {
+		return channelIds
+	}
+	allowed := make([]int, 0, len(channelIds))
+	for _, channelId := range channelIds {
+		if !isChannelSaturatedLocked(channelId, channelCooldownWholeChannel, nowMs) {
+			allowed = append(allowed, channelId)
+		}
+	}
+	if len(allowed) == 0 {
+		return channelIds
+	}
+	return allowed
+}
+
+// filterSaturatedKeys 过滤掉并发已满的 key；全部已满时标记整个渠道并返回原切片
+func filterSaturatedKeys(channelId int, keyIndexes []int) []int {
+	nowMs := time.Now().UnixMilli()
+	channelSaturationsLock.RLock()
+	if len(channelSaturations) == 0 {
+		channelSaturationsLock.RUnlock()
+		return keyIndexes
+	}
+	allowed := make([]int, 0, len(keyIndexes))
+	for _, idx := range keyIndexes {
+		if !isChannelSaturatedLocked(channelId, idx, nowMs) {
+			allowed = append(allowed, idx)
+		}
+	}
+	channelSaturationsLock.RUnlock()
+	if len(allowed) == 0 {
+		MarkChannelSaturated(channelId)
+		return keyIndexes
+	}
+	return allowed
+}
@@ -0,0 +1,222 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// ChannelScore 渠道 + 模型维度的实时健康评分（EWMA），仅保存在当前节点内存中
+type ChannelScore struct {
+	ChannelId    int     `json:"channel_id"`
+	ModelName    string  `json:"model_name"`
+	LatencyMs    float64 `json:"latency_ms"`
+	TTFTMs       float64 `json:"ttft_ms"`
+	ErrorRate    float64 `json:"error_rate"`
+	Samples      int64   `json:"samples"`
+	Successes    int64   `json:"successes"`
+	Failures     int64   `json:"failures"`
+	HealthFactor float64 `json:"health_factor"`
+	UpdatedAt    int64   `json:"updated_at"`
+}
+
+var (
+	channelScores     = make(map[string]*ChannelScore)
+	channelScoresLock sync.RWMutex
+)
+
+func channelScoreKey(channelId int, modelName string) string {
+	return fmt.Sprintf("%d:%s", channelId, modelName)
+}
+
+func ewma(prev float64, sample float64, alpha float64, initialized bool) float64 {
+	if !initialized {
+		return sample
+	}
+	return alpha*sample + (1-alpha)*prev
+}
+
+func getChannelScoreAlpha() float64 {
+	alpha := operation_setting.GetChannelSelectSetting().EwmaAlpha
+	if alpha <= 0 || alpha > 1 {
+		return 0.2
+	}
+	return alpha
+}
+
+func loadOrCreateChannelScore(channelId int, modelName string) *ChannelScore {
+	key := channelScoreKey(channelId, modelName)
+	score, ok := channelScores[key]
+	if !ok {
+		score = &ChannelScore{ChannelId: channelId, ModelName: modelName}
+		channelScores[key] = score
+	}
+	return score
+}
+
+// RecordChannelScoreSuccess 记录一次成功请求，latency 为整次请求耗时，ttft 为首字耗时（未知时传 0）
+func RecordChannelScoreSuccess(channelId int, modelName string, latency time.Duration, ttft time.Duration) {
+	if channelId <= 0 || modelName == "" {
+		return
+	}
+	alpha := getChannelScoreAlpha()
+	channelScoresLock.Lock()
+	defer channelScoresLock.Unlock()
+	score := loadOrCreateChannelScore(channelId, modelName)
+	initialized := score.Samples > 0
+	score.LatencyMs = ewma(score.LatencyMs, float64(latency.Milliseconds()), alpha, initialized && score.LatencyMs > 0)
+	if ttft > 0 {
+		score.TTFTMs = ewma(score.TTFTMs, float64(ttft.Milliseconds()), alpha, score.TTFTMs > 0)
+	}
+	score.ErrorRate = ewma(score.ErrorRate, 0, alpha, initialized)
+	score.Samples++
+	score.Successes++
+	score.UpdatedAt = time.Now().Unix()
+}
+
+// RecordChannelScoreFailure 记录一次由渠道导致的失败请求
+func RecordChannelScoreFailure(channelId int, modelName string) {
+	if channelId <= 0 || modelName == "" {
+		return
+	}
+	alpha := getChannelScoreAlpha()
+	channelScoresLock.Lock()
+	defer channelScoresLock.Unlock()
+	score := loadOrCreateChannelScore(channelId, modelName)
+	score.ErrorRate = ewma(score.ErrorRate, 1, alpha, score.Samples > 0)
+	score.Samples++
+	score.Failures++
+	score.UpdatedAt = time.Now().Unix()
+}
+
+// GetChannelScores 返回评分快照，channelId <= 0 或 modelName 为空时不做对应过滤
+func GetChannelScores(channelId int, modelName string) []ChannelScore {
+	channelScoresLock.RLock()
+	defer channelScoresLock.RUnlock()
+	result := make([]ChannelScore, 0, len(channelScores))
+	for _, score := range channelScores {
+		if channelId > 0 && score.ChannelId != channelId {
+			continue
+		}
+		if modelName != "" && score.ModelName != modelName {
+			continue
+		}
+		result = append(result, *score)
+	}
+	// 计算同模型下的相对健康系数，便于在管理端直接观察选择倾向
+	bestMetric := make(map[string]float64)
+	for _, score := range result {
+		if !isChannelScoreUsable(&score) {
+			continue
+		}
+		metric := channelScoreLatencyMetric(&score)
+		if best, ok := bestMetric[score.ModelName]; metric > 0 && (!ok || metric < best) {
+			bestMetric[score.ModelName] = metric
+		}
+	}
+	for i := range result {
+		result[i].HealthFactor = channelHealthFactor(&result[i], bestMetric[result[i].ModelName])
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ModelName != result[j].ModelName {
+			return result[i].ModelName < result[j].ModelName
+		}
+		return result[i].ChannelId < result[j].ChannelId
+	})
+	return result
+}
+
+// ResetChannelScores 清除评分，channelId <= 0 时清除全部，返回删除数量
+func ResetChannelScores(channelId int) int {
+	channelScoresLock.Lock()
+	defer channelScoresLock.Unlock()
+	if channelId <= 0 {
+		n := len(channelScores)
+		channelScores = make(map[string]*ChannelScore)
+		return n
+	}
+	n := 0
+	for key, score := range channelScores {
+		if score.ChannelId == channelId {
+			delete(channelScores, key)
+			n++
+		}
+	}
+	return n
+}
+
+func isChannelScoreUsable(score *ChannelScore) bool {
+	if score == nil {
+		return false
+	}
+	setting := operation_setting.GetChannelSelectSetting()
+	if score.Samples < int64(setting.MinSamples) {
+		return false
+	}
+	if setting.ScoreTTLSeconds > 0 && time.Now().Unix()-score.UpdatedAt > int64(setting.ScoreTTLSeconds) {
+		return false
+	}
+	return true
+}
+
+// channelScoreLatencyMetric 优先使用首字时间，没有时退回整体延迟
+func channelScoreLatencyMetric(score *ChannelScore) float64 {
+	if score.TTFTMs > 0 {
+		return score.TTFTMs
+	}
+	return score.LatencyMs
+}
+
+// channelHealthFactor 计算权重乘数，bestMetric 为同批候选渠道中最优的延迟指标
+func channelHealthFactor(score *ChannelScore, bestMetric float64) float64 {
+	if !isChannelScoreUsable(score) {
+		return 1
+	}
+	setting := operation_setting.GetChannelSelectSetting()
+	factor := math.Pow(math.Max(0, 1-score.ErrorRate), setting.ErrorPenalty)
+	metric := channelScoreLatencyMetric(score)
+	if bestMetric > 0 && metric > 0 {
+		factor *= math.Pow(math.Min(1, bestMetric/metric), setting.LatencyPenalty)
+	}
+	if factor < setting.MinHealthFactor {
+		factor = setting.MinHealthFactor
+	}
+	if factor > 1 {
+		factor = 1
+	}
+	return factor
+}
+
+// getChannelHealthFactors 返回候选渠道对应的权重乘数，顺序与 channelIds 一致
+func getChannelHealthFactors(channelIds []int, modelName string) []float64 {
+	factors := make([]float64, len(channelIds))
+	scores := make([]*ChannelScore, len(channelIds))
+	bestMetric := 0.0
+
+	channelScoresLock.RLock()
+	for i, channelId := range channelIds {
+		if score, ok := channelScores[channelScoreKey(channelId, modelName)]; ok {
+			snapshot := *score
+			scores[i] = &snapshot
+		}
+	}
+	channelScoresLock.RUnlock()
+
+	for _, score := range scores {
+		if !isChannelScoreUsable(score) {
+			continue
+		}
+		metric := channelScoreLatencyMetric(score)
+		if metric > 0 && (bestMetric == 0 || metric < bestMetric) {
+			bestMetric = metric
+		}
+	}
+	for i, score := range scores {
+		factors[i] = channelHealthFactor(score, bestMetric)
+	}
+	return factors
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// setChannelScoreTestSetting 使用便于计算的评分参数，测试结束后恢复
+func setChannelScoreTestSetting(t *testing.T) {
+	t.Helper()
+	setting := operation_setting.GetChannelSelectSetting()
+	origin := *setting
+	setting.EwmaAlpha, setting.MinSamples, setting.ErrorPenalty, setting.LatencyPenalty = 0.5, 2, 2, 1
+	setting.MinHealthFactor, setting.ScoreTTLSeconds = 0.1, 60
+	t.Cleanup(func() { *setting = origin })
+}
+
+func almostEqual(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestChannelScoreEwma(t *testing.T) {
+	setChannelScoreTestSetting(t)
+	const channelId = 900001
+	t.Cleanup(func() { ResetChannelScores(channelId) })
+
+	// 每一步之后的错误率和延迟（alpha = 0.5），首个样本直接作为初始值
+	steps := []struct {
+		name      string
+		fail      bool
+		latencyMs int64
+		ttftMs    int64
+		errorRate float64
+		latency   float64
+		ttft      float64
+	}{
+		{name: "first failure", fail: true, errorRate: 1},
+		{name: "first latency sample", latencyMs: 400, ttftMs: 200, errorRate: 0.5, latency: 400, ttft: 200},
+		{name: "success decays errors", latencyMs: 200, errorRate: 0.25, latency: 300, ttft: 200},
+		{name: "ttft smoothing", latencyMs: 300, ttftMs: 100, errorRate: 0.125, latency: 300, ttft: 150},
+		{name: "failure keeps latency", fail: true, errorRate: 0.5625, latency: 300, ttft: 150},
+	}
+	for i, step := range steps {
+		if step.fail {
+			RecordChannelScoreFailure(channelId, "gpt-4o")
+		} else {
+			RecordChannelScoreSuccess(channelId, "gpt-4o", time.Duration(step.latencyMs)*time.Millisecond, time.Duration(step.ttftMs)*time.Millisecond)
+		}
+		scores := GetChannelScores(channelId, "gpt-4o")
+		if len(scores) != 1 {
+			t.Fatalf("%s: scores = %+v", step.name, scores)
+		}
+		score := scores[0]
+		if !almostEqual(score.ErrorRate, step.errorRate) || !almostEqual(score.LatencyMs, step.latency) || !almostEqual(score.TTFTMs, step.ttft) {
+			t.Fatalf("%s: error rate = %v, latency = %v, ttft = %v, want %v, %v, %v",
+				step.name, score.ErrorRate, score.LatencyMs, score.TTFTMs, step.errorRate, step.latency, step.ttft)
+		}
+		if score.Samples != int64(i+1) {
+			t.Fatalf("%s: samples = %d", step.name, score.Samples)
+		}
+	}
+
+	// 无效的渠道和模型不记录
+	RecordChannelScoreSuccess(0, "gpt-4o", time.Second, 0)
+	RecordChannelScoreFailure(channelId, "")
+	if scores := GetChannelScores(channelId, ""); len(scores) != 1 {
+		t.Fatalf("scores = %+v", scores)
+	}
+}
+
+func TestChannelHealthFactor(t *testing.T) {
+	setChannelScoreTestSetting(t)
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name       string
+		score      *ChannelScore
+		bestMetric float64
+		want       float64
+	}{
+		{name: "no score", score: nil, bestMetric: 100, want: 1},
+		{name: "too few samples", score: &ChannelScore{Samples: 1, ErrorRate: 1, UpdatedAt: now}, want: 1},
+		{name: "stale score", score: &ChannelScore{Samples: 10, ErrorRate: 1, UpdatedAt: now - 120}, want: 1},
+		{name: "healthy best channel", score: &ChannelScore{Samples: 10, LatencyMs: 100, UpdatedAt: now}, bestMetric: 100, want: 1},
+		{name: "error penalty", score: &ChannelScore{Samples: 10, ErrorRate: 0.5, LatencyMs: 100, UpdatedAt: now}, bestMetric: 100, want: 0.25},
+		{name: "latency penalty", score: &ChannelScore{Samples: 10, LatencyMs: 400, UpdatedAt: now}, bestMetric: 100, want: 0.25},
+		{name: "ttft preferred over latency", score: &ChannelScore{Samples: 10, LatencyMs: 1000, TTFTMs: 200, UpdatedAt: now}, bestMetric: 100, want: 0.5},
+		{name: "combined penalties", score: &ChannelScore{Samples: 10, ErrorRate: 0.2, LatencyMs: 200, UpdatedAt: now}, bestMetric: 100, want: 0.32},
+		{name: "floor for unhealthy channel", score: &ChannelScore{Samples: 10, ErrorRate: 0.9, LatencyMs: 1000, UpdatedAt: now}, bestMetric: 100, want: 0.1},
+		{name: "no latency reference", score: &ChannelScore{Samples: 10, ErrorRate: 0.5, UpdatedAt: now}, want: 0.25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := channelHealthFactor(tt.score, tt.bestMetric); !almostEqual(got, tt.want) {
+				t.Errorf("channelHealthFactor = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetChannelHealthFactors(t *testing.T) {
+	setChannelScoreTestSetting(t)
+	const fast, slow, failing, unknown = 900011, 900012, 900013, 900014
+	t.Cleanup(func() {
+		for _, channelId := range []int{fast, slow, failing, unknown} {
+			ResetChannelScores(channelId)
+		}
+	})
+	for i := 0; i < 2; i++ {
+		RecordChannelScoreSuccess(fast, "gpt-4o", 100*time.Millisecond, 0)
+		RecordChannelScoreSuccess(slow, "gpt-4o", 200*time.Millisecond, 0)
+		RecordChannelScoreFailure(failing, "gpt-4o")
+	}
+
+	// 最快的可用渠道作为延迟基准，没有评分的渠道按静态权重处理
+	factors := getChannelHealthFactors([]int{fast, slow, failing, unknown}, "gpt-4o")
+	want := []float64{1, 0.5, 0.1, 1}
+	for i := range want {
+		if !almostEqual(factors[i], want[i]) {
+			t.Fatalf("factors = %v, want %v", factors, want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// File 用户通过 Files API 上传的文件，内容保存在文件存储后端
+type File struct {
+	Id         int    `json:"-" gorm:"primaryKey;autoIncrement"`
+	FileId     string `json:"id" gorm:"type:varchar(64);uniqueIndex;not null"`
+	UserId     int    `json:"-" gorm:"index;not null"`
+	TokenId    int    `json:"-" gorm:"default:0"`
+	Filename   string `json:"filename" gorm:"type:varchar(255)"`
+	Purpose    string `json:"purpose" gorm:"type:varchar(32);index"`
+	Bytes      int64  `json:"bytes" gorm:"bigint"`
+	MimeType   string `json:"-" gorm:"type:varchar(128)"`
+	StorageKey string `json:"-" gorm:"type:varchar(255)"`
+	Status     string `json:"status" gorm:"type:varchar(16)"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint"`
+}
+
+// FileUpstream 文件重新上传到上游渠道后的上游 file_id
+type FileUpstream struct {
+	Id             int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	FileId         string `json:"file_id" gorm:"type:varchar(64);uniqueIndex:idx_file_upstream_channel;not null"`
+	ChannelId      int    `json:"channel_id" gorm:"uniqueIndex:idx_file_upstream_channel;not null"`
+	UpstreamFileId string `json:"upstream_file_id" gorm:"type:varchar(128)"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint"`
+}
+
+const FileStatusProcessed = "processed"
+
+func (File) TableName() string {
+	return "files"
+}
+
+func (FileUpstream) TableName() string {
+	return "file_upstreams"
+}
+
+func InsertFile(file *File) error {
+	if file.CreatedAt == 0 {
+		file.CreatedAt = common.GetTimestamp()
+	}
+	return DB.Create(file).Error
+}
+
+// InsertUserFileWithinQuota 在用户已上传文件的总字节数不超过 quotaBytes 时创建文件记录，返回是否创建成功。
+// 锁定用户行使同一用户的并发上传依次检查配额
+func InsertUserFileWithinQuota(file *File, quotaBytes int64) (bool, error) {
+	if file.CreatedAt == 0 {
+		file.CreatedAt = common.GetTimestamp()
+	}
+	inserted := false
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var user User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", file.UserId).Find(&user).Error; err != nil {
+			return err
+		}
+		var used int64
+		if err := tx.Model(&File{}).Where("user_id = ?", file.UserId).Select("COALESCE(SUM(bytes), 0)").Scan(&used).Error; err != nil {
+			return err
+		}
+		if used+file.Bytes > quotaBytes {
+			return nil
+		}
+		inserted = true
+		return tx.Create(file).Error
+	})
+	return inserted && err == nil, err
+}
+
+// GetUserFile 获取用户的文件，不存在或不属于该用户时返回 nil
+func GetUserFile(userId int, fileId string) (*File, error) {
+	var file File
+	err := DB.Where("file_id = ? AND user_id = ?", fileId, userId).First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// ListUserFiles 按创建顺序分页获取用户的文件，afterFileId 为上一页最后一个文件的 id
+func ListUserFiles(userId int, purpose string, afterFileId string, limit int, ascending bool) ([]*File, error) {
+	query := DB.Where("user_id = ?", userId)
+	if purpose != "" {
+		query = query.Where("purpose = ?", purpose)
+	}
+	order := "id desc"
+	if ascending {
+		order = "id asc"
+	}
+	if afterFileId != "" {
+		after, err := GetUserFile(userId, afterFileId)
+		if err != nil {
+			return nil, err
+		}
+		if after != nil {
+			if ascending {
+				query = query.Where("id > ?", after.Id)
+			} else {
+				query = query.Where("id < ?", after.Id)
+			}
+		}
+	}
+	var files []*File
+	err := query.Order(order).Limit(limit).Find(&files).Error
+	return files, err
+}
+
+// SumUserFileBytes 用户已上传文件的总字节数
+func SumUserFileBytes(userId int) (int64, error) {
+	var total int64
+	err := DB.Model(&File{}).Where("user_id = ?", userId).Select("COALESCE(SUM(bytes), 0)").Scan(&total).Error
+	return total, err
+}
+
+// DeleteFile 删除文件记录以及上游 file_id 映射
+func DeleteFile(file *File) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("file_id = ?", file.FileId).Delete(&FileUpstream{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(file).Error
+	})
+}
+
+// GetFileUpstreamId 获取文件在渠道上的上游 file_id，没有上传过时返回空字符串
+func GetFileUpstreamId(fileId string, channelId int) (string, error) {
+	var upstream FileUpstream
+	err := DB.Where("file_id = ? AND channel_id = ?", fileId, channelId).First(&upstream).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return upstream.UpstreamFileId, nil
+}
+
+// SaveFileUpstreamId 记录文件在渠道上的上游 file_id
+func SaveFileUpstreamId(fileId string, channelId int, upstreamFileId string) error {
+	return DB.Where("file_id = ? AND channel_id = ?", fileId, channelId).
+		Assign(FileUpstream{UpstreamFileId: upstreamFileId, CreatedAt: common.GetTimestamp()}).
+		FirstOrCreate(&FileUpstream{FileId: fileId, ChannelId: channelId}).Error
+}
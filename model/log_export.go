@@ -0,0 +1,122 @@
+package model
+
+import (
+	"context"
+
+	"github.com/QuantumNous/new-api/types"
+
+	"gorm.io/gorm"
+)
+
+// LogExportFilter 导出日志的筛选条件，与 GetAllLogs 保持一致
+type LogExportFilter struct {
+	// 为 0 时导出所有用户
+	UserId         int
+	Type           int
+	StartTimestamp int64
+	EndTimestamp   int64
+	ModelName      string
+	Username       string
+	TokenName      string
+	Channel        int
+	Group          string
+}
+
+func (f *LogExportFilter) apply(tx *gorm.DB) *gorm.DB {
+	if f.UserId != 0 {
+		tx = tx.Where("logs.user_id = ?", f.UserId)
+	}
+	if f.Type != LogTypeUnknown {
+		tx = tx.Where("logs.type = ?", f.Type)
+	}
+	if f.ModelName != "" {
+		tx = tx.Where("logs.model_name like ?", f.ModelName)
+	}
+	if f.Username != "" {
+		tx = tx.Where("logs.username = ?", f.Username)
+	}
+	if f.TokenName != "" {
+		tx = tx.Where("logs.token_name = ?", f.TokenName)
+	}
+	if f.StartTimestamp != 0 {
+		tx = tx.Where("logs.created_at >= ?", f.StartTimestamp)
+	}
+	if f.EndTimestamp != 0 {
+		tx = tx.Where("logs.created_at <= ?", f.EndTimestamp)
+	}
+	if f.Channel != 0 {
+		tx = tx.Where("logs.channel_id = ?", f.Channel)
+	}
+	if f.Group != "" {
+		tx = tx.Where("logs."+logGroupCol+" = ?", f.Group)
+	}
+	return tx
+}
+
+// ExportLogs 按 id 倒序分批读取日志并交给 fn 处理，使用游标分页，内存占用与总行数无关。
+// 按用户导出时与 GetUserLogs 一样隐藏渠道名和管理员信息。
+func ExportLogs(ctx context.Context, filter LogExportFilter, batchSize int, fn func(logs []*Log) error) error {
+	channelNames := make(map[int]string)
+	lastId := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		tx := filter.apply(LOG_DB.WithContext(ctx).Model(&Log{}))
+		if lastId != 0 {
+			tx = tx.Where("logs.id < ?", lastId)
+		}
+		var logs []*Log
+		if err := tx.Order("logs.id desc").Limit(batchSize).Find(&logs).Error; err != nil {
+			return err
+		}
+		if len(logs) == 0 {
+			return nil
+		}
+		lastId = logs[len(logs)-1].Id
+
+		if filter.UserId != 0 {
+			formatUserLogs(logs)
+		} else if err := fillExportChannelNames(logs, channelNames); err != nil {
+			return err
+		}
+		if err := fn(logs); err != nil {
+			return err
+		}
+		if len(logs) < batchSize {
+			return nil
+		}
+	}
+}
+
+// fillExportChannelNames 填充渠道名，查询过的渠道缓存在 cache 中
+func fillExportChannelNames(logs []*Log, cache map[int]string) error {
+	missing := types.NewSet[int]()
+	for _, log := range logs {
+		if log.ChannelId == 0 {
+			continue
+		}
+		if _, ok := cache[log.ChannelId]; !ok {
+			missing.Add(log.ChannelId)
+		}
+	}
+	if missing.Len() > 0 {
+		var channels []struct {
+			Id   int    `gorm:"column:id"`
+			Name string `gorm:"column:name"`
+		}
+		if err := DB.Table("channels").Select("id, name").Where("id IN ?", missing.Items()).Find(&channels).Error; err != nil {
+			return err
+		}
+		for _, id := range missing.Items() {
+			cache[id] = ""
+		}
+		for _, channel := range channels {
+			cache[channel.Id] = channel.Name
+		}
+	}
+	for i := range logs {
+		logs[i].ChannelName = cache[logs[i].ChannelId]
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package model
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LogDailyRollup 日志按天汇总，明细过期删除后长周期统计仍然可用
+type LogDailyRollup struct {
+	Id               int    `json:"id"`
+	Day              int64  `json:"day" gorm:"bigint;index:idx_log_rollup_day_type,priority:1"`
+	Type             int    `json:"type" gorm:"index:idx_log_rollup_day_type,priority:2"`
+	UserId           int    `json:"user_id" gorm:"index"`
+	Username         string `json:"username" gorm:"default:''"`
+	TokenId          int    `json:"token_id" gorm:"default:0"`
+	TokenName        string `json:"token_name" gorm:"default:''"`
+	ModelName        string `json:"model_name" gorm:"default:''"`
+	ChannelId        int    `json:"channel" gorm:"default:0"`
+	Group            string `json:"group" gorm:"column:group_name;default:''"`
+	Count            int64  `json:"count" gorm:"default:0"`
+	Quota            int64  `json:"quota" gorm:"default:0"`
+	PromptTokens     int64  `json:"prompt_tokens" gorm:"default:0"`
+	CompletionTokens int64  `json:"completion_tokens" gorm:"default:0"`
+	UseTime          int64  `json:"use_time" gorm:"default:0"`
+}
+
+// LogRollupState 记录每种日志已汇总到的时间点（不含），汇总和更新进度在同一事务中完成
+type LogRollupState struct {
+	Type       int   `json:"type" gorm:"primaryKey;autoIncrement:false"`
+	RolledUpTo int64 `json:"rolled_up_to" gorm:"bigint"`
+}
+
+func GetLogRollupState(logType int) (int64, error) {
+	var state LogRollupState
+	err := LOG_DB.Where("type = ?", logType).Limit(1).Find(&state).Error
+	return state.RolledUpTo, err
+}
+
+// MinLogCreatedAt 返回 [from, before) 内最早的日志时间
+func MinLogCreatedAt(logType int, from int64, before int64) (int64, bool, error) {
+	var minCreatedAt *int64
+	err := LOG_DB.Model(&Log{}).Select("min(created_at)").
+		Where("type = ? and created_at >= ? and created_at < ?", logType, from, before).
+		Scan(&minCreatedAt).Error
+	if err != nil || minCreatedAt == nil {
+		return 0, false, err
+	}
+	return *minCreatedAt, true, nil
+}
+
+// RollupLogs 把 [dayStart, dayEnd) 内的日志汇总为 dayStart 当天的记录，并把汇总进度推进到 dayEnd
+func RollupLogs(logType int, dayStart int64, dayEnd int64) (int, error) {
+	var rows []*LogDailyRollup
+	err := LOG_DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&Log{}).
+			Select("type, user_id, username, token_id, token_name, model_name, channel_id, "+logGroupCol+" as group_name, "+
+				"count(*) as count, sum(quota) as quota, sum(prompt_tokens) as prompt_tokens, sum(completion_tokens) as completion_tokens, sum(use_time) as use_time").
+			Where("type = ? and created_at >= ? and created_at < ?", logType, dayStart, dayEnd).
+			Group("type, user_id, username, token_id, token_name, model_name, channel_id, " + logGroupCol).
+			Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			row.Id = 0
+			row.Day = dayStart
+		}
+		if len(rows) > 0 {
+			if err := tx.CreateInBatches(rows, 200).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rolled_up_to"}),
+		}).Create(&LogRollupState{Type: logType, RolledUpTo: dayEnd}).Error
+	})
+	return len(rows), err
+}
+
+// DeleteLogsBefore 分批删除指定类型在 before 之前的日志
+func DeleteLogsBefore(ctx context.Context, logType int, before int64, limit int) (int64, error) {
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		result := LOG_DB.Where("type = ? and created_at < ?", logType, before).Limit(limit).Delete(&Log{})
+		if result.Error != nil {
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(limit) {
+			return total, nil
+		}
+	}
+}
+
+type LogRollupQuery struct {
+	Type           int
+	StartTimestamp int64
+	EndTimestamp   int64
+	Username       string
+	TokenName      string
+	ModelName      string
+	Channel        int
+	Group          string
+}
+
+// GetLogRollupStats 按天和模型汇总 rollup 数据
+func GetLogRollupStats(query LogRollupQuery) ([]*LogDailyRollup, error) {
+	tx := LOG_DB.Model(&LogDailyRollup{})
+	if query.Type != LogTypeUnknown {
+		tx = tx.Where("type = ?", query.Type)
+	}
+	if query.StartTimestamp != 0 {
+		tx = tx.Where("day >= ?", query.StartTimestamp)
+	}
+	if query.EndTimestamp != 0 {
+		tx = tx.Where("day <= ?", query.EndTimestamp)
+	}
+	if query.Username != "" {
+		tx = tx.Where("username = ?", query.Username)
+	}
+	if query.TokenName != "" {
+		tx = tx.Where("token_name = ?", query.TokenName)
+	}
+	if query.ModelName != "" {
+		tx = tx.Where("model_name like ?", query.ModelName)
+	}
+	if query.Channel != 0 {
+		tx = tx.Where("channel_id = ?", query.Channel)
+	}
+	if query.Group != "" {
+		tx = tx.Where("group_name = ?", query.Group)
+	}
+	var stats []*LogDailyRollup
+	err := tx.Select("day, model_name, sum(count) as count, sum(quota) as quota, sum(prompt_tokens) as prompt_tokens, " +
+		"sum(completion_tokens) as completion_tokens, sum(use_time) as use_time").
+		Group("day, model_name").Order("day asc").Scan(&stats).Error
+	return stats, err
+}
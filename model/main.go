@@ -213,7 +213,11 @@ func InitDB() (err error) {
 func InitLogDB() (err error) {
 	if os.Getenv("LOG_SQL_DSN") == "" {
 		LOG_DB = DB
-		return
+		// 与主库共用时，日志汇总表仍在这里迁移
+		if !common.IsMasterNode {
+			return
+		}
+		return migrateLOGDB()
 	}
 	db, err := chooseDB("LOG_SQL_DSN", true)
 	if err == nil {
@@ -273,6 +277,12 @@ func migrateDB() error {
 		&HighActiveTaskRecord{},
 		&InvitationCode{},
 		&InvitationCodeUsageLog{},
+		&File{},
+		&FileUpstream{},
+		&Batch{},
+		&QuotaWindowUsage{},
+		&AuditLog{},
+		&StoredResponse{},
 	)
 	if err != nil {
 		return err
@@ -337,7 +347,7 @@ func migrateDBFast() error {
 
 func migrateLOGDB() error {
 	var err error
-	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
+	if err = LOG_DB.AutoMigrate(&Log{}, &LogDailyRollup{}, &LogRollupState{}); err != nil {
 		return err
 	}
 	return nil
@@ -33,6 +33,12 @@ func initModelHealthWriter() {
 	}
 }
 
+// ModelHealthQueueDepth 返回等待写入的健康事件数
+func ModelHealthQueueDepth() int {
+	modelHealthOnce.Do(initModelHealthWriter)
+	return len(modelHealthQueue)
+}
+
 func RecordModelHealthEventAsync(_ any, event *ModelHealthEvent) {
 	if event == nil {
 		return
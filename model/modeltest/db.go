@@ -0,0 +1,39 @@
+// Package modeltest 提供其他包测试中使用的数据库环境
+package modeltest
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// SetupDB 使用内存 SQLite 作为主库和日志库，迁移 models 并在测试结束后恢复
+func SetupDB(t testing.TB, models ...any) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 内存数据库只在同一个连接内可见
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	// 测试中没有 Redis，额度缓存在测试结束后仍可能被异步更新，因此不恢复
+	common.RedisEnabled = false
+	originDB, originLogDB, originSQLite := model.DB, model.LOG_DB, common.UsingSQLite
+	model.DB, model.LOG_DB, common.UsingSQLite = db, db, true
+	t.Cleanup(func() {
+		model.DB, model.LOG_DB, common.UsingSQLite = originDB, originLogDB, originSQLite
+		_ = sqlDB.Close()
+	})
+	return db
+}
@@ -0,0 +1,115 @@
+package model
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	SpendLimitWindowCalendar = "calendar"
+	SpendLimitWindowRolling  = "rolling"
+
+	QuotaWindowSubjectToken = "token"
+	QuotaWindowSubjectUser  = "user"
+
+	// 用量按小时分桶记录
+	QuotaWindowBucketSeconds = 3600
+)
+
+// SpendLimit 令牌或用户按时间窗口的消费上限，单位为额度，0 表示不限制
+type SpendLimit struct {
+	Daily   int
+	Weekly  int
+	Monthly int
+	// 为 true 时使用滚动窗口（最近 24 小时、7 天、30 天），否则按自然日、周、月重置
+	Rolling bool
+}
+
+func (l SpendLimit) Enabled() bool {
+	return l.Daily > 0 || l.Weekly > 0 || l.Monthly > 0
+}
+
+func (token *Token) GetSpendLimit() SpendLimit {
+	return SpendLimit{
+		Daily:   token.DailyQuotaLimit,
+		Weekly:  token.WeeklyQuotaLimit,
+		Monthly: token.MonthlyQuotaLimit,
+		Rolling: token.QuotaLimitWindow == SpendLimitWindowRolling,
+	}
+}
+
+func (user *UserBase) GetSpendLimit() SpendLimit {
+	return SpendLimit{
+		Daily:   user.DailyQuotaLimit,
+		Weekly:  user.WeeklyQuotaLimit,
+		Monthly: user.MonthlyQuotaLimit,
+		Rolling: user.QuotaLimitWindow == SpendLimitWindowRolling,
+	}
+}
+
+// QuotaWindowUsage 令牌或用户每小时的消费额度，用于计算时间窗口内的用量
+type QuotaWindowUsage struct {
+	SubjectType string `gorm:"type:varchar(8);primaryKey"`
+	SubjectId   int    `gorm:"primaryKey;autoIncrement:false"`
+	Bucket      int64  `gorm:"primaryKey;autoIncrement:false"` // 小时起始时间戳
+	Used        int    `gorm:"default:0"`
+}
+
+// RecordQuotaWindowUsage 把消费额度累加到 timestamp 所在的小时，delta 为负数时表示退还
+func RecordQuotaWindowUsage(subjectType string, subjectId int, timestamp int64, delta int) error {
+	usage := QuotaWindowUsage{
+		SubjectType: subjectType,
+		SubjectId:   subjectId,
+		Bucket:      timestamp - timestamp%QuotaWindowBucketSeconds,
+		Used:        delta,
+	}
+	return DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "subject_type"}, {Name: "subject_id"}, {Name: "bucket"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"used": gorm.Expr("quota_window_usages.used + ?", delta),
+		}),
+	}).Create(&usage).Error
+}
+
+// SumQuotaWindowUsages 分别统计从 starts 中各个时间开始到现在的用量
+func SumQuotaWindowUsages(subjectType string, subjectId int, starts []int64) ([]int, error) {
+	result := make([]int, len(starts))
+	if len(starts) == 0 {
+		return result, nil
+	}
+	since := starts[0]
+	for _, start := range starts {
+		since = min(since, start)
+	}
+	var usages []QuotaWindowUsage
+	err := DB.Select("bucket", "used").
+		Where("subject_type = ? AND subject_id = ? AND bucket >= ?", subjectType, subjectId, since-since%QuotaWindowBucketSeconds).
+		Find(&usages).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, usage := range usages {
+		for i, start := range starts {
+			// 窗口起点所在的小时整体计入窗口
+			if usage.Bucket+QuotaWindowBucketSeconds > start {
+				result[i] += usage.Used
+			}
+		}
+	}
+	return result, nil
+}
+
+// GetQuotaWindowFirstBucket 返回 since 之后第一个有用量的小时，没有用量时返回 0
+func GetQuotaWindowFirstBucket(subjectType string, subjectId int, since int64) (int64, error) {
+	var usage QuotaWindowUsage
+	err := DB.Select("bucket").
+		Where("subject_type = ? AND subject_id = ? AND bucket >= ? AND used > 0", subjectType, subjectId, since-since%QuotaWindowBucketSeconds).
+		Order("bucket asc").Limit(1).Find(&usage).Error
+	return usage.Bucket, err
+}
+
+// DeleteQuotaWindowUsagesBefore 删除 timestamp 之前的用量记录
+func DeleteQuotaWindowUsagesBefore(timestamp int64) (int64, error) {
+	result := DB.Where("bucket < ?", timestamp).Delete(&QuotaWindowUsage{})
+	return result.RowsAffected, result.Error
+}
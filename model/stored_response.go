@@ -0,0 +1,165 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"slices"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"gorm.io/gorm"
+)
+
+// StoredResponse 网关保存的 Responses API 响应，用于 previous_response_id 重建上下文以及查询、删除响应
+type StoredResponse struct {
+	Id                 int    `json:"-" gorm:"primaryKey;autoIncrement"`
+	ResponseId         string `json:"id" gorm:"type:varchar(64);uniqueIndex;not null"`
+	UserId             int    `json:"-" gorm:"index;not null"`
+	PreviousResponseId string `json:"previous_response_id" gorm:"type:varchar(64)"`
+	// 同一条 previous_response_id 链上的响应共用第一个响应的 id，用于一次查出整条链
+	ConversationId string `json:"-" gorm:"type:varchar(64);index"`
+	Model          string `json:"model" gorm:"type:varchar(255)"`
+	Status         string `json:"status" gorm:"type:varchar(16)"`
+	// 本轮请求的 input item，不含历史轮次
+	InputItems json.RawMessage `json:"-" gorm:"type:json"`
+	// 完整的 Responses 响应对象
+	Response  json.RawMessage `json:"-" gorm:"type:json"`
+	CreatedAt int64           `json:"created_at" gorm:"bigint"`
+	// 后台响应执行期间定期更新，用于发现中断的任务
+	UpdatedAt int64 `json:"-" gorm:"bigint;index"`
+	// 过期时间，0 表示不过期
+	ExpiresAt int64 `json:"-" gorm:"bigint;index"`
+	// 后台响应提交时预扣且尚未结算的额度
+	Quota int `json:"-" gorm:"default:0"`
+	// 后台响应提交时的计费信息，用于其他节点退还预扣的额度
+	Billing StoredResponseBilling `json:"-" gorm:"type:json"`
+}
+
+// 后台响应未结束时的状态
+var storedResponseActiveStatuses = []string{dto.ResponsesStatusQueued, dto.ResponsesStatusInProgress}
+
+type StoredResponseBilling struct {
+	TokenId         int    `json:"token_id,omitempty"`
+	Group           string `json:"group,omitempty"`
+	IsPlayground    bool   `json:"is_playground,omitempty"`
+	TrackTokenSpend bool   `json:"track_token_spend,omitempty"`
+	TrackUserSpend  bool   `json:"track_user_spend,omitempty"`
+}
+
+func (b *StoredResponseBilling) Scan(val interface{}) error {
+	var bytesValue []byte
+	switch v := val.(type) {
+	case []byte:
+		bytesValue = v
+	case string:
+		bytesValue = []byte(v)
+	}
+	if len(bytesValue) == 0 {
+		*b = StoredResponseBilling{}
+		return nil
+	}
+	return json.Unmarshal(bytesValue, b)
+}
+
+func (b StoredResponseBilling) Value() (driver.Value, error) {
+	if b == (StoredResponseBilling{}) {
+		return nil, nil
+	}
+	return json.Marshal(b)
+}
+
+func (StoredResponse) TableName() string {
+	return "stored_responses"
+}
+
+func InsertStoredResponse(response *StoredResponse) error {
+	if response.CreatedAt == 0 {
+		response.CreatedAt = common.GetTimestamp()
+	}
+	response.UpdatedAt = response.CreatedAt
+	return DB.Create(response).Error
+}
+
+// GetUserStoredResponse 获取用户保存的响应，不存在、已过期或不属于该用户时返回 nil
+func GetUserStoredResponse(userId int, responseId string) (*StoredResponse, error) {
+	var response StoredResponse
+	err := DB.Where("response_id = ? AND user_id = ?", responseId, userId).
+		Where("expires_at = 0 OR expires_at > ?", common.GetTimestamp()).
+		First(&response).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetConversationStoredResponses 获取会话中不晚于指定响应的所有响应
+func GetConversationStoredResponses(userId int, conversationId string, maxId int) ([]*StoredResponse, error) {
+	var responses []*StoredResponse
+	err := DB.Where("user_id = ? AND conversation_id = ? AND id <= ?", userId, conversationId, maxId).
+		Where("expires_at = 0 OR expires_at > ?", common.GetTimestamp()).
+		Find(&responses).Error
+	return responses, err
+}
+
+func DeleteStoredResponse(response *StoredResponse) error {
+	return DB.Delete(response).Error
+}
+
+// TrimUserStoredResponses 只保留用户最近的 keep 个响应，未结束的后台响应不删除，由执行节点结算预扣的额度
+func TrimUserStoredResponses(userId int, keep int) (int64, error) {
+	var boundary StoredResponse
+	err := DB.Where("user_id = ?", userId).Order("id desc").Offset(keep).Limit(1).Select("id").Take(&boundary).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	result := DB.Where("user_id = ? AND id <= ? AND status NOT IN ?", userId, boundary.Id, storedResponseActiveStatuses).Delete(&StoredResponse{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteExpiredStoredResponses 分批删除已过期的响应
+func DeleteExpiredStoredResponses(limit int) (int64, error) {
+	var ids []int
+	err := DB.Model(&StoredResponse{}).Where("expires_at > 0 AND expires_at <= ?", common.GetTimestamp()).
+		Where("status NOT IN ?", storedResponseActiveStatuses).Limit(limit).Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	result := DB.Where("id IN ?", ids).Delete(&StoredResponse{})
+	return result.RowsAffected, result.Error
+}
+
+// UpdateStoredResponseResult 响应状态为 fromStatuses 之一时更新状态和响应对象，返回是否更新成功。
+// 变为已结束的状态时同时清零预扣的额度，更新成功的一方负责结算这部分额度
+func UpdateStoredResponseResult(id int, status string, response json.RawMessage, fromStatuses ...string) (bool, error) {
+	updates := map[string]any{
+		"status":     status,
+		"response":   response,
+		"updated_at": common.GetTimestamp(),
+	}
+	if !slices.Contains(storedResponseActiveStatuses, status) {
+		updates["quota"] = 0
+	}
+	result := DB.Model(&StoredResponse{}).Where("id = ? AND status IN ?", id, fromStatuses).Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// TouchStoredResponse 响应状态为 statuses 之一时刷新更新时间，响应已被取消或删除时返回 false
+func TouchStoredResponse(id int, statuses ...string) (bool, error) {
+	result := DB.Model(&StoredResponse{}).Where("id = ? AND status IN ?", id, statuses).
+		Update("updated_at", common.GetTimestamp())
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetStaleStoredResponses 获取状态为 statuses 之一且在 before 之前没有更新过的响应
+func GetStaleStoredResponses(before int64, limit int, statuses ...string) ([]*StoredResponse, error) {
+	var responses []*StoredResponse
+	err := DB.Where("status IN ? AND updated_at < ?", statuses, before).Order("id").Limit(limit).Find(&responses).Error
+	return responses, err
+}
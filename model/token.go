@@ -27,6 +27,14 @@ type Token struct {
 	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
 	Group              string         `json:"group" gorm:"default:''"`
 	CrossGroupRetry    bool           `json:"cross_group_retry" gorm:"default:false"` // 跨分组重试，仅auto分组有效
+	ResponseCache      bool           `json:"response_cache" gorm:"default:false"`    // 启用响应缓存
+	ResponseCacheTTL   int            `json:"response_cache_ttl" gorm:"default:0"`    // 响应缓存秒数，0 使用全局默认值
+	DailyQuotaLimit    int            `json:"daily_quota_limit" gorm:"default:0"`     // 每日消费上限，0 表示不限制
+	WeeklyQuotaLimit   int            `json:"weekly_quota_limit" gorm:"default:0"`    // 每周消费上限，0 表示不限制
+	MonthlyQuotaLimit  int            `json:"monthly_quota_limit" gorm:"default:0"`   // 每月消费上限，0 表示不限制
+	QuotaLimitWindow   string         `json:"quota_limit_window" gorm:"default:''"`   // rolling 为滚动窗口，否则按自然日/周/月重置
+	RpmLimit           int            `json:"rpm_limit" gorm:"default:0"`             // 每分钟请求数上限，0 表示不限制
+	TpmLimit           int            `json:"tpm_limit" gorm:"default:0"`             // 每分钟 token 数上限，0 表示不限制
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -190,7 +198,10 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry",
+		"response_cache", "response_cache_ttl",
+		"daily_quota_limit", "weekly_quota_limit", "monthly_quota_limit", "quota_limit_window",
+		"rpm_limit", "tpm_limit").Updates(token).Error
 	return err
 }
 
@@ -51,6 +51,12 @@ type User struct {
 	StripeCustomer   string         `json:"stripe_customer" gorm:"type:varchar(64);column:stripe_customer;index"`
 	BannedAt         int64          `json:"banned_at" gorm:"type:bigint;default:0;column:banned_at"`
 	BanDuration      int64          `json:"ban_duration" gorm:"type:bigint;default:0;column:ban_duration"` // 秒，0=永久
+
+	// 按时间窗口的消费上限，0 表示不限制
+	DailyQuotaLimit   int    `json:"daily_quota_limit" gorm:"type:int;default:0"`
+	WeeklyQuotaLimit  int    `json:"weekly_quota_limit" gorm:"type:int;default:0"`
+	MonthlyQuotaLimit int    `json:"monthly_quota_limit" gorm:"type:int;default:0"`
+	QuotaLimitWindow  string `json:"quota_limit_window" gorm:"type:varchar(16);default:''"` // rolling 为滚动窗口，否则按自然日/周/月重置
 }
 
 func (user *User) ToBaseUser() *UserBase {
@@ -64,6 +70,11 @@ func (user *User) ToBaseUser() *UserBase {
 		Email:       user.Email,
 		BannedAt:    user.BannedAt,
 		BanDuration: user.BanDuration,
+
+		DailyQuotaLimit:   user.DailyQuotaLimit,
+		WeeklyQuotaLimit:  user.WeeklyQuotaLimit,
+		MonthlyQuotaLimit: user.MonthlyQuotaLimit,
+		QuotaLimitWindow:  user.QuotaLimitWindow,
 	}
 	return cache
 }
@@ -475,6 +486,11 @@ func (user *User) Edit(updatePassword bool) error {
 		"group":        newUser.Group,
 		"quota":        newUser.Quota,
 		"remark":       newUser.Remark,
+
+		"daily_quota_limit":   newUser.DailyQuotaLimit,
+		"weekly_quota_limit":  newUser.WeeklyQuotaLimit,
+		"monthly_quota_limit": newUser.MonthlyQuotaLimit,
+		"quota_limit_window":  newUser.QuotaLimitWindow,
 	}
 	if updatePassword {
 		updates["password"] = newUser.Password
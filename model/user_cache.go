@@ -24,6 +24,11 @@ type UserBase struct {
 	Setting     string `json:"setting"`
 	BannedAt    int64  `json:"banned_at"`
 	BanDuration int64  `json:"ban_duration"`
+
+	DailyQuotaLimit   int    `json:"daily_quota_limit"`
+	WeeklyQuotaLimit  int    `json:"weekly_quota_limit"`
+	MonthlyQuotaLimit int    `json:"monthly_quota_limit"`
+	QuotaLimitWindow  string `json:"quota_limit_window"`
 }
 
 func (user *UserBase) WriteContext(c *gin.Context) {
@@ -33,6 +38,7 @@ func (user *UserBase) WriteContext(c *gin.Context) {
 	common.SetContextKey(c, constant.ContextKeyUserEmail, user.Email)
 	common.SetContextKey(c, constant.ContextKeyUserName, user.Username)
 	common.SetContextKey(c, constant.ContextKeyUserSetting, user.GetSetting())
+	common.SetContextKey(c, constant.ContextKeyUserSpendLimit, user.GetSpendLimit())
 }
 
 func (user *UserBase) GetSetting() dto.UserSetting {
@@ -116,6 +122,11 @@ func GetUserCache(userId int) (userCache *UserBase, err error) {
 		Email:       user.Email,
 		BannedAt:    user.BannedAt,
 		BanDuration: user.BanDuration,
+
+		DailyQuotaLimit:   user.DailyQuotaLimit,
+		WeeklyQuotaLimit:  user.WeeklyQuotaLimit,
+		MonthlyQuotaLimit: user.MonthlyQuotaLimit,
+		QuotaLimitWindow:  user.QuotaLimitWindow,
 	}
 
 	return userCache, nil
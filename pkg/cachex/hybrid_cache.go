@@ -7,6 +7,8 @@ import (
 	"sync"
 	"time"
 
+	"github.com/QuantumNous/new-api/pkg/metrics"
+
 	"github.com/go-redis/redis/v8"
 	"github.com/samber/hot"
 )
@@ -78,6 +80,12 @@ func (c *HybridCache[V]) memCache() *hot.HotCache[string, V] {
 }
 
 func (c *HybridCache[V]) Get(key string) (value V, found bool, err error) {
+	value, found, err = c.get(key)
+	metrics.ObserveCacheLookup(string(c.ns), found, err)
+	return value, found, err
+}
+
+func (c *HybridCache[V]) get(key string) (value V, found bool, err error) {
 	full := c.ns.FullKey(key)
 	if full == "" {
 		var zero V
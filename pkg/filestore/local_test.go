@@ -0,0 +1,39 @@
+package filestore
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	storage, err := New("local", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := storage.Save("file-abc123", strings.NewReader("hello"))
+	if err != nil || n != 5 {
+		t.Fatalf("save failed: n=%d err=%v", n, err)
+	}
+	r, err := storage.Open("file-abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(r)
+	_ = r.Close()
+	if string(data) != "hello" {
+		t.Fatalf("unexpected content %q", data)
+	}
+
+	if err := storage.Delete("file-abc123"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := storage.Open("file-abc123"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := storage.Save("../escape", strings.NewReader("x")); err == nil {
+		t.Fatal("expected invalid key error")
+	}
+}
@@ -0,0 +1,59 @@
+package filestore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrNotFound 文件不存在
+var ErrNotFound = errors.New("file not found")
+
+// Storage 文件存储后端，key 由调用方生成，只包含字母、数字、- 和 _
+type Storage interface {
+	// Save 保存 r 的全部内容，返回写入的字节数
+	Save(key string, r io.Reader) (int64, error)
+	// Open 打开文件内容，文件不存在时返回 ErrNotFound
+	Open(key string) (io.ReadCloser, error)
+	// Delete 删除文件，文件不存在时不返回错误
+	Delete(key string) error
+}
+
+// Factory 根据后端自定义的配置创建存储后端，例如 local 后端的配置为目录
+type Factory func(config string) (Storage, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = map[string]Factory{}
+)
+
+// Register 注册存储后端，同名后端会被覆盖
+func Register(name string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+	factories[name] = factory
+}
+
+// New 创建指定名称的存储后端
+func New(name string, config string) (Storage, error) {
+	factoriesMu.RLock()
+	factory, ok := factories[name]
+	factoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown file storage backend: %s", name)
+	}
+	return factory(config)
+}
+
+func validKey(key string) bool {
+	if key == "" || len(key) > 128 {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+// Package metrics exposes internal counters in the Prometheus text format.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "new_api"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	relayRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_requests_total",
+		Help:      "Relay requests by model, final channel, group, relay format and response status.",
+	}, []string{"model", "channel", "group", "format", "status"})
+
+	relayRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "relay_request_duration_seconds",
+		Help:      "Relay request latency including retries.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"model", "channel", "group", "format"})
+
+	relayRetries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "relay_retries_total",
+		Help:      "Relay attempts retried on another channel.",
+	}, []string{"model", "group"})
+
+	upstreamResponses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_responses_total",
+		Help:      "Upstream responses by channel and HTTP status code; code is \"error\" when the request failed without a response.",
+	}, []string{"channel", "code"})
+
+	quotaPreConsumed = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "quota_pre_consumed_total",
+		Help:      "Quota deducted before relaying requests.",
+	})
+
+	quotaRefunded = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "quota_refunded_total",
+		Help:      "Pre-consumed quota returned after failed requests.",
+	})
+
+	channelAutoDisabled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "channel_auto_disabled_total",
+		Help:      "Channels or channel keys disabled automatically after errors.",
+	}, []string{"channel"})
+
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "HybridCache lookups by cache namespace and result (hit, miss or error).",
+	}, []string{"cache", "result"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		relayRequests,
+		relayRequestDuration,
+		relayRetries,
+		upstreamResponses,
+		quotaPreConsumed,
+		quotaRefunded,
+		channelAutoDisabled,
+		cacheRequests,
+	)
+}
+
+// Handler serves all registered metrics.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RegisterGaugeFunc registers a gauge whose value is read on every scrape.
+func RegisterGaugeFunc(name string, help string, fn func() float64) {
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, fn))
+}
+
+func ObserveRelayRequest(model string, channelId int, group string, format string, status int, duration time.Duration) {
+	channel := strconv.Itoa(channelId)
+	relayRequests.WithLabelValues(model, channel, group, format, strconv.Itoa(status)).Inc()
+	relayRequestDuration.WithLabelValues(model, channel, group, format).Observe(duration.Seconds())
+}
+
+func IncRelayRetry(model string, group string) {
+	relayRetries.WithLabelValues(model, group).Inc()
+}
+
+// IncUpstreamResponse records an upstream response; statusCode 0 means no response was received.
+func IncUpstreamResponse(channelId int, statusCode int) {
+	code := "error"
+	if statusCode > 0 {
+		code = strconv.Itoa(statusCode)
+	}
+	upstreamResponses.WithLabelValues(strconv.Itoa(channelId), code).Inc()
+}
+
+func AddQuotaPreConsumed(quota int) {
+	if quota > 0 {
+		quotaPreConsumed.Add(float64(quota))
+	}
+}
+
+func AddQuotaRefunded(quota int) {
+	if quota > 0 {
+		quotaRefunded.Add(float64(quota))
+	}
+}
+
+func IncChannelAutoDisabled(channelId int) {
+	channelAutoDisabled.WithLabelValues(strconv.Itoa(channelId)).Inc()
+}
+
+// ObserveCacheLookup records a cache lookup result.
+func ObserveCacheLookup(cache string, found bool, err error) {
+	result := "miss"
+	if err != nil {
+		result = "error"
+	} else if found {
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(cache, result).Inc()
+}
@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandlerExposesRelayMetrics(t *testing.T) {
+	ObserveRelayRequest("gpt-4o", 3, "default", "openai", 200, 1500*time.Millisecond)
+	ObserveCacheLookup("new-api:response_cache:v1", true, nil)
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`new_api_relay_requests_total{channel="3",format="openai",group="default",model="gpt-4o",status="200"} 1`,
+		`new_api_relay_request_duration_seconds_bucket{channel="3",format="openai",group="default",model="gpt-4o",le="2.5"} 1`,
+		`new_api_cache_requests_total{cache="new-api:response_cache:v1",result="hit"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected metrics output to contain %s", want)
+		}
+	}
+}
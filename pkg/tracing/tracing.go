@@ -0,0 +1,115 @@
+// Package tracing configures OpenTelemetry tracing for the relay pipeline.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/QuantumNous/new-api"
+
+// Attribute keys shared by relay spans.
+const (
+	AttrChannelId  = attribute.Key("newapi.channel.id")
+	AttrModel      = attribute.Key("newapi.model")
+	AttrGroup      = attribute.Key("newapi.group")
+	AttrRetryIndex = attribute.Key("newapi.retry.index")
+	AttrRequestId  = attribute.Key("newapi.request.id")
+	AttrTokenId    = attribute.Key("newapi.token.id")
+	AttrUserId     = attribute.Key("newapi.user.id")
+)
+
+// Config selects the span exporter.
+type Config struct {
+	// Exporter is one of otlp, console, file or none.
+	Exporter string
+	// File is the output path of the file exporter.
+	File        string
+	ServiceName string
+}
+
+// Start installs the global tracer provider and the W3C trace context propagator.
+// The propagator is installed even when tracing is disabled so incoming traceparent
+// headers still reach the upstream.
+func Start(ctx context.Context, cfg Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	shutdown = func(context.Context) error { return nil }
+
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	switch strings.ToLower(strings.TrimSpace(cfg.Exporter)) {
+	case "", "none":
+		return shutdown, nil
+	case "otlp":
+		// endpoint, headers and TLS come from the standard OTEL_EXPORTER_OTLP_* variables
+		exporter, err = otlptracehttp.New(ctx)
+	case "console", "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		if cfg.File == "" {
+			return shutdown, fmt.Errorf("trace file path is empty")
+		}
+		// one JSON span per line
+		file, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err == nil {
+			exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		}
+	default:
+		return shutdown, fmt.Errorf("unknown trace exporter: %s", cfg.Exporter)
+	}
+	if err != nil {
+		return shutdown, err
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "new-api"
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return shutdown, err
+	}
+	// the sampler follows OTEL_TRACES_SAMPLER / OTEL_TRACES_SAMPLER_ARG
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			_ = file.Close()
+		}
+		return err
+	}, nil
+}
+
+// StartSpan starts a span as a child of the span in ctx.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartServerSpan starts the root span of an incoming request, continuing the trace from its traceparent header.
+func StartServerSpan(ctx context.Context, name string, header http.Header, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attrs...))
+}
+
+// StartClientSpan starts a span for an outgoing request and writes its traceparent into header.
+func StartClientSpan(ctx context.Context, name string, header http.Header, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+	return ctx, span
+}
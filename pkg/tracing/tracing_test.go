@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTraceparentPropagatesToUpstream(t *testing.T) {
+	if _, err := Start(context.Background(), Config{Exporter: "none"}); err != nil {
+		t.Fatal(err)
+	}
+	provider := sdktrace.NewTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer provider.Shutdown(context.Background())
+
+	const traceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+	incoming := http.Header{}
+	incoming.Set("traceparent", "00-"+traceId+"-00f067aa0ba902b7-01")
+
+	ctx, server := StartServerSpan(context.Background(), "POST /v1/chat/completions", incoming)
+	defer server.End()
+	if got := server.SpanContext().TraceID().String(); got != traceId {
+		t.Fatalf("expected server span to continue trace %s, got %s", traceId, got)
+	}
+
+	upstream := http.Header{}
+	_, client := StartClientSpan(ctx, "upstream_request", upstream)
+	defer client.End()
+	traceparent := upstream.Get("traceparent")
+	if !strings.Contains(traceparent, traceId) || !strings.Contains(traceparent, client.SpanContext().SpanID().String()) {
+		t.Fatalf("expected upstream traceparent with trace %s and client span id, got %q", traceId, traceparent)
+	}
+}
+
+func TestStartUnknownExporter(t *testing.T) {
+	if _, err := Start(context.Background(), Config{Exporter: "zipkin"}); err == nil {
+		t.Fatal("expected error for unknown exporter")
+	}
+}
@@ -12,6 +12,8 @@ import (
 
 	common2 "github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/pkg/metrics"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	"github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
@@ -22,6 +24,8 @@ import (
 	"github.com/bytedance/gopkg/util/gopool"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
 func SetupApiRequestHeader(info *common.RelayInfo, c *gin.Context, req *http.Header) {
@@ -264,6 +268,19 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		client = service.GetHttpClient()
 	}
 
+	if info.UpstreamContext != nil {
+		req = req.WithContext(info.UpstreamContext)
+	}
+
+	// 上游请求 span，并通过 traceparent 请求头把链路传给上游
+	_, span := tracing.StartClientSpan(c.Request.Context(), "upstream_request", req.Header,
+		tracing.AttrChannelId.Int(info.ChannelId),
+		tracing.AttrModel.String(info.UpstreamModelName),
+		attribute.String("http.request.method", req.Method),
+		attribute.String("server.address", req.URL.Host),
+	)
+	defer span.End()
+
 	var stopPinger context.CancelFunc
 	if info.IsStream {
 		helper.SetEventStreamHeaders(c)
@@ -284,20 +301,62 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		}
 	}
 
+	// 流式请求的首字超时：在收到首个数据行之前不会向客户端输出内容（ping 注释除外），超时后取消请求交给重试逻辑换渠道
+	var gate *firstTokenGate
+	firstTokenTimeout := time.Duration(0)
+	if info.IsStream {
+		firstTokenTimeout = operation_setting.GetFirstTokenTimeout(info.OriginModelName, info.ChannelOtherSettings.FirstTokenTimeoutSeconds)
+	}
+	if firstTokenTimeout > 0 {
+		req, gate = startFirstTokenGate(req, firstTokenTimeout)
+	}
+
 	resp, err := client.Do(req)
+	if resp != nil {
+		metrics.IncUpstreamResponse(info.ChannelId, resp.StatusCode)
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode >= http.StatusBadRequest {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	} else {
+		metrics.IncUpstreamResponse(info.ChannelId, 0)
+	}
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		if gate != nil && gate.timedOut() {
+			return nil, newFirstTokenTimeoutError(c, info, firstTokenTimeout)
+		}
+		if gate != nil {
+			gate.stop()
+			gate.cancel()
+		}
 		logger.LogError(c, "do request failed: "+err.Error())
 		return nil, types.NewError(err, types.ErrorCodeDoRequestFailed, types.ErrOptionWithHideErrMsg("upstream error: do request failed"))
 	}
 	if resp == nil {
 		return nil, errors.New("resp is nil")
 	}
+	if gate != nil {
+		if resp.StatusCode != http.StatusOK {
+			// 错误响应交给后续处理，不再计时
+			gate.stop()
+			resp.Body = &firstTokenBody{Reader: resp.Body, body: resp.Body, cancel: gate.cancel}
+		} else if !gate.awaitFirstToken(resp) {
+			_ = resp.Body.Close()
+			return nil, newFirstTokenTimeoutError(c, info, firstTokenTimeout)
+		}
+	}
 
 	_ = req.Body.Close()
 	_ = c.Request.Body.Close()
 	return resp, nil
 }
 
+func newFirstTokenTimeoutError(c *gin.Context, info *common.RelayInfo, timeout time.Duration) *types.NewAPIError {
+	logger.LogWarn(c, fmt.Sprintf("first token timeout after %s: channel #%d, model %s", timeout, info.ChannelId, info.OriginModelName))
+	return types.NewErrorWithStatusCode(fmt.Errorf("upstream did not return the first token within %s", timeout), types.ErrorCodeFirstTokenTimeout, http.StatusGatewayTimeout)
+}
+
 func DoTaskApiRequest(a TaskAdaptor, c *gin.Context, info *common.RelayInfo, requestBody io.Reader) (*http.Response, error) {
 	fullRequestURL, err := a.BuildRequestURL(info)
 	if err != nil {
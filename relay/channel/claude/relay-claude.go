@@ -19,6 +19,7 @@ import (
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tidwall/sjson"
 )
 
 const (
@@ -691,6 +692,15 @@ func HandleStreamResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 	if info.RelayFormat == types.RelayFormatClaude {
 		FormatClaudeResponseInfo(requestMode, &claudeResponse, nil, claudeInfo)
 
+		// 续写时拼接到客户端已有的响应流中
+		send, remapped := info.SpliceClaudeStreamEvent(&claudeResponse)
+		if !send {
+			return nil
+		}
+		if remapped {
+			data, _ = sjson.Set(data, "index", claudeResponse.GetIndex())
+		}
+
 		if requestMode == RequestModeCompletion {
 		} else {
 			if claudeResponse.Type == "message_start" {
@@ -792,6 +802,10 @@ func ClaudeStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 		}
 		return true
 	})
+	// 上游响应流中途断开或返回错误事件时交给重试逻辑换渠道续写
+	if failoverErr := streamFailoverError(c, info, claudeInfo, requestMode); failoverErr != nil {
+		return nil, failoverErr
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,33 @@
+package claude
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamFailoverError 判断中途断开的 Claude messages 流式响应能否换渠道续写。
+// 可以续写时记录已发送的内容并返回 ErrorCodeStreamInterrupted，不结束客户端的响应流
+func streamFailoverError(c *gin.Context, info *relaycommon.RelayInfo, claudeInfo *ClaudeResponseInfo, requestMode int) *types.NewAPIError {
+	if info.RelayFormat != types.RelayFormatClaude || requestMode != RequestModeMessage || claudeInfo.Done {
+		return nil
+	}
+	if c.Request.Context().Err() != nil || !info.CanStreamFailover() || !info.CanClaudeStreamFailover() {
+		return nil
+	}
+	// 只有文本内容块时 ResponseText 即为发送给客户端的文本
+	partialText := claudeInfo.ResponseText.String()
+	if partialText == "" {
+		return nil
+	}
+	delivered := service.ResponseText2Usage(c, partialText, info.UpstreamModelName, 0).CompletionTokens
+	info.MarkStreamInterrupted(partialText, claudeInfo.Usage.PromptTokens, delivered)
+	logger.LogWarn(c, "upstream stream interrupted, trying to continue on another channel")
+	return types.NewErrorWithStatusCode(errors.New("upstream stream interrupted"), types.ErrorCodeStreamInterrupted, http.StatusBadGateway)
+}
@@ -55,6 +55,12 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 		switch info.RelayMode {
 		case constant.RelayModeCompletions:
 			return fmt.Sprintf("%s/completions", fimBaseUrl), nil
+		case constant.RelayModeChatCompletions:
+			// 续写使用的对话前缀续写（prefix）只在 beta 接口可用
+			if info.IsStreamContinuation() {
+				return fmt.Sprintf("%s/chat/completions", fimBaseUrl), nil
+			}
+			return fmt.Sprintf("%s/v1/chat/completions", info.ChannelBaseUrl), nil
 		default:
 			return fmt.Sprintf("%s/v1/chat/completions", info.ChannelBaseUrl), nil
 		}
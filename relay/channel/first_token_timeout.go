@@ -0,0 +1,108 @@
+package channel
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// 等待首字时最多缓存的字节数，超过后视为已收到数据
+const firstTokenPeekLimit = 64 * 1024
+
+const (
+	firstTokenPending int32 = iota
+	firstTokenReceived
+	firstTokenTimedOut
+)
+
+// firstTokenGate 流式请求的首字计时器，在收到首个数据行之前超时会取消上游请求
+type firstTokenGate struct {
+	timer  *time.Timer
+	cancel context.CancelFunc
+	state  atomic.Int32
+}
+
+func startFirstTokenGate(req *http.Request, timeout time.Duration) (*http.Request, *firstTokenGate) {
+	ctx, cancel := context.WithCancel(req.Context())
+	gate := &firstTokenGate{cancel: cancel}
+	gate.timer = time.AfterFunc(timeout, func() {
+		if gate.state.CompareAndSwap(firstTokenPending, firstTokenTimedOut) {
+			cancel()
+		}
+	})
+	return req.WithContext(ctx), gate
+}
+
+// stop 停止计时，返回 false 表示已经超时
+func (g *firstTokenGate) stop() bool {
+	g.timer.Stop()
+	return g.state.CompareAndSwap(firstTokenPending, firstTokenReceived)
+}
+
+func (g *firstTokenGate) timedOut() bool {
+	return g.state.Load() == firstTokenTimedOut
+}
+
+// awaitFirstToken 读取上游响应直到出现首个数据行，返回 false 表示首字超时，此时上游请求已被取消
+func (g *firstTokenGate) awaitFirstToken(resp *http.Response) bool {
+	// EOF 或读取错误交给后续的响应处理
+	_ = PeekFirstStreamData(resp)
+	ok := g.stop()
+	resp.Body = &firstTokenBody{
+		Reader: resp.Body,
+		body:   resp.Body,
+		cancel: g.cancel,
+	}
+	return ok
+}
+
+// PeekFirstStreamData 读取流式响应直到出现首个非空且不是 SSE 注释的行，已读取的内容会放回响应体。
+// 在首个数据行之前读取失败时返回错误
+func PeekFirstStreamData(resp *http.Response) error {
+	reader := bufio.NewReader(resp.Body)
+	var peeked bytes.Buffer
+	var readErr error
+	for peeked.Len() < firstTokenPeekLimit {
+		line, err := reader.ReadSlice('\n')
+		peeked.Write(line)
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 && trimmed[0] != ':' {
+			break
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			readErr = err
+			break
+		}
+	}
+	resp.Body = &peekedBody{
+		Reader: io.MultiReader(&peeked, reader),
+		body:   resp.Body,
+	}
+	return readErr
+}
+
+// peekedBody 先返回已读取的内容，再继续读取原响应体
+type peekedBody struct {
+	io.Reader
+	body io.ReadCloser
+}
+
+func (b *peekedBody) Close() error {
+	return b.body.Close()
+}
+
+// firstTokenBody 关闭时同时释放首字计时使用的 context
+type firstTokenBody struct {
+	io.Reader
+	body   io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *firstTokenBody) Close() error {
+	err := b.body.Close()
+	b.cancel()
+	return err
+}
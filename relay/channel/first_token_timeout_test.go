@@ -0,0 +1,72 @@
+package channel
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newFirstTokenTestServer(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(w, ": keep-alive\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = io.WriteString(w, "data: {\"id\":1}\n\ndata: [DONE]\n\n")
+	}))
+}
+
+func doFirstTokenTestRequest(t *testing.T, url string, timeout time.Duration) (*http.Response, bool) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, gate := startFirstTokenGate(req, timeout)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, gate.awaitFirstToken(resp)
+}
+
+func TestFirstTokenGate(t *testing.T) {
+	t.Run("first token in time keeps the whole body", func(t *testing.T) {
+		server := newFirstTokenTestServer(10 * time.Millisecond)
+		defer server.Close()
+
+		resp, ok := doFirstTokenTestRequest(t, server.URL, time.Second)
+		defer resp.Body.Close()
+		if !ok {
+			t.Fatal("expected first token before timeout")
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := ": keep-alive\n\ndata: {\"id\":1}\n\ndata: [DONE]\n\n"
+		if string(body) != expected {
+			t.Fatalf("unexpected body %q", body)
+		}
+	})
+
+	t.Run("comment lines do not count as first token", func(t *testing.T) {
+		server := newFirstTokenTestServer(time.Second)
+		defer server.Close()
+
+		start := time.Now()
+		resp, ok := doFirstTokenTestRequest(t, server.URL, 50*time.Millisecond)
+		resp.Body.Close()
+		if ok {
+			t.Fatal("expected first token timeout")
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Fatalf("request was not cancelled in time: %v", elapsed)
+		}
+	})
+}
@@ -0,0 +1,742 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// claudeRequestConverter 保存转换 Claude 请求时跨消息的状态
+type claudeRequestConverter struct {
+	c        *gin.Context
+	info     *relaycommon.RelayInfo
+	request  *dto.ClaudeRequest
+	imageNum int
+}
+
+// ClaudeToGeminiRequest 将 Claude Messages 请求直接转换为 Gemini generateContent 请求，
+// 不经过 OpenAI 格式，保留思考签名、文档和工具调用结果的结构
+func ClaudeToGeminiRequest(c *gin.Context, claudeRequest *dto.ClaudeRequest, info *relaycommon.RelayInfo) (*dto.GeminiChatRequest, error) {
+	geminiRequest := &dto.GeminiChatRequest{
+		Contents: make([]dto.GeminiChatContent, 0, len(claudeRequest.Messages)),
+		GenerationConfig: dto.GeminiChatGenerationConfig{
+			Temperature:     claudeRequest.Temperature,
+			TopP:            claudeRequest.TopP,
+			TopK:            float64(claudeRequest.TopK),
+			MaxOutputTokens: claudeRequest.MaxTokens,
+			StopSequences:   claudeRequest.StopSequences,
+		},
+		SafetySettings: geminiSafetySettings(),
+	}
+	if model_setting.IsGeminiModelSupportImagine(info.UpstreamModelName) {
+		geminiRequest.GenerationConfig.ResponseModalities = []string{
+			"TEXT",
+			"IMAGE",
+		}
+	}
+
+	if claudeRequest.Thinking != nil {
+		claudeThinkingToGemini(geminiRequest, claudeRequest.Thinking, info.UpstreamModelName)
+	} else {
+		ThinkingAdaptor(geminiRequest, info)
+	}
+
+	if tools := claudeToolsToGemini(claudeRequest.GetTools()); len(tools) > 0 {
+		geminiRequest.SetTools(tools)
+		geminiRequest.ToolConfig = claudeToolChoiceToGemini(claudeRequest.ToolChoice)
+	}
+
+	converter := &claudeRequestConverter{c: c, info: info, request: claudeRequest}
+	systemParts, err := converter.systemParts()
+	if err != nil {
+		return nil, err
+	}
+	if len(systemParts) > 0 {
+		geminiRequest.SystemInstructions = &dto.GeminiChatContent{Parts: systemParts}
+	}
+
+	for _, message := range claudeRequest.Messages {
+		content, err := converter.messageContent(message)
+		if err != nil {
+			return nil, err
+		}
+		if len(content.Parts) > 0 {
+			geminiRequest.Contents = append(geminiRequest.Contents, content)
+		}
+	}
+	return geminiRequest, nil
+}
+
+// claudeThinkingToGemini 将 Claude 的思考预算映射为 Gemini 的 thinkingConfig
+func claudeThinkingToGemini(geminiRequest *dto.GeminiChatRequest, thinking *dto.Thinking, modelName string) {
+	switch thinking.Type {
+	case "enabled":
+		geminiRequest.GenerationConfig.ThinkingConfig = &dto.GeminiThinkingConfig{
+			IncludeThoughts: true,
+		}
+		if thinking.BudgetTokens != nil {
+			geminiRequest.GenerationConfig.ThinkingConfig.SetThinkingBudget(clampThinkingBudget(modelName, thinking.GetBudgetTokens()))
+		}
+	case "adaptive":
+		// -1 表示由模型动态决定思考预算
+		geminiRequest.GenerationConfig.ThinkingConfig = &dto.GeminiThinkingConfig{
+			IncludeThoughts: true,
+			ThinkingBudget:  common.GetPointer(-1),
+		}
+	case "disabled":
+		// 2.5 Pro 不支持关闭思考，保持默认
+		if !isNew25ProModel(modelName) {
+			geminiRequest.GenerationConfig.ThinkingConfig = &dto.GeminiThinkingConfig{
+				ThinkingBudget: common.GetPointer(0),
+			}
+		}
+	}
+}
+
+// claudeToolsToGemini 转换自定义工具，web_search 映射为 googleSearch，其他 Anthropic 内置工具无法在 Gemini 上执行，直接忽略
+func claudeToolsToGemini(tools []any) []dto.GeminiChatTool {
+	functions := make([]dto.FunctionRequest, 0, len(tools))
+	googleSearch := false
+	for _, tool := range tools {
+		toolMap, ok := tool.(map[string]any)
+		if !ok {
+			continue
+		}
+		toolType, _ := toolMap["type"].(string)
+		if strings.HasPrefix(toolType, "web_search") {
+			googleSearch = true
+			continue
+		}
+		if toolType != "" && toolType != "custom" {
+			continue
+		}
+		claudeTool, err := common.Any2Type[dto.Tool](toolMap)
+		if err != nil || claudeTool.Name == "" {
+			continue
+		}
+		var params any = claudeTool.InputSchema
+		if props, ok := claudeTool.InputSchema["properties"].(map[string]interface{}); !ok || len(props) == 0 {
+			params = nil
+		}
+		functions = append(functions, dto.FunctionRequest{
+			Name:        claudeTool.Name,
+			Description: claudeTool.Description,
+			Parameters:  cleanFunctionParameters(params),
+		})
+	}
+
+	var geminiTools []dto.GeminiChatTool
+	if googleSearch {
+		geminiTools = append(geminiTools, dto.GeminiChatTool{
+			GoogleSearch: make(map[string]string),
+		})
+	}
+	if len(functions) > 0 {
+		geminiTools = append(geminiTools, dto.GeminiChatTool{
+			FunctionDeclarations: functions,
+		})
+	}
+	return geminiTools
+}
+
+func claudeToolChoiceToGemini(toolChoice any) *dto.ToolConfig {
+	if toolChoice == nil {
+		return nil
+	}
+	choice, err := common.Any2Type[dto.ClaudeToolChoice](toolChoice)
+	if err != nil {
+		return nil
+	}
+	config := &dto.FunctionCallingConfig{}
+	switch choice.Type {
+	case "auto":
+		config.Mode = "AUTO"
+	case "any":
+		config.Mode = "ANY"
+	case "tool":
+		config.Mode = "ANY"
+		config.AllowedFunctionNames = []string{choice.Name}
+	case "none":
+		config.Mode = "NONE"
+	default:
+		return nil
+	}
+	return &dto.ToolConfig{FunctionCallingConfig: config}
+}
+
+func (conv *claudeRequestConverter) systemParts() ([]dto.GeminiPart, error) {
+	if conv.request.System == nil {
+		return nil, nil
+	}
+	if conv.request.IsStringSystem() {
+		if text := conv.request.GetStringSystem(); text != "" {
+			return []dto.GeminiPart{{Text: text}}, nil
+		}
+		return nil, nil
+	}
+	var parts []dto.GeminiPart
+	// Gemini 没有按内容块的缓存控制，cache_control 直接丢弃，由隐式缓存命中
+	for _, block := range conv.request.ParseSystem() {
+		if block.Type == dto.ContentTypeText && block.GetText() != "" {
+			parts = append(parts, dto.GeminiPart{Text: block.GetText()})
+		}
+	}
+	return parts, nil
+}
+
+func (conv *claudeRequestConverter) messageContent(message dto.ClaudeMessage) (dto.GeminiChatContent, error) {
+	content := dto.GeminiChatContent{Role: "user"}
+	if message.Role == "assistant" {
+		content.Role = "model"
+	}
+	if message.IsStringContent() {
+		if text := message.GetStringContent(); text != "" {
+			content.Parts = append(content.Parts, dto.GeminiPart{Text: text})
+		}
+		return content, nil
+	}
+	blocks, err := message.ParseContent()
+	if err != nil {
+		return content, fmt.Errorf("invalid content of %s message: %w", message.Role, err)
+	}
+
+	// 思考内容由 Gemini 根据签名恢复，只需把签名回传到思考块之后的第一个 part 上
+	pendingSignature := ""
+	appendPart := func(part dto.GeminiPart) {
+		if pendingSignature != "" {
+			part.ThoughtSignature = json.RawMessage(strconv.Quote(pendingSignature))
+			pendingSignature = ""
+		}
+		content.Parts = append(content.Parts, part)
+	}
+	for _, block := range blocks {
+		switch block.Type {
+		case dto.ContentTypeText:
+			if block.GetText() != "" {
+				appendPart(dto.GeminiPart{Text: block.GetText()})
+			}
+		case "image", "document":
+			part, err := conv.mediaPart(block)
+			if err != nil {
+				return content, err
+			}
+			appendPart(*part)
+		case "thinking":
+			if block.Signature != "" {
+				pendingSignature = block.Signature
+			}
+		case "tool_use":
+			appendPart(dto.GeminiPart{
+				FunctionCall: &dto.FunctionCall{
+					FunctionName: block.Name,
+					Arguments:    claudeToolInput(block.Input),
+				},
+			})
+		case "tool_result":
+			parts, err := conv.toolResultParts(block)
+			if err != nil {
+				return content, err
+			}
+			for _, part := range parts {
+				appendPart(part)
+			}
+		}
+	}
+
+	if content.Role == "model" {
+		conv.attachBypassSignature(content.Parts)
+	}
+	return content, nil
+}
+
+// attachBypassSignature 历史消息没有思考签名时（如来自其他模型），与 OpenAI 格式转换一样附加跳过校验的签名
+func (conv *claudeRequestConverter) attachBypassSignature(parts []dto.GeminiPart) {
+	if conv.info.ChannelType != constant.ChannelTypeGemini && conv.info.ChannelType != constant.ChannelTypeVertexAi {
+		return
+	}
+	if !model_setting.GetGeminiSettings().FunctionCallThoughtSignatureEnabled {
+		return
+	}
+	target := -1
+	for i := range parts {
+		if len(parts[i].ThoughtSignature) > 0 {
+			return
+		}
+		if parts[i].FunctionCall != nil && hasFunctionCallContent(parts[i].FunctionCall) {
+			target = i
+			break
+		}
+		if target < 0 && parts[i].Text != "" {
+			target = i
+		}
+	}
+	if target >= 0 {
+		parts[target].ThoughtSignature = json.RawMessage(strconv.Quote(thoughtSignatureBypassValue))
+	}
+}
+
+func claudeToolInput(input any) any {
+	switch v := input.(type) {
+	case nil:
+		return map[string]interface{}{}
+	case string:
+		var args map[string]interface{}
+		if err := common.UnmarshalJsonStr(v, &args); err == nil {
+			return args
+		}
+		return map[string]interface{}{"input": v}
+	default:
+		return v
+	}
+}
+
+// mediaPart 转换 image 和 document 内容块，PDF 等文件以 inlineData 发送
+func (conv *claudeRequestConverter) mediaPart(block dto.ClaudeMediaMessage) (*dto.GeminiPart, error) {
+	source := block.Source
+	if source == nil {
+		return nil, fmt.Errorf("%s block without source", block.Type)
+	}
+	if block.Type == "image" {
+		conv.imageNum++
+		if constant.GeminiVisionMaxImageNum != -1 && conv.imageNum > constant.GeminiVisionMaxImageNum {
+			return nil, fmt.Errorf("too many images in the message, max allowed is %d", constant.GeminiVisionMaxImageNum)
+		}
+	}
+	switch source.Type {
+	case "base64":
+		data, _ := source.Data.(string)
+		if data == "" {
+			return nil, fmt.Errorf("empty base64 data in %s block", block.Type)
+		}
+		return &dto.GeminiPart{
+			InlineData: &dto.GeminiInlineData{
+				MimeType: source.MediaType,
+				Data:     data,
+			},
+		}, nil
+	case "url":
+		fileData, err := service.GetFileBase64FromUrl(conv.c, source.Url, "formatting "+block.Type+" for Gemini")
+		if err != nil {
+			return nil, fmt.Errorf("get file base64 from url '%s' failed: %w", source.Url, err)
+		}
+		if _, ok := geminiSupportedMimeTypes[strings.ToLower(fileData.MimeType)]; !ok {
+			return nil, fmt.Errorf("mime type is not supported by Gemini: '%s', url: '%s', supported types are: %v", fileData.MimeType, source.Url, getSupportedMimeTypesList())
+		}
+		return &dto.GeminiPart{
+			InlineData: &dto.GeminiInlineData{
+				MimeType: fileData.MimeType,
+				Data:     fileData.Base64Data,
+			},
+		}, nil
+	case "text":
+		data, _ := source.Data.(string)
+		return &dto.GeminiPart{Text: data}, nil
+	default:
+		return nil, fmt.Errorf("%s source type '%s' is not supported by Gemini", block.Type, source.Type)
+	}
+}
+
+// toolResultParts 将 tool_result 转换为 functionResponse，结果中的图片和文档作为后续的 inlineData 发送
+func (conv *claudeRequestConverter) toolResultParts(block dto.ClaudeMediaMessage) ([]dto.GeminiPart, error) {
+	name := block.Name
+	if name == "" {
+		name = conv.request.SearchToolNameByToolCallId(block.ToolUseId)
+	}
+
+	var mediaParts []dto.GeminiPart
+	text := ""
+	if block.IsStringContent() {
+		text = block.GetStringContent()
+	} else {
+		var texts []string
+		for _, item := range block.ParseMediaContent() {
+			switch item.Type {
+			case dto.ContentTypeText:
+				texts = append(texts, item.GetText())
+			case "image", "document":
+				part, err := conv.mediaPart(item)
+				if err != nil {
+					return nil, err
+				}
+				mediaParts = append(mediaParts, *part)
+			}
+		}
+		text = strings.Join(texts, "\n")
+	}
+
+	// Gemini 约定使用 output 和 error 字段表示函数的结果和错误
+	var response map[string]interface{}
+	if block.IsError != nil && *block.IsError {
+		response = map[string]interface{}{"error": text}
+	} else if err := common.UnmarshalJsonStr(text, &response); err != nil || response == nil {
+		response = map[string]interface{}{"output": text}
+	}
+
+	parts := []dto.GeminiPart{{
+		FunctionResponse: &dto.GeminiFunctionResponse{
+			Name:     name,
+			Response: response,
+		},
+	}}
+	return append(parts, mediaParts...), nil
+}
+
+func claudeMessageID(c *gin.Context) string {
+	return "msg_" + c.GetString(common.RequestIdKey)
+}
+
+// claudeToolUseID 按请求 ID 和序号生成 tool_use 的 ID，Gemini 的 functionCall 没有 ID
+func claudeToolUseID(c *gin.Context, n int) string {
+	return fmt.Sprintf("toolu_%s_%d", c.GetString(common.RequestIdKey), n)
+}
+
+func geminiThoughtSignature(part *dto.GeminiPart) string {
+	if len(part.ThoughtSignature) == 0 {
+		return ""
+	}
+	var signature string
+	if err := common.Unmarshal(part.ThoughtSignature, &signature); err != nil {
+		return ""
+	}
+	return signature
+}
+
+func geminiFunctionArgs(call *dto.FunctionCall) any {
+	switch v := call.Arguments.(type) {
+	case nil:
+		return map[string]interface{}{}
+	case map[string]interface{}:
+		return unescapeMapOrSlice(v)
+	default:
+		return v
+	}
+}
+
+// geminiPartText 返回非思考、非函数调用 part 对应的文本，图片与 OpenAI 格式转换一样以 markdown 形式返回
+func geminiPartText(part *dto.GeminiPart) string {
+	switch {
+	case part.InlineData != nil:
+		if strings.HasPrefix(part.InlineData.MimeType, "image") {
+			return "![image](data:" + part.InlineData.MimeType + ";base64," + part.InlineData.Data + ")"
+		}
+		return fmt.Sprintf("[media](data:%s;base64,%s)", part.InlineData.MimeType, part.InlineData.Data)
+	case part.ExecutableCode != nil:
+		return "```" + part.ExecutableCode.Language + "\n" + part.ExecutableCode.Code + "\n```\n"
+	case part.CodeExecutionResult != nil:
+		return "```output\n" + part.CodeExecutionResult.Output + "\n```\n"
+	default:
+		return part.Text
+	}
+}
+
+func geminiFinishReason2Claude(reason string, toolUse bool) string {
+	switch reason {
+	case "MAX_TOKENS":
+		return "max_tokens"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "refusal"
+	}
+	if toolUse {
+		return "tool_use"
+	}
+	return "end_turn"
+}
+
+// geminiUsage2Claude 转换用量，Claude 的 input_tokens 不包含缓存命中的部分
+func geminiUsage2Claude(usage *dto.Usage, cachedTokens int) *dto.ClaudeUsage {
+	cachedTokens = min(cachedTokens, usage.PromptTokens)
+	return &dto.ClaudeUsage{
+		InputTokens:          usage.PromptTokens - cachedTokens,
+		CacheReadInputTokens: cachedTokens,
+		OutputTokens:         usage.CompletionTokens,
+	}
+}
+
+// responseGeminiChat2Claude 将非流式 Gemini 响应转换为 Claude 消息
+func responseGeminiChat2Claude(c *gin.Context, info *relaycommon.RelayInfo, response *dto.GeminiChatResponse, usage *dto.Usage) *dto.ClaudeResponse {
+	claudeResponse := &dto.ClaudeResponse{
+		Id:    claudeMessageID(c),
+		Type:  "message",
+		Role:  "assistant",
+		Model: info.UpstreamModelName,
+	}
+	contents := make([]dto.ClaudeMediaMessage, 0)
+	lastType := func() string {
+		if len(contents) == 0 {
+			return ""
+		}
+		return contents[len(contents)-1].Type
+	}
+	toolUse := false
+	finishReason := ""
+	if len(response.Candidates) > 0 {
+		candidate := response.Candidates[0]
+		if candidate.FinishReason != nil {
+			finishReason = *candidate.FinishReason
+		}
+		for i := range candidate.Content.Parts {
+			part := &candidate.Content.Parts[i]
+			signature := geminiThoughtSignature(part)
+			if part.Thought {
+				if lastType() == "thinking" && contents[len(contents)-1].Signature == "" {
+					*contents[len(contents)-1].Thinking += part.Text
+				} else {
+					contents = append(contents, dto.ClaudeMediaMessage{Type: "thinking", Thinking: common.GetPointer(part.Text)})
+				}
+				contents[len(contents)-1].Signature = signature
+				continue
+			}
+			// 签名在函数调用或正文上时，放入前一个未签名的思考块，没有则单独生成一个思考块
+			if signature != "" {
+				if lastType() == "thinking" && contents[len(contents)-1].Signature == "" {
+					contents[len(contents)-1].Signature = signature
+				} else {
+					contents = append(contents, dto.ClaudeMediaMessage{Type: "thinking", Thinking: common.GetPointer(""), Signature: signature})
+				}
+			}
+			if part.FunctionCall != nil {
+				contents = append(contents, dto.ClaudeMediaMessage{
+					Type:  "tool_use",
+					Id:    claudeToolUseID(c, countToolUse(contents)),
+					Name:  part.FunctionCall.FunctionName,
+					Input: geminiFunctionArgs(part.FunctionCall),
+				})
+				toolUse = true
+				continue
+			}
+			text := geminiPartText(part)
+			if text == "" {
+				continue
+			}
+			if lastType() == dto.ContentTypeText {
+				*contents[len(contents)-1].Text += text
+			} else {
+				contents = append(contents, dto.ClaudeMediaMessage{Type: dto.ContentTypeText, Text: common.GetPointer(text)})
+			}
+		}
+	} else if response.PromptFeedback != nil && response.PromptFeedback.BlockReason != nil {
+		finishReason = "SAFETY"
+	}
+	claudeResponse.Content = contents
+	claudeResponse.StopReason = geminiFinishReason2Claude(finishReason, toolUse)
+	claudeResponse.Usage = geminiUsage2Claude(usage, response.UsageMetadata.CachedContentTokenCount)
+	return claudeResponse
+}
+
+func countToolUse(contents []dto.ClaudeMediaMessage) int {
+	n := 0
+	for _, content := range contents {
+		if content.Type == "tool_use" {
+			n++
+		}
+	}
+	return n
+}
+
+// claudeStreamConverter 将 Gemini 流式分片转换为 Claude SSE 事件
+type claudeStreamConverter struct {
+	c    *gin.Context
+	info *relaycommon.RelayInfo
+	// 当前内容块的序号和类型，类型为空表示没有打开的内容块
+	index     int
+	blockType string
+	// 当前思考块是否已发送签名
+	signed       bool
+	started      bool
+	toolUseCount int
+	finishReason string
+	cachedTokens int
+}
+
+func newClaudeStreamConverter(c *gin.Context, info *relaycommon.RelayInfo) *claudeStreamConverter {
+	return &claudeStreamConverter{c: c, info: info, index: -1}
+}
+
+func (s *claudeStreamConverter) start() []*dto.ClaudeResponse {
+	if s.started {
+		return nil
+	}
+	s.started = true
+	msg := &dto.ClaudeMediaMessage{
+		Id:    claudeMessageID(s.c),
+		Model: s.info.UpstreamModelName,
+		Type:  "message",
+		Role:  "assistant",
+		Usage: &dto.ClaudeUsage{
+			InputTokens: s.info.GetEstimatePromptTokens(),
+		},
+	}
+	msg.SetContent(make([]any, 0))
+	return []*dto.ClaudeResponse{{Type: "message_start", Message: msg}}
+}
+
+func (s *claudeStreamConverter) stopBlock() []*dto.ClaudeResponse {
+	if s.blockType == "" {
+		return nil
+	}
+	s.blockType = ""
+	return []*dto.ClaudeResponse{{Type: "content_block_stop", Index: common.GetPointer(s.index)}}
+}
+
+func (s *claudeStreamConverter) startBlock(block *dto.ClaudeMediaMessage) []*dto.ClaudeResponse {
+	events := s.stopBlock()
+	s.index++
+	s.blockType = block.Type
+	s.signed = false
+	return append(events, &dto.ClaudeResponse{Type: "content_block_start", Index: common.GetPointer(s.index), ContentBlock: block})
+}
+
+func (s *claudeStreamConverter) delta(delta *dto.ClaudeMediaMessage) *dto.ClaudeResponse {
+	return &dto.ClaudeResponse{Type: "content_block_delta", Index: common.GetPointer(s.index), Delta: delta}
+}
+
+func (s *claudeStreamConverter) thinkingBlock() []*dto.ClaudeResponse {
+	if s.blockType == "thinking" && !s.signed {
+		return nil
+	}
+	return s.startBlock(&dto.ClaudeMediaMessage{Type: "thinking", Thinking: common.GetPointer("")})
+}
+
+func (s *claudeStreamConverter) signature(signature string) *dto.ClaudeResponse {
+	s.signed = true
+	return s.delta(&dto.ClaudeMediaMessage{Type: "signature_delta", Signature: signature})
+}
+
+// convert 转换一个 Gemini 分片，签名的处理方式与非流式响应相同
+func (s *claudeStreamConverter) convert(response *dto.GeminiChatResponse) []*dto.ClaudeResponse {
+	events := s.start()
+	if response.UsageMetadata.CachedContentTokenCount > 0 {
+		s.cachedTokens = response.UsageMetadata.CachedContentTokenCount
+	}
+	if len(response.Candidates) == 0 {
+		if response.PromptFeedback != nil && response.PromptFeedback.BlockReason != nil {
+			s.finishReason = "SAFETY"
+		}
+		return events
+	}
+	candidate := response.Candidates[0]
+	if candidate.FinishReason != nil {
+		s.finishReason = *candidate.FinishReason
+	}
+	for i := range candidate.Content.Parts {
+		part := &candidate.Content.Parts[i]
+		signature := geminiThoughtSignature(part)
+		if part.Thought {
+			events = append(events, s.thinkingBlock()...)
+			if part.Text != "" {
+				events = append(events, s.delta(&dto.ClaudeMediaMessage{Type: "thinking_delta", Thinking: common.GetPointer(part.Text)}))
+			}
+			if signature != "" {
+				events = append(events, s.signature(signature))
+			}
+			continue
+		}
+		if signature != "" {
+			events = append(events, s.thinkingBlock()...)
+			events = append(events, s.signature(signature))
+		}
+		if part.FunctionCall != nil {
+			args, _ := common.Marshal(geminiFunctionArgs(part.FunctionCall))
+			events = append(events, s.startBlock(&dto.ClaudeMediaMessage{
+				Type:  "tool_use",
+				Id:    claudeToolUseID(s.c, s.toolUseCount),
+				Name:  part.FunctionCall.FunctionName,
+				Input: map[string]interface{}{},
+			})...)
+			events = append(events, s.delta(&dto.ClaudeMediaMessage{Type: "input_json_delta", PartialJson: common.GetPointer(string(args))}))
+			s.toolUseCount++
+			continue
+		}
+		text := geminiPartText(part)
+		if text == "" {
+			continue
+		}
+		if s.blockType != dto.ContentTypeText {
+			events = append(events, s.startBlock(&dto.ClaudeMediaMessage{Type: dto.ContentTypeText, Text: common.GetPointer("")})...)
+		}
+		events = append(events, s.delta(&dto.ClaudeMediaMessage{Type: "text_delta", Text: common.GetPointer(text)}))
+	}
+	return events
+}
+
+// finish 结束最后的内容块并发送 stop_reason 和用量
+func (s *claudeStreamConverter) finish(usage *dto.Usage) []*dto.ClaudeResponse {
+	events := s.start()
+	events = append(events, s.stopBlock()...)
+	events = append(events, &dto.ClaudeResponse{
+		Type:  "message_delta",
+		Usage: geminiUsage2Claude(usage, s.cachedTokens),
+		Delta: &dto.ClaudeMediaMessage{
+			StopReason: common.GetPointer(geminiFinishReason2Claude(s.finishReason, s.toolUseCount > 0)),
+		},
+	})
+	return append(events, &dto.ClaudeResponse{Type: "message_stop"})
+}
+
+// GeminiClaudeStreamHandler 将 Gemini 流式响应直接转换为 Claude SSE 事件
+func GeminiClaudeStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	converter := newClaudeStreamConverter(c, info)
+	usage, err := geminiStreamHandler(c, info, resp, func(data string, geminiResponse *dto.GeminiChatResponse) bool {
+		for _, event := range converter.convert(geminiResponse) {
+			_ = helper.ClaudeData(c, *event)
+		}
+		return true
+	})
+	if err != nil {
+		return usage, err
+	}
+	for _, event := range converter.finish(usage) {
+		_ = helper.ClaudeData(c, *event)
+	}
+	return usage, nil
+}
+
+// GeminiClaudeHandler 将非流式 Gemini 响应直接转换为 Claude 消息
+func GeminiClaudeHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	service.RecentCallsCache().UpsertUpstreamResponseByContext(c, resp, append([]byte(nil), responseBody...))
+	service.CloseResponseBodyGracefully(resp)
+
+	var geminiResponse dto.GeminiChatResponse
+	if err := common.Unmarshal(responseBody, &geminiResponse); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	usage := &dto.Usage{
+		PromptTokens: geminiResponse.UsageMetadata.PromptTokenCount,
+		TotalTokens:  geminiResponse.UsageMetadata.TotalTokenCount,
+	}
+	usage.CompletionTokens = usage.TotalTokens - usage.PromptTokens
+	usage.CompletionTokenDetails.ReasoningTokens = geminiResponse.UsageMetadata.ThoughtsTokenCount
+	for _, detail := range geminiResponse.UsageMetadata.PromptTokensDetails {
+		if detail.Modality == "AUDIO" {
+			usage.PromptTokensDetails.AudioTokens = detail.TokenCount
+		} else if detail.Modality == "TEXT" {
+			usage.PromptTokensDetails.TextTokens = detail.TokenCount
+		}
+	}
+
+	claudeResponse := responseGeminiChat2Claude(c, info, &geminiResponse, usage)
+	responseBody, err = common.Marshal(claudeResponse)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+	}
+	service.IOCopyBytesGracefully(c, resp, responseBody)
+	return usage, nil
+}
@@ -290,14 +290,7 @@ func CovertOpenAI2Gemini(c *gin.Context, textRequest dto.GeneralOpenAIRequest, i
 		ThinkingAdaptor(&geminiRequest, info, textRequest)
 	}
 
-	safetySettings := make([]dto.GeminiChatSafetySettings, 0, len(SafetySettingList))
-	for _, category := range SafetySettingList {
-		safetySettings = append(safetySettings, dto.GeminiChatSafetySettings{
-			Category:  category,
-			Threshold: model_setting.GetGeminiSafetySetting(category),
-		})
-	}
-	geminiRequest.SafetySettings = safetySettings
+	geminiRequest.SafetySettings = geminiSafetySettings()
 
 	// openaiContent.FuncToToolCalls()
 	if textRequest.Tools != nil {
@@ -621,6 +614,17 @@ func CovertOpenAI2Gemini(c *gin.Context, textRequest dto.GeneralOpenAIRequest, i
 	return &geminiRequest, nil
 }
 
+func geminiSafetySettings() []dto.GeminiChatSafetySettings {
+	safetySettings := make([]dto.GeminiChatSafetySettings, 0, len(SafetySettingList))
+	for _, category := range SafetySettingList {
+		safetySettings = append(safetySettings, dto.GeminiChatSafetySettings{
+			Category:  category,
+			Threshold: model_setting.GetGeminiSafetySetting(category),
+		})
+	}
+	return safetySettings
+}
+
 func hasFunctionCallContent(call *dto.FunctionCall) bool {
 	if call == nil {
 		return false
@@ -1142,6 +1146,9 @@ func geminiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 }
 
 func GeminiChatStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	if info.RelayFormat == types.RelayFormatClaude {
+		return GeminiClaudeStreamHandler(c, info, resp)
+	}
 	id := helper.GetResponseID(c)
 	createAt := common.GetTimestamp()
 	finishReason := constant.FinishReasonStop
@@ -1208,6 +1215,9 @@ func GeminiChatStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *
 }
 
 func GeminiChatHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	if info.RelayFormat == types.RelayFormatClaude {
+		return GeminiClaudeHandler(c, info, resp)
+	}
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
@@ -1260,13 +1270,6 @@ func GeminiChatHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.R
 		if err != nil {
 			return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
 		}
-	case types.RelayFormatClaude:
-		claudeResp := service.ResponseOpenAI2Claude(fullTextResponse, info)
-		claudeRespStr, err := common.Marshal(claudeResp)
-		if err != nil {
-			return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
-		}
-		responseBody = claudeRespStr
 	case types.RelayFormatGemini:
 		break
 	}
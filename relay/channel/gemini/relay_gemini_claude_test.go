@@ -0,0 +1,178 @@
+package gemini
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+)
+
+// go test ./relay/channel/gemini -run Claude -update 重新生成 golden 文件
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+func newClaudeTestContext() (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	c.Set(common.RequestIdKey, "test")
+	return c, w
+}
+
+func newClaudeTestInfo(stream bool) *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{
+		RelayFormat: types.RelayFormatClaude,
+		IsStream:    stream,
+		DisablePing: true,
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelType:       constant.ChannelTypeGemini,
+			UpstreamModelName: "gemini-2.5-flash",
+		},
+	}
+}
+
+// goldenCases 返回目录下指定后缀的输入文件，golden 文件与输入同名，后缀为 .golden
+func goldenCases(t *testing.T, dir string, ext string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join("testdata", dir, "*"+ext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no test cases in testdata/%s", dir)
+	}
+	return files
+}
+
+func goldenPath(input string) string {
+	return strings.TrimSuffix(input, filepath.Ext(input)) + ".golden"
+}
+
+func checkGoldenJSON(t *testing.T, input string, got any) {
+	t.Helper()
+	gotBytes, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := goldenPath(input)
+	if *updateGolden {
+		if err := os.WriteFile(path, append(gotBytes, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotValue, wantValue any
+	if err := json.Unmarshal(gotBytes, &gotValue); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(want, &wantValue); err != nil {
+		t.Fatalf("invalid golden file %s: %v", path, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", path, gotBytes, want)
+	}
+}
+
+func checkGoldenText(t *testing.T, input string, got string) {
+	t.Helper()
+	path := goldenPath(input)
+	if *updateGolden {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestClaudeToGeminiRequestGolden(t *testing.T) {
+	constant.GeminiVisionMaxImageNum = 16
+	for _, input := range goldenCases(t, "claude_request", ".json") {
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			data, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var request dto.ClaudeRequest
+			if err := common.Unmarshal(data, &request); err != nil {
+				t.Fatal(err)
+			}
+			c, _ := newClaudeTestContext()
+			geminiRequest, err := ClaudeToGeminiRequest(c, &request, newClaudeTestInfo(request.Stream))
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGoldenJSON(t, input, geminiRequest)
+		})
+	}
+}
+
+func TestGeminiClaudeHandlerGolden(t *testing.T) {
+	for _, input := range goldenCases(t, "gemini_response", ".json") {
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			data, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, w := newClaudeTestContext()
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(bytes.NewReader(data)),
+			}
+			if _, apiErr := GeminiChatHandler(c, newClaudeTestInfo(false), resp); apiErr != nil {
+				t.Fatalf("unexpected api error: %v", apiErr)
+			}
+			var got any
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("invalid response %s: %v", w.Body.String(), err)
+			}
+			checkGoldenJSON(t, input, got)
+		})
+	}
+}
+
+func TestGeminiClaudeStreamHandlerGolden(t *testing.T) {
+	for _, input := range goldenCases(t, "gemini_stream", ".sse") {
+		t.Run(filepath.Base(input), func(t *testing.T) {
+			data, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c, w := newClaudeTestContext()
+			resp := &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+				Body:       io.NopCloser(bytes.NewReader(data)),
+			}
+			if _, apiErr := GeminiChatStreamHandler(c, newClaudeTestInfo(true), resp); apiErr != nil {
+				t.Fatalf("unexpected api error: %v", apiErr)
+			}
+			checkGoldenText(t, input, w.Body.String())
+		})
+	}
+}
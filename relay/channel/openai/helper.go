@@ -44,7 +44,9 @@ func handleClaudeFormat(c *gin.Context, data string, info *relaycommon.RelayInfo
 	}
 	claudeResponses := service.StreamResponseOpenAI2Claude(&streamResponse, info)
 	for _, resp := range claudeResponses {
-		helper.ClaudeData(c, *resp)
+		if send, _ := info.SpliceClaudeStreamEvent(resp); send {
+			helper.ClaudeData(c, *resp)
+		}
 	}
 	return nil
 }
@@ -218,7 +220,9 @@ func HandleFinalResponse(c *gin.Context, info *relaycommon.RelayInfo, lastStream
 
 		claudeResponses := service.StreamResponseOpenAI2Claude(&streamResponse, info)
 		for _, resp := range claudeResponses {
-			_ = helper.ClaudeData(c, *resp)
+			if send, _ := info.SpliceClaudeStreamEvent(resp); send {
+				_ = helper.ClaudeData(c, *resp)
+			}
 		}
 		info.ClaudeConvertInfo.Done = true
 
@@ -0,0 +1,70 @@
+package openai
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamFailoverError 判断中途断开（没有收到 [DONE]、usage 或 finish_reason）的 chat completions 流式响应能否换渠道续写。
+// 很多兼容上游正常结束时只发送 [DONE]，finish_reason 为 null，这类响应视为已完成。
+// 可以续写时把最后一个缓存的数据块发给客户端、记录已发送的内容，并返回 ErrorCodeStreamInterrupted，不结束客户端的响应流
+func streamFailoverError(c *gin.Context, info *relaycommon.RelayInfo, streamDone bool, lastStreamData string, streamItems []string) *types.NewAPIError {
+	if streamDone || info.RelayFormat != types.RelayFormatOpenAI || info.RelayMode != relayconstant.RelayModeChatCompletions {
+		return nil
+	}
+	if c.Request.Context().Err() != nil || !info.CanStreamFailover() {
+		return nil
+	}
+
+	var partialText strings.Builder
+	finished := false
+	for _, item := range streamItems {
+		var streamResponse dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(item, &streamResponse); err != nil {
+			return nil
+		}
+		if streamResponse.Usage != nil {
+			finished = true
+		}
+		for _, choice := range streamResponse.Choices {
+			// 多个候选或工具调用无法通过预填充续写
+			if choice.Index != 0 || len(choice.Delta.ToolCalls) > 0 {
+				return nil
+			}
+			partialText.WriteString(choice.Delta.GetContentString())
+			if choice.FinishReason != nil && *choice.FinishReason != "" {
+				finished = true
+			}
+		}
+	}
+	if finished || partialText.Len() == 0 {
+		return nil
+	}
+
+	if lastStreamData != "" {
+		if err := HandleStreamFormat(c, info, lastStreamData, info.ChannelSetting.ForceFormat, info.ChannelSetting.ThinkingToContent); err != nil {
+			common.SysLog("error handling stream format: " + err.Error())
+		}
+	}
+
+	var responseTextBuilder strings.Builder
+	var toolCount int
+	if err := processTokens(info.RelayMode, streamItems, &responseTextBuilder, &toolCount); err != nil {
+		logger.LogError(c, "error processing tokens: "+err.Error())
+	}
+	delivered := service.ResponseText2Usage(c, responseTextBuilder.String(), info.UpstreamModelName, 0).CompletionTokens
+	info.MarkStreamInterrupted(partialText.String(), info.GetEstimatePromptTokens(), delivered)
+	logger.LogWarn(c, "upstream stream interrupted, trying to continue on another channel")
+	return types.NewErrorWithStatusCode(errors.New("upstream stream interrupted"), types.ErrorCodeStreamInterrupted, http.StatusBadGateway)
+}
@@ -0,0 +1,60 @@
+package openai
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestOaiStreamHandlerFailover(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setting := operation_setting.GetStreamFailoverSetting()
+	origin := *setting
+	setting.Enabled, setting.MaxFailovers = true, 1
+	t.Cleanup(func() { *setting = origin })
+
+	const chunk = `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[{"index":0,"delta":{"content":"Hello"},"finish_reason":null}]}` + "\n\n"
+	tests := []struct {
+		name        string
+		body        string
+		interrupted bool
+	}{
+		// 兼容上游正常结束时可能只发送 [DONE]，不带 finish_reason
+		{name: "done without finish_reason", body: chunk + "data: [DONE]\n\n"},
+		{name: "usage without finish_reason", body: chunk + `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"gpt-4o","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}` + "\n\n"},
+		{name: "interrupted", body: chunk, interrupted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			info := &relaycommon.RelayInfo{
+				IsStream:          true,
+				RelayFormat:       types.RelayFormatOpenAI,
+				RelayMode:         relayconstant.RelayModeChatCompletions,
+				FirstResponseTime: time.Now(),
+				ChannelMeta:       &relaycommon.ChannelMeta{UpstreamModelName: "gpt-4o"},
+			}
+			resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(tt.body))}
+
+			_, err := OaiStreamHandler(c, info, resp)
+			interrupted := err != nil && err.GetErrorCode() == types.ErrorCodeStreamInterrupted
+			if interrupted != tt.interrupted {
+				t.Fatalf("error = %v, want interrupted = %v", err, tt.interrupted)
+			}
+			if pending := info.StreamFailover != nil && info.StreamFailover.Pending; pending != tt.interrupted {
+				t.Fatalf("stream failover pending = %v, want %v", pending, tt.interrupted)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package common
+
+import "context"
+
+type batchRequestKey struct{}
+
+// WithBatchRequest 标记由批处理任务发起的请求，这类请求按批处理折扣计费
+func WithBatchRequest(ctx context.Context, batchId string) context.Context {
+	return context.WithValue(ctx, batchRequestKey{}, batchId)
+}
+
+// GetBatchRequestId 返回发起请求的批处理任务 id，不是批处理请求时返回空字符串
+func GetBatchRequestId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	batchId, _ := ctx.Value(batchRequestKey{}).(string)
+	return batchId
+}
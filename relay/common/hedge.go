@@ -0,0 +1,13 @@
+package common
+
+// HedgeInfo 对冲请求的结果，记录到消费日志
+type HedgeInfo struct {
+	PrimaryChannelId int
+	HedgeChannelId   int
+	WinnerChannelId  int
+	DelayMillis      int
+	// 落败请求的状态：cancelled 已取消，failed 上游返回错误
+	LoserStatus string
+	// 落败请求已发送到上游、可能产生额外费用的输入 token 估算值
+	ExtraPromptTokens int
+}
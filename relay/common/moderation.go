@@ -0,0 +1,10 @@
+package common
+
+// ModerationInfo 审核模型的结果，记录到消费日志
+type ModerationInfo struct {
+	// 命中后的处理方式：block 拒绝请求，flag 只记录
+	Action string
+	// 提示词和响应命中的分类
+	PromptCategories     []string
+	CompletionCategories []string
+}
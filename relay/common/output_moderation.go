@@ -0,0 +1,10 @@
+package common
+
+// OutputModerationInfo 响应内容审核的结果，记录到消费日志
+type OutputModerationInfo struct {
+	// 命中后的处理方式：mask 替换敏感词，abort 中断响应
+	Action string
+	Words  []string
+	// 是否已中断响应
+	Aborted bool
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -48,6 +49,33 @@ func ApplyParamOverride(jsonData []byte, paramOverride map[string]interface{}, c
 	return applyOperationsLegacy(jsonData, paramOverride)
 }
 
+// ApplyResponseOverride 使用与 ApplyParamOverride 相同的规则改写上游响应。
+// 流式响应各分片的结构不同，单个操作失败时跳过该操作，继续应用其余操作，返回合并后的错误
+func ApplyResponseOverride(jsonData []byte, responseOverride map[string]interface{}, conditionContext map[string]interface{}) ([]byte, error) {
+	if len(responseOverride) == 0 {
+		return jsonData, nil
+	}
+	operations, ok := tryParseOperations(responseOverride)
+	if !ok {
+		return applyOperationsLegacy(jsonData, responseOverride)
+	}
+	contextJSON, err := marshalConditionContext(conditionContext)
+	if err != nil {
+		return jsonData, err
+	}
+	result := string(jsonData)
+	var errs []error
+	for _, op := range operations {
+		updated, err := applyOperationsWithContext(result, []ParamOperation{op}, contextJSON)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result = updated
+	}
+	return []byte(result), errors.Join(errs...)
+}
+
 func tryParseOperations(paramOverride map[string]interface{}) ([]ParamOperation, bool) {
 	// 检查是否包含 "operations" 字段
 	if opsValue, exists := paramOverride["operations"]; exists {
@@ -308,16 +336,26 @@ func applyOperationsLegacy(jsonData []byte, paramOverride map[string]interface{}
 	return common.Marshal(reqMap)
 }
 
+func marshalConditionContext(conditionContext map[string]interface{}) (string, error) {
+	if len(conditionContext) == 0 {
+		return "", nil
+	}
+	ctxBytes, err := common.Marshal(conditionContext)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal condition context: %v", err)
+	}
+	return string(ctxBytes), nil
+}
+
 func applyOperations(jsonStr string, operations []ParamOperation, conditionContext map[string]interface{}) (string, error) {
-	var contextJSON string
-	if conditionContext != nil && len(conditionContext) > 0 {
-		ctxBytes, err := common.Marshal(conditionContext)
-		if err != nil {
-			return "", fmt.Errorf("failed to marshal condition context: %v", err)
-		}
-		contextJSON = string(ctxBytes)
+	contextJSON, err := marshalConditionContext(conditionContext)
+	if err != nil {
+		return "", err
 	}
+	return applyOperationsWithContext(jsonStr, operations, contextJSON)
+}
 
+func applyOperationsWithContext(jsonStr string, operations []ParamOperation, contextJSON string) (string, error) {
 	result := jsonStr
 	for _, op := range operations {
 		// 检查条件是否满足
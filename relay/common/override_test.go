@@ -0,0 +1,43 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestApplyResponseOverride(t *testing.T) {
+	override := map[string]interface{}{
+		"operations": []interface{}{
+			map[string]interface{}{"path": "model", "mode": "set", "value": "gpt-4o"},
+			map[string]interface{}{"path": "provider_meta", "mode": "delete"},
+			// 只有最后一个分片带 finish_reason，其余分片跳过该操作
+			map[string]interface{}{"mode": "move", "from": "choices.0.stop_reason", "to": "choices.0.finish_reason"},
+			map[string]interface{}{
+				"path":  "choices.0.finish_reason",
+				"mode":  "set",
+				"value": "stop",
+				"conditions": []interface{}{
+					map[string]interface{}{"path": "choices.0.finish_reason", "mode": "full", "value": "end_turn"},
+				},
+			},
+		},
+	}
+	ctx := map[string]interface{}{"model": "upstream-model"}
+
+	chunk, err := ApplyResponseOverride([]byte(`{"model":"upstream-model","provider_meta":{"x":1},"choices":[{"delta":{"content":"hi"}}]}`), override, ctx)
+	if err == nil {
+		t.Fatal("expected error for move without source")
+	}
+	if gjson.GetBytes(chunk, "model").String() != "gpt-4o" || gjson.GetBytes(chunk, "provider_meta").Exists() {
+		t.Fatalf("other operations should still apply: %s", chunk)
+	}
+
+	last, err := ApplyResponseOverride([]byte(`{"model":"upstream-model","choices":[{"delta":{},"stop_reason":"end_turn"}]}`), override, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gjson.GetBytes(last, "choices.0.finish_reason").String() != "stop" || gjson.GetBytes(last, "choices.0.stop_reason").Exists() {
+		t.Fatalf("unexpected result: %s", last)
+	}
+}
@@ -1,6 +1,7 @@
 package common
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -67,6 +68,8 @@ type ChannelMeta struct {
 	ChannelCreateTime    int64
 	ParamOverride        map[string]interface{}
 	HeadersOverride      map[string]interface{}
+	// 响应覆盖规则，与 ParamOverride 使用相同的格式
+	ResponseOverride     map[string]interface{}
 	ChannelSetting       dto.ChannelSettings
 	ChannelOtherSettings dto.ChannelOtherSettings
 	UpstreamModelName    string
@@ -115,6 +118,30 @@ type RelayInfo struct {
 	SendResponseCount      int
 	FinalPreConsumedQuota  int  // 最终预消耗的配额
 	IsClaudeBetaQuery      bool // /v1/messages?beta=true
+	// 流式响应中途断开后换渠道续写的状态
+	StreamFailover *StreamFailoverState
+	// Responses 请求转为 chat completions 请求上游，响应由 writer 转回 Responses 格式
+	ResponsesViaChat bool
+	// 后台模式的 Responses 请求提交时返回给用户的响应 id，非后台请求为空
+	BackgroundResponseId string
+	// 上游请求使用的 context，为空时不可单独取消
+	UpstreamContext context.Context
+	// 对冲请求的结果，未发出对冲请求时为 nil
+	Hedge *HedgeInfo
+	// 响应缓存 key，未命中且请求成功后写入缓存；为空时不缓存
+	ResponseCacheKey string
+	// 是否由响应缓存返回
+	ResponseCacheHit bool
+	// 响应内容审核的结果，未开启审核时为 nil
+	OutputModeration *OutputModerationInfo
+	// 审核模型的结果，未开启审核时为 nil
+	Moderation *ModerationInfo
+	// 令牌、用户设置了时间窗口消费上限时记录消费用量
+	TrackTokenSpend bool
+	TrackUserSpend  bool
+	// 令牌每分钟 token 数上限，以及按预估 token 数预占的用量，响应后按实际用量校正
+	TpmLimit    int
+	TpmReserved int
 
 	PriceData types.PriceData
 
@@ -133,6 +160,7 @@ func (info *RelayInfo) InitChannelMeta(c *gin.Context) {
 	channelType := common.GetContextKeyInt(c, constant.ContextKeyChannelType)
 	paramOverride := common.GetContextKeyStringMap(c, constant.ContextKeyChannelParamOverride)
 	headerOverride := common.GetContextKeyStringMap(c, constant.ContextKeyChannelHeaderOverride)
+	responseOverride := common.GetContextKeyStringMap(c, constant.ContextKeyChannelResponseOverride)
 	apiType, _ := common.ChannelType2APIType(channelType)
 	channelMeta := &ChannelMeta{
 		ChannelType:          channelType,
@@ -147,6 +175,7 @@ func (info *RelayInfo) InitChannelMeta(c *gin.Context) {
 		ChannelCreateTime:    c.GetInt64("channel_create_time"),
 		ParamOverride:        paramOverride,
 		HeadersOverride:      headerOverride,
+		ResponseOverride:     responseOverride,
 		UpstreamModelName:    common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
 		IsModelMapped:        false,
 		SupportStreamOptions: false,
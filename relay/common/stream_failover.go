@@ -0,0 +1,158 @@
+package common
+
+import (
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// StreamFailoverState 流式响应中途断开后换渠道续写的状态，在同一请求的多次重试之间共享
+type StreamFailoverState struct {
+	// 已经发生的续写次数
+	Failovers int
+	// 客户端已收到部分内容且响应流尚未结束，等待下一个渠道续写
+	Pending bool
+	// 当前尝试是否为续写
+	Continuing bool
+	// 已发送给客户端的助手文本，续写时作为 assistant 预填充
+	PartialText string
+	// 中断前上游的输入 token 数，未知时为 0
+	PromptTokens int
+	// 已中断的尝试中发送给客户端的输出 token 数
+	DeliveredCompletionTokens int
+	// 预填充文本的 token 数，续写成功后从上游返回的输入 token 中扣除
+	PrefillTokens int
+
+	// Claude 格式下客户端已收到的内容块数量，以及最后一个内容块是否仍未结束
+	claudeBlocks    int
+	claudeBlockOpen bool
+	// 发送过 thinking / tool_use 等非文本内容块时无法续写
+	claudeNonText bool
+	// 续写时新上游内容块序号到客户端序号的偏移
+	claudeIndexOffset  int
+	claudeBlockStarted bool
+}
+
+// CanStreamFailover 判断当前已中断的流式响应能否换渠道续写
+func (info *RelayInfo) CanStreamFailover() bool {
+	setting := operation_setting.GetStreamFailoverSetting()
+	// 转换格式后发送的响应流无法续写
+	if !setting.Enabled || !info.IsStream || !info.HasSendResponse() || info.ResponsesViaChat {
+		return false
+	}
+	if info.StreamFailover != nil && info.StreamFailover.Failovers >= setting.MaxFailovers {
+		return false
+	}
+	return true
+}
+
+// MarkStreamInterrupted 记录中断的流式响应，partialText 为本次尝试发送给客户端的助手文本
+func (info *RelayInfo) MarkStreamInterrupted(partialText string, promptTokens int, deliveredCompletionTokens int) {
+	state := info.streamFailoverState()
+	state.Failovers++
+	state.Pending = true
+	state.PartialText += partialText
+	if state.PromptTokens == 0 {
+		state.PromptTokens = promptTokens
+	}
+	state.DeliveredCompletionTokens += deliveredCompletionTokens
+}
+
+// BeginStreamContinuation 开始一次续写尝试，返回需要预填充的文本，不是续写时返回 false
+func (info *RelayInfo) BeginStreamContinuation() (string, bool) {
+	state := info.StreamFailover
+	if state == nil {
+		return "", false
+	}
+	state.Continuing = state.Pending
+	state.claudeBlockStarted = false
+	state.claudeIndexOffset = 0
+	return state.PartialText, state.Continuing
+}
+
+// IsStreamContinuation 当前尝试是否为续写
+func (info *RelayInfo) IsStreamContinuation() bool {
+	return info.StreamFailover != nil && info.StreamFailover.Continuing
+}
+
+// ApplyStreamFailoverUsage 续写成功或放弃续写时合并计费：输入只计一次，输出为全部已发送的内容
+func (info *RelayInfo) ApplyStreamFailoverUsage(usage *dto.Usage) {
+	state := info.StreamFailover
+	if state == nil || !state.Pending || usage == nil {
+		return
+	}
+	state.Pending = false
+	usage.PromptTokens = max(usage.PromptTokens-state.PrefillTokens, 0)
+	usage.CompletionTokens += state.DeliveredCompletionTokens
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+}
+
+// FinishStreamFailover 放弃续写，返回按已发送内容计算的用量，没有等待续写的响应流时返回 nil
+func (info *RelayInfo) FinishStreamFailover() *dto.Usage {
+	state := info.StreamFailover
+	if state == nil || !state.Pending {
+		return nil
+	}
+	state.Pending = false
+	promptTokens := state.PromptTokens
+	if promptTokens == 0 {
+		promptTokens = info.GetEstimatePromptTokens()
+	}
+	return &dto.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: state.DeliveredCompletionTokens,
+		TotalTokens:      promptTokens + state.DeliveredCompletionTokens,
+	}
+}
+
+func (info *RelayInfo) streamFailoverState() *StreamFailoverState {
+	if info.StreamFailover == nil {
+		info.StreamFailover = &StreamFailoverState{}
+	}
+	return info.StreamFailover
+}
+
+// CanClaudeStreamFailover Claude 格式下只有全部为文本内容块时才能续写
+func (info *RelayInfo) CanClaudeStreamFailover() bool {
+	return info.StreamFailover == nil || !info.StreamFailover.claudeNonText
+}
+
+// SpliceClaudeStreamEvent 处理即将发送给客户端的 Claude 流事件。
+// 续写时丢弃新上游的 message_start 以及接续未结束文本块的 content_block_start，并平移内容块序号；
+// send 为 false 表示不发送该事件，remapped 为 true 表示事件的 index 已被修改
+func (info *RelayInfo) SpliceClaudeStreamEvent(resp *dto.ClaudeResponse) (send bool, remapped bool) {
+	if !operation_setting.GetStreamFailoverSetting().Enabled {
+		return true, false
+	}
+	state := info.streamFailoverState()
+	if state.Continuing {
+		switch resp.Type {
+		case "message_start":
+			return false, false
+		case "content_block_start":
+			if !state.claudeBlockStarted {
+				state.claudeBlockStarted = true
+				if state.claudeBlockOpen && resp.ContentBlock != nil && resp.ContentBlock.Type == "text" {
+					state.claudeIndexOffset = state.claudeBlocks - 1 - resp.GetIndex()
+					return false, false
+				}
+				state.claudeIndexOffset = state.claudeBlocks - resp.GetIndex()
+			}
+		}
+		if resp.Index != nil && state.claudeIndexOffset != 0 {
+			resp.SetIndex(resp.GetIndex() + state.claudeIndexOffset)
+			remapped = true
+		}
+	}
+
+	switch resp.Type {
+	case "content_block_start":
+		state.claudeBlocks = max(state.claudeBlocks, resp.GetIndex()+1)
+		state.claudeBlockOpen = true
+		if resp.ContentBlock != nil && resp.ContentBlock.Type != "text" {
+			state.claudeNonText = true
+		}
+	case "content_block_stop":
+		state.claudeBlockOpen = false
+	}
+	return true, remapped
+}
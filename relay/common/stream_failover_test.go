@@ -0,0 +1,83 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+func claudeEvent(eventType string, index int, blockType string) *dto.ClaudeResponse {
+	resp := &dto.ClaudeResponse{Type: eventType}
+	if eventType != "message_start" && eventType != "message_delta" && eventType != "message_stop" {
+		resp.SetIndex(index)
+	}
+	if blockType != "" {
+		resp.ContentBlock = &dto.ClaudeMediaMessage{Type: blockType}
+	}
+	return resp
+}
+
+func TestSpliceClaudeStreamEvent(t *testing.T) {
+	setting := operation_setting.GetStreamFailoverSetting()
+	enabled := setting.Enabled
+	setting.Enabled = true
+	defer func() { setting.Enabled = enabled }()
+
+	info := &RelayInfo{}
+	for _, event := range []*dto.ClaudeResponse{
+		claudeEvent("message_start", 0, ""),
+		claudeEvent("content_block_start", 0, "text"),
+		claudeEvent("content_block_delta", 0, ""),
+	} {
+		if send, _ := info.SpliceClaudeStreamEvent(event); !send {
+			t.Fatalf("unexpected drop of %s before failover", event.Type)
+		}
+	}
+	info.MarkStreamInterrupted("hello", 10, 1)
+	if _, ok := info.BeginStreamContinuation(); !ok {
+		t.Fatal("expected continuation")
+	}
+
+	// 新上游的 message_start 和接续文本块的 content_block_start 被丢弃，后续内容块序号平移
+	cases := []struct {
+		event     *dto.ClaudeResponse
+		send      bool
+		wantIndex int
+	}{
+		{claudeEvent("message_start", 0, ""), false, 0},
+		{claudeEvent("content_block_start", 0, "text"), false, 0},
+		{claudeEvent("content_block_delta", 0, ""), true, 0},
+		{claudeEvent("content_block_stop", 0, ""), true, 0},
+		{claudeEvent("content_block_start", 1, "text"), true, 1},
+		{claudeEvent("content_block_delta", 1, ""), true, 1},
+		{claudeEvent("message_delta", 0, ""), true, 0},
+	}
+	for _, tc := range cases {
+		send, _ := info.SpliceClaudeStreamEvent(tc.event)
+		if send != tc.send {
+			t.Fatalf("%s: expected send=%v, got %v", tc.event.Type, tc.send, send)
+		}
+		if send && tc.event.GetIndex() != tc.wantIndex {
+			t.Fatalf("%s: expected index %d, got %d", tc.event.Type, tc.wantIndex, tc.event.GetIndex())
+		}
+	}
+}
+
+func TestApplyStreamFailoverUsage(t *testing.T) {
+	info := &RelayInfo{}
+	info.MarkStreamInterrupted("partial", 100, 20)
+	info.StreamFailover.PrefillTokens = 20
+
+	usage := &dto.Usage{PromptTokens: 120, CompletionTokens: 30}
+	info.ApplyStreamFailoverUsage(usage)
+	if usage.PromptTokens != 100 || usage.CompletionTokens != 50 || usage.TotalTokens != 150 {
+		t.Fatalf("unexpected usage %+v", usage)
+	}
+
+	// 只合并一次
+	info.ApplyStreamFailoverUsage(usage)
+	if usage.CompletionTokens != 50 {
+		t.Fatalf("usage merged twice: %+v", usage)
+	}
+}
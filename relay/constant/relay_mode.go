@@ -148,3 +148,8 @@ func Path2RelaySuno(method, path string) int {
 	}
 	return relayMode
 }
+
+// IsCountTokensPath 判断是否为 Claude count_tokens 或 Gemini countTokens 请求
+func IsCountTokensPath(path string) bool {
+	return strings.HasSuffix(path, "/messages/count_tokens") || strings.HasSuffix(path, ":countTokens")
+}
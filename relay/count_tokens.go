@@ -0,0 +1,197 @@
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	"github.com/QuantumNous/new-api/relay/channel/claude"
+	"github.com/QuantumNous/new-api/relay/channel/gemini"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/sjson"
+)
+
+// errCountTokensUnsupported 渠道没有可用的上游计数接口，直接本地估算
+var errCountTokensUnsupported = errors.New("count tokens is not supported by channel")
+
+// countTokensAdaptor 复用渠道适配器的鉴权与请求头，只替换请求地址
+type countTokensAdaptor struct {
+	channel.Adaptor
+	url string
+}
+
+func (a *countTokensAdaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	return a.url, nil
+}
+
+// CountTokensHelper 统计请求的输入 token 数，渠道支持时调用上游计数接口，否则本地估算
+// 计数请求不预扣、不结算额度，也不记录消费日志
+func CountTokensHelper(c *gin.Context, info *relaycommon.RelayInfo) (int, *types.NewAPIError) {
+	info.InitChannelMeta(c)
+	info.IsStream = false
+
+	err := helper.ModelMappedHelper(c, info, info.Request)
+	if err != nil {
+		return 0, types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
+	}
+
+	tokens, err := countTokensUpstream(c, info)
+	if err == nil {
+		return tokens, nil
+	}
+	if !errors.Is(err, errCountTokensUnsupported) {
+		logger.LogWarn(c, fmt.Sprintf("upstream count tokens failed, fallback to local: %s", err.Error()))
+	}
+
+	tokens, err = countTokensLocal(c, info)
+	if err != nil {
+		return 0, types.NewError(err, types.ErrorCodeCountTokenFailed, types.ErrOptionWithSkipRetry())
+	}
+	return tokens, nil
+}
+
+func countTokensLocal(c *gin.Context, info *relaycommon.RelayInfo) (int, error) {
+	meta := info.Request.GetTokenCountMeta()
+	// GetTokenCountMeta 只统计已解析为结构体的工具，原始 JSON 工具定义在这里补上
+	if claudeRequest, ok := info.Request.(*dto.ClaudeRequest); ok && meta != nil && meta.ToolsCount == 0 && len(claudeRequest.GetTools()) > 0 {
+		b, _ := common.Marshal(claudeRequest.Tools)
+		meta.CombineText += "\n" + string(b)
+	}
+	return service.CountInputTokens(c, meta, info.OriginModelName)
+}
+
+func countTokensUpstream(c *gin.Context, info *relaycommon.RelayInfo) (int, error) {
+	adaptor := GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		return 0, errCountTokensUnsupported
+	}
+	adaptor.Init(info)
+
+	switch {
+	case info.RelayFormat == types.RelayFormatClaude && info.ChannelType == constant.ChannelTypeAnthropic:
+		return claudeCountTokensUpstream(c, info, adaptor)
+	case info.ChannelType == constant.ChannelTypeGemini:
+		return geminiCountTokensUpstream(c, info, adaptor)
+	}
+	return 0, errCountTokensUnsupported
+}
+
+func claudeCountTokensUpstream(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor) (int, error) {
+	if a, ok := adaptor.(*claude.Adaptor); !ok || a.RequestMode != claude.RequestModeMessage {
+		return 0, errCountTokensUnsupported
+	}
+	requestURL, err := adaptor.GetRequestURL(info)
+	if err != nil {
+		return 0, err
+	}
+	requestURL = strings.Replace(requestURL, "/v1/messages", "/v1/messages/count_tokens", 1)
+
+	// 与对话请求一致，去掉思考适配的模型后缀
+	if model_setting.GetClaudeSettings().ThinkingAdapterEnabled &&
+		!model_setting.ShouldPreserveThinkingSuffix(info.OriginModelName) {
+		info.UpstreamModelName = strings.TrimSuffix(info.UpstreamModelName, "-thinking")
+	}
+
+	// 透传原始请求体，只替换模型名，避免结构体序列化丢失上游新增的字段
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return 0, err
+	}
+	body, err = sjson.SetBytes(body, "model", info.UpstreamModelName)
+	if err != nil {
+		return 0, err
+	}
+
+	var response dto.ClaudeCountTokensResponse
+	if err = doCountTokensRequest(c, info, adaptor, requestURL, body, &response); err != nil {
+		return 0, err
+	}
+	return response.InputTokens, nil
+}
+
+func geminiCountTokensUpstream(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor) (int, error) {
+	requestURL, err := adaptor.GetRequestURL(info)
+	if err != nil {
+		return 0, err
+	}
+	// 嵌入、绘图模型没有 countTokens 接口
+	if !strings.HasSuffix(requestURL, ":generateContent") {
+		return 0, errCountTokensUnsupported
+	}
+	requestURL = strings.TrimSuffix(requestURL, ":generateContent") + ":countTokens"
+
+	var body []byte
+	switch info.RelayFormat {
+	case types.RelayFormatGemini:
+		body, err = common.GetRequestBody(c)
+		if err != nil {
+			return 0, err
+		}
+		if countRequest, ok := info.Request.(*dto.GeminiCountTokensRequest); ok && countRequest.GenerateContentRequest != nil {
+			body, err = sjson.SetBytes(body, "generateContentRequest.model", "models/"+info.UpstreamModelName)
+			if err != nil {
+				return 0, err
+			}
+		}
+	case types.RelayFormatClaude:
+		claudeRequest, ok := info.Request.(*dto.ClaudeRequest)
+		if !ok {
+			return 0, errCountTokensUnsupported
+		}
+		geminiRequest, err := gemini.ClaudeToGeminiRequest(c, claudeRequest, info)
+		if err != nil {
+			return 0, err
+		}
+		body, err = common.Marshal(geminiRequest)
+		if err != nil {
+			return 0, err
+		}
+		body, err = sjson.SetBytes(body, "model", "models/"+info.UpstreamModelName)
+		if err != nil {
+			return 0, err
+		}
+		body, err = sjson.SetRawBytes([]byte(`{}`), "generateContentRequest", body)
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, errCountTokensUnsupported
+	}
+
+	var response dto.GeminiCountTokensResponse
+	if err = doCountTokensRequest(c, info, adaptor, requestURL, body, &response); err != nil {
+		return 0, err
+	}
+	return response.TotalTokens, nil
+}
+
+func doCountTokensRequest(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, requestURL string, body []byte, response any) error {
+	resp, err := channel.DoApiRequest(&countTokensAdaptor{Adaptor: adaptor, url: requestURL}, c, info, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad response status code %d: %s", resp.StatusCode, string(respBody))
+	}
+	return common.Unmarshal(respBody, response)
+}
@@ -0,0 +1,94 @@
+package relay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/gin-gonic/gin"
+)
+
+const countTokensTestBody = `{"model":"claude-alias","messages":[{"role":"user","content":"hello world"}],"tools":[{"name":"get_weather","description":"查询天气","input_schema":{"type":"object"}}]}`
+
+func newCountTokensTestContext(t *testing.T, baseURL string) (*gin.Context, *relaycommon.RelayInfo) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	service.InitHttpClient()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages/count_tokens", strings.NewReader(countTokensTestBody))
+	c.Set(common.KeyRequestBody, []byte(countTokensTestBody))
+	common.SetContextKey(c, constant.ContextKeyChannelType, constant.ChannelTypeAnthropic)
+	common.SetContextKey(c, constant.ContextKeyChannelBaseUrl, baseURL)
+	common.SetContextKey(c, constant.ContextKeyChannelKey, "sk-test")
+	common.SetContextKey(c, constant.ContextKeyOriginalModel, "claude-alias")
+	common.SetContextKey(c, constant.ContextKeyChannelModelMapping, `{"claude-alias":"claude-sonnet-4-5"}`)
+
+	request := &dto.ClaudeRequest{}
+	if err := common.Unmarshal([]byte(countTokensTestBody), request); err != nil {
+		t.Fatal(err)
+	}
+	return c, relaycommon.GenRelayInfoClaude(c, request)
+}
+
+func TestCountTokensHelperForwardsToAnthropic(t *testing.T) {
+	var gotPath, gotModel, gotKey string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotKey = r.Header.Get("x-api-key")
+		body, _ := io.ReadAll(r.Body)
+		var request dto.ClaudeRequest
+		_ = common.Unmarshal(body, &request)
+		gotModel = request.Model
+		_, _ = w.Write([]byte(`{"input_tokens":42}`))
+	}))
+	defer server.Close()
+
+	c, info := newCountTokensTestContext(t, server.URL)
+	tokens, apiErr := CountTokensHelper(c, info)
+	if apiErr != nil {
+		t.Fatalf("unexpected error: %v", apiErr)
+	}
+	if tokens != 42 {
+		t.Errorf("tokens = %d, want 42", tokens)
+	}
+	if gotPath != "/v1/messages/count_tokens" {
+		t.Errorf("upstream path = %s", gotPath)
+	}
+	if gotModel != "claude-sonnet-4-5" {
+		t.Errorf("upstream model = %s, want mapped model", gotModel)
+	}
+	if gotKey != "sk-test" {
+		t.Errorf("upstream key = %s", gotKey)
+	}
+}
+
+func TestCountTokensHelperFallsBackToLocal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	c, info := newCountTokensTestContext(t, server.URL)
+	tokens, apiErr := CountTokensHelper(c, info)
+	if apiErr != nil {
+		t.Fatalf("unexpected error: %v", apiErr)
+	}
+
+	// 本地估算需要包含工具定义
+	c, info = newCountTokensTestContext(t, server.URL)
+	info.Request.(*dto.ClaudeRequest).Tools = nil
+	withoutTools, apiErr := CountTokensHelper(c, info)
+	if apiErr != nil {
+		t.Fatalf("unexpected error: %v", apiErr)
+	}
+	if withoutTools <= 0 || tokens <= withoutTools {
+		t.Errorf("local tokens = %d, without tools = %d", tokens, withoutTools)
+	}
+}
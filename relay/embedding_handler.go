@@ -83,5 +83,6 @@ func EmbeddingHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		return newAPIError
 	}
 	postConsumeQuota(c, info, usage.(*dto.Usage))
+	saveResponseCache(c, info, usage.(*dto.Usage))
 	return nil
 }
@@ -0,0 +1,151 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// resolvedFile 本地文件在当前渠道上的引用方式，UpstreamFileId 和 DataURL 二选一
+type resolvedFile struct {
+	File           *model.File
+	UpstreamFileId string
+	DataURL        string
+}
+
+// resolveFileReference 查找请求引用的本地文件，支持上传文件的渠道上传后使用上游 file_id，其它渠道内联为 data URL。
+// 不是本地文件时返回 nil，交给上游处理
+func resolveFileReference(c *gin.Context, info *relaycommon.RelayInfo, fileId string) (*resolvedFile, *types.NewAPIError) {
+	if fileId == "" {
+		return nil, nil
+	}
+	file, err := model.GetUserFile(info.UserId, fileId)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	if file == nil {
+		return nil, nil
+	}
+	if service.ShouldReuploadFile(info) {
+		upstreamFileId, err := service.EnsureUpstreamFile(c.Request.Context(), info, file)
+		if err != nil {
+			return nil, types.NewError(fmt.Errorf("upload file %s to upstream failed: %w", fileId, err), types.ErrorCodeDoRequestFailed)
+		}
+		return &resolvedFile{File: file, UpstreamFileId: upstreamFileId}, nil
+	}
+	dataURL, err := service.GetFileDataURL(file)
+	if err != nil {
+		if errors.Is(err, service.ErrFileTooLargeToInline) {
+			return nil, types.NewErrorWithStatusCode(fmt.Errorf("file %s: %w", fileId, err), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+		}
+		return nil, types.NewError(fmt.Errorf("read file %s failed: %w", fileId, err), types.ErrorCodeReadRequestBodyFailed, types.ErrOptionWithSkipRetry())
+	}
+	return &resolvedFile{File: file, DataURL: dataURL}, nil
+}
+
+// applyChatFileReferences 替换 Chat Completions 消息中 file 内容块引用的本地文件，返回请求是否被修改
+func applyChatFileReferences(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (bool, *types.NewAPIError) {
+	changed := false
+	for i := range request.Messages {
+		parts, ok := request.Messages[i].Content.([]any)
+		if !ok {
+			continue
+		}
+		for _, part := range parts {
+			partMap, ok := part.(map[string]any)
+			if !ok || partMap["type"] != dto.ContentTypeFile {
+				continue
+			}
+			fileMap, ok := partMap["file"].(map[string]any)
+			if !ok {
+				continue
+			}
+			fileId, _ := fileMap["file_id"].(string)
+			resolved, apiErr := resolveFileReference(c, info, fileId)
+			if apiErr != nil {
+				return false, apiErr
+			}
+			if resolved == nil {
+				continue
+			}
+			if resolved.UpstreamFileId != "" {
+				fileMap["file_id"] = resolved.UpstreamFileId
+			} else {
+				delete(fileMap, "file_id")
+				fileMap["filename"] = resolved.File.Filename
+				fileMap["file_data"] = resolved.DataURL
+			}
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// applyResponsesFileReferences 替换 Responses 请求中 input_file / input_image 引用的本地文件，返回请求是否被修改
+func applyResponsesFileReferences(c *gin.Context, info *relaycommon.RelayInfo, request *dto.OpenAIResponsesRequest) (bool, *types.NewAPIError) {
+	input := request.Input
+	if len(input) == 0 || !gjson.ValidBytes(input) {
+		return false, nil
+	}
+	changed := false
+	var apiErr *types.NewAPIError
+	var err error
+	gjson.ParseBytes(input).ForEach(func(itemKey, item gjson.Result) bool {
+		item.Get("content").ForEach(func(partKey, part gjson.Result) bool {
+			partType := part.Get("type").String()
+			if partType != "input_file" && partType != "input_image" {
+				return true
+			}
+			var resolved *resolvedFile
+			resolved, apiErr = resolveFileReference(c, info, part.Get("file_id").String())
+			if apiErr != nil {
+				return false
+			}
+			if resolved == nil {
+				return true
+			}
+			path := fmt.Sprintf("%d.content.%d", itemKey.Int(), partKey.Int())
+			switch {
+			case resolved.UpstreamFileId != "":
+				input, err = sjson.SetBytes(input, path+".file_id", resolved.UpstreamFileId)
+			case partType == "input_image":
+				input, err = sjson.DeleteBytes(input, path+".file_id")
+				if err == nil {
+					input, err = sjson.SetBytes(input, path+".image_url", resolved.DataURL)
+				}
+			default:
+				input, err = sjson.DeleteBytes(input, path+".file_id")
+				if err == nil {
+					input, err = sjson.SetBytes(input, path+".filename", resolved.File.Filename)
+				}
+				if err == nil {
+					input, err = sjson.SetBytes(input, path+".file_data", resolved.DataURL)
+				}
+			}
+			if err != nil {
+				return false
+			}
+			changed = true
+			return true
+		})
+		return apiErr == nil && err == nil
+	})
+	if apiErr != nil {
+		return false, apiErr
+	}
+	if err != nil {
+		return false, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	request.Input = input
+	return changed, nil
+}
@@ -0,0 +1,364 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 选择对冲渠道时最多尝试的次数
+const hedgeSelectAttempts = 3
+
+// hedgeRacer 参与对冲的一个上游请求
+type hedgeRacer struct {
+	c       *gin.Context
+	info    *relaycommon.RelayInfo
+	adaptor channel.Adaptor
+	cancel  context.CancelFunc
+	lease   *service.ChannelConcurrencyLease
+
+	resp any
+	err  error
+	// 是否已收到首字（非流式为完整的成功响应）
+	ok bool
+}
+
+func (r *hedgeRacer) run(body []byte, results chan<- *hedgeRacer) {
+	r.resp, r.err = r.adaptor.DoRequest(r.c, r.info, bytes.NewReader(body))
+	if r.err == nil {
+		r.ok = r.awaitFirstToken()
+	}
+	results <- r
+}
+
+func (r *hedgeRacer) awaitFirstToken() bool {
+	httpResp, ok := r.resp.(*http.Response)
+	if !ok || httpResp == nil || httpResp.StatusCode != http.StatusOK {
+		return false
+	}
+	if r.info.IsStream {
+		return channel.PeekFirstStreamData(httpResp) == nil
+	}
+	data, err := io.ReadAll(httpResp.Body)
+	_ = httpResp.Body.Close()
+	if err != nil {
+		r.resp, r.err = nil, err
+		return false
+	}
+	httpResp.Body = io.NopCloser(bytes.NewReader(data))
+	return true
+}
+
+// keep 返回请求结果，响应体关闭时释放 context 和并发槽
+func (r *hedgeRacer) keep() (any, error) {
+	if httpResp, ok := r.resp.(*http.Response); ok && httpResp != nil {
+		httpResp.Body = &hedgeBody{ReadCloser: httpResp.Body, release: r.release}
+	} else {
+		r.release()
+	}
+	return r.resp, r.err
+}
+
+// discard 取消请求并丢弃结果
+func (r *hedgeRacer) discard() {
+	if httpResp, ok := r.resp.(*http.Response); ok && httpResp != nil {
+		_ = httpResp.Body.Close()
+	}
+	r.release()
+}
+
+func (r *hedgeRacer) release() {
+	r.cancel()
+	r.lease.Release()
+}
+
+type hedgeBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *hedgeBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// doRequestWithHedge 命中对冲策略时同时（或等待策略设置的延迟后）向另一个渠道发送同一请求，
+// 返回先收到首字的结果并取消另一个请求；对冲渠道胜出时把 context 和 RelayInfo 切换到该渠道
+func doRequestWithHedge(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, requestBody io.Reader) (any, error) {
+	policy := getHedgePolicy(c, info)
+	if policy == nil {
+		return adaptor.DoRequest(c, info, requestBody)
+	}
+	body, err := io.ReadAll(requestBody)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsStream {
+		// 提前设置响应头，避免两个请求并发写入
+		helper.SetEventStreamHeaders(c)
+	}
+
+	// 两个请求都使用各自的 context 副本，只有胜出的请求写回 c；ping 由 raceHedge 统一发送
+	primaryInfo := *info
+	primaryInfo.DisablePing = true
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	primaryInfo.UpstreamContext = ctx
+	primary := &hedgeRacer{c: c.Copy(), info: &primaryInfo, adaptor: adaptor, cancel: cancel}
+	delay := time.Duration(policy.DelayMillis) * time.Millisecond
+	return raceHedge(c, info, body, primary, delay, func() *hedgeRacer {
+		return newHedgeRacer(c, info)
+	})
+}
+
+// raceHedge 发出主请求，delay 后通过 newHedge 发出对冲请求，返回胜出请求的结果。
+// 等待期间只有当前 goroutine 访问 c
+func raceHedge(c *gin.Context, info *relaycommon.RelayInfo, body []byte, primary *hedgeRacer, delay time.Duration, newHedge func() *hedgeRacer) (any, error) {
+	results := make(chan *hedgeRacer, 2)
+	go primary.run(body, results)
+	pending := 1
+
+	var hedge *hedgeRacer
+	fire := func() {
+		hedge = newHedge()
+		if hedge != nil {
+			logger.LogInfo(c, fmt.Sprintf("hedging request to channel #%d after %v", hedge.info.ChannelId, delay))
+			pending++
+			go hedge.run(body, results)
+		}
+	}
+	var timerC <-chan time.Time
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		timerC = timer.C
+	} else {
+		fire()
+	}
+	var pingC <-chan time.Time
+	if generalSettings := operation_setting.GetGeneralSetting(); info.IsStream && generalSettings.PingIntervalEnabled && !info.DisablePing {
+		pingInterval := time.Duration(generalSettings.PingIntervalSeconds) * time.Second
+		if pingInterval <= 0 {
+			pingInterval = helper.DefaultPingInterval
+		}
+		ping := time.NewTicker(pingInterval)
+		defer ping.Stop()
+		pingC = ping.C
+	}
+
+	var result *hedgeRacer
+	primaryDone := false
+	hedgeFailed := false
+	for result == nil {
+		select {
+		case <-timerC:
+			timerC = nil
+			fire()
+		case <-pingC:
+			if helper.PingData(c) != nil {
+				pingC = nil
+			}
+		case r := <-results:
+			pending--
+			switch {
+			case r.ok:
+				result = r
+			case r == primary:
+				primaryDone = true
+				if hedge == nil || pending == 0 {
+					// 对冲请求未发出或也已失败，按主请求的结果处理
+					result = primary
+				}
+			default:
+				hedgeFailed = true
+				if primaryDone {
+					result = primary
+				}
+				r.discard()
+			}
+		}
+	}
+
+	if hedge != nil {
+		loser, loserDone := hedge, hedgeFailed
+		if result == hedge {
+			loser, loserDone = primary, primaryDone
+			if primaryDone {
+				primary.discard()
+			}
+		}
+		if !loserDone {
+			loser.cancel()
+		}
+		recordHedgeResult(c, info, primary, hedge, result, loserDone, int(delay.Milliseconds()))
+	}
+	// 等待未结束的请求返回后释放资源
+	if pending > 0 {
+		go func(n int) {
+			for i := 0; i < n; i++ {
+				(<-results).discard()
+			}
+		}(pending)
+	}
+
+	adoptHedgeResult(c, info, result, result == hedge)
+	return result.keep()
+}
+
+// getHedgePolicy 只对首次尝试且未指定渠道的请求做对冲，续写和多次重试时不做对冲
+func getHedgePolicy(c *gin.Context, info *relaycommon.RelayInfo) *operation_setting.HedgePolicy {
+	if info.IsStreamContinuation() || len(c.GetStringSlice("use_channel")) > 1 {
+		return nil
+	}
+	if _, ok := c.Get("specific_channel_id"); ok {
+		return nil
+	}
+	return operation_setting.GetHedgePolicy(info.UsingGroup, info.OriginModelName)
+}
+
+// newHedgeRacer 选择与当前渠道不同且请求体可以通用的渠道，没有可用渠道时返回 nil
+func newHedgeRacer(c *gin.Context, info *relaycommon.RelayInfo) *hedgeRacer {
+	primaryChannel, err := model.CacheGetChannel(info.ChannelId)
+	if err != nil || primaryChannel == nil {
+		return nil
+	}
+	for i := 0; i < hedgeSelectAttempts; i++ {
+		hedgeCtx := c.Copy()
+		retry := 0
+		candidate, selectGroup, err := service.CacheGetRandomSatisfiedChannel(&service.RetryParam{
+			Ctx:        hedgeCtx,
+			TokenGroup: info.TokenGroup,
+			ModelName:  info.OriginModelName,
+			Retry:      &retry,
+		})
+		if err != nil || candidate == nil {
+			return nil
+		}
+		if selectGroup != info.UsingGroup || !isHedgeCompatible(info, primaryChannel, candidate) {
+			continue
+		}
+		if service.SetupContextForSelectedChannel(hedgeCtx, candidate, info.OriginModelName) != nil {
+			continue
+		}
+		lease, leaseErr := service.AcquireChannelConcurrency(hedgeCtx, candidate.Id, false)
+		if leaseErr != nil {
+			continue
+		}
+
+		hedgeInfo := *info
+		// InitChannelMeta 会重置请求的模型名，请求体已经生成，这里不需要
+		hedgeInfo.Request = nil
+		hedgeInfo.InitChannelMeta(hedgeCtx)
+		hedgeInfo.Request = info.Request
+		hedgeInfo.UpstreamModelName = info.UpstreamModelName
+		hedgeInfo.IsModelMapped = info.IsModelMapped
+		hedgeInfo.DisablePing = true
+		adaptor := GetAdaptor(hedgeInfo.ApiType)
+		if adaptor == nil {
+			lease.Release()
+			return nil
+		}
+		adaptor.Init(&hedgeInfo)
+		ctx, cancel := context.WithCancel(c.Request.Context())
+		hedgeInfo.UpstreamContext = ctx
+		return &hedgeRacer{c: hedgeCtx, info: &hedgeInfo, adaptor: adaptor, cancel: cancel, lease: lease}
+	}
+	return nil
+}
+
+// isHedgeCompatible 请求体按主渠道生成，对冲渠道的类型、模型映射和影响请求体的设置都必须与主渠道一致
+func isHedgeCompatible(info *relaycommon.RelayInfo, primary *model.Channel, candidate *model.Channel) bool {
+	if candidate.Id == primary.Id || candidate.Type != primary.Type || candidate.Other != primary.Other {
+		return false
+	}
+	if len(info.ParamOverride) > 0 || len(candidate.GetParamOverride()) > 0 {
+		return false
+	}
+	if hedgeUpstreamModel(candidate, info.OriginModelName) != info.UpstreamModelName {
+		return false
+	}
+	primarySetting, candidateSetting := primary.GetSetting(), candidate.GetSetting()
+	primarySetting.Proxy, candidateSetting.Proxy = "", ""
+	if !reflect.DeepEqual(primarySetting, candidateSetting) {
+		return false
+	}
+	primaryOther, candidateOther := primary.GetOtherSettings(), candidate.GetOtherSettings()
+	resetHedgeIrrelevantSettings(&primaryOther)
+	resetHedgeIrrelevantSettings(&candidateOther)
+	return reflect.DeepEqual(primaryOther, candidateOther)
+}
+
+// resetHedgeIrrelevantSettings 清除不影响请求体的渠道设置
+func resetHedgeIrrelevantSettings(settings *dto.ChannelOtherSettings) {
+	settings.MaxConcurrency = 0
+	settings.FirstTokenTimeoutSeconds = 0
+}
+
+func hedgeUpstreamModel(candidate *model.Channel, modelName string) string {
+	mapping := candidate.GetModelMapping()
+	if mapping == "" || mapping == "{}" {
+		return modelName
+	}
+	modelMap := make(map[string]string)
+	if err := common.Unmarshal([]byte(mapping), &modelMap); err != nil {
+		return ""
+	}
+	if mapped := modelMap[modelName]; mapped != "" {
+		return mapped
+	}
+	return modelName
+}
+
+// adoptHedgeResult 把胜出请求 context 中的内容写回 c；对冲渠道胜出时后续的响应处理、计费和日志都按该渠道进行
+func adoptHedgeResult(c *gin.Context, info *relaycommon.RelayInfo, result *hedgeRacer, isHedge bool) {
+	usedChannels := c.GetStringSlice("use_channel")
+	for key, value := range result.c.Keys {
+		c.Set(key, value)
+	}
+	c.Set("use_channel", usedChannels)
+	if isHedge {
+		info.ChannelMeta = result.info.ChannelMeta
+		ServeResponseOverride(c, info)
+	}
+}
+
+func recordHedgeResult(c *gin.Context, info *relaycommon.RelayInfo, primary *hedgeRacer, hedge *hedgeRacer, winner *hedgeRacer, loserFailed bool, delayMillis int) {
+	c.Set("use_channel", append(c.GetStringSlice("use_channel"), strconv.Itoa(hedge.info.ChannelId)))
+	hedgeInfo := &relaycommon.HedgeInfo{
+		PrimaryChannelId: primary.info.ChannelId,
+		HedgeChannelId:   hedge.info.ChannelId,
+		WinnerChannelId:  winner.info.ChannelId,
+		DelayMillis:      delayMillis,
+		LoserStatus:      "cancelled",
+	}
+	if !winner.ok {
+		// 两个请求都失败
+		hedgeInfo.WinnerChannelId = 0
+		hedgeInfo.LoserStatus = "failed"
+	} else if loserFailed {
+		hedgeInfo.LoserStatus = "failed"
+	} else {
+		// 被取消的请求已经发送到上游，输入部分可能已被计费
+		hedgeInfo.ExtraPromptTokens = info.GetEstimatePromptTokens()
+	}
+	info.Hedge = hedgeInfo
+	logger.LogInfo(c, fmt.Sprintf("hedged request: primary channel #%d, hedge channel #%d, winner channel #%d, loser %s",
+		hedgeInfo.PrimaryChannelId, hedgeInfo.HedgeChannelId, hedgeInfo.WinnerChannelId, hedgeInfo.LoserStatus))
+}
@@ -0,0 +1,141 @@
+package relay
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hedgeTestAdaptor 等待 delay 后返回 status，期间请求被取消时关闭 cancelled
+type hedgeTestAdaptor struct {
+	channel.Adaptor
+	delay     time.Duration
+	status    int
+	cancelled chan struct{}
+	done      chan struct{}
+}
+
+func newHedgeTestAdaptor(delay time.Duration, status int) *hedgeTestAdaptor {
+	return &hedgeTestAdaptor{delay: delay, status: status, cancelled: make(chan struct{}), done: make(chan struct{})}
+}
+
+func (a *hedgeTestAdaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
+	defer close(a.done)
+	// 每个请求都会写自己的 context，验证只有胜出的请求被写回
+	defer c.Set("hedge_test_channel", info.ChannelId)
+	select {
+	case <-time.After(a.delay):
+	case <-info.UpstreamContext.Done():
+		close(a.cancelled)
+		return nil, info.UpstreamContext.Err()
+	}
+	return &http.Response{StatusCode: a.status, Body: io.NopCloser(strings.NewReader(`{}`))}, nil
+}
+
+func newHedgeTestRacer(c *gin.Context, channelId int, adaptor *hedgeTestAdaptor) *hedgeRacer {
+	ctx, cancel := context.WithCancel(context.Background())
+	info := &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{ChannelId: channelId}, UpstreamContext: ctx}
+	return &hedgeRacer{c: c.Copy(), info: info, adaptor: adaptor, cancel: cancel}
+}
+
+// raceHedgeTest 以渠道 1 为主请求、渠道 2 为对冲请求进行对冲
+func raceHedgeTest(t *testing.T, primary *hedgeTestAdaptor, hedge *hedgeTestAdaptor, delay time.Duration) (*gin.Context, *relaycommon.RelayInfo, *http.Response) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Set("use_channel", []string{"1"})
+	info := &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{ChannelId: 1}}
+
+	resp, err := raceHedge(c, info, []byte(`{}`), newHedgeTestRacer(c, 1, primary), delay, func() *hedgeRacer {
+		return newHedgeTestRacer(c, 2, hedge)
+	})
+	httpResp, _ := resp.(*http.Response)
+	if err != nil || httpResp == nil {
+		t.Fatalf("raceHedge = %v, %v", resp, err)
+	}
+	t.Cleanup(func() { _ = httpResp.Body.Close() })
+	return c, info, httpResp
+}
+
+func awaitHedgeTest(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestRaceHedgeWinnerSwitch(t *testing.T) {
+	primary := newHedgeTestAdaptor(time.Minute, http.StatusOK)
+	hedge := newHedgeTestAdaptor(0, http.StatusOK)
+	c, info, _ := raceHedgeTest(t, primary, hedge, 10*time.Millisecond)
+
+	// 落后的主请求被取消，结束时写入的 context 不会影响 c
+	awaitHedgeTest(t, primary.cancelled, "primary cancel")
+	awaitHedgeTest(t, primary.done, "primary return")
+	if got := c.GetInt("hedge_test_channel"); got != 2 {
+		t.Errorf("context written by channel %d, want hedge channel 2", got)
+	}
+	if info.ChannelId != 2 {
+		t.Errorf("relay info channel = %d, want 2", info.ChannelId)
+	}
+	if used := c.GetStringSlice("use_channel"); len(used) != 2 || used[1] != "2" {
+		t.Errorf("use_channel = %v", used)
+	}
+	if info.Hedge == nil || info.Hedge.WinnerChannelId != 2 || info.Hedge.LoserStatus != "cancelled" {
+		t.Fatalf("hedge info = %+v", info.Hedge)
+	}
+}
+
+func TestRaceHedgePrimaryWins(t *testing.T) {
+	primary := newHedgeTestAdaptor(10*time.Millisecond, http.StatusOK)
+	hedge := newHedgeTestAdaptor(time.Minute, http.StatusOK)
+	c, info, _ := raceHedgeTest(t, primary, hedge, 0)
+
+	awaitHedgeTest(t, hedge.cancelled, "hedge cancel")
+	awaitHedgeTest(t, hedge.done, "hedge return")
+	if got := c.GetInt("hedge_test_channel"); got != 1 {
+		t.Errorf("context written by channel %d, want primary channel 1", got)
+	}
+	if info.ChannelId != 1 {
+		t.Errorf("relay info channel = %d, want 1", info.ChannelId)
+	}
+	if info.Hedge == nil || info.Hedge.WinnerChannelId != 1 || info.Hedge.LoserStatus != "cancelled" {
+		t.Fatalf("hedge info = %+v", info.Hedge)
+	}
+}
+
+func TestRaceHedgeFailedRacer(t *testing.T) {
+	// 先返回的对冲请求失败时继续等待主请求
+	primary := newHedgeTestAdaptor(50*time.Millisecond, http.StatusOK)
+	hedge := newHedgeTestAdaptor(0, http.StatusInternalServerError)
+	_, info, resp := raceHedgeTest(t, primary, hedge, 0)
+	if resp.StatusCode != http.StatusOK || info.ChannelId != 1 {
+		t.Fatalf("status = %d, channel = %d", resp.StatusCode, info.ChannelId)
+	}
+	if info.Hedge.WinnerChannelId != 1 || info.Hedge.LoserStatus != "failed" {
+		t.Fatalf("hedge info = %+v", info.Hedge)
+	}
+
+	// 两个请求都失败时按主请求的结果处理
+	primary = newHedgeTestAdaptor(50*time.Millisecond, http.StatusBadGateway)
+	hedge = newHedgeTestAdaptor(0, http.StatusInternalServerError)
+	_, info, resp = raceHedgeTest(t, primary, hedge, 0)
+	if resp.StatusCode != http.StatusBadGateway || info.ChannelId != 1 {
+		t.Fatalf("status = %d, channel = %d", resp.StatusCode, info.ChannelId)
+	}
+	if info.Hedge.WinnerChannelId != 0 || info.Hedge.LoserStatus != "failed" {
+		t.Fatalf("hedge info = %+v", info.Hedge)
+	}
+}
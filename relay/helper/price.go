@@ -44,6 +44,11 @@ func HandleGroupRatio(ctx *gin.Context, relayInfo *relaycommon.RelayInfo) types.
 		groupRatioInfo.GroupRatio = ratio_setting.GetGroupRatio(relayInfo.UsingGroup)
 	}
 
+	// 批处理任务在分组倍率基础上再乘以批处理折扣
+	if ctx.Request != nil && relaycommon.GetBatchRequestId(ctx.Request.Context()) != "" {
+		groupRatioInfo.GroupRatio *= ratio_setting.GetBatchGroupRatio(relayInfo.UsingGroup)
+	}
+
 	return groupRatioInfo
 }
 
@@ -8,17 +8,20 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/pkg/tracing"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 
 	"github.com/bytedance/gopkg/util/gopool"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -34,12 +37,20 @@ func getScannerBufferSize() int {
 	return DefaultMaxScannerBufferSize
 }
 
-func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, dataHandler func(data string) bool) {
+// StreamScannerHandler 逐行读取 SSE 响应并交给 dataHandler 处理，返回是否收到了 [DONE] 结束标志
+func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, dataHandler func(data string) bool) (doneReceived bool) {
 
 	if resp == nil || dataHandler == nil {
 		return
 	}
 
+	_, span := tracing.StartSpan(c.Request.Context(), "stream_response",
+		tracing.AttrChannelId.Int(info.ChannelId), tracing.AttrModel.String(info.OriginModelName))
+	defer func() {
+		span.SetAttributes(attribute.Int("newapi.stream.chunks", info.SendResponseCount))
+		span.End()
+	}()
+
 	// 确保响应体总是被关闭
 	defer func() {
 		if resp.Body != nil {
@@ -59,6 +70,7 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		pingTicker *time.Ticker
 		writeMutex sync.Mutex     // Mutex to protect concurrent writes
 		wg         sync.WaitGroup // 用于等待所有 goroutine 退出
+		sawDone    atomic.Bool
 	)
 
 	generalSettings := operation_setting.GetGeneralSetting()
@@ -105,6 +117,7 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		}
 
 		close(stopChan)
+		doneReceived = sawDone.Load()
 	}()
 
 	scanner.Buffer(make([]byte, InitialScannerBufferSize), getScannerBufferSize())
@@ -246,6 +259,7 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 				}
 			} else {
 				// done, 处理完成标志，直接退出停止读取剩余数据防止出错
+				sawDone.Store(true)
 				if common.DebugEnabled {
 					println("received [DONE], stopping scanner")
 				}
@@ -272,4 +286,5 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		// 客户端断开连接
 		logger.LogInfo(c, "client disconnected")
 	}
+	return
 }
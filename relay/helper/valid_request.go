@@ -321,6 +321,18 @@ func GetAndValidateGeminiRequest(c *gin.Context) (*dto.GeminiChatRequest, error)
 	return request, nil
 }
 
+func GetAndValidateGeminiCountTokensRequest(c *gin.Context) (*dto.GeminiCountTokensRequest, error) {
+	request := &dto.GeminiCountTokensRequest{}
+	err := common.UnmarshalBodyReusable(c, request)
+	if err != nil {
+		return nil, err
+	}
+	if len(request.ChatRequest().Contents) == 0 {
+		return nil, errors.New("contents is required")
+	}
+	return request, nil
+}
+
 func GetAndValidateGeminiEmbeddingRequest(c *gin.Context) (*dto.GeminiEmbeddingRequest, error) {
 	request := &dto.GeminiEmbeddingRequest{}
 	err := common.UnmarshalBodyReusable(c, request)
@@ -0,0 +1,213 @@
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModeratePrompt 按分组配置使用审核模型检查提示词，处理方式为 block 且命中时返回错误
+func ModeratePrompt(c *gin.Context, info *relaycommon.RelayInfo, text string) *types.NewAPIError {
+	setting := operation_setting.GetModerationSetting()
+	action := setting.ActionForGroup(info.UsingGroup)
+	if action == "" {
+		return nil
+	}
+	info.Moderation = &relaycommon.ModerationInfo{Action: action}
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	categories, err := moderateText(c, info.UserId, text)
+	if err != nil {
+		return moderationFailed(c, setting, err)
+	}
+	if len(categories) == 0 {
+		return nil
+	}
+	info.Moderation.PromptCategories = categories
+	logger.LogWarn(c, fmt.Sprintf("prompt moderation %s: model=%s, group=%s, categories=%s",
+		action, info.OriginModelName, info.UsingGroup, strings.Join(categories, ", ")))
+	if action != operation_setting.ModerationActionBlock {
+		return nil
+	}
+	return types.NewErrorWithStatusCode(fmt.Errorf("prompt flagged by content moderation: %s", strings.Join(categories, ", ")),
+		types.ErrorCodeModerationFlagged, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+}
+
+// moderateCompletion 使用审核模型检查非流式响应，返回是否需要拒绝
+func moderateCompletion(c *gin.Context, info *relaycommon.RelayInfo, body []byte) bool {
+	setting := operation_setting.GetModerationSetting()
+	text := service.OutputText(body)
+	if strings.TrimSpace(text) == "" {
+		return false
+	}
+	categories, err := moderateText(c, info.UserId, text)
+	if err != nil {
+		return moderationFailed(c, setting, err) != nil
+	}
+	if len(categories) == 0 {
+		return false
+	}
+	info.Moderation.CompletionCategories = categories
+	logger.LogWarn(c, fmt.Sprintf("completion moderation %s: model=%s, group=%s, categories=%s",
+		info.Moderation.Action, info.OriginModelName, info.UsingGroup, strings.Join(categories, ", ")))
+	return info.Moderation.Action == operation_setting.ModerationActionBlock
+}
+
+func moderationFailed(c *gin.Context, setting *operation_setting.ModerationSetting, err error) *types.NewAPIError {
+	logger.LogError(c, "moderation request failed: "+err.Error())
+	if setting.FailOpen {
+		return nil
+	}
+	return types.NewErrorWithStatusCode(errors.New("content moderation is unavailable"),
+		types.ErrorCodeModerationFailed, http.StatusServiceUnavailable, types.ErrOptionWithSkipRetry())
+}
+
+// moderateText 通过审核模型渠道对文本分类，返回超过阈值的分类
+func moderateText(c *gin.Context, userId int, text string) ([]string, error) {
+	setting := operation_setting.GetModerationSetting()
+	text = service.TruncateModerationInput(text, setting.MaxInputChars)
+
+	channel, err := model.GetRandomSatisfiedChannel(setting.ChannelGroup, setting.Model, 0)
+	if err != nil {
+		return nil, err
+	}
+	if channel == nil {
+		return nil, fmt.Errorf("分组 %s 下模型 %s 的可用渠道不存在", setting.ChannelGroup, setting.Model)
+	}
+
+	requestPath := "/v1/moderations"
+	request := &dto.GeneralOpenAIRequest{Model: setting.Model, Input: text}
+	if setting.Provider == operation_setting.ModerationProviderGuard {
+		requestPath = "/v1/chat/completions"
+		request = &dto.GeneralOpenAIRequest{
+			Model:    setting.Model,
+			Messages: []dto.Message{{Role: "user", Content: text}},
+		}
+	}
+
+	// 与渠道测试相同，使用独立的 context 调用渠道适配器，响应不会写给客户端
+	w := httptest.NewRecorder()
+	ic, _ := gin.CreateTestContext(w)
+	ic.Request = (&http.Request{
+		Method: http.MethodPost,
+		URL:    &url.URL{Path: requestPath},
+		Header: make(http.Header),
+	}).WithContext(c.Request.Context())
+	ic.Request.Header.Set("Content-Type", "application/json")
+	ic.Set(common.RequestIdKey, c.GetString(common.RequestIdKey))
+	// 审核请求的费用从超级管理员的额度中扣除，不向发起请求的用户收取
+	rootUser := model.GetRootUser()
+	if rootUser == nil || rootUser.Id == 0 {
+		return nil, errors.New("root user not found")
+	}
+	cache, err := model.GetUserCache(rootUser.Id)
+	if err != nil {
+		return nil, err
+	}
+	cache.WriteContext(ic)
+	common.SetContextKey(ic, constant.ContextKeyUsingGroup, setting.ChannelGroup)
+	if apiErr := service.SetupContextForSelectedChannel(ic, channel, setting.Model); apiErr != nil {
+		return nil, apiErr
+	}
+
+	info := relaycommon.GenRelayInfoOpenAI(ic, request)
+	info.InitChannelMeta(ic)
+	if err := helper.ModelMappedHelper(ic, info, request); err != nil {
+		return nil, err
+	}
+	request.Model = info.UpstreamModelName
+
+	apiType, _ := common.ChannelType2APIType(channel.Type)
+	adaptor := GetAdaptor(apiType)
+	if adaptor == nil {
+		return nil, fmt.Errorf("invalid api type: %d", apiType)
+	}
+	adaptor.Init(info)
+	convertedRequest, err := adaptor.ConvertOpenAIRequest(ic, info, request)
+	if err != nil {
+		return nil, err
+	}
+	jsonData, err := common.Marshal(convertedRequest)
+	if err != nil {
+		return nil, err
+	}
+	ic.Request.Body = io.NopCloser(bytes.NewReader(jsonData))
+	resp, err := adaptor.DoRequest(ic, info, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	httpResp, _ := resp.(*http.Response)
+	if httpResp == nil {
+		return nil, errors.New("empty moderation response")
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, service.RelayErrorHandler(ic.Request.Context(), httpResp, true)
+	}
+	usageA, apiErr := adaptor.DoResponse(ic, httpResp, info)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	scores, err := service.ParseModerationScores(setting.Provider, w.Body.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if usage, ok := usageA.(*dto.Usage); ok && usage != nil {
+		recordModerationConsume(ic, info, channel.Id, rootUser.Id, userId, usage)
+	}
+	return service.FlaggedModerationCategories(setting, scores), nil
+}
+
+// recordModerationConsume 从 billingUserId 的额度中扣除审核请求的费用并记录消费日志，计入渠道用量
+func recordModerationConsume(c *gin.Context, info *relaycommon.RelayInfo, channelId int, billingUserId int, userId int, usage *dto.Usage) {
+	priceData, err := helper.ModelPriceHelper(c, info, usage.PromptTokens, &types.TokenCountMeta{})
+	if err != nil {
+		// 审核模型未设置价格时不计费
+		priceData = types.PriceData{}
+	}
+	quota := 0
+	if priceData.UsePrice {
+		quota = int(priceData.ModelPrice * common.QuotaPerUnit)
+	} else {
+		quota = usage.PromptTokens + int(math.Round(float64(usage.CompletionTokens)*priceData.CompletionRatio))
+		quota = int(math.Round(float64(quota) * priceData.ModelRatio))
+	}
+	if quota > 0 {
+		if err := model.DecreaseUserQuota(billingUserId, quota); err != nil {
+			logger.LogError(c, "failed to consume moderation quota: "+err.Error())
+			return
+		}
+		model.UpdateUserUsedQuotaAndRequestCount(billingUserId, quota)
+		model.UpdateChannelUsedQuota(channelId, quota)
+	}
+	model.RecordConsumeLog(c, billingUserId, model.RecordConsumeLogParams{
+		ChannelId:        channelId,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		ModelName:        info.OriginModelName,
+		TokenName:        "内容审核",
+		Quota:            quota,
+		Content:          fmt.Sprintf("内容审核，用户 %d", userId),
+		Group:            info.UsingGroup,
+	})
+}
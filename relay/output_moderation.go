@@ -0,0 +1,190 @@
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// outputModerationWriter 在写给客户端前检测响应中的生成文本，按配置替换敏感词或中断响应
+type outputModerationWriter struct {
+	gin.ResponseWriter
+	c    *gin.Context
+	info *relaycommon.RelayInfo
+	// 客户端请求的格式，转换格式请求上游时 info.RelayFormat 会临时改变
+	format types.RelayFormat
+	// 敏感词的处理方式，为空时不检测敏感词
+	action string
+	// 是否使用审核模型检查非流式响应
+	checkCompletion bool
+	mode            responseWriterMode
+	buf             bytes.Buffer
+	moderator       *service.OutputStreamModerator
+	finished        bool
+}
+
+// ServeOutputModeration 按分组配置为 OpenAI、Claude、Gemini 和 Responses 格式的请求开启响应内容审核
+func ServeOutputModeration(c *gin.Context, info *relaycommon.RelayInfo) {
+	switch info.RelayFormat {
+	case types.RelayFormatOpenAI, types.RelayFormatClaude, types.RelayFormatGemini, types.RelayFormatOpenAIResponses:
+	default:
+		return
+	}
+	action := ""
+	if setting.CheckSensitiveEnabled && len(setting.SensitiveWords) > 0 {
+		action = operation_setting.GetOutputModerationSetting().ActionForGroup(info.UsingGroup)
+	}
+	// 审核模型只检查非流式响应，流式响应已发送的内容无法撤回
+	checkCompletion := info.Moderation != nil && !info.IsStream && operation_setting.GetModerationSetting().CheckCompletion
+	if action == "" && !checkCompletion {
+		return
+	}
+	if action != "" {
+		info.OutputModeration = &relaycommon.OutputModerationInfo{Action: action}
+	}
+	c.Writer = &outputModerationWriter{ResponseWriter: c.Writer, c: c, info: info, format: info.RelayFormat, action: action, checkCompletion: checkCompletion}
+}
+
+func (w *outputModerationWriter) abort() bool {
+	return w.action == operation_setting.OutputModerationActionAbort
+}
+
+// selectMode 按首次写入时的状态码和 Content-Type 选择处理方式
+func (w *outputModerationWriter) selectMode() {
+	if w.mode != writerModePending {
+		return
+	}
+	w.mode = writerModePassthrough
+	if w.finished || w.ResponseWriter.Status() != http.StatusOK || w.Header().Get("Content-Encoding") != "" {
+		return
+	}
+	contentType := w.Header().Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "text/event-stream") && w.action != "":
+		w.mode = writerModeStream
+		w.moderator = service.NewOutputStreamModerator(w.format, w.abort())
+	case strings.HasPrefix(contentType, "application/json"):
+		w.mode = writerModeJSON
+	}
+}
+
+func (w *outputModerationWriter) Write(data []byte) (int, error) {
+	w.selectMode()
+	switch w.mode {
+	case writerModeStream:
+		w.buf.Write(data)
+		return len(data), w.writeEvents()
+	case writerModeJSON:
+		w.buf.Write(data)
+		// 响应体可能分多次写入，完整后再检测
+		if gjson.ValidBytes(w.buf.Bytes()) {
+			return len(data), w.writeJSON()
+		}
+		return len(data), nil
+	default:
+		return w.ResponseWriter.Write(data)
+	}
+}
+
+func (w *outputModerationWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// writeEvents 逐个处理已完整的 SSE 事件
+func (w *outputModerationWriter) writeEvents() error {
+	return nextSSEEvents(&w.buf, func(event string) error {
+		return w.writeModerated(w.moderator.Push(event))
+	})
+}
+
+func (w *outputModerationWriter) writeModerated(out string) error {
+	w.recordWords(w.moderator.Words())
+	if w.moderator.Aborted() {
+		w.info.OutputModeration.Aborted = true
+	}
+	if out == "" {
+		return nil
+	}
+	_, err := w.ResponseWriter.WriteString(out)
+	return err
+}
+
+func (w *outputModerationWriter) writeJSON() error {
+	body := bytes.Clone(w.buf.Bytes())
+	w.buf.Reset()
+	w.mode = writerModePassthrough
+
+	if w.checkCompletion && moderateCompletion(w.c, w.info, body) {
+		body = w.abortBody(types.ErrorCodeModerationFlagged)
+		w.ResponseWriter.WriteHeader(http.StatusBadRequest)
+	} else if w.action != "" {
+		var words []string
+		body, words = service.ModerateOutputBody(body)
+		w.recordWords(words)
+		if len(words) > 0 && w.abort() {
+			w.info.OutputModeration.Aborted = true
+			body = w.abortBody(types.ErrorCodeSensitiveWordsDetected)
+			w.ResponseWriter.WriteHeader(http.StatusBadRequest)
+		}
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	_, err := w.ResponseWriter.Write(body)
+	return err
+}
+
+func (w *outputModerationWriter) abortBody(code types.ErrorCode) []byte {
+	apiErr := types.NewErrorWithStatusCode(errors.New("response blocked by content moderation"), code, http.StatusBadRequest)
+	apiErr.SetMessage(common.MessageWithRequestId(apiErr.Error(), w.c.GetString(common.RequestIdKey)))
+	var body []byte
+	if w.format == types.RelayFormatClaude {
+		body, _ = common.Marshal(gin.H{"type": "error", "error": apiErr.ToClaudeError()})
+	} else {
+		body, _ = common.Marshal(gin.H{"error": apiErr.ToOpenAIError()})
+	}
+	return body
+}
+
+func (w *outputModerationWriter) recordWords(words []string) {
+	w.info.OutputModeration.Words = words
+}
+
+func (w *outputModerationWriter) finish() {
+	if w.finished {
+		return
+	}
+	w.finished = true
+	switch w.mode {
+	case writerModeStream:
+		if w.buf.Len() > 0 {
+			_ = w.writeModerated(w.moderator.Push(strings.TrimRight(w.buf.String(), "\n")))
+			w.buf.Reset()
+		}
+		_ = w.writeModerated(w.moderator.Flush())
+		w.ResponseWriter.Flush()
+	case writerModeJSON:
+		// 响应体不是完整的 JSON，原样发送
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+	}
+	w.mode = writerModePassthrough
+
+	moderation := w.info.OutputModeration
+	if moderation != nil && len(moderation.Words) > 0 {
+		logger.LogWarn(w.c, fmt.Sprintf("output moderation %s: model=%s, group=%s, words=%v, aborted=%t",
+			moderation.Action, w.info.OriginModelName, w.info.UsingGroup, moderation.Words, moderation.Aborted))
+	}
+}
@@ -0,0 +1,125 @@
+package relay
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseCaptureWriter 在写给客户端的同时记录响应内容，请求成功后写入响应缓存
+type responseCaptureWriter struct {
+	gin.ResponseWriter
+	buf      bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (w *responseCaptureWriter) capture(n int, write func()) {
+	if w.overflow {
+		return
+	}
+	if w.limit > 0 && w.buf.Len()+n > w.limit {
+		w.overflow = true
+		w.buf = bytes.Buffer{}
+		return
+	}
+	write()
+}
+
+func (w *responseCaptureWriter) Write(data []byte) (int, error) {
+	w.capture(len(data), func() { w.buf.Write(data) })
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseCaptureWriter) WriteString(s string) (int, error) {
+	w.capture(len(s), func() { w.buf.WriteString(s) })
+	return w.ResponseWriter.WriteString(s)
+}
+
+// ServeResponseCache 命中响应缓存时直接返回缓存的响应并按命中倍率计费，返回是否已处理请求。
+// 未命中时记录本次响应，请求成功后写入缓存
+func ServeResponseCache(c *gin.Context, info *relaycommon.RelayInfo) bool {
+	key := service.GetResponseCacheKey(c, info)
+	if key == "" {
+		return false
+	}
+	setting := operation_setting.GetResponseCacheSetting()
+	entry, ok := service.GetResponseCache(key)
+	if !ok {
+		info.ResponseCacheKey = key
+		c.Writer = &responseCaptureWriter{ResponseWriter: c.Writer, limit: setting.MaxResponseKB << 10}
+		return false
+	}
+
+	// 计费和日志需要渠道信息，使用分发时选中的渠道，但不会请求该渠道
+	info.InitChannelMeta(c)
+	info.ResponseCacheHit = true
+	info.IsStream = entry.IsStream
+	if info.PriceData.OtherRatios == nil {
+		info.PriceData.OtherRatios = make(map[string]float64)
+	}
+	info.PriceData.OtherRatios[service.ResponseCacheHitRatioKey] = max(setting.HitRatio, 0)
+
+	c.Header("X-Response-Cache", "hit")
+	info.SetFirstResponseTime()
+	if entry.IsStream {
+		replayCachedStream(c, entry.Body)
+	} else {
+		c.Data(http.StatusOK, entry.ContentType, entry.Body)
+	}
+	usage := entry.Usage
+	postConsumeQuota(c, info, &usage)
+	return true
+}
+
+// replayCachedStream 按事件逐个发送缓存的 SSE 数据
+func replayCachedStream(c *gin.Context, body []byte) {
+	helper.SetEventStreamHeaders(c)
+	c.Status(http.StatusOK)
+	for _, event := range strings.SplitAfter(string(body), "\n\n") {
+		if event == "" {
+			continue
+		}
+		if _, err := c.Writer.WriteString(event); err != nil {
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// saveResponseCache 请求成功后把记录的响应写入缓存
+func saveResponseCache(c *gin.Context, info *relaycommon.RelayInfo, usage *dto.Usage) {
+	if info.ResponseCacheKey == "" || usage == nil {
+		return
+	}
+	// 响应覆盖和审核安装在响应缓存之后，缓存记录的是改写后的内容
+	w, ok := unwrapResponseWriter(c.Writer).(*responseCaptureWriter)
+	if !ok || w.overflow || w.buf.Len() == 0 || w.Status() != http.StatusOK {
+		return
+	}
+	// 缓存按分组和模型共享，按渠道规则改写过的响应不缓存
+	if isResponseOverridden(c) {
+		return
+	}
+	// 换渠道续写的响应由多个上游拼接而成，不缓存
+	if info.StreamFailover != nil && info.StreamFailover.Failovers > 0 {
+		return
+	}
+	entry := &service.ResponseCacheEntry{
+		ContentType: w.Header().Get("Content-Type"),
+		IsStream:    info.IsStream,
+		Body:        bytes.Clone(w.buf.Bytes()),
+		Usage:       *usage,
+	}
+	service.SetResponseCache(info.ResponseCacheKey, entry, service.GetResponseCacheTTL(c))
+	logger.LogDebug(c, "response cached: "+info.ResponseCacheKey)
+}
@@ -0,0 +1,163 @@
+package relay
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// responseOverrideWriter 按渠道的响应覆盖规则改写非流式响应和每个 SSE 分片。
+// 规则在首次写入时读取，重试换渠道后使用实际返回响应的渠道的规则
+type responseOverrideWriter struct {
+	gin.ResponseWriter
+	c         *gin.Context
+	info      *relaycommon.RelayInfo
+	mode      responseWriterMode
+	rules     map[string]interface{}
+	ruleCtx   map[string]interface{}
+	buf       bytes.Buffer
+	finished  bool
+	errLogged bool
+}
+
+// ServeResponseOverride 选定渠道后调用，渠道配置了响应覆盖规则时安装改写响应的 writer。
+// writer 安装在响应审核之外，改写后的内容同样经过审核；已安装时不重复安装
+func ServeResponseOverride(c *gin.Context, info *relaycommon.RelayInfo) {
+	if info.RelayFormat == types.RelayFormatOpenAIRealtime {
+		return
+	}
+	if len(common.GetContextKeyStringMap(c, constant.ContextKeyChannelResponseOverride)) == 0 {
+		return
+	}
+	if findResponseOverrideWriter(c.Writer) != nil {
+		return
+	}
+	c.Writer = &responseOverrideWriter{ResponseWriter: c.Writer, c: c, info: info}
+}
+
+// findResponseOverrideWriter 返回已安装的响应覆盖 writer
+func findResponseOverrideWriter(w gin.ResponseWriter) *responseOverrideWriter {
+	for {
+		switch v := w.(type) {
+		case *responseOverrideWriter:
+			return v
+		case *outputModerationWriter:
+			w = v.ResponseWriter
+		default:
+			return nil
+		}
+	}
+}
+
+// isResponseOverridden 响应是否已按渠道规则改写
+func isResponseOverridden(c *gin.Context) bool {
+	w := findResponseOverrideWriter(c.Writer)
+	return w != nil && w.rules != nil
+}
+
+func (w *responseOverrideWriter) selectMode() {
+	if w.mode != writerModePending {
+		return
+	}
+	w.mode = writerModePassthrough
+	if w.finished || w.info.ChannelMeta == nil || len(w.info.ResponseOverride) == 0 {
+		return
+	}
+	if w.ResponseWriter.Status() != http.StatusOK || w.Header().Get("Content-Encoding") != "" {
+		return
+	}
+	w.rules = w.info.ResponseOverride
+	w.ruleCtx = relaycommon.BuildParamOverrideContext(w.info)
+	contentType := w.Header().Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "text/event-stream"):
+		w.mode = writerModeStream
+	case strings.HasPrefix(contentType, "application/json"):
+		w.mode = writerModeJSON
+	}
+}
+
+func (w *responseOverrideWriter) Write(data []byte) (int, error) {
+	w.selectMode()
+	switch w.mode {
+	case writerModeStream:
+		w.buf.Write(data)
+		return len(data), nextSSEEvents(&w.buf, w.writeEvent)
+	case writerModeJSON:
+		w.buf.Write(data)
+		// 响应体可能分多次写入，完整后再改写
+		if gjson.ValidBytes(w.buf.Bytes()) {
+			return len(data), w.writeJSON()
+		}
+		return len(data), nil
+	default:
+		return w.ResponseWriter.Write(data)
+	}
+}
+
+func (w *responseOverrideWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *responseOverrideWriter) apply(data []byte) []byte {
+	result, err := relaycommon.ApplyResponseOverride(data, w.rules, w.ruleCtx)
+	if err != nil && !w.errLogged {
+		// 同一响应只记录一次，避免流式响应每个分片都输出日志
+		w.errLogged = true
+		logger.LogWarn(w.c, "response override failed: "+err.Error())
+	}
+	if result == nil {
+		return data
+	}
+	return result
+}
+
+func (w *responseOverrideWriter) writeEvent(event string) error {
+	lines := strings.Split(event, "\n")
+	for i, line := range lines {
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimPrefix(data, " ")
+		if gjson.Valid(data) && gjson.Parse(data).IsObject() {
+			lines[i] = "data: " + string(w.apply([]byte(data)))
+		}
+		break
+	}
+	_, err := w.ResponseWriter.WriteString(strings.Join(lines, "\n") + "\n\n")
+	return err
+}
+
+func (w *responseOverrideWriter) writeJSON() error {
+	body := w.apply(bytes.Clone(w.buf.Bytes()))
+	w.buf.Reset()
+	w.mode = writerModePassthrough
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	_, err := w.ResponseWriter.Write(body)
+	return err
+}
+
+func (w *responseOverrideWriter) finish() {
+	if w.finished {
+		return
+	}
+	w.finished = true
+	// 不完整的事件或 JSON 原样发送
+	if w.buf.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+		w.buf.Reset()
+		w.ResponseWriter.Flush()
+	}
+	w.mode = writerModePassthrough
+}
@@ -0,0 +1,79 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newResponseOverrideTestContext(rules map[string]any) (*gin.Context, *httptest.ResponseRecorder, *relaycommon.RelayInfo) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	info := &relaycommon.RelayInfo{
+		RelayFormat: types.RelayFormatOpenAI,
+		UsingGroup:  "default",
+		ChannelMeta: &relaycommon.ChannelMeta{ResponseOverride: rules},
+	}
+	if rules != nil {
+		common.SetContextKey(c, constant.ContextKeyChannelResponseOverride, rules)
+	}
+	return c, recorder, info
+}
+
+func TestServeResponseOverrideWithoutRules(t *testing.T) {
+	c, _, info := newResponseOverrideTestContext(nil)
+	writer := c.Writer
+	ServeResponseOverride(c, info)
+	if c.Writer != writer {
+		t.Fatal("channel without response override rules should not install the writer")
+	}
+
+	c, _, info = newResponseOverrideTestContext(map[string]any{"model": "renamed"})
+	ServeResponseOverride(c, info)
+	overrideWriter := c.Writer
+	ServeResponseOverride(c, info)
+	if findResponseOverrideWriter(c.Writer) == nil || c.Writer != overrideWriter {
+		t.Fatal("response override writer should be installed once")
+	}
+}
+
+func TestResponseOverrideIsModerated(t *testing.T) {
+	outputSetting := operation_setting.GetOutputModerationSetting()
+	enabled, words, checkEnabled := outputSetting.Enabled, setting.SensitiveWords, setting.CheckSensitiveEnabled
+	outputSetting.Enabled, setting.SensitiveWords, setting.CheckSensitiveEnabled = true, []string{"forbidden"}, true
+	t.Cleanup(func() {
+		outputSetting.Enabled, setting.SensitiveWords, setting.CheckSensitiveEnabled = enabled, words, checkEnabled
+	})
+
+	c, recorder, info := newResponseOverrideTestContext(map[string]any{
+		"operations": []any{map[string]any{"path": "choices.0.message.content", "mode": "set", "value": "forbidden text"}},
+	})
+	// 与 relayWithRetry 相同：先安装审核，选定渠道后安装响应覆盖
+	ServeOutputModeration(c, info)
+	ServeResponseOverride(c, info)
+
+	c.Header("Content-Type", "application/json")
+	c.Status(http.StatusOK)
+	_, _ = c.Writer.Write([]byte(`{"object":"chat.completion","choices":[{"index":0,"message":{"role":"assistant","content":"hello"}}]}`))
+	FinishResponseWriters(c)
+
+	body := recorder.Body.String()
+	if strings.Contains(body, "forbidden") || !strings.Contains(body, "text") {
+		t.Fatalf("overridden content should be moderated, got %s", body)
+	}
+	if !isResponseOverridden(c) {
+		t.Fatal("response should be marked as overridden")
+	}
+}
@@ -0,0 +1,65 @@
+package relay
+
+import (
+	"bytes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseWriterMode 改写响应的 writer 在首次写入时按 Content-Type 选择的处理方式
+type responseWriterMode int
+
+const (
+	writerModePending responseWriterMode = iota
+	writerModeStream
+	writerModeJSON
+	writerModePassthrough
+)
+
+// nextSSEEvents 依次取出缓冲区中已完整的 SSE 事件（不含结尾空行），不完整的部分留在缓冲区
+func nextSSEEvents(buf *bytes.Buffer, fn func(event string) error) error {
+	for {
+		data := buf.Bytes()
+		idx := bytes.Index(data, []byte("\n\n"))
+		if idx < 0 {
+			return nil
+		}
+		event := string(data[:idx])
+		buf.Next(idx + 2)
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+}
+
+// FinishResponseWriters 由外向内结束响应审核和响应覆盖的 writer，发送保留的内容。
+// 计费前调用，保证日志中记录完整的审核结果
+func FinishResponseWriters(c *gin.Context) {
+	w := c.Writer
+	for {
+		switch v := w.(type) {
+		case *outputModerationWriter:
+			v.finish()
+			w = v.ResponseWriter
+		case *responseOverrideWriter:
+			v.finish()
+			w = v.ResponseWriter
+		default:
+			return
+		}
+	}
+}
+
+// unwrapResponseWriter 去掉响应审核和响应覆盖的 writer
+func unwrapResponseWriter(w gin.ResponseWriter) gin.ResponseWriter {
+	for {
+		switch v := w.(type) {
+		case *outputModerationWriter:
+			w = v.ResponseWriter
+		case *responseOverrideWriter:
+			w = v.ResponseWriter
+		default:
+			return w
+		}
+	}
+}
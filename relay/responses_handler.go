@@ -68,13 +68,36 @@ func ResponsesHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
 	}
 
+	// 上游没有 Responses 接口时转为 chat completions，previous_response_id 指向网关保存的响应时重建上下文
+	viaChat := shouldResponsesUseChatCompletions(info)
+	turn, turnErr := loadResponsesTurn(info, request, viaChat)
+	if turnErr != nil {
+		return turnErr
+	}
+
+	// 引用本地文件时需要按渠道替换，不使用透传
+	fileReferenced, fileErr := applyResponsesFileReferences(c, info, request)
+	if fileErr != nil {
+		return fileErr
+	}
+
 	adaptor := GetAdaptor(info.ApiType)
 	if adaptor == nil {
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
 	}
 	adaptor.Init(info)
+
+	if viaChat {
+		usage, newAPIError := responsesViaChatCompletions(c, info, adaptor, request, turn)
+		if newAPIError != nil {
+			return newAPIError
+		}
+		postConsumeQuota(c, info, usage)
+		return nil
+	}
+
 	var requestBody io.Reader
-	if model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled {
+	if (model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled) && !fileReferenced && turn.history == nil {
 		body, err := common.GetRequestBody(c)
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeReadRequestBodyFailed, types.ErrOptionWithSkipRetry())
@@ -0,0 +1,337 @@
+package relay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	appconstant "github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/service/openaicompat"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responsesTurn 本轮 Responses 请求的输入，以及 previous_response_id 指向的网关保存的上下文
+type responsesTurn struct {
+	inputItems         []map[string]any
+	previousResponseId string
+	history            *service.ResponsesHistory
+}
+
+// shouldResponsesUseChatCompletions 上游没有 Responses 接口的渠道把请求转为 chat completions
+func shouldResponsesUseChatCompletions(info *relaycommon.RelayInfo) bool {
+	if info.RelayMode != relayconstant.RelayModeResponses {
+		return false
+	}
+	switch info.ApiType {
+	case appconstant.APITypeOpenAI, appconstant.APITypeOpenRouter, appconstant.APITypeXinference, appconstant.APITypeCloudflare:
+		return false
+	}
+	return true
+}
+
+// loadResponsesTurn 解析本轮输入，previous_response_id 指向网关保存的响应时把历史上下文合并到 input。
+// 原生 Responses 渠道找不到响应时交给上游处理，转为 chat completions 的渠道直接返回错误
+func loadResponsesTurn(info *relaycommon.RelayInfo, request *dto.OpenAIResponsesRequest, viaChat bool) (*responsesTurn, *types.NewAPIError) {
+	inputItems, err := service.ResponsesInputItems(request.Input)
+	if err != nil {
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	turn := &responsesTurn{inputItems: inputItems, previousResponseId: request.PreviousResponseID}
+	if request.PreviousResponseID == "" {
+		return turn, nil
+	}
+
+	notFound := types.NewErrorWithStatusCode(
+		fmt.Errorf("Previous response with id '%s' not found.", request.PreviousResponseID),
+		types.ErrorCodePreviousResponseNotFound,
+		http.StatusBadRequest,
+		types.ErrOptionWithSkipRetry(),
+	)
+	if !service.IsResponsesStoreEnabled() {
+		if viaChat {
+			return nil, notFound
+		}
+		return turn, nil
+	}
+	history, err := service.LoadResponsesHistory(info.UserId, request.PreviousResponseID)
+	if errors.Is(err, service.ErrPreviousResponseNotFound) {
+		if viaChat {
+			return nil, notFound
+		}
+		return turn, nil
+	}
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	turn.history = history
+
+	items := history.Items
+	if !viaChat {
+		items = nativeResponsesHistoryItems(items)
+	}
+	request.Input, err = common.Marshal(append(items, inputItems...))
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	request.PreviousResponseID = ""
+	return turn, nil
+}
+
+// nativeResponsesHistoryItems 发给原生 Responses 上游的历史 item 去掉网关生成的 id，思考 item 上游无法识别，去掉
+func nativeResponsesHistoryItems(items []map[string]any) []map[string]any {
+	result := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		if common.Interface2String(item["type"]) == "reasoning" {
+			continue
+		}
+		delete(item, "id")
+		result = append(result, item)
+	}
+	return result
+}
+
+// responsesViaChatCompletions 把 Responses 请求转为 chat completions 请求上游，响应转回 Responses 格式，并按需保存响应
+func responsesViaChatCompletions(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, request *dto.OpenAIResponsesRequest, turn *responsesTurn) (*dto.Usage, *types.NewAPIError) {
+	items, err := service.ResponsesInputItems(request.Input)
+	if err != nil {
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	chatRequest, err := service.ResponsesRequestToChatCompletionsRequest(request, items)
+	if err != nil {
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	applySystemPromptIfNeeded(c, info, chatRequest)
+	if !info.SupportStreamOptions {
+		chatRequest.StreamOptions = nil
+	}
+
+	savedRelayMode := info.RelayMode
+	savedRelayFormat := info.RelayFormat
+	savedRequestURLPath := info.RequestURLPath
+	defer func() {
+		info.RelayMode = savedRelayMode
+		info.RelayFormat = savedRelayFormat
+		info.RequestURLPath = savedRequestURLPath
+		info.ResponsesViaChat = false
+	}()
+	info.RelayMode = relayconstant.RelayModeChatCompletions
+	info.RelayFormat = types.RelayFormatOpenAI
+	info.RequestURLPath = "/v1/chat/completions"
+	info.ResponsesViaChat = true
+
+	convertedRequest, err := adaptor.ConvertOpenAIRequest(c, info, chatRequest)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	jsonData, err := common.Marshal(convertedRequest)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	jsonData, err = relaycommon.RemoveDisabledFields(jsonData, info.ChannelOtherSettings)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	if len(info.ParamOverride) > 0 {
+		jsonData, err = relaycommon.ApplyParamOverride(jsonData, info.ParamOverride, relaycommon.BuildParamOverrideContext(info))
+		if err != nil {
+			return nil, types.NewError(err, types.ErrorCodeChannelParamOverrideInvalid, types.ErrOptionWithSkipRetry())
+		}
+	}
+	if common.DebugEnabled {
+		println("requestBody: ", string(jsonData))
+	}
+
+	resp, err := adaptor.DoRequest(c, info, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp == nil {
+		return nil, types.NewOpenAIError(nil, types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+	statusCodeMappingStr := c.GetString("status_code_mapping")
+	httpResp := resp.(*http.Response)
+	if httpResp.StatusCode != http.StatusOK {
+		newAPIError := service.RelayErrorHandler(c.Request.Context(), httpResp, false)
+		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
+		return nil, newAPIError
+	}
+
+	response := service.NewResponsesResponse("resp_"+common.GetUUID(), int(info.StartTime.Unix()), request)
+	response.Model = info.OriginModelName
+	response.PreviousResponseID = turn.previousResponseId
+	if info.BackgroundResponseId != "" {
+		response.ID = info.BackgroundResponseId
+		response.Background = true
+	}
+	writer := &responsesChatWriter{ResponseWriter: c.Writer, converter: service.NewChatToResponsesConverter(response)}
+	c.Writer = writer
+	usageAny, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	c.Writer = writer.ResponseWriter
+	if newAPIError != nil {
+		writer.fail(newAPIError)
+		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
+		return nil, newAPIError
+	}
+	usage, _ := usageAny.(*dto.Usage)
+	if err := writer.finish(usage); err != nil {
+		logger.LogError(c, "convert chat completions response to responses failed: "+err.Error())
+	}
+
+	// 后台响应由提交时创建的记录保存结果
+	if response.Store && service.IsResponsesStoreEnabled() && writer.converted && info.BackgroundResponseId == "" {
+		if err := service.SaveStoredResponse(info.UserId, turn.history, turn.inputItems, writer.converter.Response()); err != nil {
+			logger.LogError(c, "save response failed: "+err.Error())
+		}
+	}
+	if usage == nil {
+		usage = &dto.Usage{}
+	}
+	return usage, nil
+}
+
+// responsesChatWriter 把渠道处理器写出的 chat completions 响应转换为 Responses 响应。
+// 流式响应逐个分片转换为 Responses 事件，非流式响应在 finish 时转换后发送
+type responsesChatWriter struct {
+	gin.ResponseWriter
+	converter *openaicompat.ChatToResponsesConverter
+	mode      responseWriterMode
+	buf       bytes.Buffer
+	finished  bool
+	// 响应是否已完整转换
+	converted bool
+}
+
+func (w *responsesChatWriter) selectMode() {
+	if w.mode != writerModePending {
+		return
+	}
+	w.mode = writerModePassthrough
+	if w.ResponseWriter.Status() != http.StatusOK {
+		return
+	}
+	contentType := w.Header().Get("Content-Type")
+	switch {
+	case strings.HasPrefix(contentType, "text/event-stream"):
+		w.mode = writerModeStream
+	case strings.HasPrefix(contentType, "application/json"):
+		w.mode = writerModeJSON
+	}
+}
+
+func (w *responsesChatWriter) Write(data []byte) (int, error) {
+	w.selectMode()
+	switch w.mode {
+	case writerModeStream:
+		w.buf.Write(data)
+		return len(data), nextSSEEvents(&w.buf, w.writeChunk)
+	case writerModeJSON:
+		w.buf.Write(data)
+		return len(data), nil
+	default:
+		return w.ResponseWriter.Write(data)
+	}
+}
+
+func (w *responsesChatWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *responsesChatWriter) writeChunk(event string) error {
+	for _, line := range strings.Split(event, "\n") {
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+			continue
+		}
+		if err := w.writeEvents(w.converter.ConvertChunk(&chunk)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *responsesChatWriter) writeEvents(events []dto.ResponsesStreamResponse) error {
+	var out strings.Builder
+	for _, event := range events {
+		data, err := common.Marshal(event)
+		if err != nil {
+			return err
+		}
+		out.WriteString("event: " + event.Type + "\ndata: ")
+		out.Write(data)
+		out.WriteString("\n\n")
+	}
+	if out.Len() == 0 {
+		return nil
+	}
+	if _, err := w.ResponseWriter.WriteString(out.String()); err != nil {
+		return err
+	}
+	w.ResponseWriter.Flush()
+	return nil
+}
+
+// finish 发送转换后的非流式响应，或结束流式响应的所有 item 并发送 response.completed 事件
+func (w *responsesChatWriter) finish(usage *dto.Usage) error {
+	if w.finished {
+		return nil
+	}
+	w.finished = true
+	switch w.mode {
+	case writerModeStream:
+		if w.buf.Len() > 0 {
+			_ = w.writeChunk(w.buf.String())
+			w.buf.Reset()
+		}
+		w.converted = true
+		return w.writeEvents(w.converter.Finish(usage))
+	case writerModeJSON:
+		var chatResponse dto.OpenAITextResponse
+		if err := common.Unmarshal(w.buf.Bytes(), &chatResponse); err != nil {
+			// 无法解析时原样发送
+			_, _ = w.ResponseWriter.Write(w.buf.Bytes())
+			return err
+		}
+		body, err := common.Marshal(w.converter.ConvertResponse(&chatResponse, usage))
+		if err != nil {
+			return err
+		}
+		w.converted = true
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, err = w.ResponseWriter.Write(body)
+		return err
+	}
+	return nil
+}
+
+// fail 流式响应已开始输出后出错时发送 response.failed 事件结束响应流
+func (w *responsesChatWriter) fail(apiErr *types.NewAPIError) {
+	if w.finished || w.mode != writerModeStream {
+		return
+	}
+	w.finished = true
+	response := w.converter.Response()
+	response.Status = "failed"
+	openAIError := apiErr.ToOpenAIError()
+	response.Error = map[string]any{"code": openAIError.Code, "message": openAIError.Message}
+	_ = w.writeEvents([]dto.ResponsesStreamResponse{{Type: "response.failed", Response: response}})
+}
@@ -0,0 +1,127 @@
+package relay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+)
+
+const responsesViaChatTestBody = `{"model":"deepseek-chat","instructions":"be brief","input":[{"role":"user","content":[{"type":"input_text","text":"weather?"}]},{"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{}"},{"type":"function_call_output","call_id":"call_1","output":"sunny"}],"tools":[{"type":"function","name":"get_weather","parameters":{"type":"object"}},{"type":"web_search"}],"stream":true}`
+
+const responsesViaChatTestStream = `data: {"id":"1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"think"}}]}
+
+data: {"id":"1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{"content":"It is "}}]}
+
+data: {"id":"1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[{"index":0,"delta":{"content":"sunny."},"finish_reason":"stop"}]}
+
+data: {"id":"1","object":"chat.completion.chunk","created":1,"model":"deepseek-chat","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":5,"total_tokens":17}}
+
+data: [DONE]
+
+`
+
+func TestResponsesViaChatCompletionsStream(t *testing.T) {
+	storeSetting := operation_setting.GetResponsesStoreSetting()
+	enabled := storeSetting.Enabled
+	storeSetting.Enabled = false
+	defer func() { storeSetting.Enabled = enabled }()
+
+	var upstreamRequest dto.GeneralOpenAIRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = common.Unmarshal(body, &upstreamRequest)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(responsesViaChatTestStream))
+	}))
+	defer server.Close()
+
+	gin.SetMode(gin.TestMode)
+	service.InitHttpClient()
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(responsesViaChatTestBody))
+	c.Set(common.KeyRequestBody, []byte(responsesViaChatTestBody))
+	common.SetContextKey(c, constant.ContextKeyChannelType, constant.ChannelTypeDeepSeek)
+	common.SetContextKey(c, constant.ContextKeyChannelBaseUrl, server.URL)
+	common.SetContextKey(c, constant.ContextKeyChannelKey, "sk-test")
+	common.SetContextKey(c, constant.ContextKeyOriginalModel, "deepseek-chat")
+
+	request := &dto.OpenAIResponsesRequest{}
+	if err := common.Unmarshal([]byte(responsesViaChatTestBody), request); err != nil {
+		t.Fatal(err)
+	}
+	info := relaycommon.GenRelayInfoResponses(c, request)
+	info.InitChannelMeta(c)
+	if !shouldResponsesUseChatCompletions(info) {
+		t.Fatal("deepseek channel should use chat completions")
+	}
+	turn, apiErr := loadResponsesTurn(info, request, true)
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	adaptor := GetAdaptor(info.ApiType)
+	adaptor.Init(info)
+	usage, apiErr := responsesViaChatCompletions(c, info, adaptor, request, turn)
+	if apiErr != nil {
+		t.Fatalf("unexpected error: %v", apiErr)
+	}
+	if usage.PromptTokens != 12 || usage.CompletionTokens != 5 {
+		t.Errorf("usage = %+v", usage)
+	}
+
+	// 请求转换：instructions 为 system 消息，工具调用合并到 assistant 消息，内置工具被忽略
+	if len(upstreamRequest.Messages) != 4 {
+		t.Fatalf("upstream messages = %+v", upstreamRequest.Messages)
+	}
+	if upstreamRequest.Messages[0].Role != "system" || upstreamRequest.Messages[1].StringContent() != "weather?" {
+		t.Errorf("upstream messages = %+v", upstreamRequest.Messages)
+	}
+	if toolCalls := upstreamRequest.Messages[2].ParseToolCalls(); len(toolCalls) != 1 || toolCalls[0].ID != "call_1" {
+		t.Errorf("assistant tool calls = %+v", toolCalls)
+	}
+	if upstreamRequest.Messages[3].Role != "tool" || upstreamRequest.Messages[3].ToolCallId != "call_1" {
+		t.Errorf("tool message = %+v", upstreamRequest.Messages[3])
+	}
+	if len(upstreamRequest.Tools) != 1 || upstreamRequest.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("upstream tools = %+v", upstreamRequest.Tools)
+	}
+
+	var eventTypes []string
+	var completed *dto.OpenAIResponsesResponse
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var event dto.ResponsesStreamResponse
+		if err := common.UnmarshalJsonStr(data, &event); err != nil {
+			t.Fatalf("invalid event %s: %v", data, err)
+		}
+		eventTypes = append(eventTypes, event.Type)
+		if event.Type == "response.completed" {
+			completed = event.Response
+		}
+	}
+	if len(eventTypes) == 0 || eventTypes[0] != "response.created" {
+		t.Fatalf("events = %v", eventTypes)
+	}
+	if completed == nil {
+		t.Fatalf("missing response.completed, events = %v", eventTypes)
+	}
+	if len(completed.Output) != 2 || completed.Output[0].Type != "reasoning" || completed.Output[1].Content[0].Text != "It is sunny." {
+		t.Errorf("output = %+v", completed.Output)
+	}
+	if completed.Usage == nil || completed.Usage.InputTokens != 12 || completed.Usage.OutputTokens != 5 {
+		t.Errorf("usage = %+v", completed.Usage)
+	}
+}
@@ -0,0 +1,104 @@
+package relay
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// supportsStreamContinuation 上游是否支持 assistant 预填充，即从末尾的 assistant 消息接着生成。
+// 其他上游会把它当作一轮已结束的对话重新回答，续写的内容接不上已发送的部分
+func supportsStreamContinuation(info *relaycommon.RelayInfo) bool {
+	switch info.ChannelType {
+	case constant.ChannelTypeAnthropic:
+		return true
+	case constant.ChannelTypeDeepSeek:
+		// Claude 格式转发到 DeepSeek 的 Anthropic 兼容接口，不支持预填充
+		return info.RelayFormat == types.RelayFormatOpenAI
+	}
+	return false
+}
+
+// streamContinuationUnsupportedError 不向上游发送请求，交给重试逻辑换一个支持预填充的渠道
+func streamContinuationUnsupportedError(info *relaycommon.RelayInfo) *types.NewAPIError {
+	return types.NewErrorWithStatusCode(fmt.Errorf("channel #%d does not support assistant prefill, cannot continue the interrupted stream", info.ChannelId),
+		types.ErrorCodeStreamContinuationUnsupported, http.StatusBadGateway)
+}
+
+// applyOpenAIStreamContinuation 续写时把已发送给客户端的文本作为 assistant 消息追加到请求末尾，
+// DeepSeek 需要设置 prefix 才会从该消息接着生成
+func applyOpenAIStreamContinuation(info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (bool, *types.NewAPIError) {
+	partialText, ok := info.BeginStreamContinuation()
+	if !ok {
+		return false, nil
+	}
+	if !supportsStreamContinuation(info) {
+		return false, streamContinuationUnsupportedError(info)
+	}
+	message := dto.Message{
+		Role:    "assistant",
+		Content: partialText,
+	}
+	if info.ChannelType == constant.ChannelTypeDeepSeek {
+		message.SetPrefix(true)
+	} else {
+		// Claude 不允许预填充内容以空白结尾
+		message.Content = strings.TrimRight(partialText, " \t\r\n")
+	}
+	request.Messages = append(request.Messages, message)
+	info.StreamFailover.PrefillTokens = service.CountTextToken(partialText, info.UpstreamModelName)
+	return true, nil
+}
+
+// applyClaudeStreamContinuation 续写时把已发送给客户端的文本作为 assistant 预填充，请求本身以 assistant 结尾时直接拼接
+func applyClaudeStreamContinuation(info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (bool, *types.NewAPIError) {
+	partialText, ok := info.BeginStreamContinuation()
+	if !ok {
+		return false, nil
+	}
+	if !supportsStreamContinuation(info) {
+		return false, streamContinuationUnsupportedError(info)
+	}
+	n := len(request.Messages)
+	if n > 0 && request.Messages[n-1].Role == "assistant" && request.Messages[n-1].IsStringContent() {
+		// Claude 不允许预填充内容以空白结尾
+		request.Messages[n-1].SetStringContent(strings.TrimRight(request.Messages[n-1].GetStringContent()+partialText, " \t\r\n"))
+	} else {
+		request.Messages = append(request.Messages, dto.ClaudeMessage{
+			Role:    "assistant",
+			Content: strings.TrimRight(partialText, " \t\r\n"),
+		})
+	}
+	info.StreamFailover.PrefillTokens = service.CountTextToken(partialText, info.UpstreamModelName)
+	return true, nil
+}
+
+// FinishInterruptedStream 续写失败时结束已中断的客户端响应流，并按已发送的内容计费。
+// 没有等待续写的响应流时返回 false
+func FinishInterruptedStream(c *gin.Context, info *relaycommon.RelayInfo) bool {
+	usage := info.FinishStreamFailover()
+	if usage == nil {
+		return false
+	}
+	if info.RelayFormat == types.RelayFormatClaude {
+		FinishResponseWriters(c)
+		service.PostClaudeConsumeQuota(c, info, usage)
+		return true
+	}
+	if info.ShouldIncludeUsage {
+		response := helper.GenerateFinalUsageResponse(helper.GetResponseID(c), info.StartTime.Unix(), info.UpstreamModelName, *usage)
+		_ = helper.ObjectData(c, response)
+	}
+	helper.Done(c)
+	postConsumeQuota(c, info, usage)
+	return true
+}
@@ -0,0 +1,76 @@
+package relay
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+)
+
+func TestApplyOpenAIStreamContinuation(t *testing.T) {
+	tests := []struct {
+		name        string
+		channelType int
+		wantContent string
+		wantPrefix  bool
+		unsupported bool
+	}{
+		{name: "claude prefill", channelType: constant.ChannelTypeAnthropic, wantContent: "Hello,"},
+		{name: "deepseek prefix", channelType: constant.ChannelTypeDeepSeek, wantContent: "Hello, ", wantPrefix: true},
+		{name: "openai", channelType: constant.ChannelTypeOpenAI, unsupported: true},
+		{name: "gemini", channelType: constant.ChannelTypeGemini, unsupported: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := &relaycommon.RelayInfo{
+				RelayFormat: types.RelayFormatOpenAI,
+				ChannelMeta: &relaycommon.ChannelMeta{ChannelType: tt.channelType},
+			}
+			request := &dto.GeneralOpenAIRequest{Messages: []dto.Message{{Role: "user", Content: "hi"}}}
+
+			// 没有中断的响应流时不续写
+			if continuation, err := applyOpenAIStreamContinuation(info, request); continuation || err != nil {
+				t.Fatalf("continuation without interruption = %v, %v", continuation, err)
+			}
+
+			info.MarkStreamInterrupted("Hello, ", 10, 2)
+			continuation, err := applyOpenAIStreamContinuation(info, request)
+			if tt.unsupported {
+				if continuation || err == nil || err.GetErrorCode() != types.ErrorCodeStreamContinuationUnsupported {
+					t.Fatalf("continuation = %v, %v, want unsupported", continuation, err)
+				}
+				if len(request.Messages) != 1 {
+					t.Fatalf("request should not be changed, messages = %+v", request.Messages)
+				}
+				return
+			}
+			if !continuation || err != nil {
+				t.Fatalf("continuation = %v, %v", continuation, err)
+			}
+			last := request.Messages[len(request.Messages)-1]
+			if last.Role != "assistant" || last.StringContent() != tt.wantContent || last.GetPrefix() != tt.wantPrefix {
+				t.Fatalf("prefill message = %+v", last)
+			}
+		})
+	}
+}
+
+func TestClaudeStreamContinuationRequiresClaudeChannel(t *testing.T) {
+	for channelType, supported := range map[int]bool{
+		constant.ChannelTypeAnthropic: true,
+		constant.ChannelTypeDeepSeek:  false,
+		constant.ChannelTypeOpenAI:    false,
+	} {
+		info := &relaycommon.RelayInfo{
+			RelayFormat: types.RelayFormatClaude,
+			ChannelMeta: &relaycommon.ChannelMeta{ChannelType: channelType},
+		}
+		info.MarkStreamInterrupted("Hello", 10, 1)
+		continuation, err := applyClaudeStreamContinuation(info, &dto.ClaudeRequest{})
+		if continuation != supported || (err != nil) == supported {
+			t.Errorf("channel type %d: continuation = %v, %v", channelType, continuation, err)
+		}
+	}
+}
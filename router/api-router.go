@@ -148,6 +148,11 @@ func SetApiRouter(router *gin.Engine) {
 			optionRoute.GET("/channel_affinity_cache", controller.GetChannelAffinityCacheStats)
 			optionRoute.DELETE("/channel_affinity_cache", controller.ClearChannelAffinityCache)
 		}
+		auditLogRoute := apiRouter.Group("/audit_log")
+		auditLogRoute.Use(middleware.RootAuth())
+		{
+			auditLogRoute.GET("/", controller.GetAuditLogs)
+		}
 		ratioSyncRoute := apiRouter.Group("/ratio_sync")
 		ratioSyncRoute.Use(middleware.RootAuth())
 		{
@@ -161,6 +166,12 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/search", controller.SearchChannels)
 			channelRoute.GET("/models", controller.ChannelListModels)
 			channelRoute.GET("/models_enabled", controller.EnabledListModels)
+			channelRoute.GET("/scores", controller.GetChannelScores)
+			channelRoute.DELETE("/scores", controller.ResetChannelScores)
+			channelRoute.GET("/circuit_breakers", controller.GetChannelCircuitBreakers)
+			channelRoute.DELETE("/circuit_breakers", controller.ResetChannelCircuitBreakers)
+			channelRoute.GET("/cooldowns", controller.GetChannelCooldowns)
+			channelRoute.DELETE("/cooldowns", controller.ClearChannelCooldowns)
 			channelRoute.GET("/:id", controller.GetChannel)
 			channelRoute.POST("/:id/key", middleware.RootAuth(), middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.SecureVerificationRequired(), controller.GetChannelKey)
 			channelRoute.GET("/test", controller.TestAllChannels)
@@ -228,6 +239,9 @@ func SetApiRouter(router *gin.Engine) {
 		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), controller.SearchUserLogs)
+		logRoute.GET("/export", middleware.AdminAuth(), controller.ExportAllLogs)
+		logRoute.GET("/rollup", middleware.AdminAuth(), controller.GetLogRollupStats)
+		logRoute.GET("/self/export", middleware.UserAuth(), controller.ExportUserLogs)
 
 		dataRoute := apiRouter.Group("/data")
 		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
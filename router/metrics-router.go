@@ -0,0 +1,21 @@
+package router
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SetMetricsRouter(router *gin.Engine) {
+	if !common.GetEnvOrDefaultBool("METRICS_ENABLED", false) {
+		return
+	}
+	metrics.RegisterGaugeFunc("model_health_writer_queue_depth", "Model health events waiting to be written.", func() float64 {
+		return float64(model.ModelHealthQueueDepth())
+	})
+	router.GET("/metrics", middleware.MetricsAuth(common.GetEnvOrDefaultString("METRICS_TOKEN", "")), gin.WrapH(metrics.Handler()))
+	common.SysLog("prometheus metrics enabled at /metrics")
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/QuantumNous/new-api/controller"
 	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/relay"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -66,20 +67,49 @@ func SetRelayRouter(router *gin.Engine) {
 	{
 		// WebSocket 路由（统一到 Relay）
 		wsRouter := relayV1Router.Group("")
+		wsRouter.Use(middleware.TokenRateLimit())
 		wsRouter.Use(middleware.Distribute())
 		wsRouter.GET("/realtime", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatOpenAIRealtime)
 		})
 	}
+	{
+		// files、batches 和保存的响应不请求上游，不计入令牌的请求频率限制
+		// files 路由不需要选择渠道
+		filesRouter := relayV1Router.Group("/files")
+		filesRouter.GET("", controller.ListFiles)
+		filesRouter.POST("", controller.UploadFile)
+		filesRouter.GET("/:id", controller.RetrieveFile)
+		filesRouter.DELETE("/:id", controller.DeleteFile)
+		filesRouter.GET("/:id/content", controller.RetrieveFileContent)
+
+		// batches 路由不需要选择渠道，每行请求执行时再选择
+		batchesRouter := relayV1Router.Group("/batches")
+		batchesRouter.POST("", controller.CreateBatch)
+		batchesRouter.GET("", controller.ListBatches)
+		batchesRouter.GET("/:id", controller.RetrieveBatch)
+		batchesRouter.POST("/:id/cancel", controller.CancelBatch)
+
+		// 网关保存的 Responses 响应，不需要选择渠道
+		responsesRouter := relayV1Router.Group("/responses")
+		responsesRouter.GET("/:id", controller.RetrieveResponse)
+		responsesRouter.DELETE("/:id", controller.DeleteResponse)
+		responsesRouter.GET("/:id/input_items", controller.ListResponseInputItems)
+		responsesRouter.POST("/:id/cancel", controller.CancelResponse)
+	}
 	{
 		//http router
 		httpRouter := relayV1Router.Group("")
+		httpRouter.Use(middleware.TokenRateLimit())
 		httpRouter.Use(middleware.Distribute())
 
 		// claude related routes
 		httpRouter.POST("/messages", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatClaude)
 		})
+		httpRouter.POST("/messages/count_tokens", func(c *gin.Context) {
+			controller.RelayCountTokens(c, types.RelayFormatClaude)
+		})
 
 		// chat related routes
 		httpRouter.POST("/completions", func(c *gin.Context) {
@@ -133,9 +163,7 @@ func SetRelayRouter(router *gin.Engine) {
 		httpRouter.POST("/engines/:model/embeddings", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatGemini)
 		})
-		httpRouter.POST("/models/*path", func(c *gin.Context) {
-			controller.Relay(c, types.RelayFormatGemini)
-		})
+		httpRouter.POST("/models/*path", relayGemini)
 
 		// other relay routes
 		httpRouter.POST("/moderations", func(c *gin.Context) {
@@ -144,11 +172,6 @@ func SetRelayRouter(router *gin.Engine) {
 
 		// not implemented
 		httpRouter.POST("/images/variations", controller.RelayNotImplemented)
-		httpRouter.GET("/files", controller.RelayNotImplemented)
-		httpRouter.POST("/files", controller.RelayNotImplemented)
-		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id/content", controller.RelayNotImplemented)
 		httpRouter.POST("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes/:id", controller.RelayNotImplemented)
@@ -175,13 +198,21 @@ func SetRelayRouter(router *gin.Engine) {
 	relayGeminiRouter := router.Group("/v1beta")
 	relayGeminiRouter.Use(middleware.TokenAuth())
 	relayGeminiRouter.Use(middleware.ModelRequestRateLimit())
+	relayGeminiRouter.Use(middleware.TokenRateLimit())
 	relayGeminiRouter.Use(middleware.Distribute())
 	{
 		// Gemini API 路径格式: /v1beta/models/{model_name}:{action}
-		relayGeminiRouter.POST("/models/*path", func(c *gin.Context) {
-			controller.Relay(c, types.RelayFormatGemini)
-		})
+		relayGeminiRouter.POST("/models/*path", relayGemini)
+	}
+}
+
+// relayGemini 处理 Gemini 原生接口，countTokens 单独处理，不计费
+func relayGemini(c *gin.Context) {
+	if relayconstant.IsCountTokensPath(c.Request.URL.Path) {
+		controller.RelayCountTokens(c, types.RelayFormatGemini)
+		return
 	}
+	controller.Relay(c, types.RelayFormatGemini)
 }
 
 func registerMjRouterGroup(relayMjRouter *gin.RouterGroup) {
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	AuditTargetOption     = "option"
+	AuditTargetChannel    = "channel"
+	AuditTargetUser       = "user"
+	AuditTargetRedemption = "redemption"
+)
+
+const auditMaskedValue = "******"
+
+// maskAuditValue 用以服务端密钥计算的 HMAC 前缀代替敏感值，不泄露原文也能看出是否被修改，
+// 读取审计日志的人无法离线验证猜测的密钥
+func maskAuditValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return auditMaskedValue + common.GenerateHMAC(value)[:8]
+}
+
+// 审计快照中需要脱敏的字段
+var auditSensitiveFields = map[string]bool{
+	"key":               true,
+	"password":          true,
+	"access_token":      true,
+	"original_password": true,
+	"secret":            true,
+	// 代理地址可能包含账号密码
+	"proxy": true,
+}
+
+// 以 JSON 字符串保存的渠道配置，解析后按字段名脱敏，无法解析时整体脱敏
+var auditJSONStringFields = map[string]bool{
+	"param_override": true,
+	"other":          true,
+	"setting":        true,
+	"settings":       true,
+}
+
+// 请求头覆盖中的值可能是认证信息，全部脱敏
+const auditHeaderOverrideField = "header_override"
+
+type auditChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// isAuditSensitiveName 与 GetOptions 的过滤规则保持一致，Token/Secret/Key 结尾的配置视为敏感，
+// 此外认证相关的请求头名称也视为敏感
+func isAuditSensitiveName(name string) bool {
+	lower := strings.ToLower(name)
+	if auditSensitiveFields[lower] {
+		return true
+	}
+	if strings.Contains(lower, "authorization") || strings.Contains(lower, "cookie") || strings.Contains(lower, "password") {
+		return true
+	}
+	return strings.HasSuffix(lower, "token") || strings.HasSuffix(lower, "secret") || strings.HasSuffix(lower, "key")
+}
+
+// auditSnapshot 把实体转换为字段映射并脱敏，非对象值放在 value 字段中
+func auditSnapshot(v any) map[string]any {
+	if v == nil {
+		return nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil
+	}
+	data, err := common.Marshal(v)
+	if err != nil {
+		return map[string]any{"value": fmt.Sprintf("%v", v)}
+	}
+	var fields map[string]any
+	if err := common.Unmarshal(data, &fields); err != nil || fields == nil {
+		var value any
+		_ = common.Unmarshal(data, &value)
+		return map[string]any{"value": value}
+	}
+	maskAuditFields(fields)
+	return fields
+}
+
+func maskAuditFields(fields map[string]any) {
+	for k, v := range fields {
+		lower := strings.ToLower(k)
+		switch {
+		case isAuditSensitiveName(k):
+			fields[k] = maskAuditAny(v)
+		case lower == auditHeaderOverrideField:
+			fields[k] = maskAuditJSONString(v, maskAuditLeaves)
+		case auditJSONStringFields[lower]:
+			fields[k] = maskAuditJSONString(v, maskAuditNested)
+		default:
+			maskAuditNested(v)
+		}
+	}
+	// 参数覆盖的操作按 path 的最后一段判断 value 是否敏感
+	if path, ok := fields["path"].(string); ok {
+		if value, ok := fields["value"]; ok && isAuditSensitiveName(path[strings.LastIndex(path, ".")+1:]) {
+			fields["value"] = maskAuditAny(value)
+		}
+	}
+}
+
+func maskAuditAny(v any) any {
+	if s, ok := v.(string); ok {
+		return maskAuditValue(s)
+	}
+	if v != nil {
+		return auditMaskedValue
+	}
+	return nil
+}
+
+// maskAuditNested 对嵌套的对象和数组按字段名脱敏
+func maskAuditNested(v any) any {
+	switch nested := v.(type) {
+	case map[string]any:
+		maskAuditFields(nested)
+	case []any:
+		for _, item := range nested {
+			maskAuditNested(item)
+		}
+	}
+	return v
+}
+
+// maskAuditLeaves 脱敏嵌套对象和数组中的所有值，保留字段名
+func maskAuditLeaves(v any) any {
+	switch nested := v.(type) {
+	case map[string]any:
+		for k, item := range nested {
+			nested[k] = maskAuditLeaves(item)
+		}
+		return nested
+	case []any:
+		for i, item := range nested {
+			nested[i] = maskAuditLeaves(item)
+		}
+		return nested
+	case nil:
+		return nil
+	default:
+		return maskAuditValue(fmt.Sprintf("%v", v))
+	}
+}
+
+// maskAuditJSONString 把 JSON 字符串字段解析后用 mask 脱敏，无法解析时整体脱敏
+func maskAuditJSONString(v any, mask func(any) any) any {
+	s, ok := v.(string)
+	if !ok {
+		return mask(v)
+	}
+	if strings.TrimSpace(s) == "" {
+		return s
+	}
+	var parsed any
+	if err := common.UnmarshalJsonStr(s, &parsed); err != nil {
+		return maskAuditValue(s)
+	}
+	if _, ok := parsed.(string); ok {
+		return maskAuditValue(s)
+	}
+	return mask(parsed)
+}
+
+// optionSnapshot JSON 格式的配置（如模型倍率）按键展开，便于逐项比较
+func optionSnapshot(key string, value string) map[string]any {
+	if isAuditSensitiveName(key) {
+		return map[string]any{"value": maskAuditValue(value)}
+	}
+	var fields map[string]any
+	if strings.HasPrefix(strings.TrimSpace(value), "{") && common.UnmarshalJsonStr(value, &fields) == nil {
+		maskAuditFields(fields)
+		return fields
+	}
+	return map[string]any{"value": value}
+}
+
+// auditDiff 返回前后快照中发生变化的顶层字段
+func auditDiff(before map[string]any, after map[string]any) map[string]auditChange {
+	diff := make(map[string]auditChange)
+	for k, b := range before {
+		a, ok := after[k]
+		if !ok || !reflect.DeepEqual(a, b) {
+			diff[k] = auditChange{Before: b, After: a}
+		}
+	}
+	for k, a := range after {
+		if _, ok := before[k]; !ok {
+			diff[k] = auditChange{Before: nil, After: a}
+		}
+	}
+	return diff
+}
+
+// RecordAudit 记录一次管理操作，before/after 为操作前后的实体，新增时 before 为 nil，删除时 after 为 nil
+func RecordAudit(c *gin.Context, action string, targetType string, targetId any, before any, after any) {
+	recordAudit(c, action, targetType, fmt.Sprintf("%v", targetId), auditSnapshot(before), auditSnapshot(after))
+}
+
+// RecordOptionAudit 记录配置项修改，敏感配置只记录哈希前缀
+func RecordOptionAudit(c *gin.Context, key string, before string, after string) {
+	if before == after {
+		return
+	}
+	recordAudit(c, "update", AuditTargetOption, key, optionSnapshot(key, before), optionSnapshot(key, after))
+}
+
+func recordAudit(c *gin.Context, action string, targetType string, targetId string, before map[string]any, after map[string]any) {
+	setting := operation_setting.GetAuditSetting()
+	if !setting.Enabled {
+		return
+	}
+	diff := auditDiff(before, after)
+	// 更新操作没有实际变化时不记录
+	if before != nil && after != nil && len(diff) == 0 {
+		return
+	}
+	entry := &model.AuditLog{
+		CreatedAt:  time.Now().Unix(),
+		ActorId:    c.GetInt("id"),
+		ActorName:  c.GetString("username"),
+		ActorRole:  c.GetInt("role"),
+		Ip:         c.ClientIP(),
+		Action:     action,
+		TargetType: targetType,
+		TargetId:   targetId,
+		Before:     auditJSON(before),
+		After:      auditJSON(after),
+		Diff:       auditJSON(diff),
+	}
+	webhookEnabled := setting.WebhookEnabled && setting.WebhookUrl != ""
+	webhookUrl, webhookSecret := setting.WebhookUrl, setting.WebhookSecret
+	gopool.Go(func() {
+		if err := entry.Insert(); err != nil {
+			common.SysError("failed to record audit log: " + err.Error())
+		}
+		if !webhookEnabled {
+			return
+		}
+		title := fmt.Sprintf("%s %s#%s by %s", entry.Action, entry.TargetType, entry.TargetId, entry.ActorName)
+		notify := dto.NewNotify(dto.NotifyTypeAuditLog, title, entry.Diff, nil)
+		if err := SendWebhookNotify(webhookUrl, webhookSecret, notify); err != nil {
+			common.SysError("failed to send audit webhook: " + err.Error())
+		}
+	})
+}
+
+func auditJSON(v any) string {
+	if v == nil || reflect.ValueOf(v).IsNil() {
+		return ""
+	}
+	data, err := common.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
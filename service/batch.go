@@ -0,0 +1,524 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// 支持批处理的接口
+var batchEndpoints = map[string]bool{
+	"/v1/chat/completions": true,
+	"/v1/completions":      true,
+	"/v1/embeddings":       true,
+	"/v1/responses":        true,
+	"/v1/moderations":      true,
+}
+
+const (
+	// 批处理任务的完成时限
+	BatchCompletionWindow = "24h"
+	batchCompletionWindow = 24 * time.Hour
+	// 单行请求的大小上限
+	batchMaxLineBytes = 32 << 20
+	// 校验失败时最多记录的错误数
+	batchMaxValidationErrors = 100
+)
+
+func IsValidBatchEndpoint(endpoint string) bool {
+	return batchEndpoints[endpoint]
+}
+
+// BatchExpiresAt 返回批处理任务的过期时间
+func BatchExpiresAt(createdAt int64) int64 {
+	return createdAt + int64(batchCompletionWindow/time.Second)
+}
+
+type batchExecutor struct {
+	handler http.Handler
+	mu      sync.Mutex
+	running map[int]bool
+}
+
+var batchExecutorOnce sync.Once
+
+// StartBatchExecutor 在主节点启动批处理执行器，handler 为处理 HTTP 请求的路由，
+// 每行请求都通过它走完整的鉴权、限流、选渠道和计费流程
+func StartBatchExecutor(handler http.Handler) {
+	batchExecutorOnce.Do(func() {
+		// 执行进度只保存在内存中，重启前未完成的任务无法继续
+		errorsJSON := marshalBatchErrors([]dto.OpenAIBatchError{{Code: "server_restarted", Message: "The batch was interrupted by a server restart."}})
+		if n, err := model.FailInterruptedBatches(errorsJSON); err != nil {
+			common.SysError("failed to mark interrupted batches: " + err.Error())
+		} else if n > 0 {
+			common.SysLog(fmt.Sprintf("marked %d interrupted batches as failed", n))
+		}
+		executor := &batchExecutor{handler: handler, running: make(map[int]bool)}
+		gopool.Go(executor.loop)
+	})
+}
+
+func (e *batchExecutor) loop() {
+	for {
+		setting := operation_setting.GetBatchSetting()
+		if setting.Enabled {
+			e.schedule(setting)
+		}
+		time.Sleep(time.Duration(max(setting.PollIntervalSeconds, 1)) * time.Second)
+	}
+}
+
+func (e *batchExecutor) schedule(setting *operation_setting.BatchSetting) {
+	e.mu.Lock()
+	free := setting.MaxRunningBatches - len(e.running)
+	runningIds := make([]int, 0, len(e.running))
+	for id := range e.running {
+		runningIds = append(runningIds, id)
+	}
+	e.mu.Unlock()
+	if free <= 0 {
+		return
+	}
+
+	batches, err := model.GetPendingBatches(free, runningIds)
+	if err != nil {
+		common.SysError("failed to get pending batches: " + err.Error())
+		return
+	}
+	for _, batch := range batches {
+		e.mu.Lock()
+		e.running[batch.Id] = true
+		e.mu.Unlock()
+		gopool.Go(func() {
+			defer func() {
+				if r := recover(); r != nil {
+					common.SysError(fmt.Sprintf("batch %s panic: %v", batch.BatchId, r))
+				}
+				e.mu.Lock()
+				delete(e.running, batch.Id)
+				e.mu.Unlock()
+			}()
+			runner := &batchRunner{handler: e.handler, batch: batch}
+			runner.run()
+		})
+	}
+}
+
+// batchRunner 执行一个批处理任务
+type batchRunner struct {
+	handler  http.Handler
+	batch    *model.Batch
+	tokenKey string
+
+	completed atomic.Int64
+	failed    atomic.Int64
+	// 任务被取消或过期时停止发送新的请求，值为最终状态
+	stopStatus atomic.Value
+
+	outputMu   sync.Mutex
+	outputFile *os.File
+	errorFile  *os.File
+}
+
+func (r *batchRunner) run() {
+	batch := r.batch
+	inputFile, err := model.GetUserFile(batch.UserId, batch.InputFileId)
+	if err != nil {
+		common.SysError(fmt.Sprintf("batch %s: get input file failed: %v", batch.BatchId, err))
+		r.fail(model.BatchStatusValidating, "server_error", "Failed to load the input file, please retry the batch.")
+		return
+	}
+	if inputFile == nil {
+		r.fail(model.BatchStatusValidating, "file_not_found", "The input file was not found.")
+		return
+	}
+	total, validationErrors, err := r.validate(inputFile)
+	if err != nil {
+		r.fail(model.BatchStatusValidating, "read_input_failed", "Failed to read the input file.")
+		return
+	}
+	if len(validationErrors) > 0 {
+		r.failWithErrors(model.BatchStatusValidating, validationErrors)
+		return
+	}
+	token, err := model.GetTokenById(batch.TokenId)
+	if err != nil || token == nil {
+		r.fail(model.BatchStatusValidating, "token_not_found", "The API key used to create the batch no longer exists.")
+		return
+	}
+	r.tokenKey = token.Key
+
+	// 结果先写入临时文件，任务结束时保存为输出文件和错误文件
+	if r.outputFile, err = os.CreateTemp("", "batch-output-*.jsonl"); err != nil {
+		r.fail(model.BatchStatusValidating, "execution_failed", "The batch failed to execute.")
+		return
+	}
+	defer removeTempFile(r.outputFile)
+	if r.errorFile, err = os.CreateTemp("", "batch-error-*.jsonl"); err != nil {
+		r.fail(model.BatchStatusValidating, "execution_failed", "The batch failed to execute.")
+		return
+	}
+	defer removeTempFile(r.errorFile)
+
+	now := common.GetTimestamp()
+	ok, err := model.UpdateBatchFields(batch.Id, model.BatchStatusValidating, map[string]interface{}{
+		"status":         model.BatchStatusInProgress,
+		"in_progress_at": now,
+		"request_total":  total,
+	})
+	if err != nil || !ok {
+		// 任务在校验期间被取消
+		return
+	}
+
+	if err := r.execute(inputFile); err != nil {
+		common.SysError(fmt.Sprintf("batch %s: execute failed: %v", batch.BatchId, err))
+		// 执行期间任务可能已被标记为取消中，两种状态下都标记为失败
+		r.fail(model.BatchStatusInProgress, "execution_failed", "The batch failed to execute.")
+		r.fail(model.BatchStatusCancelling, "execution_failed", "The batch failed to execute.")
+		return
+	}
+	r.finish()
+}
+
+// validate 检查输入文件的每一行，返回请求数和校验错误
+func (r *batchRunner) validate(inputFile *model.File) (int, []dto.OpenAIBatchError, error) {
+	content, err := OpenFileContent(inputFile)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer content.Close()
+
+	maxRequests := operation_setting.GetBatchSetting().MaxRequestsPerBatch
+	customIds := make(map[string]bool)
+	var validationErrors []dto.OpenAIBatchError
+	total := 0
+	err = forEachBatchLine(content, func(lineNo int, data []byte) bool {
+		total++
+		if maxRequests > 0 && total > maxRequests {
+			validationErrors = append(validationErrors, newBatchLineError("too_many_requests", fmt.Sprintf("The batch exceeds the maximum of %d requests.", maxRequests), lineNo))
+			return false
+		}
+		var line dto.OpenAIBatchInputLine
+		if err := common.Unmarshal(data, &line); err != nil {
+			validationErrors = append(validationErrors, newBatchLineError("invalid_json_line", "This line is not parseable as valid JSON.", lineNo))
+		} else if line.CustomId == "" {
+			validationErrors = append(validationErrors, newBatchLineError("missing_required_parameter", "The custom_id parameter is required.", lineNo))
+		} else if customIds[line.CustomId] {
+			validationErrors = append(validationErrors, newBatchLineError("duplicate_custom_id", fmt.Sprintf("The custom_id %s is duplicated.", line.CustomId), lineNo))
+		} else if line.Method != http.MethodPost {
+			validationErrors = append(validationErrors, newBatchLineError("invalid_method", "Only the POST method is supported.", lineNo))
+		} else if line.Url != r.batch.Endpoint {
+			validationErrors = append(validationErrors, newBatchLineError("mismatched_endpoint", fmt.Sprintf("The url must be %s to match the batch endpoint.", r.batch.Endpoint), lineNo))
+		} else if !gjson.ValidBytes(line.Body) || !gjson.ParseBytes(line.Body).IsObject() {
+			validationErrors = append(validationErrors, newBatchLineError("invalid_body", "The body must be a JSON object.", lineNo))
+		}
+		customIds[line.CustomId] = true
+		return len(validationErrors) < batchMaxValidationErrors
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	if total == 0 {
+		validationErrors = append(validationErrors, dto.OpenAIBatchError{Code: "empty_file", Message: "The input file is empty."})
+	}
+	return total, validationErrors, nil
+}
+
+func (r *batchRunner) execute(inputFile *model.File) error {
+	content, err := OpenFileContent(inputFile)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	stopWatch := make(chan struct{})
+	watchDone := make(chan struct{})
+	go func() {
+		defer close(watchDone)
+		r.watch(stopWatch)
+	}()
+
+	concurrency := max(operation_setting.GetBatchSetting().RequestConcurrency, 1)
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	err = forEachBatchLine(content, func(lineNo int, data []byte) bool {
+		if r.stopStatus.Load() != nil {
+			return false
+		}
+		var line dto.OpenAIBatchInputLine
+		if err := common.Unmarshal(data, &line); err != nil {
+			return true
+		}
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			r.writeResult(r.executeLine(&line))
+		}()
+		return true
+	})
+	wg.Wait()
+	close(stopWatch)
+	<-watchDone
+	return err
+}
+
+// watch 定期保存执行进度，并检查任务是否被取消或过期
+func (r *batchRunner) watch(stop <-chan struct{}) {
+	for {
+		interval := time.Duration(max(operation_setting.GetBatchSetting().PollIntervalSeconds, 1)) * time.Second
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+		_, _ = model.UpdateBatchFields(r.batch.Id, "", map[string]interface{}{
+			"request_completed": r.completed.Load(),
+			"request_failed":    r.failed.Load(),
+		})
+		if r.stopStatus.Load() != nil {
+			continue
+		}
+		if status, err := model.GetBatchStatus(r.batch.Id); err == nil && status == model.BatchStatusCancelling {
+			r.stopStatus.Store(model.BatchStatusCancelled)
+		} else if common.GetTimestamp() >= r.batch.ExpiresAt {
+			r.stopStatus.Store(model.BatchStatusExpired)
+		}
+	}
+}
+
+// executeLine 通过路由执行一行请求，被限流时按 Retry-After 等待后重试
+func (r *batchRunner) executeLine(line *dto.OpenAIBatchInputLine) *dto.OpenAIBatchOutputLine {
+	body := []byte(line.Body)
+	if gjson.GetBytes(body, "stream").Bool() {
+		// 批处理不支持流式响应
+		body, _ = sjson.SetBytes(body, "stream", false)
+	}
+	clientIp := r.batch.ClientIp
+	if clientIp == "" {
+		clientIp = "127.0.0.1"
+	}
+
+	retries := operation_setting.GetBatchSetting().RateLimitRetries
+	var recorder *httptest.ResponseRecorder
+	for attempt := 0; ; attempt++ {
+		ctx := relaycommon.WithBatchRequest(context.Background(), r.batch.BatchId)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, line.Url, bytes.NewReader(body))
+		if err != nil {
+			return newBatchOutputError(line.CustomId, nil, "invalid_request", err.Error())
+		}
+		req.Header.Set("Authorization", "Bearer sk-"+r.tokenKey)
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = net.JoinHostPort(clientIp, "0")
+		recorder = httptest.NewRecorder()
+		r.handler.ServeHTTP(recorder, req)
+		if recorder.Code != http.StatusTooManyRequests || attempt >= retries || r.stopStatus.Load() != nil {
+			break
+		}
+		time.Sleep(batchRetryAfter(recorder.Header().Get("Retry-After"), attempt))
+	}
+
+	response := &dto.OpenAIBatchOutputResponse{
+		StatusCode: recorder.Code,
+		RequestId:  recorder.Header().Get(common.RequestIdKey),
+		Body:       recorder.Body.Bytes(),
+	}
+	if !gjson.ValidBytes(response.Body) {
+		response.Body, _ = common.Marshal(recorder.Body.String())
+	}
+	if recorder.Code < 200 || recorder.Code >= 300 {
+		code := gjson.GetBytes(response.Body, "error.code").String()
+		if code == "" {
+			code = "request_failed"
+		}
+		message := gjson.GetBytes(response.Body, "error.message").String()
+		if message == "" {
+			message = http.StatusText(recorder.Code)
+		}
+		return newBatchOutputError(line.CustomId, response, code, message)
+	}
+	return &dto.OpenAIBatchOutputLine{
+		Id:       "batch_req_" + common.GetRandomString(24),
+		CustomId: line.CustomId,
+		Response: response,
+	}
+}
+
+func batchRetryAfter(header string, attempt int) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return time.Duration(1<<min(attempt, 5)) * time.Second
+}
+
+func (r *batchRunner) writeResult(result *dto.OpenAIBatchOutputLine) {
+	data, err := common.Marshal(result)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	r.outputMu.Lock()
+	defer r.outputMu.Unlock()
+	if result.Error != nil {
+		r.failed.Add(1)
+		_, err = r.errorFile.Write(data)
+	} else {
+		r.completed.Add(1)
+		_, err = r.outputFile.Write(data)
+	}
+	if err != nil {
+		common.SysError(fmt.Sprintf("batch %s: write result failed: %v", r.batch.BatchId, err))
+	}
+}
+
+// finish 保存输出文件和错误文件并更新任务的最终状态
+func (r *batchRunner) finish() {
+	batch := r.batch
+	now := common.GetTimestamp()
+	_, _ = model.UpdateBatchFields(batch.Id, "", map[string]interface{}{
+		"finalizing_at":     now,
+		"request_completed": r.completed.Load(),
+		"request_failed":    r.failed.Load(),
+	})
+	// 执行期间被取消的任务保持 cancelling 状态，其余进入 finalizing
+	_, _ = model.UpdateBatchFields(batch.Id, model.BatchStatusInProgress, map[string]interface{}{"status": model.BatchStatusFinalizing})
+
+	fields := map[string]interface{}{}
+	if fileId, err := r.saveResultFile(r.outputFile, "output"); err != nil {
+		common.SysError(fmt.Sprintf("batch %s: save output file failed: %v", batch.BatchId, err))
+	} else if fileId != "" {
+		fields["output_file_id"] = fileId
+	}
+	if fileId, err := r.saveResultFile(r.errorFile, "error"); err != nil {
+		common.SysError(fmt.Sprintf("batch %s: save error file failed: %v", batch.BatchId, err))
+	} else if fileId != "" {
+		fields["error_file_id"] = fileId
+	}
+
+	now = common.GetTimestamp()
+	status, _ := r.stopStatus.Load().(string)
+	switch status {
+	case model.BatchStatusCancelled:
+		fields["cancelled_at"] = now
+	case model.BatchStatusExpired:
+		fields["expired_at"] = now
+	default:
+		status = model.BatchStatusCompleted
+		fields["completed_at"] = now
+	}
+	if status != model.BatchStatusCancelled {
+		if current, err := model.GetBatchStatus(batch.Id); err == nil && current == model.BatchStatusCancelling {
+			// 最后一批请求执行完成后才被取消
+			status = model.BatchStatusCancelled
+			delete(fields, "completed_at")
+			delete(fields, "expired_at")
+			fields["cancelled_at"] = now
+		}
+	}
+	fields["status"] = status
+	if _, err := model.UpdateBatchFields(batch.Id, "", fields); err != nil {
+		common.SysError(fmt.Sprintf("batch %s: update status failed: %v", batch.BatchId, err))
+	}
+}
+
+func (r *batchRunner) saveResultFile(f *os.File, kind string) (string, error) {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	filename := fmt.Sprintf("%s_%s.jsonl", r.batch.BatchId, kind)
+	file, err := SaveUserFile(r.batch.UserId, r.batch.TokenId, filename, "batch_output", "application/jsonl", f)
+	if err != nil {
+		return "", err
+	}
+	return file.FileId, nil
+}
+
+func (r *batchRunner) fail(fromStatus string, code string, message string) {
+	r.failWithErrors(fromStatus, []dto.OpenAIBatchError{{Code: code, Message: message}})
+}
+
+// failWithErrors 把任务标记为失败，fromStatus 不为空时只在任务处于该状态时更新
+func (r *batchRunner) failWithErrors(fromStatus string, batchErrors []dto.OpenAIBatchError) {
+	_, err := model.UpdateBatchFields(r.batch.Id, fromStatus, map[string]interface{}{
+		"status":    model.BatchStatusFailed,
+		"failed_at": common.GetTimestamp(),
+		"errors":    marshalBatchErrors(batchErrors),
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("batch %s: update status failed: %v", r.batch.BatchId, err))
+	}
+}
+
+func marshalBatchErrors(batchErrors []dto.OpenAIBatchError) string {
+	data, _ := common.Marshal(batchErrors)
+	return string(data)
+}
+
+func newBatchLineError(code string, message string, lineNo int) dto.OpenAIBatchError {
+	return dto.OpenAIBatchError{Code: code, Message: message, Line: &lineNo}
+}
+
+func newBatchOutputError(customId string, response *dto.OpenAIBatchOutputResponse, code string, message string) *dto.OpenAIBatchOutputLine {
+	return &dto.OpenAIBatchOutputLine{
+		Id:       "batch_req_" + common.GetRandomString(24),
+		CustomId: customId,
+		Response: response,
+		Error:    &dto.OpenAIBatchLineError{Code: code, Message: message},
+	}
+}
+
+// forEachBatchLine 逐行读取 JSONL，跳过空行，fn 返回 false 时停止
+func forEachBatchLine(r io.Reader, fn func(lineNo int, data []byte) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), batchMaxLineBytes)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if !fn(lineNo, data) {
+			return nil
+		}
+	}
+	if errors.Is(scanner.Err(), bufio.ErrTooLong) {
+		return fmt.Errorf("line %d exceeds %d bytes", lineNo+1, batchMaxLineBytes)
+	}
+	return scanner.Err()
+}
+
+func removeTempFile(f *os.File) {
+	_ = f.Close()
+	_ = os.Remove(f.Name())
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/model/modeltest"
+	"github.com/QuantumNous/new-api/pkg/filestore"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/tidwall/gjson"
+)
+
+// setupBatchTestRunner 创建处于 validating 状态的批处理任务，输入文件每行一个 custom_id
+func setupBatchTestRunner(t *testing.T, handler http.HandlerFunc, customIds ...string) *batchRunner {
+	t.Helper()
+	db := modeltest.SetupDB(t, &model.Batch{}, &model.File{}, &model.Token{})
+
+	fileSetting := operation_setting.GetFileSetting()
+	originFileSetting := *fileSetting
+	fileSetting.StorageBackend, fileSetting.StorageConfig = "local", t.TempDir()
+	batchSetting := operation_setting.GetBatchSetting()
+	originBatchSetting := *batchSetting
+	batchSetting.RequestConcurrency, batchSetting.RateLimitRetries, batchSetting.PollIntervalSeconds = 1, 1, 1
+	t.Cleanup(func() {
+		*fileSetting = originFileSetting
+		*batchSetting = originBatchSetting
+	})
+
+	if err := db.Create(&model.Token{Id: 1, UserId: 1, Key: "batch-key"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var input strings.Builder
+	for _, customId := range customIds {
+		input.WriteString(`{"custom_id":"` + customId + `","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"` + customId + `"}]}}` + "\n\n")
+	}
+	inputFile, err := SaveUserFile(1, 1, "input.jsonl", "batch", "application/jsonl", strings.NewReader(input.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := &model.Batch{
+		BatchId:     "batch_test",
+		UserId:      1,
+		TokenId:     1,
+		Endpoint:    "/v1/chat/completions",
+		InputFileId: inputFile.FileId,
+		Status:      model.BatchStatusValidating,
+		ExpiresAt:   BatchExpiresAt(common.GetTimestamp()),
+	}
+	if err := model.InsertBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	return &batchRunner{handler: handler, batch: batch}
+}
+
+// readBatchTestResults 读取结果文件中每个 custom_id 对应的行
+func readBatchTestResults(t *testing.T, fileId string) map[string]gjson.Result {
+	t.Helper()
+	results := make(map[string]gjson.Result)
+	if fileId == "" {
+		return results
+	}
+	file, err := model.GetUserFile(1, fileId)
+	if err != nil || file == nil {
+		t.Fatalf("get result file %s: %v", fileId, err)
+	}
+	content, err := OpenFileContent(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer content.Close()
+	data, err := io.ReadAll(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		result := gjson.Parse(line)
+		results[result.Get("custom_id").String()] = result
+	}
+	return results
+}
+
+func getBatchTestBatch(t *testing.T, runner *batchRunner) *model.Batch {
+	t.Helper()
+	batch, err := model.GetUserBatch(1, runner.batch.BatchId)
+	if err != nil || batch == nil {
+		t.Fatalf("get batch: %v", err)
+	}
+	return batch
+}
+
+func TestBatchRunnerExecutesLines(t *testing.T) {
+	var rateLimited atomic.Bool
+	runner := setupBatchTestRunner(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		customId := gjson.GetBytes(body, "messages.0.content").String()
+		// 每行请求使用任务令牌，按批处理请求计费且不使用流式响应
+		if r.Header.Get("Authorization") != "Bearer sk-batch-key" || relaycommon.GetBatchRequestId(r.Context()) != "batch_test" || gjson.GetBytes(body, "stream").Bool() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(common.RequestIdKey, "req-"+customId)
+		switch customId {
+		case "limited":
+			if !rateLimited.Swap(true) {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		case "bad":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"code":"invalid_model","message":"bad model"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-` + customId + `","object":"chat.completion"}`))
+	}, "ok", "limited", "bad")
+
+	runner.run()
+
+	batch := getBatchTestBatch(t, runner)
+	if batch.Status != model.BatchStatusCompleted || batch.CompletedAt == 0 {
+		t.Fatalf("status = %s, completed_at = %d", batch.Status, batch.CompletedAt)
+	}
+	if batch.RequestTotal != 3 || batch.RequestCompleted != 2 || batch.RequestFailed != 1 {
+		t.Fatalf("request counts = %d/%d/%d", batch.RequestTotal, batch.RequestCompleted, batch.RequestFailed)
+	}
+
+	outputs := readBatchTestResults(t, batch.OutputFileId)
+	if len(outputs) != 2 {
+		t.Fatalf("outputs = %v", outputs)
+	}
+	for _, customId := range []string{"ok", "limited"} {
+		output := outputs[customId]
+		if output.Get("response.status_code").Int() != http.StatusOK ||
+			output.Get("response.request_id").String() != "req-"+customId ||
+			output.Get("response.body.id").String() != "chatcmpl-"+customId {
+			t.Errorf("output %s = %s", customId, output.Raw)
+		}
+	}
+	errorLine := readBatchTestResults(t, batch.ErrorFileId)["bad"]
+	if errorLine.Get("error.code").String() != "invalid_model" || errorLine.Get("response.status_code").Int() != http.StatusBadRequest {
+		t.Errorf("error line = %s", errorLine.Raw)
+	}
+}
+
+func TestBatchRunnerValidationFailure(t *testing.T) {
+	var called atomic.Bool
+	runner := setupBatchTestRunner(t, func(w http.ResponseWriter, r *http.Request) {
+		called.Store(true)
+	}, "dup", "dup")
+
+	runner.run()
+
+	batch := getBatchTestBatch(t, runner)
+	if batch.Status != model.BatchStatusFailed || !strings.Contains(batch.Errors, "duplicate_custom_id") {
+		t.Fatalf("status = %s, errors = %s", batch.Status, batch.Errors)
+	}
+	if called.Load() {
+		t.Fatal("invalid batch should not execute any request")
+	}
+}
+
+// batchTestStorage 在打开指定次数后返回错误，模拟执行期间读取输入文件失败
+type batchTestStorage struct {
+	filestore.Storage
+	opens     atomic.Int32
+	failAfter int32
+}
+
+func (s *batchTestStorage) Open(key string) (io.ReadCloser, error) {
+	if s.opens.Add(1) > s.failAfter {
+		return nil, errors.New("storage unavailable")
+	}
+	return s.Storage.Open(key)
+}
+
+func TestBatchRunnerExecuteFailure(t *testing.T) {
+	var called atomic.Bool
+	runner := setupBatchTestRunner(t, func(w http.ResponseWriter, r *http.Request) {
+		called.Store(true)
+	}, "a")
+	// 校验时可以读取输入文件，开始执行后读取失败
+	storage, err := GetFileStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileStorageMu.Lock()
+	fileStorage = &batchTestStorage{Storage: storage, failAfter: 1}
+	fileStorageMu.Unlock()
+	t.Cleanup(func() {
+		fileStorageMu.Lock()
+		fileStorage = storage
+		fileStorageMu.Unlock()
+	})
+
+	runner.run()
+
+	batch := getBatchTestBatch(t, runner)
+	if batch.Status != model.BatchStatusFailed || batch.InProgressAt == 0 || batch.FailedAt == 0 || !strings.Contains(batch.Errors, "execution_failed") {
+		t.Fatalf("status = %s, in_progress_at = %d, failed_at = %d, errors = %s", batch.Status, batch.InProgressAt, batch.FailedAt, batch.Errors)
+	}
+	if called.Load() {
+		t.Fatal("no request should be executed")
+	}
+}
+
+func TestBatchRunnerInputFileError(t *testing.T) {
+	runner := setupBatchTestRunner(t, func(w http.ResponseWriter, r *http.Request) {}, "a")
+	// 查询输入文件出错时任务失败，而不是一直停留在 validating
+	if err := model.DB.Migrator().DropTable(&model.File{}); err != nil {
+		t.Fatal(err)
+	}
+
+	runner.run()
+
+	batch := getBatchTestBatch(t, runner)
+	if batch.Status != model.BatchStatusFailed || !strings.Contains(batch.Errors, "server_error") {
+		t.Fatalf("status = %s, errors = %s", batch.Status, batch.Errors)
+	}
+}
+
+func TestBatchRunnerCancel(t *testing.T) {
+	var runner *batchRunner
+	var calls atomic.Int32
+	runner = setupBatchTestRunner(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			// 第一行执行期间取消任务，等待执行器发现取消
+			if err := model.CancelBatch(runner.batch.Id); err != nil {
+				t.Error(err)
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for runner.stopStatus.Load() == nil && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+		_, _ = w.Write([]byte(`{"object":"chat.completion"}`))
+	}, "a", "b", "c", "d")
+
+	runner.run()
+
+	batch := getBatchTestBatch(t, runner)
+	if batch.Status != model.BatchStatusCancelled || batch.CancelledAt == 0 || batch.CompletedAt != 0 {
+		t.Fatalf("status = %s, cancelled_at = %d, completed_at = %d", batch.Status, batch.CancelledAt, batch.CompletedAt)
+	}
+	// 已在等待执行的一行仍会完成，之后不再发送新的请求
+	if calls.Load() != 2 || batch.RequestCompleted != 2 || batch.RequestFailed != 0 {
+		t.Fatalf("calls = %d, request counts = %d/%d", calls.Load(), batch.RequestCompleted, batch.RequestFailed)
+	}
+	if outputs := readBatchTestResults(t, batch.OutputFileId); len(outputs) != 2 {
+		t.Fatalf("outputs = %v", outputs)
+	}
+}
+
+func TestBatchRunnerCancelAfterLastRequest(t *testing.T) {
+	var runner *batchRunner
+	runner = setupBatchTestRunner(t, func(w http.ResponseWriter, r *http.Request) {
+		// 最后一行执行完成后、执行器发现取消之前被取消
+		if err := model.CancelBatch(runner.batch.Id); err != nil {
+			t.Error(err)
+		}
+		_, _ = w.Write([]byte(`{"object":"chat.completion"}`))
+	}, "a")
+
+	runner.run()
+
+	batch := getBatchTestBatch(t, runner)
+	if batch.Status != model.BatchStatusCancelled || batch.CompletedAt != 0 || batch.OutputFileId == "" {
+		t.Fatalf("status = %s, completed_at = %d, output = %q", batch.Status, batch.CompletedAt, batch.OutputFileId)
+	}
+}
+
+func TestBatchRetryAfter(t *testing.T) {
+	tests := []struct {
+		header  string
+		attempt int
+		want    time.Duration
+	}{
+		{header: "3", want: 3 * time.Second},
+		{header: "", attempt: 0, want: time.Second},
+		{header: "invalid", attempt: 2, want: 4 * time.Second},
+		{header: "0", attempt: 10, want: 32 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := batchRetryAfter(tt.header, tt.attempt); got != tt.want {
+			t.Errorf("batchRetryAfter(%q, %d) = %v, want %v", tt.header, tt.attempt, got, tt.want)
+		}
+	}
+}
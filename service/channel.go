@@ -9,6 +9,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/metrics"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
 )
@@ -29,6 +30,7 @@ func DisableChannel(channelError types.ChannelError, reason string) {
 
 	success := model.UpdateChannelStatus(channelError.ChannelId, channelError.UsingKey, common.ChannelStatusAutoDisabled, reason)
 	if success {
+		metrics.IncChannelAutoDisabled(channelError.ChannelId)
 		subject := fmt.Sprintf("通道「%s」（#%d）已被禁用", channelError.ChannelName, channelError.ChannelId)
 		content := fmt.Sprintf("通道「%s」（#%d）已被禁用，原因：%s", channelError.ChannelName, channelError.ChannelId, reason)
 		NotifyRootUser(formatNotifyType(channelError.ChannelId, common.ChannelStatusAutoDisabled), subject, content)
@@ -57,6 +59,10 @@ func ShouldDisableChannel(channelType int, err *types.NewAPIError) bool {
 	if types.IsSkipRetryError(err) {
 		return false
 	}
+	// 启用熔断后，仅影响单个模型的错误交给熔断器处理，不再禁用整个渠道
+	if operation_setting.IsCircuitBreakerEnabled() && IsModelScopedChannelError(err) {
+		return false
+	}
 	if operation_setting.ShouldDisableByStatusCode(err.StatusCode) {
 		return true
 	}
@@ -99,6 +105,52 @@ func ShouldDisableChannel(channelType int, err *types.NewAPIError) bool {
 	return search
 }
 
+// 上游表示模型不存在或不支持的错误码
+var modelScopedErrorCodes = map[types.ErrorCode]bool{
+	types.ErrorCodeModelNotFound: true,
+	"model_not_supported":        true,
+	"unsupported_model":          true,
+	"invalid_model":              true,
+	"not_found_error":            true,
+}
+
+// 不说明具体原因的通用错误码，只有这些情况才根据错误信息判断
+var genericUpstreamErrorCodes = map[types.ErrorCode]bool{
+	"":                                   true,
+	"unknown_error":                      true,
+	"upstream_error":                     true,
+	"invalid_request_error":              true,
+	types.ErrorCodeBadResponseStatusCode: true,
+}
+
+// IsModelScopedChannelError 判断错误是否只与当前请求的模型有关（如模型不存在、不支持）。
+// 以状态码和错误码为准，只有上游返回没有具体错误码的 400 时才根据错误信息判断
+func IsModelScopedChannelError(err *types.NewAPIError) bool {
+	if err == nil {
+		return false
+	}
+	if err.StatusCode == http.StatusNotFound || modelScopedErrorCodes[err.GetErrorCode()] {
+		return true
+	}
+	if err.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	// OpenAI 格式的参数错误会指出出错的参数
+	if err.ToOpenAIError().Param == "model" {
+		return true
+	}
+	if !genericUpstreamErrorCodes[err.GetErrorCode()] {
+		return false
+	}
+	lowerMessage := strings.ToLower(err.Error())
+	if !strings.Contains(lowerMessage, "model") {
+		return false
+	}
+	return strings.Contains(lowerMessage, "not found") ||
+		strings.Contains(lowerMessage, "does not exist") ||
+		strings.Contains(lowerMessage, "not supported")
+}
+
 func ShouldEnableChannel(newAPIError *types.NewAPIError, status int) bool {
 	if !common.AutomaticEnableChannelEnabled {
 		return false
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const channelConcurrencyNamespace = "new-api:channel_concurrency:v1"
+
+// 依次检查每个槽是否已满，全部未满时一起占用。返回 0 表示成功，否则返回已满的槽序号（从 1 开始）
+var channelConcurrencyAcquireScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local expire = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+local member = ARGV[4]
+for i, key in ipairs(KEYS) do
+	redis.call('ZREMRANGEBYSCORE', key, '-inf', now)
+	if redis.call('ZCARD', key) >= tonumber(ARGV[4 + i]) then
+		return i
+	end
+end
+for _, key in ipairs(KEYS) do
+	redis.call('ZADD', key, expire, member)
+	redis.call('EXPIRE', key, ttl)
+end
+return 0
+`)
+
+type channelConcurrencySlot struct {
+	key    string
+	limit  int
+	perKey bool
+}
+
+// ChannelConcurrencyLease 一次请求占用的渠道 / key 并发槽，Release 可重复调用
+type ChannelConcurrencyLease struct {
+	channelId int
+	keyIndex  int
+	slots     []channelConcurrencySlot
+	member    string
+	once      sync.Once
+	// 关闭后停止续期
+	stop chan struct{}
+}
+
+var (
+	// 本节点的并发槽，与 Redis 相同记录每个占用者的租约到期时间：slot key -> member -> 到期时间（毫秒）
+	channelConcurrencyLeases = make(map[string]map[string]int64)
+	channelConcurrencyLock   sync.Mutex
+	// 每次释放槽时关闭并替换，用于唤醒本节点排队中的请求
+	channelConcurrencyReleased = make(chan struct{})
+	channelConcurrencyWaiters  atomic.Int64
+	channelConcurrencySeq      atomic.Int64
+)
+
+// getChannelConcurrencySlots 返回请求需要占用的槽，渠道和 key 都未配置并发上限时返回空
+func getChannelConcurrencySlots(channel *model.Channel, keyIndex int) []channelConcurrencySlot {
+	slots := make([]channelConcurrencySlot, 0, 2)
+	if limit := channel.GetOtherSettings().MaxConcurrency; limit > 0 {
+		slots = append(slots, channelConcurrencySlot{
+			key:   fmt.Sprintf("%s:%d", channelConcurrencyNamespace, channel.Id),
+			limit: limit,
+		})
+	}
+	if channel.ChannelInfo.IsMultiKey && keyIndex >= 0 {
+		if limit := channel.ChannelInfo.MultiKeyLimits[keyIndex].MaxConcurrency; limit > 0 {
+			slots = append(slots, channelConcurrencySlot{
+				key:    fmt.Sprintf("%s:%d:%d", channelConcurrencyNamespace, channel.Id, keyIndex),
+				limit:  limit,
+				perKey: true,
+			})
+		}
+	}
+	return slots
+}
+
+func channelConcurrencyLeaseDuration() time.Duration {
+	leaseSeconds := operation_setting.GetChannelConcurrencySetting().LeaseSeconds
+	if leaseSeconds <= 0 {
+		leaseSeconds = 600
+	}
+	return time.Duration(leaseSeconds) * time.Second
+}
+
+// tryAcquireSlots 尝试占用全部槽，返回已满的槽序号（从 0 开始），-1 表示成功
+func (l *ChannelConcurrencyLease) tryAcquireSlots(ctx context.Context) (int, error) {
+	leaseDuration := channelConcurrencyLeaseDuration()
+	now := time.Now()
+	expire := now.Add(leaseDuration).UnixMilli()
+	if common.RedisEnabled {
+		keys := make([]string, len(l.slots))
+		args := []interface{}{now.UnixMilli(), expire, int(leaseDuration/time.Second) * 2, l.member}
+		for i, slot := range l.slots {
+			keys[i] = slot.key
+			args = append(args, slot.limit)
+		}
+		full, err := channelConcurrencyAcquireScript.Run(ctx, common.RDB, keys, args...).Int()
+		if err != nil {
+			return -1, err
+		}
+		return full - 1, nil
+	}
+
+	channelConcurrencyLock.Lock()
+	defer channelConcurrencyLock.Unlock()
+	for i, slot := range l.slots {
+		members := channelConcurrencyLeases[slot.key]
+		for member, expireAt := range members {
+			if expireAt <= now.UnixMilli() {
+				delete(members, member)
+			}
+		}
+		if len(members) >= slot.limit {
+			return i, nil
+		}
+	}
+	for _, slot := range l.slots {
+		members := channelConcurrencyLeases[slot.key]
+		if members == nil {
+			members = make(map[string]int64)
+			channelConcurrencyLeases[slot.key] = members
+		}
+		members[l.member] = expire
+	}
+	return -1, nil
+}
+
+// renew 延长租约的到期时间，已被回收的槽不会重新占用
+func (l *ChannelConcurrencyLease) renew(ctx context.Context) error {
+	leaseDuration := channelConcurrencyLeaseDuration()
+	expire := time.Now().Add(leaseDuration).UnixMilli()
+	if common.RedisEnabled {
+		pipe := common.RDB.Pipeline()
+		for _, slot := range l.slots {
+			pipe.ZAddXX(ctx, slot.key, &redis.Z{Score: float64(expire), Member: l.member})
+			pipe.Expire(ctx, slot.key, leaseDuration*2)
+		}
+		_, err := pipe.Exec(ctx)
+		return err
+	}
+
+	channelConcurrencyLock.Lock()
+	defer channelConcurrencyLock.Unlock()
+	for _, slot := range l.slots {
+		if _, ok := channelConcurrencyLeases[slot.key][l.member]; ok {
+			channelConcurrencyLeases[slot.key][l.member] = expire
+		}
+	}
+	return nil
+}
+
+// keepAlive 请求进行中时每隔三分之一租约时长续期一次，直到 Release
+func (l *ChannelConcurrencyLease) keepAlive() {
+	l.stop = make(chan struct{})
+	interval := channelConcurrencyLeaseDuration() / 3
+	gopool.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			err := l.renew(ctx)
+			cancel()
+			if err != nil {
+				common.SysLog(fmt.Sprintf("failed to renew channel concurrency slot: channel_id=%d, error=%v", l.channelId, err))
+			}
+		}
+	})
+}
+
+// Release 释放占用的并发槽并唤醒排队中的请求
+func (l *ChannelConcurrencyLease) Release() {
+	if l == nil {
+		return
+	}
+	l.once.Do(func() {
+		if l.stop != nil {
+			close(l.stop)
+		}
+		if common.RedisEnabled {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			pipe := common.RDB.Pipeline()
+			for _, slot := range l.slots {
+				pipe.ZRem(ctx, slot.key, l.member)
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				common.SysLog(fmt.Sprintf("failed to release channel concurrency slot: channel_id=%d, error=%v", l.channelId, err))
+			}
+		}
+		channelConcurrencyLock.Lock()
+		if !common.RedisEnabled {
+			for _, slot := range l.slots {
+				members := channelConcurrencyLeases[slot.key]
+				delete(members, l.member)
+				if len(members) == 0 {
+					delete(channelConcurrencyLeases, slot.key)
+				}
+			}
+		}
+		close(channelConcurrencyReleased)
+		channelConcurrencyReleased = make(chan struct{})
+		channelConcurrencyLock.Unlock()
+		model.ClearChannelSaturated(l.channelId, l.keyIndex)
+	})
+}
+
+func channelConcurrencyReleasedSignal() <-chan struct{} {
+	channelConcurrencyLock.Lock()
+	defer channelConcurrencyLock.Unlock()
+	return channelConcurrencyReleased
+}
+
+// AcquireChannelConcurrency 为当前选中的渠道和 key 占用并发槽，渠道和 key 均未配置上限时返回 nil。
+// wait 为 false 时槽已满直接返回 ErrorCodeChannelSaturated，调用方可以换一个渠道；
+// wait 为 true 时进入有界队列等待，直到拿到槽、超时或客户端断开。
+func AcquireChannelConcurrency(c *gin.Context, channelId int, wait bool) (*ChannelConcurrencyLease, *types.NewAPIError) {
+	channel, err := model.CacheGetChannel(channelId)
+	if err != nil || channel == nil {
+		return nil, nil
+	}
+	keyIndex := -1
+	if common.GetContextKeyBool(c, constant.ContextKeyChannelIsMultiKey) {
+		keyIndex = common.GetContextKeyInt(c, constant.ContextKeyChannelMultiKeyIndex)
+	}
+	slots := getChannelConcurrencySlots(channel, keyIndex)
+	if len(slots) == 0 {
+		return nil, nil
+	}
+	lease := &ChannelConcurrencyLease{
+		channelId: channelId,
+		keyIndex:  keyIndex,
+		slots:     slots,
+		member:    fmt.Sprintf("%s:%d", common.GetUUID(), channelConcurrencySeq.Add(1)),
+	}
+
+	full, err := lease.tryAcquireSlots(c.Request.Context())
+	if err != nil {
+		// Redis 异常时不阻塞请求
+		common.SysLog(fmt.Sprintf("failed to acquire channel concurrency slot: channel_id=%d, error=%v", channelId, err))
+		return nil, nil
+	}
+	if full < 0 {
+		lease.keepAlive()
+		return lease, nil
+	}
+	markChannelConcurrencyFull(lease, full)
+	if !wait {
+		return nil, types.NewErrorWithStatusCode(fmt.Errorf("channel #%d concurrency limit reached", channelId), types.ErrorCodeChannelSaturated, http.StatusTooManyRequests)
+	}
+
+	setting := operation_setting.GetChannelConcurrencySetting()
+	if channelConcurrencyWaiters.Add(1) > int64(setting.QueueSize) {
+		channelConcurrencyWaiters.Add(-1)
+		return nil, types.NewErrorWithStatusCode(errors.New("channel concurrency queue is full"), types.ErrorCodeChannelSaturated, http.StatusTooManyRequests)
+	}
+	defer channelConcurrencyWaiters.Add(-1)
+
+	timeout := time.NewTimer(time.Duration(setting.QueueTimeoutSeconds) * time.Second)
+	defer timeout.Stop()
+	pollInterval := time.Duration(setting.PollIntervalMillis) * time.Millisecond
+	if pollInterval <= 0 {
+		pollInterval = 200 * time.Millisecond
+	}
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return nil, types.NewErrorWithStatusCode(c.Request.Context().Err(), types.ErrorCodeChannelSaturated, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry())
+		case <-timeout.C:
+			return nil, types.NewErrorWithStatusCode(fmt.Errorf("channel #%d concurrency queue timeout", channelId), types.ErrorCodeChannelSaturated, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry())
+		case <-channelConcurrencyReleasedSignal():
+		case <-poll.C:
+		}
+		full, err = lease.tryAcquireSlots(c.Request.Context())
+		if err != nil {
+			common.SysLog(fmt.Sprintf("failed to acquire channel concurrency slot: channel_id=%d, error=%v", channelId, err))
+			return nil, nil
+		}
+		if full < 0 {
+			lease.keepAlive()
+			return lease, nil
+		}
+	}
+}
+
+// markChannelConcurrencyFull 记录本节点观察到的已满状态，之后选择渠道 / key 时优先跳过
+func markChannelConcurrencyFull(lease *ChannelConcurrencyLease, full int) {
+	if lease.slots[full].perKey {
+		model.MarkChannelKeySaturated(lease.channelId, lease.keyIndex)
+		return
+	}
+	model.MarkChannelSaturated(lease.channelId)
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/model/modeltest"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupChannelConcurrencyTest 创建最大并发为 1 的渠道，租约时长 1 秒
+func setupChannelConcurrencyTest(t *testing.T) (*gin.Context, int) {
+	t.Helper()
+	db := modeltest.SetupDB(t, &model.Channel{})
+	memoryCache := common.MemoryCacheEnabled
+	common.MemoryCacheEnabled = false
+	setting := operation_setting.GetChannelConcurrencySetting()
+	originSetting := *setting
+	setting.LeaseSeconds = 1
+	t.Cleanup(func() {
+		common.MemoryCacheEnabled = memoryCache
+		*setting = originSetting
+	})
+
+	channel := &model.Channel{Id: 1, Name: "concurrency", Key: "sk-test", Status: common.ChannelStatusEnabled}
+	channel.SetOtherSettings(dto.ChannelOtherSettings{MaxConcurrency: 1})
+	if err := db.Create(channel).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	return c, channel.Id
+}
+
+func acquireTestChannelConcurrency(t *testing.T, c *gin.Context, channelId int) *ChannelConcurrencyLease {
+	t.Helper()
+	lease, err := AcquireChannelConcurrency(c, channelId, false)
+	if err != nil || lease == nil {
+		t.Fatalf("acquire = %v, %v", lease, err)
+	}
+	return lease
+}
+
+func assertTestChannelSaturated(t *testing.T, c *gin.Context, channelId int) {
+	t.Helper()
+	lease, err := AcquireChannelConcurrency(c, channelId, false)
+	if lease != nil || err == nil || err.GetErrorCode() != types.ErrorCodeChannelSaturated {
+		t.Fatalf("acquire on a full channel = %v, %v", lease, err)
+	}
+}
+
+func TestChannelConcurrencyAcquireRelease(t *testing.T) {
+	c, channelId := setupChannelConcurrencyTest(t)
+
+	lease := acquireTestChannelConcurrency(t, c, channelId)
+	assertTestChannelSaturated(t, c, channelId)
+
+	lease.Release()
+	next := acquireTestChannelConcurrency(t, c, channelId)
+	// 重复释放不会多释放其他请求占用的槽
+	lease.Release()
+	assertTestChannelSaturated(t, c, channelId)
+	next.Release()
+}
+
+func TestChannelConcurrencyQueueWaitsForRelease(t *testing.T) {
+	c, channelId := setupChannelConcurrencyTest(t)
+	lease := acquireTestChannelConcurrency(t, c, channelId)
+	time.AfterFunc(50*time.Millisecond, lease.Release)
+
+	queued, err := AcquireChannelConcurrency(c, channelId, true)
+	if err != nil || queued == nil {
+		t.Fatalf("queued acquire = %v, %v", queued, err)
+	}
+	queued.Release()
+}
+
+func TestChannelConcurrencyLeaseExpiry(t *testing.T) {
+	c, channelId := setupChannelConcurrencyTest(t)
+	channel, err := model.GetChannelById(channelId, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 节点异常退出时租约不再续期，到期后槽被回收
+	orphan := &ChannelConcurrencyLease{channelId: channelId, keyIndex: -1, slots: getChannelConcurrencySlots(channel, -1), member: "orphan"}
+	if full, err := orphan.tryAcquireSlots(c.Request.Context()); full >= 0 || err != nil {
+		t.Fatalf("orphan acquire = %d, %v", full, err)
+	}
+	assertTestChannelSaturated(t, c, channelId)
+	time.Sleep(1100 * time.Millisecond)
+	lease := acquireTestChannelConcurrency(t, c, channelId)
+
+	// 续期后的槽不会被回收，已被回收的租约续期时也不会重新占用槽
+	if err := orphan.renew(c.Request.Context()); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	assertTestChannelSaturated(t, c, channelId)
+	lease.Release()
+	acquireTestChannelConcurrency(t, c, channelId).Release()
+}
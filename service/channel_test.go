@@ -0,0 +1,37 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/QuantumNous/new-api/types"
+)
+
+func TestIsModelScopedChannelError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *types.NewAPIError
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "404", err: types.WithOpenAIError(types.OpenAIError{Message: "not found"}, http.StatusNotFound), want: true},
+		{name: "model_not_found code", err: types.WithOpenAIError(types.OpenAIError{Message: "bad", Code: "model_not_found"}, http.StatusBadRequest), want: true},
+		{name: "claude not_found_error", err: types.WithClaudeError(types.ClaudeError{Type: "not_found_error", Message: "model: foo"}, http.StatusBadRequest), want: true},
+		{name: "model param", err: types.WithOpenAIError(types.OpenAIError{Message: "invalid value", Type: "invalid_request_error", Param: "model"}, http.StatusBadRequest), want: true},
+		{name: "400 message without code", err: types.WithOpenAIError(types.OpenAIError{Message: "The model foo does not exist"}, http.StatusBadRequest), want: true},
+		{name: "400 unrelated message", err: types.WithOpenAIError(types.OpenAIError{Message: "messages is required"}, http.StatusBadRequest), want: false},
+		// 状态码或错误码说明是其他原因时，即使错误信息提到模型也不算
+		{name: "401 mentioning model", err: types.WithOpenAIError(types.OpenAIError{Message: "key has no access, model not supported", Code: "invalid_api_key"}, http.StatusUnauthorized), want: false},
+		{name: "429 mentioning model", err: types.WithOpenAIError(types.OpenAIError{Message: "rate limit for model not found in tier"}, http.StatusTooManyRequests), want: false},
+		{name: "500 mentioning model", err: types.NewErrorWithStatusCode(errors.New("model not found in backend pool"), types.ErrorCodeBadResponseStatusCode, http.StatusInternalServerError), want: false},
+		{name: "specific code mentioning model", err: types.WithOpenAIError(types.OpenAIError{Message: "model context length not supported", Code: "context_length_exceeded"}, http.StatusBadRequest), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsModelScopedChannelError(tt.err); got != tt.want {
+				t.Errorf("IsModelScopedChannelError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/filestore"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+var (
+	ErrFileTooLarge         = errors.New("file exceeds the maximum allowed size")
+	ErrFileQuotaExceeded    = errors.New("file storage quota exceeded")
+	ErrFileTooLargeToInline = errors.New("file is too large to be inlined into the request")
+)
+
+// 支持的文件用途
+var filePurposes = map[string]bool{
+	"assistants": true,
+	"batch":      true,
+	"fine-tune":  true,
+	"vision":     true,
+	"user_data":  true,
+	"evals":      true,
+}
+
+func IsValidFilePurpose(purpose string) bool {
+	return filePurposes[purpose]
+}
+
+var (
+	fileStorageMu     sync.Mutex
+	fileStorage       filestore.Storage
+	fileStorageConfig string
+)
+
+// GetFileStorage 返回当前配置的文件存储后端，配置变化后重新创建
+func GetFileStorage() (filestore.Storage, error) {
+	setting := operation_setting.GetFileSetting()
+	backend := setting.StorageBackend
+	if backend == "" {
+		backend = "local"
+	}
+	configKey := backend + "\x00" + setting.StorageConfig
+
+	fileStorageMu.Lock()
+	defer fileStorageMu.Unlock()
+	if fileStorage != nil && fileStorageConfig == configKey {
+		return fileStorage, nil
+	}
+	storage, err := filestore.New(backend, setting.StorageConfig)
+	if err != nil {
+		return nil, err
+	}
+	fileStorage = storage
+	fileStorageConfig = configKey
+	return storage, nil
+}
+
+// CreateUserFile 保存用户上传的文件，检查单文件大小和用户的总存储配额
+func CreateUserFile(userId int, tokenId int, header *multipart.FileHeader, purpose string) (*model.File, error) {
+	setting := operation_setting.GetFileSetting()
+	maxBytes := int64(setting.MaxFileSizeMB) << 20
+	if maxBytes > 0 && header.Size > maxBytes {
+		return nil, ErrFileTooLarge
+	}
+	quotaBytes := int64(setting.UserQuotaMB) << 20
+	if quotaBytes > 0 {
+		// 先粗略检查，避免明显超出配额的文件写入存储，创建记录时再在事务中准确检查
+		used, err := model.SumUserFileBytes(userId)
+		if err != nil {
+			return nil, err
+		}
+		if used+header.Size > quotaBytes {
+			return nil, ErrFileQuotaExceeded
+		}
+	}
+
+	src, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	return saveUserFile(userId, tokenId, header.Filename, purpose, detectFileMimeType(header), src, quotaBytes)
+}
+
+// SaveUserFile 保存文件内容并创建文件记录，不检查大小限制和存储配额
+func SaveUserFile(userId int, tokenId int, filename string, purpose string, mimeType string, content io.Reader) (*model.File, error) {
+	return saveUserFile(userId, tokenId, filename, purpose, mimeType, content, 0)
+}
+
+// saveUserFile 保存文件内容并创建文件记录，quotaBytes 大于 0 时超出用户的存储配额则删除已保存的内容
+func saveUserFile(userId int, tokenId int, filename string, purpose string, mimeType string, content io.Reader, quotaBytes int64) (*model.File, error) {
+	storage, err := GetFileStorage()
+	if err != nil {
+		return nil, err
+	}
+	fileId := "file-" + common.GetRandomString(24)
+	size, err := storage.Save(fileId, content)
+	if err != nil {
+		return nil, err
+	}
+	file := &model.File{
+		FileId:     fileId,
+		UserId:     userId,
+		TokenId:    tokenId,
+		Filename:   filepath.Base(filename),
+		Purpose:    purpose,
+		Bytes:      size,
+		MimeType:   mimeType,
+		StorageKey: fileId,
+		Status:     model.FileStatusProcessed,
+	}
+	if quotaBytes <= 0 {
+		err = model.InsertFile(file)
+	} else if ok, insertErr := model.InsertUserFileWithinQuota(file, quotaBytes); insertErr != nil {
+		err = insertErr
+	} else if !ok {
+		err = ErrFileQuotaExceeded
+	}
+	if err != nil {
+		_ = storage.Delete(fileId)
+		return nil, err
+	}
+	return file, nil
+}
+
+func detectFileMimeType(header *multipart.FileHeader) string {
+	if mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(header.Filename))); mimeType != "" {
+		return mimeType
+	}
+	if mimeType := header.Header.Get("Content-Type"); mimeType != "" {
+		return mimeType
+	}
+	return "application/octet-stream"
+}
+
+// DeleteUserFile 删除文件记录和存储的内容，已上传到上游渠道的副本不会删除
+func DeleteUserFile(file *model.File) error {
+	if err := model.DeleteFile(file); err != nil {
+		return err
+	}
+	storage, err := GetFileStorage()
+	if err != nil {
+		return err
+	}
+	return storage.Delete(file.StorageKey)
+}
+
+// OpenFileContent 打开文件内容
+func OpenFileContent(file *model.File) (io.ReadCloser, error) {
+	storage, err := GetFileStorage()
+	if err != nil {
+		return nil, err
+	}
+	return storage.Open(file.StorageKey)
+}
+
+// GetFileDataURL 把文件内容编码为 data URL，用于内联到请求中
+func GetFileDataURL(file *model.File) (string, error) {
+	maxBytes := int64(operation_setting.GetFileSetting().InlineMaxSizeMB) << 20
+	if maxBytes > 0 && file.Bytes > maxBytes {
+		return "", ErrFileTooLargeToInline
+	}
+	content, err := OpenFileContent(file)
+	if err != nil {
+		return "", err
+	}
+	defer content.Close()
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("data:%s;base64,%s", file.MimeType, base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// ShouldReuploadFile 判断引用文件时是否上传到上游渠道，只有单 key 的 OpenAI 渠道支持，多 key 渠道的文件无法在各个 key 之间共享
+func ShouldReuploadFile(info *relaycommon.RelayInfo) bool {
+	return operation_setting.GetFileSetting().ReuploadToOpenAI &&
+		info.ChannelMeta != nil &&
+		info.ChannelType == constant.ChannelTypeOpenAI &&
+		!info.ChannelIsMultiKey
+}
+
+// EnsureUpstreamFile 返回文件在当前渠道上的上游 file_id，尚未上传时上传到上游并记录
+func EnsureUpstreamFile(ctx context.Context, info *relaycommon.RelayInfo, file *model.File) (string, error) {
+	upstreamFileId, err := model.GetFileUpstreamId(file.FileId, info.ChannelId)
+	if err != nil {
+		return "", err
+	}
+	if upstreamFileId != "" {
+		return upstreamFileId, nil
+	}
+	upstreamFileId, err = uploadFileToUpstream(ctx, info, file)
+	if err != nil {
+		return "", err
+	}
+	if err := model.SaveFileUpstreamId(file.FileId, info.ChannelId, upstreamFileId); err != nil {
+		common.SysError(fmt.Sprintf("failed to save upstream file id: file_id=%s, channel_id=%d, error=%v", file.FileId, info.ChannelId, err))
+	}
+	return upstreamFileId, nil
+}
+
+func uploadFileToUpstream(ctx context.Context, info *relaycommon.RelayInfo, file *model.File) (string, error) {
+	content, err := OpenFileContent(file)
+	if err != nil {
+		return "", err
+	}
+
+	// 边读边写，避免把大文件读入内存
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		defer content.Close()
+		err := writer.WriteField("purpose", file.Purpose)
+		if err == nil {
+			partHeader := make(textproto.MIMEHeader)
+			partHeader.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, strings.ReplaceAll(file.Filename, `"`, "")))
+			partHeader.Set("Content-Type", file.MimeType)
+			var part io.Writer
+			part, err = writer.CreatePart(partHeader)
+			if err == nil {
+				_, err = io.Copy(part, content)
+			}
+		}
+		if err == nil {
+			err = writer.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	url := relaycommon.GetFullRequestURL(info.ChannelBaseUrl, "/v1/files", info.ChannelType)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, pr)
+	if err != nil {
+		_ = pr.Close()
+		return "", err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+info.ApiKey)
+	if info.Organization != "" {
+		req.Header.Set("OpenAI-Organization", info.Organization)
+	}
+
+	client := GetHttpClient()
+	if info.ChannelSetting.Proxy != "" {
+		client, err = NewProxyHttpClient(info.ChannelSetting.Proxy)
+		if err != nil {
+			_ = pr.Close()
+			return "", err
+		}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("upload file to upstream failed: status %d, body %s", resp.StatusCode, string(body))
+	}
+	var uploaded struct {
+		Id string `json:"id"`
+	}
+	if err := common.Unmarshal(body, &uploaded); err != nil {
+		return "", err
+	}
+	if uploaded.Id == "" {
+		return "", errors.New("upload file to upstream failed: empty file id")
+	}
+	return uploaded.Id, nil
+}
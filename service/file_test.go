@@ -0,0 +1,71 @@
+package service
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/model/modeltest"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+func TestSaveUserFileQuota(t *testing.T) {
+	db := modeltest.SetupDB(t, &model.File{}, &model.User{})
+	fileSetting := operation_setting.GetFileSetting()
+	originFileSetting := *fileSetting
+	storageDir := t.TempDir()
+	fileSetting.StorageBackend, fileSetting.StorageConfig = "local", storageDir
+	t.Cleanup(func() { *fileSetting = originFileSetting })
+	if err := db.Create(&model.User{Id: 1, Username: "file-user"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 并发上传时配额只够保存其中两个文件
+	const uploads, quotaBytes = 5, 10
+	var wg sync.WaitGroup
+	errs := make(chan error, uploads)
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := saveUserFile(1, 1, "a.txt", "batch", "text/plain", strings.NewReader("01234"), quotaBytes)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	saved := 0
+	for err := range errs {
+		if err == nil {
+			saved++
+		} else if !errors.Is(err, ErrFileQuotaExceeded) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	used, err := model.SumUserFileBytes(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved != 2 || used != quotaBytes {
+		t.Fatalf("saved = %d, used = %d", saved, used)
+	}
+	// 超出配额的文件不保留内容
+	stored := 0
+	err = filepath.WalkDir(storageDir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			stored++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != saved {
+		t.Fatalf("stored files = %d, want %d", stored, saved)
+	}
+}
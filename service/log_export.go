@@ -0,0 +1,176 @@
+package service
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+)
+
+const (
+	LogExportFormatCSV    = "csv"
+	LogExportFormatNDJSON = "ndjson"
+)
+
+// other 字段中的列使用 other.<key> 表示，嵌套字段用点号连接，如 other.admin_info.use_channel
+const logExportOtherPrefix = "other."
+
+var logExportColumns = map[string]func(log *model.Log) any{
+	"id":                func(log *model.Log) any { return log.Id },
+	"created_at":        func(log *model.Log) any { return log.CreatedAt },
+	"time":              func(log *model.Log) any { return time.Unix(log.CreatedAt, 0).Format("2006-01-02 15:04:05") },
+	"type":              func(log *model.Log) any { return log.Type },
+	"user_id":           func(log *model.Log) any { return log.UserId },
+	"username":          func(log *model.Log) any { return log.Username },
+	"token_id":          func(log *model.Log) any { return log.TokenId },
+	"token_name":        func(log *model.Log) any { return log.TokenName },
+	"model_name":        func(log *model.Log) any { return log.ModelName },
+	"quota":             func(log *model.Log) any { return log.Quota },
+	"prompt_tokens":     func(log *model.Log) any { return log.PromptTokens },
+	"completion_tokens": func(log *model.Log) any { return log.CompletionTokens },
+	"use_time":          func(log *model.Log) any { return log.UseTime },
+	"is_stream":         func(log *model.Log) any { return log.IsStream },
+	"channel":           func(log *model.Log) any { return log.ChannelId },
+	"channel_name":      func(log *model.Log) any { return log.ChannelName },
+	"group":             func(log *model.Log) any { return log.Group },
+	"ip":                func(log *model.Log) any { return log.Ip },
+	"content":           func(log *model.Log) any { return log.Content },
+	"other":             func(log *model.Log) any { return log.Other },
+}
+
+var DefaultLogExportColumns = []string{
+	"id", "time", "type", "username", "token_name", "model_name", "group", "channel",
+	"quota", "prompt_tokens", "completion_tokens",
+	"other.cache_tokens", "other.cache_creation_tokens",
+	"other.model_ratio", "other.group_ratio", "other.completion_ratio", "other.cache_ratio", "other.model_price",
+	"use_time", "is_stream", "ip",
+}
+
+// ParseLogExportColumns 解析逗号分隔的列名，为空时使用默认列
+func ParseLogExportColumns(raw string) ([]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return DefaultLogExportColumns, nil
+	}
+	var columns []string
+	for _, column := range strings.Split(raw, ",") {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			continue
+		}
+		if _, ok := logExportColumns[column]; !ok {
+			if !strings.HasPrefix(column, logExportOtherPrefix) || len(column) == len(logExportOtherPrefix) {
+				return nil, fmt.Errorf("未知的导出列: %s", column)
+			}
+		}
+		columns = append(columns, column)
+	}
+	if len(columns) == 0 {
+		return DefaultLogExportColumns, nil
+	}
+	return columns, nil
+}
+
+// LogExportWriter 把日志按行编码为 CSV 或 NDJSON
+type LogExportWriter struct {
+	format  string
+	columns []string
+	w       io.Writer
+	csv     *csv.Writer
+}
+
+func NewLogExportWriter(w io.Writer, format string, columns []string) (*LogExportWriter, error) {
+	writer := &LogExportWriter{format: format, columns: columns, w: w}
+	switch format {
+	case LogExportFormatCSV:
+		writer.csv = csv.NewWriter(w)
+		if err := writer.csv.Write(columns); err != nil {
+			return nil, err
+		}
+	case LogExportFormatNDJSON:
+	default:
+		return nil, fmt.Errorf("不支持的导出格式: %s", format)
+	}
+	return writer, nil
+}
+
+func (w *LogExportWriter) Write(logs []*model.Log) error {
+	record := make([]string, len(w.columns))
+	for _, log := range logs {
+		var other map[string]any
+		if log.Other != "" {
+			other, _ = common.StrToMap(log.Other)
+		}
+		if w.csv != nil {
+			for i, column := range w.columns {
+				record[i] = csvValue(logExportValue(log, other, column))
+			}
+			if err := w.csv.Write(record); err != nil {
+				return err
+			}
+			continue
+		}
+		row := make(map[string]any, len(w.columns))
+		for _, column := range w.columns {
+			row[column] = logExportValue(log, other, column)
+		}
+		data, err := common.Marshal(row)
+		if err != nil {
+			return err
+		}
+		if _, err := w.w.Write(append(data, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *LogExportWriter) Flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return nil
+}
+
+func logExportValue(log *model.Log, other map[string]any, column string) any {
+	if fn, ok := logExportColumns[column]; ok {
+		return fn(log)
+	}
+	var value any = other
+	for _, key := range strings.Split(strings.TrimPrefix(column, logExportOtherPrefix), ".") {
+		m, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+func csvValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		// 避免表格软件把内容当作公式执行
+		if v != "" && strings.ContainsRune("=+-@", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case int, int64, bool:
+		return fmt.Sprintf("%v", v)
+	default:
+		data, err := common.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(data)
+	}
+}
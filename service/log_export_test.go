@@ -0,0 +1,62 @@
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+)
+
+func TestLogExportWriter(t *testing.T) {
+	logs := []*model.Log{
+		{Id: 2, Username: "alice", ModelName: "gpt-4o", Quota: 150, Other: `{"cache_tokens":12,"model_ratio":1.25,"admin_info":{"use_channel":["3"]}}`},
+		{Id: 1, Username: "=cmd", ModelName: "o3", Quota: 10},
+	}
+	columns, err := ParseLogExportColumns("id, username, quota, other.cache_tokens, other.model_ratio, other.admin_info.use_channel")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	writer, err := NewLogExportWriter(&buf, LogExportFormatCSV, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Write(logs); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "id,username,quota,other.cache_tokens,other.model_ratio,other.admin_info.use_channel\n" +
+		"2,alice,150,12,1.25,\"[\"\"3\"\"]\"\n" +
+		"1,'=cmd,10,,,\n"
+	if buf.String() != want {
+		t.Errorf("csv output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	writer, err = NewLogExportWriter(&buf, LogExportFormatNDJSON, []string{"id", "other.cache_tokens"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Write(logs); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[0] != `{"id":2,"other.cache_tokens":12}` || lines[1] != `{"id":1,"other.cache_tokens":null}` {
+		t.Errorf("unexpected ndjson output: %q", buf.String())
+	}
+}
+
+func TestParseLogExportColumns(t *testing.T) {
+	if columns, err := ParseLogExportColumns(""); err != nil || len(columns) != len(DefaultLogExportColumns) {
+		t.Errorf("empty columns should use defaults, got %v %v", columns, err)
+	}
+	for _, raw := range []string{"id,password", "other."} {
+		if _, err := ParseLogExportColumns(raw); err == nil {
+			t.Errorf("%q should be rejected", raw)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -70,9 +71,58 @@ func GenerateTextOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, m
 
 	other["admin_info"] = adminInfo
 	appendRequestPath(ctx, relayInfo, other)
+	appendHedgeInfo(relayInfo, other, modelRatio, groupRatio, modelPrice)
+	if relayInfo.ResponseCacheHit {
+		other["response_cache_hit"] = true
+		other["response_cache_ratio"] = relayInfo.PriceData.OtherRatios[ResponseCacheHitRatioKey]
+	}
+	if moderation := relayInfo.OutputModeration; moderation != nil && len(moderation.Words) > 0 {
+		other["output_moderation"] = map[string]interface{}{
+			"action":  moderation.Action,
+			"words":   moderation.Words,
+			"aborted": moderation.Aborted,
+		}
+	}
+	if moderation := relayInfo.Moderation; moderation != nil && len(moderation.PromptCategories)+len(moderation.CompletionCategories) > 0 {
+		other["moderation"] = map[string]interface{}{
+			"action":                moderation.Action,
+			"prompt_categories":     moderation.PromptCategories,
+			"completion_categories": moderation.CompletionCategories,
+		}
+	}
+	if ctx != nil && ctx.Request != nil {
+		if batchId := relaycommon.GetBatchRequestId(ctx.Request.Context()); batchId != "" {
+			other["batch_id"] = batchId
+			other["batch_group_ratio"] = ratio_setting.GetBatchGroupRatio(relayInfo.UsingGroup)
+		}
+	}
 	return other
 }
 
+// appendHedgeInfo 记录对冲请求的结果，extra_quota 为被取消的请求按输入估算的额外上游费用，不向用户收取
+func appendHedgeInfo(relayInfo *relaycommon.RelayInfo, other map[string]interface{}, modelRatio, groupRatio, modelPrice float64) {
+	hedge := relayInfo.Hedge
+	if hedge == nil {
+		return
+	}
+	hedgeInfo := map[string]interface{}{
+		"primary_channel": hedge.PrimaryChannelId,
+		"hedge_channel":   hedge.HedgeChannelId,
+		"winner_channel":  hedge.WinnerChannelId,
+		"delay_ms":        hedge.DelayMillis,
+		"loser_status":    hedge.LoserStatus,
+	}
+	if hedge.ExtraPromptTokens > 0 {
+		extraQuota := float64(hedge.ExtraPromptTokens) * modelRatio * groupRatio
+		if relayInfo.PriceData.UsePrice {
+			extraQuota = modelPrice * common.QuotaPerUnit * groupRatio
+		}
+		hedgeInfo["extra_prompt_tokens"] = hedge.ExtraPromptTokens
+		hedgeInfo["extra_quota"] = int(extraQuota)
+	}
+	other["hedge"] = hedgeInfo
+}
+
 func GenerateWssOtherInfo(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.RealtimeUsage, modelRatio, groupRatio, completionRatio, audioRatio, audioCompletionRatio, modelPrice, userGroupRatio float64) map[string]interface{} {
 	info := GenerateTextOtherInfo(ctx, relayInfo, modelRatio, groupRatio, completionRatio, 0, 0.0, modelPrice, userGroupRatio)
 	info["ws"] = true
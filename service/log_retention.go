@@ -0,0 +1,157 @@
+package service
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+const logRetentionBatchSize = 1000
+
+var logRetentionTypes = map[string]int{
+	"topup":   model.LogTypeTopup,
+	"consume": model.LogTypeConsume,
+	"manage":  model.LogTypeManage,
+	"system":  model.LogTypeSystem,
+	"error":   model.LogTypeError,
+	"refund":  model.LogTypeRefund,
+}
+
+// StartLogRetention 每小时按保留策略汇总、归档并删除过期日志
+func StartLogRetention() {
+	for {
+		RunLogRetention(context.Background())
+		time.Sleep(time.Hour)
+	}
+}
+
+// logRetentionCutoff 返回保留 days 天时的过期时间点，按本地时间的整天对齐
+func logRetentionCutoff(now time.Time, days int) time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return today.AddDate(0, 0, -days)
+}
+
+func RunLogRetention(ctx context.Context) {
+	setting := operation_setting.GetLogRetentionSetting()
+	if !setting.Enabled {
+		return
+	}
+	now := time.Now()
+	for name, days := range setting.RetentionDays {
+		logType, ok := logRetentionTypes[name]
+		if !ok || days <= 0 {
+			continue
+		}
+		cutoff := logRetentionCutoff(now, days).Unix()
+		if err := applyLogRetention(ctx, name, logType, cutoff, setting); err != nil {
+			common.SysError(fmt.Sprintf("log retention for %s logs failed: %s", name, err.Error()))
+		}
+	}
+}
+
+func applyLogRetention(ctx context.Context, name string, logType int, cutoff int64, setting *operation_setting.LogRetentionSetting) error {
+	deleteBefore := cutoff
+	if setting.RollupEnabled {
+		rolledUpTo, err := rollupLogsBefore(ctx, logType, cutoff)
+		if err != nil {
+			return err
+		}
+		// 只删除已经汇总过的明细
+		deleteBefore = min(cutoff, rolledUpTo)
+	}
+	if setting.ArchiveEnabled {
+		n, file, err := archiveLogsBefore(ctx, name, logType, deleteBefore, setting.ArchiveDir)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			common.SysLog(fmt.Sprintf("archived %d %s logs to %s", n, name, file))
+		}
+	}
+	n, err := model.DeleteLogsBefore(ctx, logType, deleteBefore, logRetentionBatchSize)
+	if n > 0 {
+		common.SysLog(fmt.Sprintf("deleted %d %s logs before %s", n, name, time.Unix(deleteBefore, 0).Format(time.DateOnly)))
+	}
+	return err
+}
+
+// rollupLogsBefore 逐天汇总 cutoff 之前尚未汇总的日志，返回已汇总到的时间点
+func rollupLogsBefore(ctx context.Context, logType int, cutoff int64) (int64, error) {
+	from, err := model.GetLogRollupState(logType)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return from, err
+		}
+		next, ok, err := model.MinLogCreatedAt(logType, from, cutoff)
+		if err != nil {
+			return from, err
+		}
+		if !ok {
+			return cutoff, nil
+		}
+		t := time.Unix(next, 0)
+		dayStart := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		dayEnd := dayStart.AddDate(0, 0, 1).Unix()
+		if dayEnd > cutoff {
+			// [from, dayStart) 内没有日志，视为已汇总
+			return dayStart.Unix(), nil
+		}
+		if _, err := model.RollupLogs(logType, dayStart.Unix(), dayEnd); err != nil {
+			return from, err
+		}
+		from = dayEnd
+	}
+}
+
+// archiveLogsBefore 把 before 之前的日志写入 gzip 压缩的 NDJSON 文件，全部写完后才改名为正式文件
+func archiveLogsBefore(ctx context.Context, name string, logType int, before int64, dir string) (int, string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, "", err
+	}
+	file := filepath.Join(dir, fmt.Sprintf("logs-%s-%s-%d.ndjson.gz", name, time.Unix(before, 0).Format("20060102"), time.Now().Unix()))
+	tmp := file + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return 0, "", err
+	}
+	gz := gzip.NewWriter(f)
+	count := 0
+	err = model.ExportLogs(ctx, model.LogExportFilter{Type: logType, EndTimestamp: before - 1}, logRetentionBatchSize, func(logs []*model.Log) error {
+		for _, log := range logs {
+			data, err := common.Marshal(log)
+			if err != nil {
+				return err
+			}
+			if _, err := gz.Write(append(data, '\n')); err != nil {
+				return err
+			}
+		}
+		count += len(logs)
+		return nil
+	})
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || count == 0 {
+		_ = os.Remove(tmp)
+		return 0, "", err
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		_ = os.Remove(tmp)
+		return 0, "", err
+	}
+	return count, file, nil
+}
@@ -0,0 +1,18 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLogRetentionCutoff(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	now := time.Date(2026, 3, 18, 15, 30, 0, 0, loc)
+
+	if got, want := logRetentionCutoff(now, 14), time.Date(2026, 3, 4, 0, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("14 days: got %s, want %s", got, want)
+	}
+	if got, want := logRetentionCutoff(now, 90), time.Date(2025, 12, 18, 0, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("90 days: got %s, want %s", got, want)
+	}
+}
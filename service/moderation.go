@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/tidwall/gjson"
+)
+
+// ParseModerationScores 解析审核模型的响应，返回各分类的分数
+func ParseModerationScores(provider string, body []byte) (map[string]float64, error) {
+	if !gjson.ValidBytes(body) {
+		return nil, errors.New("invalid moderation response")
+	}
+	root := gjson.ParseBytes(body)
+	scores := make(map[string]float64)
+	switch provider {
+	case operation_setting.ModerationProviderGuard:
+		content := root.Get("choices.0.message.content")
+		if !content.Exists() {
+			return nil, errors.New("moderation response has no content")
+		}
+		// Llama Guard 输出第一行为 safe 或 unsafe，unsafe 时第二行为逗号分隔的分类
+		lines := strings.Split(strings.TrimSpace(content.String()), "\n")
+		verdict := strings.ToLower(strings.TrimSpace(lines[0]))
+		switch {
+		case verdict == "safe":
+		case strings.HasPrefix(verdict, "unsafe"):
+			for _, line := range lines[1:] {
+				for _, category := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' }) {
+					scores[strings.TrimSpace(category)] = 1
+				}
+			}
+			if len(scores) == 0 {
+				scores["unsafe"] = 1
+			}
+		default:
+			return nil, errors.New("unexpected moderation verdict: " + lines[0])
+		}
+	default:
+		results := root.Get("results")
+		if !results.IsArray() {
+			return nil, errors.New("moderation response has no results")
+		}
+		// 多段输入时按分类取最高分
+		for _, result := range results.Array() {
+			result.Get("category_scores").ForEach(func(key, value gjson.Result) bool {
+				scores[key.String()] = max(scores[key.String()], value.Float())
+				return true
+			})
+			result.Get("categories").ForEach(func(key, value gjson.Result) bool {
+				if value.Bool() {
+					scores[key.String()] = max(scores[key.String()], 1)
+				}
+				return true
+			})
+		}
+	}
+	return scores, nil
+}
+
+// FlaggedModerationCategories 返回分数不低于阈值的分类
+func FlaggedModerationCategories(setting *operation_setting.ModerationSetting, scores map[string]float64) []string {
+	var categories []string
+	for category, score := range scores {
+		if score >= setting.Threshold(category) {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// TruncateModerationInput 按字符数截断送审文本
+func TruncateModerationInput(text string, maxChars int) string {
+	if maxChars <= 0 {
+		return text
+	}
+	runes := []rune(text)
+	if len(runes) <= maxChars {
+		return text
+	}
+	return string(runes[:maxChars])
+}
+
+// OutputText 返回非流式响应中的生成文本
+func OutputText(body []byte) string {
+	var builder strings.Builder
+	for _, field := range collectModerationFields(string(body), false) {
+		if builder.Len() > 0 {
+			builder.WriteString("\n")
+		}
+		builder.WriteString(field.text)
+	}
+	return builder.String()
+}
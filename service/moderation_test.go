@@ -0,0 +1,45 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+func TestParseModerationScores(t *testing.T) {
+	scores, err := ParseModerationScores(operation_setting.ModerationProviderOpenAI, []byte(`{"results":[
+		{"flagged":true,"categories":{"violence":true},"category_scores":{"violence":0.42,"hate":0.1}},
+		{"flagged":false,"categories":{"violence":false},"category_scores":{"violence":0.2,"hate":0.7}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores["violence"] != 1 || scores["hate"] != 0.7 {
+		t.Fatalf("unexpected scores: %v", scores)
+	}
+
+	scores, err = ParseModerationScores(operation_setting.ModerationProviderGuard, []byte(`{"choices":[{"message":{"role":"assistant","content":"unsafe\nS1,S10"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(scores, map[string]float64{"S1": 1, "S10": 1}) {
+		t.Fatalf("unexpected guard scores: %v", scores)
+	}
+
+	scores, err = ParseModerationScores(operation_setting.ModerationProviderGuard, []byte(`{"choices":[{"message":{"content":" safe "}}]}`))
+	if err != nil || len(scores) != 0 {
+		t.Fatalf("expected safe verdict, got %v %v", scores, err)
+	}
+	if _, err = ParseModerationScores(operation_setting.ModerationProviderOpenAI, []byte(`{"error":{}}`)); err == nil {
+		t.Fatal("expected error for response without results")
+	}
+}
+
+func TestFlaggedModerationCategories(t *testing.T) {
+	setting := &operation_setting.ModerationSetting{Thresholds: map[string]float64{"*": 0.8, "violence": 0.3, "S6": 2}}
+	categories := FlaggedModerationCategories(setting, map[string]float64{"violence": 0.4, "hate": 0.7, "sexual": 0.9, "S6": 1})
+	if !reflect.DeepEqual(categories, []string{"sexual", "violence"}) {
+		t.Fatalf("unexpected categories: %v", categories)
+	}
+}
@@ -1,6 +1,8 @@
 package service
 
 import (
+	"encoding/json"
+
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/service/openaicompat"
 )
@@ -20,3 +22,19 @@ func ExtractOutputTextFromResponses(resp *dto.OpenAIResponsesResponse) string {
 func ShouldChatCompletionsUseResponsesGlobal(channelID int, channelType int, model string) bool {
 	return openaicompat.ShouldChatCompletionsUseResponsesGlobal(channelID, channelType, model)
 }
+
+func ResponsesInputItems(input json.RawMessage) ([]map[string]any, error) {
+	return openaicompat.ResponsesInputItems(input)
+}
+
+func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest, items []map[string]any) (*dto.GeneralOpenAIRequest, error) {
+	return openaicompat.ResponsesRequestToChatCompletionsRequest(req, items)
+}
+
+func NewResponsesResponse(id string, createdAt int, req *dto.OpenAIResponsesRequest) *dto.OpenAIResponsesResponse {
+	return openaicompat.NewResponsesResponse(id, createdAt, req)
+}
+
+func NewChatToResponsesConverter(response *dto.OpenAIResponsesResponse) *openaicompat.ChatToResponsesConverter {
+	return openaicompat.NewChatToResponsesConverter(response)
+}
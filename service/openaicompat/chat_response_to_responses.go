@@ -0,0 +1,343 @@
+package openaicompat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// NewResponsesResponse 按请求参数生成状态为 in_progress 的 Responses 响应对象
+func NewResponsesResponse(id string, createdAt int, req *dto.OpenAIResponsesRequest) *dto.OpenAIResponsesResponse {
+	resp := &dto.OpenAIResponsesResponse{
+		ID:                 id,
+		Object:             "response",
+		CreatedAt:          createdAt,
+		Status:             "in_progress",
+		MaxOutputTokens:    int(req.MaxOutputTokens),
+		Model:              req.Model,
+		Output:             []dto.ResponsesOutput{},
+		ParallelToolCalls:  true,
+		PreviousResponseID: req.PreviousResponseID,
+		Reasoning:          req.Reasoning,
+		Store:              true,
+		Temperature:        1,
+		ToolChoice:         "auto",
+		Tools:              req.GetToolsMap(),
+		TopP:               1,
+		Truncation:         "disabled",
+		Metadata:           req.Metadata,
+	}
+	if common.GetJsonType(req.Instructions) == "string" {
+		_ = common.Unmarshal(req.Instructions, &resp.Instructions)
+	}
+	if common.GetJsonType(req.ParallelToolCalls) == "boolean" {
+		_ = common.Unmarshal(req.ParallelToolCalls, &resp.ParallelToolCalls)
+	}
+	if common.GetJsonType(req.Store) == "boolean" {
+		_ = common.Unmarshal(req.Store, &resp.Store)
+	}
+	if common.GetJsonType(req.ToolChoice) == "string" {
+		_ = common.Unmarshal(req.ToolChoice, &resp.ToolChoice)
+	}
+	if req.Temperature != 0 {
+		resp.Temperature = req.Temperature
+	}
+	if req.TopP != 0 {
+		resp.TopP = req.TopP
+	}
+	if req.Truncation != "" {
+		resp.Truncation = req.Truncation
+	}
+	if req.User != "" {
+		resp.User, _ = common.Marshal(req.User)
+	}
+	if resp.Tools == nil {
+		resp.Tools = []map[string]any{}
+	}
+	return resp
+}
+
+// ChatToResponsesConverter 把 chat completions 响应转换为 Responses 响应，流式响应逐个分片转换为 Responses 事件。
+// 只转换第一个候选
+type ChatToResponsesConverter struct {
+	response *dto.OpenAIResponsesResponse
+	// item id 的公共部分，取响应 id 去掉前缀
+	idSeed string
+	items  []*dto.ResponsesOutput
+	texts  []*strings.Builder
+	// 正在输出的文本或思考 item 序号，没有时为 -1
+	current int
+	// chat 工具调用序号到 item 序号
+	toolCalls    map[int]int
+	finishReason string
+	started      bool
+	finished     bool
+}
+
+func NewChatToResponsesConverter(response *dto.OpenAIResponsesResponse) *ChatToResponsesConverter {
+	idSeed := response.ID
+	if _, after, ok := strings.Cut(idSeed, "_"); ok {
+		idSeed = after
+	}
+	return &ChatToResponsesConverter{
+		response:  response,
+		idSeed:    idSeed,
+		current:   -1,
+		toolCalls: make(map[int]int),
+	}
+}
+
+// Response 返回转换后的响应对象，Finish 之前 output 不完整
+func (c *ChatToResponsesConverter) Response() *dto.OpenAIResponsesResponse {
+	c.response.Output = make([]dto.ResponsesOutput, 0, len(c.items))
+	for _, item := range c.items {
+		c.response.Output = append(c.response.Output, *item)
+	}
+	return c.response
+}
+
+func (c *ChatToResponsesConverter) snapshot() *dto.OpenAIResponsesResponse {
+	response := *c.Response()
+	return &response
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// Start 返回 response.created 和 response.in_progress 事件，只在第一次调用时返回
+func (c *ChatToResponsesConverter) Start() []dto.ResponsesStreamResponse {
+	if c.started {
+		return nil
+	}
+	c.started = true
+	return []dto.ResponsesStreamResponse{
+		{Type: "response.created", Response: c.snapshot()},
+		{Type: "response.in_progress", Response: c.snapshot()},
+	}
+}
+
+func (c *ChatToResponsesConverter) addItem(item *dto.ResponsesOutput) (int, dto.ResponsesStreamResponse) {
+	index := len(c.items)
+	if item.ID == "" {
+		item.ID = fmt.Sprintf("%s_%s_%d", itemIdPrefix(item.Type), c.idSeed, index)
+	}
+	c.items = append(c.items, item)
+	c.texts = append(c.texts, &strings.Builder{})
+	added := *item
+	return index, dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemAdded, OutputIndex: intPtr(index), Item: &added}
+}
+
+func itemIdPrefix(itemType string) string {
+	switch itemType {
+	case "reasoning":
+		return "rs"
+	case "function_call":
+		return "fc"
+	}
+	return "msg"
+}
+
+// openItem 开始输出指定类型的文本或思考 item，类型不同时先结束正在输出的 item
+func (c *ChatToResponsesConverter) openItem(itemType string) []dto.ResponsesStreamResponse {
+	if c.current >= 0 && c.items[c.current].Type == itemType {
+		return nil
+	}
+	events := c.closeCurrent()
+	// reasoning item 没有 status 字段
+	item := &dto.ResponsesOutput{Type: itemType}
+	part := &dto.ResponsesReasoningSummaryPart{Type: "summary_text"}
+	partEvent := "response.reasoning_summary_part.added"
+	if itemType == "message" {
+		item.Status = "in_progress"
+		item.Role = "assistant"
+		part.Type = "output_text"
+		partEvent = "response.content_part.added"
+	}
+	index, added := c.addItem(item)
+	c.current = index
+	event := dto.ResponsesStreamResponse{Type: partEvent, ItemID: item.ID, OutputIndex: intPtr(index), Part: part}
+	if itemType == "message" {
+		event.ContentIndex = intPtr(0)
+	} else {
+		event.SummaryIndex = intPtr(0)
+	}
+	return append(events, added, event)
+}
+
+// closeCurrent 结束正在输出的文本或思考 item
+func (c *ChatToResponsesConverter) closeCurrent() []dto.ResponsesStreamResponse {
+	if c.current < 0 {
+		return nil
+	}
+	index := c.current
+	c.current = -1
+	item := c.items[index]
+	text := c.texts[index].String()
+
+	var events []dto.ResponsesStreamResponse
+	if item.Type == "message" {
+		item.Status = "completed"
+		item.Content = []dto.ResponsesOutputContent{{Type: "output_text", Text: text, Annotations: []interface{}{}}}
+		events = []dto.ResponsesStreamResponse{
+			{Type: "response.output_text.done", ItemID: item.ID, OutputIndex: intPtr(index), ContentIndex: intPtr(0), Text: text},
+			{Type: "response.content_part.done", ItemID: item.ID, OutputIndex: intPtr(index), ContentIndex: intPtr(0), Part: &dto.ResponsesReasoningSummaryPart{Type: "output_text", Text: text}},
+		}
+	} else {
+		item.Summary = []dto.ResponsesReasoningSummaryPart{{Type: "summary_text", Text: text}}
+		events = []dto.ResponsesStreamResponse{
+			{Type: "response.reasoning_summary_text.done", ItemID: item.ID, OutputIndex: intPtr(index), SummaryIndex: intPtr(0), Text: text},
+			{Type: "response.reasoning_summary_part.done", ItemID: item.ID, OutputIndex: intPtr(index), SummaryIndex: intPtr(0), Part: &dto.ResponsesReasoningSummaryPart{Type: "summary_text", Text: text}},
+		}
+	}
+	done := *item
+	return append(events, dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemDone, OutputIndex: intPtr(index), Item: &done})
+}
+
+func (c *ChatToResponsesConverter) appendText(itemType string, delta string) []dto.ResponsesStreamResponse {
+	events := c.openItem(itemType)
+	index := c.current
+	c.texts[index].WriteString(delta)
+	event := dto.ResponsesStreamResponse{ItemID: c.items[index].ID, OutputIndex: intPtr(index), Delta: delta}
+	if itemType == "message" {
+		event.Type = "response.output_text.delta"
+		event.ContentIndex = intPtr(0)
+	} else {
+		event.Type = "response.reasoning_summary_text.delta"
+		event.SummaryIndex = intPtr(0)
+	}
+	return append(events, event)
+}
+
+func (c *ChatToResponsesConverter) appendToolCall(position int, toolCall dto.ToolCallResponse) []dto.ResponsesStreamResponse {
+	var events []dto.ResponsesStreamResponse
+	chatIndex := position
+	if toolCall.Index != nil {
+		chatIndex = *toolCall.Index
+	}
+	index, ok := c.toolCalls[chatIndex]
+	if !ok {
+		events = c.closeCurrent()
+		callId := toolCall.ID
+		if callId == "" {
+			callId = fmt.Sprintf("call_%s_%d", c.idSeed, len(c.items))
+		}
+		var added dto.ResponsesStreamResponse
+		index, added = c.addItem(&dto.ResponsesOutput{
+			Type:   "function_call",
+			Status: "in_progress",
+			CallId: callId,
+			Name:   toolCall.Function.Name,
+		})
+		c.toolCalls[chatIndex] = index
+		events = append(events, added)
+	} else if toolCall.Function.Name != "" && c.items[index].Name == "" {
+		c.items[index].Name = toolCall.Function.Name
+	}
+	if toolCall.Function.Arguments != "" {
+		c.texts[index].WriteString(toolCall.Function.Arguments)
+		events = append(events, dto.ResponsesStreamResponse{
+			Type:        "response.function_call_arguments.delta",
+			ItemID:      c.items[index].ID,
+			OutputIndex: intPtr(index),
+			Delta:       toolCall.Function.Arguments,
+		})
+	}
+	return events
+}
+
+// ConvertChunk 转换一个 chat completions 流式分片
+func (c *ChatToResponsesConverter) ConvertChunk(chunk *dto.ChatCompletionsStreamResponse) []dto.ResponsesStreamResponse {
+	events := c.Start()
+	for _, choice := range chunk.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		if reasoning := choice.Delta.GetReasoningContent(); reasoning != "" {
+			events = append(events, c.appendText("reasoning", reasoning)...)
+		}
+		if content := choice.Delta.GetContentString(); content != "" {
+			events = append(events, c.appendText("message", content)...)
+		}
+		for i, toolCall := range choice.Delta.ToolCalls {
+			events = append(events, c.appendToolCall(i, toolCall)...)
+		}
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			c.finishReason = *choice.FinishReason
+		}
+	}
+	return events
+}
+
+// ConvertResponse 转换非流式响应，返回值同 Finish
+func (c *ChatToResponsesConverter) ConvertResponse(resp *dto.OpenAITextResponse, usage *dto.Usage) *dto.OpenAIResponsesResponse {
+	c.started = true
+	for _, choice := range resp.Choices {
+		if choice.Index != 0 {
+			continue
+		}
+		reasoning := choice.Message.ReasoningContent
+		if reasoning == "" {
+			reasoning = choice.Message.Reasoning
+		}
+		if reasoning != "" {
+			c.appendText("reasoning", reasoning)
+		}
+		if content := choice.Message.StringContent(); content != "" {
+			c.appendText("message", content)
+		}
+		for i, toolCall := range choice.Message.ParseToolCalls() {
+			c.appendToolCall(i, dto.ToolCallResponse{
+				ID:       toolCall.ID,
+				Type:     toolCall.Type,
+				Function: dto.FunctionResponse{Name: toolCall.Function.Name, Arguments: toolCall.Function.Arguments},
+			})
+		}
+		c.finishReason = choice.FinishReason
+	}
+	c.Finish(usage)
+	return c.Response()
+}
+
+// Finish 结束所有 item 并返回 response.completed 或 response.incomplete 事件
+func (c *ChatToResponsesConverter) Finish(usage *dto.Usage) []dto.ResponsesStreamResponse {
+	if c.finished {
+		return nil
+	}
+	c.finished = true
+	events := append(c.Start(), c.closeCurrent()...)
+	for index, item := range c.items {
+		if item.Type != "function_call" {
+			continue
+		}
+		item.Status = "completed"
+		item.Arguments = c.texts[index].String()
+		done := *item
+		events = append(events,
+			dto.ResponsesStreamResponse{Type: "response.function_call_arguments.done", ItemID: item.ID, OutputIndex: intPtr(index), Arguments: item.Arguments},
+			dto.ResponsesStreamResponse{Type: dto.ResponsesOutputTypeItemDone, OutputIndex: intPtr(index), Item: &done},
+		)
+	}
+
+	c.response.Status = "completed"
+	switch c.finishReason {
+	case "length":
+		c.response.Status = "incomplete"
+		c.response.IncompleteDetails = &dto.IncompleteDetails{Reason: "max_output_tokens"}
+	case "content_filter":
+		c.response.Status = "incomplete"
+		c.response.IncompleteDetails = &dto.IncompleteDetails{Reason: "content_filter"}
+	}
+	if usage != nil {
+		c.response.Usage = &dto.Usage{
+			InputTokens:            usage.PromptTokens,
+			OutputTokens:           usage.CompletionTokens,
+			TotalTokens:            usage.PromptTokens + usage.CompletionTokens,
+			InputTokensDetails:     &dto.InputTokenDetails{CachedTokens: usage.PromptTokensDetails.CachedTokens},
+			CompletionTokenDetails: dto.OutputTokenDetails{ReasoningTokens: usage.CompletionTokenDetails.ReasoningTokens},
+		}
+	}
+	return append(events, dto.ResponsesStreamResponse{Type: "response." + c.response.Status, Response: c.snapshot()})
+}
@@ -0,0 +1,253 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ResponsesInputItems 把 Responses 请求的 input 解析为 item 列表，字符串 input 视为一条用户消息
+func ResponsesInputItems(input json.RawMessage) ([]map[string]any, error) {
+	switch common.GetJsonType(input) {
+	case "string":
+		var text string
+		if err := common.Unmarshal(input, &text); err != nil {
+			return nil, err
+		}
+		return []map[string]any{{
+			"type":    "message",
+			"role":    "user",
+			"content": []any{map[string]any{"type": "input_text", "text": text}},
+		}}, nil
+	case "array":
+		var items []map[string]any
+		if err := common.Unmarshal(input, &items); err != nil {
+			return nil, err
+		}
+		return items, nil
+	case "unknown", "null":
+		return nil, nil
+	}
+	return nil, errors.New("input must be a string or an array of items")
+}
+
+// ResponsesRequestToChatCompletionsRequest 把 Responses 请求转换为 chat completions 请求，items 为包含历史轮次在内的完整上下文
+func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest, items []map[string]any) (*dto.GeneralOpenAIRequest, error) {
+	if req == nil {
+		return nil, errors.New("request is nil")
+	}
+	if req.Model == "" {
+		return nil, errors.New("model is required")
+	}
+
+	out := &dto.GeneralOpenAIRequest{
+		Model:     req.Model,
+		Stream:    req.Stream,
+		MaxTokens: req.MaxOutputTokens,
+		TopP:      req.TopP,
+		User:      req.User,
+	}
+	if req.Stream {
+		out.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+	if req.Temperature != 0 {
+		temperature := req.Temperature
+		out.Temperature = &temperature
+	}
+	if req.Reasoning != nil {
+		out.ReasoningEffort = req.Reasoning.Effort
+	}
+	if len(req.ParallelToolCalls) > 0 {
+		var parallel bool
+		if err := common.Unmarshal(req.ParallelToolCalls, &parallel); err == nil {
+			out.ParallelTooCalls = &parallel
+		}
+	}
+
+	if common.GetJsonType(req.Instructions) == "string" {
+		var instructions string
+		_ = common.Unmarshal(req.Instructions, &instructions)
+		if strings.TrimSpace(instructions) != "" {
+			out.Messages = append(out.Messages, dto.Message{Role: "system", Content: instructions})
+		}
+	}
+	out.Messages = append(out.Messages, responsesItemsToChatMessages(items)...)
+
+	for _, tool := range req.GetToolsMap() {
+		// 内置工具（web_search、file_search 等）没有对应的 chat 工具，忽略
+		if common.Interface2String(tool["type"]) != "function" {
+			continue
+		}
+		out.Tools = append(out.Tools, dto.ToolCallRequest{
+			Type: "function",
+			Function: dto.FunctionRequest{
+				Name:        common.Interface2String(tool["name"]),
+				Description: common.Interface2String(tool["description"]),
+				Parameters:  tool["parameters"],
+			},
+		})
+	}
+	if len(out.Tools) > 0 {
+		out.ToolChoice = responsesToolChoiceToChat(req.ToolChoice)
+	}
+
+	out.ResponseFormat = responsesTextToChatResponseFormat(req.Text)
+	return out, nil
+}
+
+func responsesItemsToChatMessages(items []map[string]any) []dto.Message {
+	messages := make([]dto.Message, 0, len(items))
+	for _, item := range items {
+		switch common.Interface2String(item["type"]) {
+		case "", "message":
+			role := common.Interface2String(item["role"])
+			if role == "developer" {
+				role = "system"
+			}
+			if role == "" {
+				continue
+			}
+			messages = append(messages, dto.Message{Role: role, Content: responsesContentToChat(item["content"])})
+		case "function_call":
+			toolCall := dto.ToolCallRequest{
+				ID:   common.Interface2String(item["call_id"]),
+				Type: "function",
+				Function: dto.FunctionRequest{
+					Name:      common.Interface2String(item["name"]),
+					Arguments: common.Interface2String(item["arguments"]),
+				},
+			}
+			// 同一轮的多个工具调用合并到前一条 assistant 消息
+			n := len(messages)
+			if n > 0 && messages[n-1].Role == "assistant" {
+				toolCalls := append(messages[n-1].ParseToolCalls(), toolCall)
+				messages[n-1].SetToolCalls(toolCalls)
+				continue
+			}
+			message := dto.Message{Role: "assistant", Content: ""}
+			message.SetToolCalls([]dto.ToolCallRequest{toolCall})
+			messages = append(messages, message)
+		case "function_call_output":
+			output := item["output"]
+			if _, ok := output.(string); !ok {
+				output = responsesContentToChat(output)
+			}
+			messages = append(messages, dto.Message{
+				Role:       "tool",
+				ToolCallId: common.Interface2String(item["call_id"]),
+				Content:    output,
+			})
+		}
+		// reasoning 等其它 item 上游无法识别，忽略
+	}
+	return messages
+}
+
+// responsesContentToChat 把 Responses 的内容块转换为 chat 消息内容，只有一段文本时使用字符串
+func responsesContentToChat(content any) any {
+	parts, ok := content.([]any)
+	if !ok {
+		if text, ok := content.(string); ok {
+			return text
+		}
+		return ""
+	}
+	contents := make([]any, 0, len(parts))
+	for _, part := range parts {
+		partMap, ok := part.(map[string]any)
+		if !ok {
+			continue
+		}
+		switch common.Interface2String(partMap["type"]) {
+		case "input_text", "output_text", "text":
+			contents = append(contents, dto.MediaContent{Type: dto.ContentTypeText, Text: common.Interface2String(partMap["text"])})
+		case "refusal":
+			contents = append(contents, dto.MediaContent{Type: dto.ContentTypeText, Text: common.Interface2String(partMap["refusal"])})
+		case "input_image":
+			url := common.Interface2String(partMap["image_url"])
+			if url == "" {
+				continue
+			}
+			contents = append(contents, dto.MediaContent{
+				Type:     dto.ContentTypeImageURL,
+				ImageUrl: &dto.MessageImageUrl{Url: url, Detail: common.Interface2String(partMap["detail"])},
+			})
+		case "input_file":
+			file := &dto.MessageFile{
+				FileName: common.Interface2String(partMap["filename"]),
+				FileData: common.Interface2String(partMap["file_data"]),
+				FileId:   common.Interface2String(partMap["file_id"]),
+			}
+			if file.FileData == "" && file.FileId == "" {
+				continue
+			}
+			contents = append(contents, dto.MediaContent{Type: dto.ContentTypeFile, File: file})
+		case "input_audio":
+			audio, _ := partMap["input_audio"].(map[string]any)
+			contents = append(contents, dto.MediaContent{
+				Type: dto.ContentTypeInputAudio,
+				InputAudio: &dto.MessageInputAudio{
+					Data:   common.Interface2String(audio["data"]),
+					Format: common.Interface2String(audio["format"]),
+				},
+			})
+		}
+	}
+	if len(contents) == 1 {
+		if text, ok := contents[0].(dto.MediaContent); ok && text.Type == dto.ContentTypeText {
+			return text.Text
+		}
+	}
+	return contents
+}
+
+// responsesToolChoiceToChat Responses: {"type":"function","name":"..."}，Chat: {"type":"function","function":{"name":"..."}}
+func responsesToolChoiceToChat(toolChoice json.RawMessage) any {
+	switch common.GetJsonType(toolChoice) {
+	case "string":
+		var choice string
+		_ = common.Unmarshal(toolChoice, &choice)
+		return choice
+	case "object":
+		var choice map[string]any
+		if err := common.Unmarshal(toolChoice, &choice); err != nil {
+			return nil
+		}
+		if common.Interface2String(choice["type"]) == "function" {
+			return map[string]any{
+				"type":     "function",
+				"function": map[string]any{"name": common.Interface2String(choice["name"])},
+			}
+		}
+	}
+	return nil
+}
+
+func responsesTextToChatResponseFormat(text json.RawMessage) *dto.ResponseFormat {
+	if len(text) == 0 {
+		return nil
+	}
+	var textConfig struct {
+		Format map[string]any `json:"format"`
+	}
+	if err := common.Unmarshal(text, &textConfig); err != nil || textConfig.Format == nil {
+		return nil
+	}
+	switch formatType := common.Interface2String(textConfig.Format["type"]); formatType {
+	case "json_object":
+		return &dto.ResponseFormat{Type: formatType}
+	case "json_schema":
+		schema := make(map[string]any, len(textConfig.Format))
+		for key, value := range textConfig.Format {
+			if key != "type" {
+				schema[key] = value
+			}
+		}
+		schemaRaw, _ := common.Marshal(schema)
+		return &dto.ResponseFormat{Type: formatType, JsonSchema: schemaRaw}
+	}
+	return nil
+}
@@ -0,0 +1,383 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// moderationField 响应 JSON 中的一段生成文本
+type moderationField struct {
+	path string
+	text string
+	// 流式响应中的增量文本，需要与前后分片拼接后检测
+	delta bool
+}
+
+// collectModerationFields 提取 OpenAI、Claude、Gemini 和 Responses 格式响应中的生成文本
+func collectModerationFields(data string, stream bool) []moderationField {
+	root := gjson.Parse(data)
+	if !root.IsObject() {
+		return nil
+	}
+	var fields []moderationField
+	add := func(path string, delta bool) {
+		if v := root.Get(path); v.Type == gjson.String && v.Str != "" {
+			fields = append(fields, moderationField{path: path, text: v.Str, delta: delta})
+		}
+	}
+	forEach := func(path string, fn func(prefix string)) {
+		n := int(root.Get(path + ".#").Int())
+		for i := 0; i < n; i++ {
+			fn(fmt.Sprintf("%s.%d.", path, i))
+		}
+	}
+
+	// OpenAI Chat Completions / Completions
+	forEach("choices", func(prefix string) {
+		add(prefix+"delta.content", true)
+		add(prefix+"delta.reasoning_content", true)
+		add(prefix+"message.content", false)
+		add(prefix+"message.reasoning_content", false)
+		add(prefix+"text", stream)
+	})
+	// Gemini
+	forEach("candidates", func(candidate string) {
+		forEach(candidate+"content.parts", func(part string) {
+			add(part+"text", stream)
+		})
+	})
+
+	switch root.Get("type").Str {
+	// Claude
+	case "content_block_delta":
+		add("delta.text", true)
+		add("delta.thinking", true)
+	case "message":
+		forEach("content", func(prefix string) {
+			add(prefix+"text", false)
+			add(prefix+"thinking", false)
+		})
+	// Responses 流式事件，done/completed 事件包含完整文本，单独检测
+	case "response.output_text.delta", "response.reasoning_summary_text.delta":
+		add("delta", true)
+	case "response.output_text.done", "response.reasoning_summary_text.done":
+		add("text", false)
+	case "response.content_part.done":
+		add("part.text", false)
+	case "response.output_item.done":
+		forEach("item.content", func(prefix string) {
+			add(prefix+"text", false)
+		})
+	case "response.completed":
+		forEach("response.output", func(item string) {
+			forEach(item+"content", func(prefix string) {
+				add(prefix+"text", false)
+			})
+		})
+	}
+	// Responses 非流式响应
+	if root.Get("object").Str == "response" {
+		forEach("output", func(item string) {
+			forEach(item+"content", func(prefix string) {
+				add(prefix+"text", false)
+			})
+		})
+	}
+	return fields
+}
+
+// maskModerationFields 逐个替换字段中的敏感词，返回替换后的 JSON 和命中的敏感词
+func maskModerationFields(data string, fields []moderationField) (string, []string) {
+	var words []string
+	for _, field := range fields {
+		contains, hits, masked := SensitiveWordReplace(field.text, false)
+		if !contains {
+			continue
+		}
+		words = append(words, hits...)
+		if updated, err := sjson.Set(data, field.path, masked); err == nil {
+			data = updated
+		}
+	}
+	return data, words
+}
+
+// ModerateOutputBody 检测非流式响应中的生成文本，返回替换敏感词后的响应和命中的敏感词
+func ModerateOutputBody(body []byte) ([]byte, []string) {
+	if len(setting.SensitiveWords) == 0 {
+		return body, nil
+	}
+	data := string(body)
+	masked, words := maskModerationFields(data, collectModerationFields(data, false))
+	if len(words) == 0 {
+		return body, nil
+	}
+	return []byte(masked), words
+}
+
+// maxSensitiveWordLen 返回最长敏感词的 rune 数
+func maxSensitiveWordLen() int {
+	n := 0
+	for _, word := range setting.SensitiveWords {
+		n = max(n, utf8.RuneCountInString(strings.TrimSpace(word)))
+	}
+	return n
+}
+
+type moderationEvent struct {
+	lines []string
+	// data 行在 lines 中的位置，-1 表示没有可解析的 JSON 数据
+	dataLine int
+	data     string
+	fields   []moderationField
+	runes    [][]rune
+	dirty    bool
+}
+
+func (e *moderationEvent) deltaLen() int {
+	n := 0
+	for _, r := range e.runes {
+		n += len(r)
+	}
+	return n
+}
+
+func (e *moderationEvent) String() string {
+	if e.dirty {
+		data := e.data
+		for i, field := range e.fields {
+			if updated, err := sjson.Set(data, field.path, string(e.runes[i])); err == nil {
+				data = updated
+			}
+		}
+		e.lines[e.dataLine] = "data: " + data
+	}
+	return strings.Join(e.lines, "\n") + "\n\n"
+}
+
+// OutputStreamModerator 检测流式响应中的生成文本。
+// 增量文本会在窗口内保留最长敏感词长度减一个字符后再发出，保证跨分片的敏感词也能被替换。
+type OutputStreamModerator struct {
+	abort   bool
+	format  types.RelayFormat
+	window  int
+	pending []*moderationEvent
+	words   []string
+	aborted bool
+	// 最近一个 OpenAI 格式的数据，用于构造中断时的结束分片
+	lastData string
+}
+
+func NewOutputStreamModerator(format types.RelayFormat, abort bool) *OutputStreamModerator {
+	return &OutputStreamModerator{
+		abort:  abort,
+		format: format,
+		window: max(maxSensitiveWordLen()-1, 0),
+	}
+}
+
+// Words 返回已命中的敏感词
+func (m *OutputStreamModerator) Words() []string {
+	return m.words
+}
+
+func (m *OutputStreamModerator) Aborted() bool {
+	return m.aborted
+}
+
+// Push 处理一个完整的 SSE 事件（不含结尾空行），返回可以发送给客户端的内容
+func (m *OutputStreamModerator) Push(event string) string {
+	if m.aborted {
+		return ""
+	}
+	e := &moderationEvent{lines: strings.Split(event, "\n"), dataLine: -1}
+	for i, line := range e.lines {
+		if data, ok := strings.CutPrefix(line, "data:"); ok {
+			e.dataLine = i
+			e.data = strings.TrimPrefix(data, " ")
+			break
+		}
+	}
+	if e.dataLine < 0 {
+		// 注释和心跳不影响窗口
+		if len(m.pending) == 0 {
+			return e.String()
+		}
+		m.pending = append(m.pending, e)
+		return ""
+	}
+
+	var deltas []moderationField
+	var fulls []moderationField
+	for _, field := range collectModerationFields(e.data, true) {
+		if field.delta {
+			deltas = append(deltas, field)
+		} else {
+			fulls = append(fulls, field)
+		}
+	}
+	if len(fulls) > 0 {
+		masked, words := maskModerationFields(e.data, fulls)
+		if len(words) > 0 {
+			m.words = append(m.words, words...)
+			if m.abort {
+				return m.abortOutput()
+			}
+			e.data = masked
+			e.lines[e.dataLine] = "data: " + masked
+		}
+	}
+	if gjson.Get(e.data, "choices").Exists() {
+		m.lastData = e.data
+	}
+	e.fields = deltas
+	for _, field := range deltas {
+		e.runes = append(e.runes, []rune(field.text))
+	}
+	m.pending = append(m.pending, e)
+
+	if m.scan() && m.abort {
+		return m.abortOutput()
+	}
+	// 没有增量文本的事件（如结束分片）意味着当前文本段结束，不再需要等待后续分片
+	if len(deltas) == 0 {
+		return m.emit(0)
+	}
+	return m.emit(m.window)
+}
+
+// Flush 发送所有保留的事件
+func (m *OutputStreamModerator) Flush() string {
+	if m.aborted {
+		return ""
+	}
+	if m.scan() && m.abort {
+		return m.abortOutput()
+	}
+	return m.emit(0)
+}
+
+// emit 发送之后至少还有 keep 个字符的事件
+func (m *OutputStreamModerator) emit(keep int) string {
+	total := 0
+	for _, e := range m.pending {
+		total += e.deltaLen()
+	}
+	var out strings.Builder
+	sent := 0
+	for _, e := range m.pending {
+		total -= e.deltaLen()
+		if keep > 0 && total < keep {
+			break
+		}
+		out.WriteString(e.String())
+		sent++
+	}
+	m.pending = m.pending[sent:]
+	return out.String()
+}
+
+type moderationPos struct {
+	event int
+	field int
+}
+
+// scan 检测保留窗口内拼接后的增量文本，替换命中的敏感词，返回是否有新的命中
+func (m *OutputStreamModerator) scan() bool {
+	if len(setting.SensitiveWords) == 0 {
+		return false
+	}
+	ac := getOrBuildAC(setting.SensitiveWords)
+	if ac == nil {
+		return false
+	}
+	var text []rune
+	var positions []moderationPos
+	for i, e := range m.pending {
+		for j, r := range e.runes {
+			for range r {
+				positions = append(positions, moderationPos{event: i, field: j})
+			}
+			text = append(text, r...)
+		}
+	}
+	if len(text) == 0 {
+		return false
+	}
+	hits := ac.MultiPatternSearch(lowerRunes(text), false)
+	if len(hits) == 0 {
+		return false
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Pos < hits[j].Pos })
+
+	// 命中的字符删除，在命中开始的位置插入替换文本
+	removed := make([]bool, len(text))
+	insert := make([]bool, len(text))
+	lastPos := 0
+	for _, hit := range hits {
+		m.words = append(m.words, string(hit.Word))
+		end := hit.Pos + len(hit.Word)
+		if hit.Pos >= lastPos {
+			insert[hit.Pos] = true
+		}
+		for i := hit.Pos; i < end; i++ {
+			removed[i] = true
+		}
+		lastPos = max(lastPos, end)
+	}
+	rebuilt := make(map[moderationPos][]rune)
+	changed := make(map[moderationPos]bool)
+	for i, pos := range positions {
+		if _, ok := rebuilt[pos]; !ok {
+			rebuilt[pos] = []rune{}
+		}
+		if insert[i] {
+			rebuilt[pos] = append(rebuilt[pos], []rune(sensitiveWordMask)...)
+		}
+		if removed[i] {
+			changed[pos] = true
+		} else {
+			rebuilt[pos] = append(rebuilt[pos], text[i])
+		}
+	}
+	for pos := range changed {
+		e := m.pending[pos.event]
+		e.runes[pos.field] = rebuilt[pos]
+		e.dirty = true
+	}
+	return true
+}
+
+// abortOutput 丢弃保留的事件，返回按请求格式结束响应的内容
+func (m *OutputStreamModerator) abortOutput() string {
+	m.aborted = true
+	m.pending = nil
+	message := "response blocked by content moderation"
+	switch m.format {
+	case types.RelayFormatClaude:
+		data, _ := sjson.Set(`{"type":"error","error":{"type":"invalid_request_error"}}`, "error.message", message)
+		return "event: error\ndata: " + data + "\n\n"
+	case types.RelayFormatGemini:
+		return `data: {"candidates":[{"content":{"role":"model","parts":[]},"finishReason":"SAFETY","index":0}]}` + "\n\n"
+	case types.RelayFormatOpenAIResponses:
+		data, _ := sjson.Set(`{"type":"error","code":"`+string(types.ErrorCodeSensitiveWordsDetected)+`"}`, "message", message)
+		return "event: error\ndata: " + data + "\n\n"
+	default:
+		// 与 OpenAI 的内容过滤行为一致，以 content_filter 结束
+		data := `{"object":"chat.completion.chunk"}`
+		if m.lastData != "" {
+			data = m.lastData
+		}
+		data, _ = sjson.SetRaw(data, "choices", `[{"index":0,"delta":{},"finish_reason":"content_filter"}]`)
+		data, _ = sjson.Delete(data, "usage")
+		return "data: " + data + "\n\ndata: [DONE]\n\n"
+	}
+}
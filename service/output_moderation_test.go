@@ -0,0 +1,76 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+func withSensitiveWords(t *testing.T, words ...string) {
+	old := setting.SensitiveWords
+	setting.SensitiveWords = words
+	t.Cleanup(func() { setting.SensitiveWords = old })
+}
+
+func TestSensitiveWordReplaceMultiByte(t *testing.T) {
+	withSensitiveWords(t, "敏感词", "bad")
+	contains, words, text := SensitiveWordReplace("这是敏感词和BAD内容", false)
+	if !contains || len(words) != 2 {
+		t.Fatalf("expected 2 hits, got %v", words)
+	}
+	if text != "这是**###**和**###**内容" {
+		t.Fatalf("unexpected replaced text: %s", text)
+	}
+}
+
+func openAIChunk(content string) string {
+	return `data: {"id":"1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"` + content + `"}}]}`
+}
+
+func TestOutputStreamModeratorMaskAcrossChunks(t *testing.T) {
+	withSensitiveWords(t, "敏感词")
+	m := NewOutputStreamModerator(types.RelayFormatOpenAI, false)
+	var out strings.Builder
+	for _, chunk := range []string{"你好敏", "感", "词结束", "。"} {
+		out.WriteString(m.Push(openAIChunk(chunk)))
+	}
+	out.WriteString(m.Push("data: [DONE]"))
+	out.WriteString(m.Flush())
+
+	result := out.String()
+	if strings.Contains(result, "敏") || strings.Contains(result, `"感"`) {
+		t.Fatalf("sensitive word leaked: %s", result)
+	}
+	if !strings.Contains(result, "**###**") || !strings.HasSuffix(result, "data: [DONE]\n\n") {
+		t.Fatalf("unexpected output: %s", result)
+	}
+	if len(m.Words()) != 1 || m.Words()[0] != "敏感词" {
+		t.Fatalf("unexpected words: %v", m.Words())
+	}
+}
+
+func TestOutputStreamModeratorAbort(t *testing.T) {
+	withSensitiveWords(t, "bad")
+	m := NewOutputStreamModerator(types.RelayFormatOpenAI, true)
+	first := m.Push(openAIChunk("hello b"))
+	second := m.Push(openAIChunk("ad"))
+	if strings.Contains(first, "hello") {
+		t.Fatalf("text inside the window should be held back: %s", first)
+	}
+	if !m.Aborted() || !strings.Contains(second, `"finish_reason":"content_filter"`) || strings.Contains(second, "hello") {
+		t.Fatalf("expected content_filter abort, got: %s", second)
+	}
+	if m.Push(openAIChunk("more")) != "" || m.Flush() != "" {
+		t.Fatalf("expected no output after abort")
+	}
+}
+
+func TestModerateOutputBody(t *testing.T) {
+	withSensitiveWords(t, "bad")
+	body, words := ModerateOutputBody([]byte(`{"type":"message","content":[{"type":"text","text":"a bad word"}]}`))
+	if len(words) != 1 || !strings.Contains(string(body), `"a **###** word"`) {
+		t.Fatalf("unexpected result: %s %v", body, words)
+	}
+}
@@ -168,12 +168,15 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 		}
 	}
 
+	RecordSpend(relayInfo, quota)
+
 	logger.LogInfo(ctx, "realtime streaming consume quota success, quota: "+fmt.Sprintf("%d", quota))
 	return nil
 }
 
 func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, modelName string,
 	usage *dto.RealtimeUsage, extraContent string) {
+	RecordChannelKeyTokenUsage(relayInfo, usage.TotalTokens)
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.InputTokenDetails.TextTokens
@@ -254,6 +257,8 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 }
 
 func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage) {
+	RecordChannelKeyTokenUsage(relayInfo, usage.PromptTokens+usage.CompletionTokens)
+	ReconcileTokenTPM(relayInfo, usage.PromptTokens+usage.CompletionTokens)
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	promptTokens := usage.PromptTokens
@@ -393,6 +398,7 @@ func CalcOpenRouterCacheCreateTokens(usage dto.Usage, priceData types.PriceData)
 }
 
 func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, extraContent string) {
+	RecordChannelKeyTokenUsage(relayInfo, usage.PromptTokens+usage.CompletionTokens)
 
 	useTimeSeconds := time.Now().Unix() - relayInfo.StartTime.Unix()
 	textInputTokens := usage.PromptTokensDetails.TextTokens
@@ -495,6 +501,14 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	})
 }
 
+// RecordChannelKeyTokenUsage 将本次请求消耗的 token 计入多 key 渠道中对应 key 的 TPM 统计
+func RecordChannelKeyTokenUsage(relayInfo *relaycommon.RelayInfo, tokens int) {
+	if relayInfo == nil || relayInfo.ChannelMeta == nil || !relayInfo.ChannelIsMultiKey {
+		return
+	}
+	model.RecordChannelKeyTokens(relayInfo.ChannelId, relayInfo.ChannelMultiKeyIndex, tokens)
+}
+
 func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
@@ -541,6 +555,8 @@ func PostConsumeQuota(relayInfo *relaycommon.RelayInfo, quota int, preConsumedQu
 		}
 	}
 
+	RecordSpend(relayInfo, quota)
+
 	if sendEmail {
 		if (quota + preConsumedQuota) != 0 {
 			checkAndSendQuotaNotify(relayInfo, quota, preConsumedQuota)
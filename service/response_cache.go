@@ -0,0 +1,159 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/pkg/cachex"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/hot"
+)
+
+const (
+	responseCacheNamespace = "new-api:response_cache:v1"
+	// 命中缓存时计费倍率在 PriceData.OtherRatios 中的键
+	ResponseCacheHitRatioKey = "response_cache_hit"
+)
+
+// 不影响响应内容的请求字段，计算缓存 key 时忽略
+var responseCacheIgnoredFields = []string{"user", "safety_identifier", "metadata", "store"}
+
+var (
+	responseCacheOnce sync.Once
+	responseCache     *cachex.HybridCache[ResponseCacheEntry]
+)
+
+// ResponseCacheEntry 缓存的响应，流式请求保存发送给客户端的原始 SSE 数据
+type ResponseCacheEntry struct {
+	ContentType string    `json:"content_type"`
+	IsStream    bool      `json:"is_stream"`
+	Body        []byte    `json:"body"`
+	Usage       dto.Usage `json:"usage"`
+}
+
+func getResponseCache() *cachex.HybridCache[ResponseCacheEntry] {
+	responseCacheOnce.Do(func() {
+		setting := operation_setting.GetResponseCacheSetting()
+		capacity := setting.MaxEntries
+		if capacity <= 0 {
+			capacity = 10000
+		}
+		responseCache = cachex.NewHybridCache[ResponseCacheEntry](cachex.HybridCacheConfig[ResponseCacheEntry]{
+			Namespace: cachex.Namespace(responseCacheNamespace),
+			Redis:     common.RDB,
+			RedisEnabled: func() bool {
+				return common.RedisEnabled && common.RDB != nil
+			},
+			RedisCodec: cachex.JSONCodec[ResponseCacheEntry]{},
+			Memory: func() *hot.HotCache[string, ResponseCacheEntry] {
+				return hot.NewHotCache[string, ResponseCacheEntry](hot.LRU, capacity).
+					WithJanitor().
+					Build()
+			},
+		})
+	})
+	return responseCache
+}
+
+// GetResponseCacheKey 计算请求的响应缓存 key，令牌未开启缓存或请求不可缓存时返回空
+func GetResponseCacheKey(c *gin.Context, info *relaycommon.RelayInfo) string {
+	setting := operation_setting.GetResponseCacheSetting()
+	if !setting.Enabled || !common.GetContextKeyBool(c, constant.ContextKeyTokenResponseCache) {
+		return ""
+	}
+	if info.RelayFormat != types.RelayFormatOpenAI {
+		return ""
+	}
+	switch info.RelayMode {
+	case relayconstant.RelayModeChatCompletions:
+		request, ok := info.Request.(*dto.GeneralOpenAIRequest)
+		if !ok || request.N > 1 {
+			return ""
+		}
+		if setting.RequireZeroTemperature && (request.Temperature == nil || *request.Temperature != 0) {
+			return ""
+		}
+	case relayconstant.RelayModeEmbeddings:
+	default:
+		return ""
+	}
+
+	body, err := common.GetRequestBody(c)
+	if err != nil {
+		return ""
+	}
+	normalized, err := normalizeResponseCacheBody(body)
+	if err != nil {
+		return ""
+	}
+	h := sha256.New()
+	h.Write([]byte(info.UsingGroup))
+	h.Write([]byte{0})
+	h.Write([]byte(info.OriginModelName))
+	h.Write([]byte{0})
+	if !setting.ShareAcrossUsers {
+		h.Write([]byte(strconv.Itoa(info.UserId)))
+	}
+	h.Write([]byte{0})
+	h.Write(normalized)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// normalizeResponseCacheBody 把请求体转为键有序、无多余空白的 JSON，并去掉不影响响应的字段
+func normalizeResponseCacheBody(body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// 保留数字的原始写法，避免大整数精度丢失
+	decoder.UseNumber()
+	var request map[string]any
+	if err := decoder.Decode(&request); err != nil {
+		return nil, err
+	}
+	for _, field := range responseCacheIgnoredFields {
+		delete(request, field)
+	}
+	// encoding/json 按键排序输出 map
+	return json.Marshal(request)
+}
+
+// GetResponseCacheTTL 返回令牌的响应缓存时间，未设置时使用全局默认值
+func GetResponseCacheTTL(c *gin.Context) time.Duration {
+	ttl := common.GetContextKeyInt(c, constant.ContextKeyTokenResponseCacheTTL)
+	if ttl <= 0 {
+		ttl = operation_setting.GetResponseCacheSetting().DefaultTTLSeconds
+	}
+	return time.Duration(ttl) * time.Second
+}
+
+func GetResponseCache(key string) (*ResponseCacheEntry, bool) {
+	entry, found, err := getResponseCache().Get(key)
+	if err != nil {
+		common.SysError("failed to get response cache: " + err.Error())
+		return nil, false
+	}
+	if !found {
+		return nil, false
+	}
+	return &entry, true
+}
+
+func SetResponseCache(key string, entry *ResponseCacheEntry, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	if err := getResponseCache().SetWithTTL(key, *entry, ttl); err != nil {
+		common.SysError("failed to set response cache: " + err.Error())
+	}
+}
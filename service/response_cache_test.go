@@ -0,0 +1,38 @@
+package service
+
+import "testing"
+
+func TestNormalizeResponseCacheBody(t *testing.T) {
+	a, err := normalizeResponseCacheBody([]byte(`{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"hi"}],"user":"alice"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := normalizeResponseCacheBody([]byte(`{
+		"messages": [{"content": "hi", "role": "user"}],
+		"temperature": 0,
+		"model": "gpt-4o",
+		"user": "bob"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) != string(b) {
+		t.Fatalf("expected equal normalized bodies, got %s and %s", a, b)
+	}
+
+	c, err := normalizeResponseCacheBody([]byte(`{"model":"gpt-4o","temperature":0,"messages":[{"role":"user","content":"hello"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(a) == string(c) {
+		t.Fatalf("expected different normalized bodies for different messages")
+	}
+
+	big, err := normalizeResponseCacheBody([]byte(`{"seed":12345678901234567890}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(big) != `{"seed":12345678901234567890}` {
+		t.Fatalf("expected number to keep its precision, got %s", big)
+	}
+}
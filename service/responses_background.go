@@ -0,0 +1,244 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/metrics"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// 后台响应的预扣额度记录在 stored_responses.quota 中，响应变为已结束状态时清零。
+// 把响应结束的一方负责结算：取消和执行失败时退还，执行成功时实际费用已在请求中结算
+
+const (
+	// BackgroundResponseHeartbeatInterval 后台响应执行期间刷新更新时间并检查是否已被取消的间隔
+	BackgroundResponseHeartbeatInterval = 10 * time.Second
+	// backgroundResponseStaleTimeout 超过该时间没有刷新的后台响应视为已中断
+	backgroundResponseStaleTimeout = 2 * time.Minute
+)
+
+// 后台响应未结束时的状态
+var backgroundResponseActiveStatuses = []string{dto.ResponsesStatusQueued, dto.ResponsesStatusInProgress}
+
+// 被其他节点结束的后台响应的状态，结束时已退还预扣的额度
+var backgroundResponseSettledStatuses = []string{dto.ResponsesStatusCancelled, dto.ResponsesStatusFailed}
+
+// SubmitBackgroundResponse 保存状态为 queued 的后台响应，记录提交时预扣的额度和计费信息
+func SubmitBackgroundResponse(relayInfo *relaycommon.RelayInfo, request *dto.OpenAIResponsesRequest) (*model.StoredResponse, *dto.OpenAIResponsesResponse, error) {
+	inputItems, err := ResponsesInputItems(request.Input)
+	if err != nil {
+		return nil, nil, err
+	}
+	var history *ResponsesHistory
+	if request.PreviousResponseID != "" {
+		previous, err := model.GetUserStoredResponse(relayInfo.UserId, request.PreviousResponseID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if previous != nil {
+			history = &ResponsesHistory{ConversationId: previous.ConversationId}
+		}
+	}
+	response := NewResponsesResponse("resp_"+common.GetUUID(), int(common.GetTimestamp()), request)
+	response.Status = dto.ResponsesStatusQueued
+	response.Background = true
+	stored, err := newStoredResponse(relayInfo.UserId, history, inputItems, response)
+	if err != nil {
+		return nil, nil, err
+	}
+	stored.Quota = relayInfo.FinalPreConsumedQuota
+	stored.Billing = model.StoredResponseBilling{
+		TokenId:         relayInfo.TokenId,
+		Group:           relayInfo.UsingGroup,
+		IsPlayground:    relayInfo.IsPlayground,
+		TrackTokenSpend: relayInfo.TrackTokenSpend,
+		TrackUserSpend:  relayInfo.TrackUserSpend,
+	}
+	if err = insertStoredResponse(stored); err != nil {
+		return nil, nil, err
+	}
+	return stored, response, nil
+}
+
+// updateBackgroundResponse 响应状态为 fromStatuses 之一时更新状态和响应对象，返回是否更新成功
+func updateBackgroundResponse(stored *model.StoredResponse, status string, response []byte, fromStatuses ...string) (bool, error) {
+	response, err := sjson.SetBytes(response, "status", status)
+	if err != nil {
+		return false, err
+	}
+	updated, err := model.UpdateStoredResponseResult(stored.Id, status, response, fromStatuses...)
+	if updated {
+		stored.Status = status
+		stored.Response = response
+	}
+	return updated, err
+}
+
+// endBackgroundResponse 结束未结束的后台响应并退还预扣的额度，响应已被其他一方结束时返回 false
+func endBackgroundResponse(stored *model.StoredResponse, status string, response []byte) (bool, error) {
+	quota := stored.Quota
+	ended, err := updateBackgroundResponse(stored, status, response, backgroundResponseActiveStatuses...)
+	if err != nil || !ended {
+		return ended, err
+	}
+	stored.Quota = 0
+	if quota != 0 {
+		metrics.AddQuotaRefunded(quota)
+		if err := settleBackgroundResponseQuota(stored, -quota); err != nil {
+			return true, fmt.Errorf("refund background response quota failed: %w", err)
+		}
+	}
+	return true, nil
+}
+
+// settleBackgroundResponseQuota 按提交时的计费信息结算额度，与请求结算相同，同时更新令牌额度和消费窗口
+func settleBackgroundResponseQuota(stored *model.StoredResponse, quota int) error {
+	relayInfo := &relaycommon.RelayInfo{
+		UserId:          stored.UserId,
+		TokenId:         stored.Billing.TokenId,
+		UsingGroup:      stored.Billing.Group,
+		IsPlayground:    stored.Billing.IsPlayground,
+		TrackTokenSpend: stored.Billing.TrackTokenSpend,
+		TrackUserSpend:  stored.Billing.TrackUserSpend,
+	}
+	if !relayInfo.IsPlayground {
+		token, err := model.GetTokenById(relayInfo.TokenId)
+		if err != nil {
+			return err
+		}
+		relayInfo.TokenKey = token.Key
+	}
+	return PostConsumeQuota(relayInfo, quota, 0, false)
+}
+
+// StartBackgroundResponse 将后台响应标记为 in_progress，响应已被取消时返回 false
+func StartBackgroundResponse(stored *model.StoredResponse) (bool, error) {
+	return updateBackgroundResponse(stored, dto.ResponsesStatusInProgress, stored.Response, dto.ResponsesStatusQueued)
+}
+
+// TouchBackgroundResponse 刷新后台响应的更新时间，响应已被取消或删除时返回 false
+func TouchBackgroundResponse(stored *model.StoredResponse) (bool, error) {
+	return model.TouchStoredResponse(stored.Id, backgroundResponseActiveStatuses...)
+}
+
+// FinishBackgroundResponse 保存执行成功的请求结果，id 等字段替换为提交时返回给用户的值。
+// 实际费用已在请求中结算；响应已被取消或判定中断时预扣的额度已退还，重新扣除后仍保存结果
+func FinishBackgroundResponse(stored *model.StoredResponse, body []byte) error {
+	status, body, err := backgroundResponseResult(stored, body)
+	if err != nil {
+		return err
+	}
+	quota := stored.Quota
+	finished, err := updateBackgroundResponse(stored, status, body, backgroundResponseActiveStatuses...)
+	if err != nil {
+		return err
+	}
+	stored.Quota = 0
+	if finished {
+		return nil
+	}
+	if quota != 0 {
+		if err := settleBackgroundResponseQuota(stored, quota); err != nil {
+			return fmt.Errorf("charge background response quota failed: %w", err)
+		}
+	}
+	_, err = updateBackgroundResponse(stored, status, body, backgroundResponseSettledStatuses...)
+	return err
+}
+
+// backgroundResponseResult 请求结果对应的状态和响应对象。响应被内容审核拦截等情况下 body 不是响应对象，作为失败保存
+func backgroundResponseResult(stored *model.StoredResponse, body []byte) (string, []byte, error) {
+	if gjson.GetBytes(body, "object").String() != "response" {
+		message := gjson.GetBytes(body, "error.message").String()
+		if message == "" {
+			message = "invalid response body"
+		}
+		response, err := backgroundResponseWithError(stored, types.OpenAIError{
+			Code:    gjson.GetBytes(body, "error.code").String(),
+			Message: message,
+		})
+		return dto.ResponsesStatusFailed, response, err
+	}
+	status := gjson.GetBytes(body, "status").String()
+	if status == "" {
+		status = dto.ResponsesStatusCompleted
+	}
+	var err error
+	for path, value := range map[string]any{
+		"id":                   stored.ResponseId,
+		"created_at":           stored.CreatedAt,
+		"previous_response_id": stored.PreviousResponseId,
+		"background":           true,
+	} {
+		if body, err = sjson.SetBytes(body, path, value); err != nil {
+			return "", nil, err
+		}
+	}
+	return status, body, nil
+}
+
+func backgroundResponseWithError(stored *model.StoredResponse, openaiErr types.OpenAIError) ([]byte, error) {
+	code := common.Interface2String(openaiErr.Code)
+	if code == "" {
+		code = "server_error"
+	}
+	return sjson.SetBytes(stored.Response, "error", map[string]any{
+		"code":    code,
+		"message": openaiErr.Message,
+	})
+}
+
+// FailBackgroundResponse 将未结束的后台响应标记为 failed 并退还预扣的额度，响应已被其他一方结束时返回 false
+func FailBackgroundResponse(stored *model.StoredResponse, openaiErr types.OpenAIError) (bool, error) {
+	response, err := backgroundResponseWithError(stored, openaiErr)
+	if err != nil {
+		return false, err
+	}
+	return endBackgroundResponse(stored, dto.ResponsesStatusFailed, response)
+}
+
+// CancelBackgroundResponse 取消未结束的后台响应并退还预扣的额度，响应已结束时返回 false
+func CancelBackgroundResponse(stored *model.StoredResponse) (bool, error) {
+	return endBackgroundResponse(stored, dto.ResponsesStatusCancelled, stored.Response)
+}
+
+// failStaleBackgroundResponses 将执行节点已退出的后台响应标记为失败并退还预扣的额度
+func failStaleBackgroundResponses() {
+	before := time.Now().Add(-backgroundResponseStaleTimeout).Unix()
+	responses, err := model.GetStaleStoredResponses(before, 100, backgroundResponseActiveStatuses...)
+	if err != nil {
+		common.SysError("failed to get stale background responses: " + err.Error())
+		return
+	}
+	for _, stored := range responses {
+		quota := stored.Quota
+		failed, err := FailBackgroundResponse(stored, types.OpenAIError{
+			Code:    "server_error",
+			Message: "The background response was interrupted.",
+		})
+		if err != nil {
+			common.SysError(fmt.Sprintf("failed to fail background response %s: %s", stored.ResponseId, err.Error()))
+			continue
+		}
+		if failed && quota != 0 {
+			model.RecordLog(stored.UserId, model.LogTypeSystem, fmt.Sprintf("后台响应 %s 执行中断，补偿 %s", stored.ResponseId, logger.LogQuota(quota)))
+		}
+	}
+}
+
+// StartBackgroundResponsesWatchdog 定期处理执行节点已退出的后台响应
+func StartBackgroundResponsesWatchdog() {
+	for {
+		time.Sleep(time.Minute)
+		failStaleBackgroundResponses()
+	}
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/model/modeltest"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+)
+
+const (
+	backgroundTestUserQuota = 1000
+	backgroundTestPreQuota  = 100
+)
+
+// setupBackgroundResponsesTestDB 用户和令牌已按提交后台响应时的预扣额度扣减
+func setupBackgroundResponsesTestDB(t *testing.T) {
+	t.Helper()
+	db := modeltest.SetupDB(t, &model.StoredResponse{}, &model.User{}, &model.Token{}, &model.Log{}, &model.QuotaWindowUsage{})
+
+	remain := backgroundTestUserQuota - backgroundTestPreQuota
+	if err := db.Create(&model.User{Id: 1, Username: "user", Quota: remain}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.Token{Id: 1, UserId: 1, Key: "test-key", RemainQuota: remain, UsedQuota: backgroundTestPreQuota}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func submitTestBackgroundResponse(t *testing.T) *model.StoredResponse {
+	t.Helper()
+	relayInfo := &relaycommon.RelayInfo{
+		UserId:                1,
+		TokenId:               1,
+		UsingGroup:            "default",
+		FinalPreConsumedQuota: backgroundTestPreQuota,
+	}
+	request := &dto.OpenAIResponsesRequest{Model: "gpt-5", Input: []byte(`"hi"`), Background: []byte("true")}
+	stored, response, err := SubmitBackgroundResponse(relayInfo, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != dto.ResponsesStatusQueued || !response.Background {
+		t.Fatalf("submitted response = %+v", response)
+	}
+	return stored
+}
+
+func assertBackgroundTestQuota(t *testing.T, want int) {
+	t.Helper()
+	var user model.User
+	var token model.Token
+	model.DB.First(&user, 1)
+	model.DB.First(&token, 1)
+	if user.Quota != want || token.RemainQuota != want {
+		t.Fatalf("user quota = %d, token remain quota = %d, want %d", user.Quota, token.RemainQuota, want)
+	}
+}
+
+func reloadBackgroundTestResponse(t *testing.T, stored *model.StoredResponse) *model.StoredResponse {
+	t.Helper()
+	reloaded, err := model.GetUserStoredResponse(stored.UserId, stored.ResponseId)
+	if err != nil || reloaded == nil {
+		t.Fatalf("reload response: %v", err)
+	}
+	return reloaded
+}
+
+const backgroundTestResult = `{"id":"resp_upstream","object":"response","status":"completed","output":[{"type":"message","role":"assistant","content":[{"type":"output_text","text":"ok"}]}]}`
+
+func TestBackgroundResponseCompleted(t *testing.T) {
+	setupBackgroundResponsesTestDB(t)
+	stored := submitTestBackgroundResponse(t)
+
+	if started, err := StartBackgroundResponse(stored); !started || err != nil {
+		t.Fatalf("start = %v, %v", started, err)
+	}
+	if err := FinishBackgroundResponse(stored, []byte(backgroundTestResult)); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := reloadBackgroundTestResponse(t, stored)
+	if reloaded.Status != dto.ResponsesStatusCompleted || reloaded.Quota != 0 {
+		t.Fatalf("status = %s, quota = %d", reloaded.Status, reloaded.Quota)
+	}
+	var response dto.OpenAIResponsesResponse
+	if err := common.Unmarshal(reloaded.Response, &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.ID != stored.ResponseId || !response.Background || response.Status != dto.ResponsesStatusCompleted {
+		t.Fatalf("response = %+v", response)
+	}
+	// 实际费用已在请求中结算，结束时不再变动额度
+	assertBackgroundTestQuota(t, backgroundTestUserQuota-backgroundTestPreQuota)
+	if cancelled, _ := CancelBackgroundResponse(reloaded); cancelled {
+		t.Fatal("completed response should not be cancelled")
+	}
+}
+
+func TestBackgroundResponseCancelRefundsOnce(t *testing.T) {
+	setupBackgroundResponsesTestDB(t)
+	stored := submitTestBackgroundResponse(t)
+	if _, err := StartBackgroundResponse(stored); err != nil {
+		t.Fatal(err)
+	}
+
+	// 取消接口和执行节点各自持有读取到的记录
+	cancelled, err := CancelBackgroundResponse(reloadBackgroundTestResponse(t, stored))
+	if !cancelled || err != nil {
+		t.Fatalf("cancel = %v, %v", cancelled, err)
+	}
+	assertBackgroundTestQuota(t, backgroundTestUserQuota)
+
+	// 执行节点随后因 context 取消而失败，不再退还
+	if failed, err := FailBackgroundResponse(stored, types.OpenAIError{Message: "context canceled"}); failed || err != nil {
+		t.Fatalf("fail after cancel = %v, %v", failed, err)
+	}
+	assertBackgroundTestQuota(t, backgroundTestUserQuota)
+	if reloaded := reloadBackgroundTestResponse(t, stored); reloaded.Status != dto.ResponsesStatusCancelled {
+		t.Fatalf("status = %s", reloaded.Status)
+	}
+}
+
+func TestBackgroundResponseFinishAfterCancel(t *testing.T) {
+	setupBackgroundResponsesTestDB(t)
+	stored := submitTestBackgroundResponse(t)
+	if _, err := StartBackgroundResponse(stored); err != nil {
+		t.Fatal(err)
+	}
+	if cancelled, _ := CancelBackgroundResponse(reloadBackgroundTestResponse(t, stored)); !cancelled {
+		t.Fatal("cancel failed")
+	}
+
+	// 取消时请求已完成并结算，重新扣除退还的预扣额度并保存结果
+	if err := FinishBackgroundResponse(stored, []byte(backgroundTestResult)); err != nil {
+		t.Fatal(err)
+	}
+	assertBackgroundTestQuota(t, backgroundTestUserQuota-backgroundTestPreQuota)
+	if reloaded := reloadBackgroundTestResponse(t, stored); reloaded.Status != dto.ResponsesStatusCompleted {
+		t.Fatalf("status = %s", reloaded.Status)
+	}
+}
+
+func TestBackgroundResponseFailureRefund(t *testing.T) {
+	setupBackgroundResponsesTestDB(t)
+	stored := submitTestBackgroundResponse(t)
+	if _, err := StartBackgroundResponse(stored); err != nil {
+		t.Fatal(err)
+	}
+
+	failed, err := FailBackgroundResponse(stored, types.OpenAIError{Code: "bad_response_status_code", Message: "upstream error"})
+	if !failed || err != nil {
+		t.Fatalf("fail = %v, %v", failed, err)
+	}
+	assertBackgroundTestQuota(t, backgroundTestUserQuota)
+
+	reloaded := reloadBackgroundTestResponse(t, stored)
+	var response dto.OpenAIResponsesResponse
+	if err := common.Unmarshal(reloaded.Response, &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Status != dto.ResponsesStatusFailed || response.GetOpenAIError().Message != "upstream error" {
+		t.Fatalf("response = %+v", response)
+	}
+	if failed, _ := FailBackgroundResponse(reloaded, types.OpenAIError{Message: "again"}); failed {
+		t.Fatal("failed response should not be failed again")
+	}
+	assertBackgroundTestQuota(t, backgroundTestUserQuota)
+}
+
+func TestBackgroundResponseWatchdogRefund(t *testing.T) {
+	setupBackgroundResponsesTestDB(t)
+	stored := submitTestBackgroundResponse(t)
+	if _, err := StartBackgroundResponse(stored); err != nil {
+		t.Fatal(err)
+	}
+	stored.Billing.TrackUserSpend = true
+	model.DB.Model(stored).Updates(map[string]any{"billing": stored.Billing, "updated_at": 0})
+	now := common.GetTimestamp()
+	if err := model.RecordQuotaWindowUsage(model.QuotaWindowSubjectUser, 1, now, backgroundTestPreQuota); err != nil {
+		t.Fatal(err)
+	}
+
+	failStaleBackgroundResponses()
+	assertBackgroundTestQuota(t, backgroundTestUserQuota)
+	if reloaded := reloadBackgroundTestResponse(t, stored); reloaded.Status != dto.ResponsesStatusFailed || reloaded.Quota != 0 {
+		t.Fatalf("status = %s, quota = %d", reloaded.Status, reloaded.Quota)
+	}
+	// 消费窗口异步回滚
+	bucket := now - now%model.QuotaWindowBucketSeconds
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		used, err := model.SumQuotaWindowUsages(model.QuotaWindowSubjectUser, 1, []int64{bucket})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if used[0] == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("user spend window = %d, want 0", used[0])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 执行节点心跳恢复后请求失败，不重复退还
+	if failed, _ := FailBackgroundResponse(stored, types.OpenAIError{Message: "upstream error"}); failed {
+		t.Fatal("watchdog failed response should not be failed again")
+	}
+	failStaleBackgroundResponses()
+	assertBackgroundTestQuota(t, backgroundTestUserQuota)
+
+	// 执行节点请求成功时重新扣除预扣额度并保存结果
+	if err := FinishBackgroundResponse(stored, []byte(backgroundTestResult)); err != nil {
+		t.Fatal(err)
+	}
+	assertBackgroundTestQuota(t, backgroundTestUserQuota-backgroundTestPreQuota)
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+var ErrPreviousResponseNotFound = errors.New("previous response not found")
+
+// ResponsesHistory previous_response_id 指向的历史上下文
+type ResponsesHistory struct {
+	ConversationId string
+	// 历史轮次的 input 和 output item，由旧到新排列
+	Items []map[string]any
+}
+
+func IsResponsesStoreEnabled() bool {
+	return operation_setting.GetResponsesStoreSetting().Enabled
+}
+
+// LoadResponsesHistory 从保存的响应中重建 previous_response_id 之前的上下文，响应不存在时返回 ErrPreviousResponseNotFound。
+// 链上较早的响应已被清理时，上下文从该处截断
+func LoadResponsesHistory(userId int, previousResponseId string) (*ResponsesHistory, error) {
+	previous, err := model.GetUserStoredResponse(userId, previousResponseId)
+	if err != nil {
+		return nil, err
+	}
+	if previous == nil {
+		return nil, ErrPreviousResponseNotFound
+	}
+	responses, err := model.GetConversationStoredResponses(userId, previous.ConversationId, previous.Id)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[string]*model.StoredResponse, len(responses))
+	for _, response := range responses {
+		byId[response.ResponseId] = response
+	}
+	byId[previous.ResponseId] = previous
+
+	var turns [][]map[string]any
+	for response := previous; response != nil; response = byId[response.PreviousResponseId] {
+		items, err := storedResponseItems(response)
+		if err != nil {
+			return nil, fmt.Errorf("invalid stored response %s: %w", response.ResponseId, err)
+		}
+		turns = append(turns, items)
+		// 防止异常数据形成环
+		if len(turns) > len(byId) {
+			break
+		}
+	}
+	history := &ResponsesHistory{ConversationId: previous.ConversationId}
+	for i := len(turns) - 1; i >= 0; i-- {
+		history.Items = append(history.Items, turns[i]...)
+	}
+	return history, nil
+}
+
+// storedResponseItems 一轮对话的 input item 和 output item
+func storedResponseItems(response *model.StoredResponse) ([]map[string]any, error) {
+	var items []map[string]any
+	if len(response.InputItems) > 0 {
+		if err := common.Unmarshal(response.InputItems, &items); err != nil {
+			return nil, err
+		}
+	}
+	var output struct {
+		Output []map[string]any `json:"output"`
+	}
+	if len(response.Response) > 0 {
+		if err := common.Unmarshal(response.Response, &output); err != nil {
+			return nil, err
+		}
+	}
+	return append(items, output.Output...), nil
+}
+
+// GetStoredResponseInputItems 获取响应本轮的 input item
+func GetStoredResponseInputItems(response *model.StoredResponse) ([]map[string]any, error) {
+	var items []map[string]any
+	if len(response.InputItems) == 0 {
+		return items, nil
+	}
+	err := common.Unmarshal(response.InputItems, &items)
+	return items, err
+}
+
+// SaveStoredResponse 保存一轮对话，history 为 previous_response_id 对应的上下文，没有时为 nil
+func SaveStoredResponse(userId int, history *ResponsesHistory, inputItems []map[string]any, response *dto.OpenAIResponsesResponse) error {
+	stored, err := newStoredResponse(userId, history, inputItems, response)
+	if err != nil {
+		return err
+	}
+	return insertStoredResponse(stored)
+}
+
+func newStoredResponse(userId int, history *ResponsesHistory, inputItems []map[string]any, response *dto.OpenAIResponsesResponse) (*model.StoredResponse, error) {
+	// 没有 id 的 input item 补上 id，查询 input_items 时返回
+	for _, item := range inputItems {
+		if common.Interface2String(item["id"]) != "" {
+			continue
+		}
+		prefix := "item"
+		if itemType := common.Interface2String(item["type"]); itemType == "" || itemType == "message" {
+			prefix = "msg"
+		}
+		item["id"] = prefix + "_" + common.GetUUID()
+	}
+	inputJSON, err := common.Marshal(inputItems)
+	if err != nil {
+		return nil, err
+	}
+	responseJSON, err := common.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	stored := &model.StoredResponse{
+		ResponseId:         response.ID,
+		UserId:             userId,
+		PreviousResponseId: response.PreviousResponseID,
+		ConversationId:     response.ID,
+		Model:              response.Model,
+		Status:             response.Status,
+		InputItems:         inputJSON,
+		Response:           responseJSON,
+	}
+	if history != nil {
+		stored.ConversationId = history.ConversationId
+	}
+	if retentionHours := operation_setting.GetResponsesStoreSetting().RetentionHours; retentionHours > 0 {
+		stored.ExpiresAt = time.Now().Add(time.Duration(retentionHours) * time.Hour).Unix()
+	}
+	return stored, nil
+}
+
+// insertStoredResponse 保存响应，超出用户保存数量上限时删除最早的响应
+func insertStoredResponse(stored *model.StoredResponse) error {
+	if err := model.InsertStoredResponse(stored); err != nil {
+		return err
+	}
+	if maxResponses := operation_setting.GetResponsesStoreSetting().MaxResponsesPerUser; maxResponses > 0 {
+		_, err := model.TrimUserStoredResponses(stored.UserId, maxResponses)
+		return err
+	}
+	return nil
+}
+
+// StartResponsesStoreCleanup 定期删除已过期的响应
+func StartResponsesStoreCleanup() {
+	for {
+		var total int64
+		for {
+			n, err := model.DeleteExpiredStoredResponses(1000)
+			if err != nil {
+				common.SysError("failed to clean up expired responses: " + err.Error())
+				break
+			}
+			total += n
+			if n < 1000 {
+				break
+			}
+		}
+		if total > 0 {
+			common.SysLog(fmt.Sprintf("cleaned up %d expired responses", total))
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/model/modeltest"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+func setupResponsesStoreTest(t *testing.T) {
+	t.Helper()
+	modeltest.SetupDB(t, &model.StoredResponse{})
+	storeSetting := operation_setting.GetResponsesStoreSetting()
+	originSetting := *storeSetting
+	storeSetting.Enabled, storeSetting.RetentionHours, storeSetting.MaxResponsesPerUser = true, 0, 0
+	t.Cleanup(func() { *storeSetting = originSetting })
+}
+
+// saveTestResponseTurn 按 previous_response_id 保存一轮对话，输入和输出的文本都是 responseId
+func saveTestResponseTurn(t *testing.T, userId int, responseId string, previousResponseId string) {
+	t.Helper()
+	var history *ResponsesHistory
+	if previousResponseId != "" {
+		var err error
+		if history, err = LoadResponsesHistory(userId, previousResponseId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inputItems := []map[string]any{{"type": "message", "role": "user", "content": responseId}}
+	response := &dto.OpenAIResponsesResponse{
+		ID:                 responseId,
+		PreviousResponseID: previousResponseId,
+		Status:             dto.ResponsesStatusCompleted,
+		Output:             []dto.ResponsesOutput{{Type: "message", ID: "out_" + responseId, Role: "assistant"}},
+	}
+	if err := SaveStoredResponse(userId, history, inputItems, response); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// historyTestTurns 返回历史上下文中每轮 input 的文本
+func historyTestTurns(history *ResponsesHistory) []string {
+	var turns []string
+	for _, item := range history.Items {
+		if item["role"] == "user" {
+			turns = append(turns, common.Interface2String(item["content"]))
+		}
+	}
+	return turns
+}
+
+func TestLoadResponsesHistory(t *testing.T) {
+	setupResponsesStoreTest(t)
+	saveTestResponseTurn(t, 1, "resp_a", "")
+	saveTestResponseTurn(t, 1, "resp_b", "resp_a")
+	saveTestResponseTurn(t, 1, "resp_c", "resp_b")
+	// 从 resp_b 分叉的另一条链不会出现在 resp_c 的上下文中
+	saveTestResponseTurn(t, 1, "resp_d", "resp_b")
+
+	history, err := LoadResponsesHistory(1, "resp_c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history.ConversationId != "resp_a" {
+		t.Errorf("conversation id = %s, want resp_a", history.ConversationId)
+	}
+	if turns := historyTestTurns(history); len(turns) != 3 || turns[0] != "resp_a" || turns[1] != "resp_b" || turns[2] != "resp_c" {
+		t.Fatalf("turns = %v", turns)
+	}
+	// 每轮的 input 之后紧跟本轮的 output
+	if len(history.Items) != 6 || history.Items[1]["id"] != "out_resp_a" || history.Items[5]["id"] != "out_resp_c" {
+		t.Fatalf("items = %v", history.Items)
+	}
+
+	// 其他用户无法引用
+	if _, err := LoadResponsesHistory(2, "resp_c"); !errors.Is(err, ErrPreviousResponseNotFound) {
+		t.Fatalf("other user error = %v", err)
+	}
+
+	// 链上较早的响应被删除后，上下文从该处截断
+	stored, _ := model.GetUserStoredResponse(1, "resp_b")
+	if err := model.DeleteStoredResponse(stored); err != nil {
+		t.Fatal(err)
+	}
+	history, err = LoadResponsesHistory(1, "resp_d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if turns := historyTestTurns(history); len(turns) != 1 || turns[0] != "resp_d" {
+		t.Fatalf("truncated turns = %v", turns)
+	}
+	if _, err := LoadResponsesHistory(1, "resp_b"); !errors.Is(err, ErrPreviousResponseNotFound) {
+		t.Fatalf("deleted response error = %v", err)
+	}
+}
+
+func TestTrimUserStoredResponses(t *testing.T) {
+	setupResponsesStoreTest(t)
+	operation_setting.GetResponsesStoreSetting().MaxResponsesPerUser = 2
+	saveTestResponseTurn(t, 2, "resp_other", "")
+	if err := model.InsertStoredResponse(&model.StoredResponse{ResponseId: "resp_queued", UserId: 1, Status: dto.ResponsesStatusQueued}); err != nil {
+		t.Fatal(err)
+	}
+	for _, responseId := range []string{"resp_1", "resp_2", "resp_3"} {
+		saveTestResponseTurn(t, 1, responseId, "")
+	}
+
+	// 超出上限时删除最早的响应，未结束的后台响应和其他用户的响应不受影响
+	for responseId, want := range map[string]bool{
+		"resp_queued": true,
+		"resp_1":      false,
+		"resp_2":      true,
+		"resp_3":      true,
+	} {
+		if stored, _ := model.GetUserStoredResponse(1, responseId); (stored != nil) != want {
+			t.Errorf("%s kept = %v, want %v", responseId, stored != nil, want)
+		}
+	}
+	if stored, _ := model.GetUserStoredResponse(2, "resp_other"); stored == nil {
+		t.Error("other user's response should be kept")
+	}
+}
+
+func TestDeleteExpiredStoredResponses(t *testing.T) {
+	setupResponsesStoreTest(t)
+	now := common.GetTimestamp()
+	for _, stored := range []*model.StoredResponse{
+		{ResponseId: "resp_expired_1", UserId: 1, Status: dto.ResponsesStatusCompleted, ExpiresAt: now - 10},
+		{ResponseId: "resp_expired_2", UserId: 1, Status: dto.ResponsesStatusFailed, ExpiresAt: now - 5},
+		// 未结束的后台响应由执行节点结算后再清理
+		{ResponseId: "resp_expired_active", UserId: 1, Status: dto.ResponsesStatusInProgress, ExpiresAt: now - 5},
+		{ResponseId: "resp_valid", UserId: 1, Status: dto.ResponsesStatusCompleted, ExpiresAt: now + 3600},
+		{ResponseId: "resp_forever", UserId: 1, Status: dto.ResponsesStatusCompleted},
+	} {
+		if err := model.InsertStoredResponse(stored); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 分批删除
+	if n, err := model.DeleteExpiredStoredResponses(1); n != 1 || err != nil {
+		t.Fatalf("first batch = %d, %v", n, err)
+	}
+	if n, err := model.DeleteExpiredStoredResponses(100); n != 1 || err != nil {
+		t.Fatalf("second batch = %d, %v", n, err)
+	}
+	if n, err := model.DeleteExpiredStoredResponses(100); n != 0 || err != nil {
+		t.Fatalf("third batch = %d, %v", n, err)
+	}
+
+	var remaining []string
+	model.DB.Model(&model.StoredResponse{}).Order("id").Pluck("response_id", &remaining)
+	if len(remaining) != 3 || remaining[0] != "resp_expired_active" || remaining[1] != "resp_valid" || remaining[2] != "resp_forever" {
+		t.Fatalf("remaining = %v", remaining)
+	}
+}
@@ -2,7 +2,9 @@ package service
 
 import (
 	"errors"
+	"sort"
 	"strings"
+	"unicode"
 
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/setting"
@@ -55,25 +57,44 @@ func SensitiveWordReplace(text string, returnImmediately bool) (bool, []string,
 	if len(setting.SensitiveWords) == 0 {
 		return false, nil, text
 	}
-	checkText := strings.ToLower(text)
 	m := getOrBuildAC(setting.SensitiveWords)
-	hits := m.MultiPatternSearch([]rune(checkText), returnImmediately)
+	if m == nil {
+		return false, nil, text
+	}
+	// 命中位置按 rune 计算，逐个 rune 转小写保证位置与原文一致
+	runes := []rune(text)
+	hits := m.MultiPatternSearch(lowerRunes(runes), returnImmediately)
 	if len(hits) > 0 {
 		words := make([]string, 0, len(hits))
 		var builder strings.Builder
 		builder.Grow(len(text))
 		lastPos := 0
 
+		sort.SliceStable(hits, func(i, j int) bool { return hits[i].Pos < hits[j].Pos })
 		for _, hit := range hits {
 			pos := hit.Pos
-			word := string(hit.Word)
-			builder.WriteString(text[lastPos:pos])
-			builder.WriteString("**###**")
-			lastPos = pos + len(word)
-			words = append(words, word)
+			words = append(words, string(hit.Word))
+			// 重叠的命中已经被前一个替换
+			if pos < lastPos {
+				lastPos = max(lastPos, pos+len(hit.Word))
+				continue
+			}
+			builder.WriteString(string(runes[lastPos:pos]))
+			builder.WriteString(sensitiveWordMask)
+			lastPos = pos + len(hit.Word)
 		}
-		builder.WriteString(text[lastPos:])
+		builder.WriteString(string(runes[lastPos:]))
 		return true, words, builder.String()
 	}
 	return false, nil, text
 }
+
+const sensitiveWordMask = "**###**"
+
+func lowerRunes(runes []rune) []rune {
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	return lower
+}
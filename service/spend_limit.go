@@ -0,0 +1,180 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	SpendWindowDaily   = "daily"
+	SpendWindowWeekly  = "weekly"
+	SpendWindowMonthly = "monthly"
+)
+
+var spendWindowNames = []string{SpendWindowDaily, SpendWindowWeekly, SpendWindowMonthly}
+
+var spendWindowLabels = map[string]string{
+	SpendWindowDaily:   "每日",
+	SpendWindowWeekly:  "每周",
+	SpendWindowMonthly: "每月",
+}
+
+// 滚动窗口的长度
+var spendWindowDurations = map[string]time.Duration{
+	SpendWindowDaily:   24 * time.Hour,
+	SpendWindowWeekly:  7 * 24 * time.Hour,
+	SpendWindowMonthly: 30 * 24 * time.Hour,
+}
+
+// SpendWindow 一个时间窗口的消费上限和当前用量
+type SpendWindow struct {
+	Window string `json:"window"`
+	Limit  int    `json:"limit"`
+	Used   int    `json:"used"`
+	// 自然窗口为下次清零的时间，滚动窗口为最早的用量移出窗口的时间
+	ResetAt int64 `json:"reset_at"`
+}
+
+// spendWindowRange 返回时间窗口的起始时间和重置时间，自然周从周一开始
+func spendWindowRange(window string, rolling bool, now time.Time) (time.Time, time.Time) {
+	if rolling {
+		return now.Add(-spendWindowDurations[window]), now
+	}
+	year, month, day := now.Date()
+	dayStart := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	switch window {
+	case SpendWindowDaily:
+		return dayStart, dayStart.AddDate(0, 0, 1)
+	case SpendWindowWeekly:
+		weekStart := dayStart.AddDate(0, 0, -((int(now.Weekday()) + 6) % 7))
+		return weekStart, weekStart.AddDate(0, 0, 7)
+	default:
+		monthStart := time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+		return monthStart, monthStart.AddDate(0, 1, 0)
+	}
+}
+
+// GetSpendWindows 返回设置了上限的各个时间窗口的用量
+func GetSpendWindows(subjectType string, subjectId int, limit model.SpendLimit, now time.Time) ([]SpendWindow, error) {
+	limits := map[string]int{
+		SpendWindowDaily:   limit.Daily,
+		SpendWindowWeekly:  limit.Weekly,
+		SpendWindowMonthly: limit.Monthly,
+	}
+	windows := make([]SpendWindow, 0, len(spendWindowNames))
+	starts := make([]int64, 0, len(spendWindowNames))
+	for _, name := range spendWindowNames {
+		if limits[name] <= 0 {
+			continue
+		}
+		start, reset := spendWindowRange(name, limit.Rolling, now)
+		windows = append(windows, SpendWindow{Window: name, Limit: limits[name], ResetAt: reset.Unix()})
+		starts = append(starts, start.Unix())
+	}
+	if len(windows) == 0 {
+		return windows, nil
+	}
+	used, err := model.SumQuotaWindowUsages(subjectType, subjectId, starts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range windows {
+		windows[i].Used = used[i]
+		if limit.Rolling && used[i] > 0 {
+			first, err := model.GetQuotaWindowFirstBucket(subjectType, subjectId, starts[i])
+			if err != nil {
+				return nil, err
+			}
+			if first > 0 {
+				windows[i].ResetAt = first + int64(spendWindowDurations[windows[i].Window]/time.Second) + model.QuotaWindowBucketSeconds
+			}
+		}
+	}
+	return windows, nil
+}
+
+// CheckSpendLimit 检查令牌和用户在各个时间窗口的消费上限，quota 为本次请求预计消耗的额度
+func CheckSpendLimit(c *gin.Context, relayInfo *relaycommon.RelayInfo, quota int) *types.NewAPIError {
+	now := time.Now()
+	if !relayInfo.IsPlayground {
+		if limit, ok := common.GetContextKeyType[model.SpendLimit](c, constant.ContextKeyTokenSpendLimit); ok && limit.Enabled() {
+			relayInfo.TrackTokenSpend = true
+			if apiErr := checkSpendWindows("令牌", model.QuotaWindowSubjectToken, relayInfo.TokenId, limit, quota, now); apiErr != nil {
+				return apiErr
+			}
+		}
+	}
+	if limit, ok := common.GetContextKeyType[model.SpendLimit](c, constant.ContextKeyUserSpendLimit); ok && limit.Enabled() {
+		relayInfo.TrackUserSpend = true
+		if apiErr := checkSpendWindows("用户", model.QuotaWindowSubjectUser, relayInfo.UserId, limit, quota, now); apiErr != nil {
+			return apiErr
+		}
+	}
+	return nil
+}
+
+func checkSpendWindows(subjectName string, subjectType string, subjectId int, limit model.SpendLimit, quota int, now time.Time) *types.NewAPIError {
+	windows, err := GetSpendWindows(subjectType, subjectId, limit, now)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	for _, window := range windows {
+		if window.Used+quota <= window.Limit {
+			continue
+		}
+		resetAt := time.Unix(window.ResetAt, 0).Format("2006-01-02 15:04:05 MST")
+		return types.NewErrorWithStatusCode(
+			fmt.Errorf("%s%s消费上限已达到，已用 %s，上限 %s，本次预计消耗 %s，将于 %s 重置",
+				subjectName, spendWindowLabels[window.Window], logger.FormatQuota(window.Used), logger.FormatQuota(window.Limit), logger.FormatQuota(quota), resetAt),
+			types.ErrorCodeSpendLimitExceeded, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+	}
+	return nil
+}
+
+// RecordSpend 把消费额度计入设置了上限的令牌和用户的时间窗口用量，delta 为负数时表示退还
+func RecordSpend(relayInfo *relaycommon.RelayInfo, delta int) {
+	if delta == 0 || (!relayInfo.TrackTokenSpend && !relayInfo.TrackUserSpend) {
+		return
+	}
+	tokenId, userId := relayInfo.TokenId, relayInfo.UserId
+	trackToken, trackUser := relayInfo.TrackTokenSpend, relayInfo.TrackUserSpend
+	now := common.GetTimestamp()
+	gopool.Go(func() {
+		if trackToken {
+			if err := model.RecordQuotaWindowUsage(model.QuotaWindowSubjectToken, tokenId, now, delta); err != nil {
+				common.SysError(fmt.Sprintf("failed to record token %d spend: %s", tokenId, err.Error()))
+			}
+		}
+		if trackUser {
+			if err := model.RecordQuotaWindowUsage(model.QuotaWindowSubjectUser, userId, now, delta); err != nil {
+				common.SysError(fmt.Sprintf("failed to record user %d spend: %s", userId, err.Error()))
+			}
+		}
+	})
+}
+
+// StartQuotaWindowCleanup 定期删除超出最长时间窗口的用量记录
+func StartQuotaWindowCleanup() {
+	// 自然月最长 31 天，多保留一天
+	retention := 32 * 24 * time.Hour
+	for {
+		before := time.Now().Add(-retention).Unix()
+		if n, err := model.DeleteQuotaWindowUsagesBefore(before); err != nil {
+			common.SysError("failed to clean up quota window usages: " + err.Error())
+		} else if n > 0 {
+			common.SysLog(fmt.Sprintf("cleaned up %d quota window usages", n))
+		}
+		time.Sleep(time.Hour)
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSpendWindowRange(t *testing.T) {
+	loc := time.FixedZone("UTC+8", 8*3600)
+	// 2026-03-18 是周三
+	now := time.Date(2026, 3, 18, 15, 30, 0, 0, loc)
+
+	cases := []struct {
+		window string
+		start  time.Time
+		reset  time.Time
+	}{
+		{SpendWindowDaily, time.Date(2026, 3, 18, 0, 0, 0, 0, loc), time.Date(2026, 3, 19, 0, 0, 0, 0, loc)},
+		{SpendWindowWeekly, time.Date(2026, 3, 16, 0, 0, 0, 0, loc), time.Date(2026, 3, 23, 0, 0, 0, 0, loc)},
+		{SpendWindowMonthly, time.Date(2026, 3, 1, 0, 0, 0, 0, loc), time.Date(2026, 4, 1, 0, 0, 0, 0, loc)},
+	}
+	for _, tc := range cases {
+		start, reset := spendWindowRange(tc.window, false, now)
+		if !start.Equal(tc.start) || !reset.Equal(tc.reset) {
+			t.Errorf("%s: got [%s, %s), want [%s, %s)", tc.window, start, reset, tc.start, tc.reset)
+		}
+	}
+
+	// 周日属于上周一开始的自然周
+	sunday := time.Date(2026, 3, 22, 23, 0, 0, 0, loc)
+	start, _ := spendWindowRange(SpendWindowWeekly, false, sunday)
+	if !start.Equal(time.Date(2026, 3, 16, 0, 0, 0, 0, loc)) {
+		t.Errorf("weekly start for sunday: got %s", start)
+	}
+
+	start, reset := spendWindowRange(SpendWindowWeekly, true, now)
+	if !start.Equal(now.Add(-7*24*time.Hour)) || !reset.Equal(now) {
+		t.Errorf("rolling weekly: got [%s, %s)", start, reset)
+	}
+}
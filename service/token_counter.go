@@ -269,7 +269,19 @@ func EstimateRequestToken(c *gin.Context, meta *types.TokenCountMeta, info *rela
 		shouldFetchFiles = false
 	}
 
-	for _, file := range meta.Files {
+	fileTokens, err := countFileTokens(c, meta.Files, model, info.IsStream, shouldFetchFiles)
+	if err != nil {
+		return 0, err
+	}
+	tkm += fileTokens
+
+	common.SetContextKey(c, constant.ContextKeyPromptTokens, tkm)
+	return tkm, nil
+}
+
+// countFileTokens 按文件类型估算媒体 token 数，shouldFetchFiles 为 true 时会请求远程文件识别类型
+func countFileTokens(c *gin.Context, files []*types.FileMeta, model string, isStream bool, shouldFetchFiles bool) (int, error) {
+	for _, file := range files {
 		if strings.HasPrefix(file.OriginData, "http") {
 			if shouldFetchFiles {
 				mineType, err := GetFileTypeFromUrl(c, file.OriginData, "token_counter")
@@ -314,11 +326,12 @@ func EstimateRequestToken(c *gin.Context, meta *types.TokenCountMeta, info *rela
 		}
 	}
 
-	for i, file := range meta.Files {
+	tkm := 0
+	for i, file := range files {
 		switch file.FileType {
 		case types.FileTypeImage:
 			if common.IsOpenAITextModel(model) {
-				token, err := getImageToken(file, model, info.IsStream)
+				token, err := getImageToken(file, model, isStream)
 				if err != nil {
 					return 0, fmt.Errorf("error counting image token, media index[%d], original data[%s], err: %v", i, file.OriginData, err)
 				}
@@ -336,11 +349,22 @@ func EstimateRequestToken(c *gin.Context, meta *types.TokenCountMeta, info *rela
 			tkm += 4096 // Default case for unknown file types
 		}
 	}
-
-	common.SetContextKey(c, constant.ContextKeyPromptTokens, tkm)
 	return tkm, nil
 }
 
+// CountInputTokens 本地估算请求的输入 token 数，供 count_tokens 接口使用，不受 CountToken 开关影响，也不请求远程文件识别类型
+func CountInputTokens(c *gin.Context, meta *types.TokenCountMeta, model string) (int, error) {
+	if meta == nil {
+		return 0, errors.New("token count meta is nil")
+	}
+	tkm := CountTokenInput(meta.CombineText, model)
+	fileTokens, err := countFileTokens(c, meta.Files, model, false, false)
+	if err != nil {
+		return 0, err
+	}
+	return tkm + fileTokens, nil
+}
+
 func CountTokenRealtime(info *relaycommon.RelayInfo, request dto.RealtimeEvent, model string) (int, int, error) {
 	audioToken := 0
 	textToken := 0
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/common/limiter"
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	tokenRpmKeyPrefix = "tokenRateLimit:rpm:"
+	tokenTpmKeyPrefix = "tokenRateLimit:tpm:"
+)
+
+// setRateLimitHeaders 设置 OpenAI 格式的 x-ratelimit-* 响应头，kind 为 requests 或 tokens
+func setRateLimitHeaders(c *gin.Context, kind string, result *limiter.Result) {
+	c.Header("x-ratelimit-limit-"+kind, strconv.FormatInt(result.Limit, 10))
+	c.Header("x-ratelimit-remaining-"+kind, strconv.FormatInt(result.Remaining, 10))
+	c.Header("x-ratelimit-reset-"+kind, result.Reset.Round(time.Millisecond).String())
+}
+
+func rateLimitError(message string) *types.NewAPIError {
+	return types.NewErrorWithStatusCode(fmt.Errorf("%s", message), types.ErrorCodeRateLimitExceeded, http.StatusTooManyRequests,
+		types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+}
+
+// TakeTokenRequest 按令牌的每分钟请求数上限计入本次请求
+func TakeTokenRequest(c *gin.Context) *types.NewAPIError {
+	rpm := common.GetContextKeyInt(c, constant.ContextKeyTokenRpmLimit)
+	if rpm <= 0 {
+		return nil
+	}
+	tokenId := common.GetContextKeyInt(c, constant.ContextKeyTokenId)
+	result, err := limiter.Take(c.Request.Context(), tokenRpmKeyPrefix+strconv.Itoa(tokenId),
+		limiter.WithCapacity(int64(rpm)), limiter.WithPeriod(time.Minute))
+	if err != nil {
+		// 限流器不可用时放行，避免影响正常请求
+		common.SysError("token rpm limit failed: " + err.Error())
+		return nil
+	}
+	setRateLimitHeaders(c, "requests", result)
+	if !result.Allowed {
+		return rateLimitError(fmt.Sprintf("令牌已达到每分钟请求数上限 %d，请在 %s 后重试", rpm, retryAfter(result, 1, int64(rpm))))
+	}
+	return nil
+}
+
+// ReserveTokenTPM 按预估的 token 数预占令牌的每分钟 token 数额度
+func ReserveTokenTPM(c *gin.Context, relayInfo *relaycommon.RelayInfo, tokens int) *types.NewAPIError {
+	tpm := common.GetContextKeyInt(c, constant.ContextKeyTokenTpmLimit)
+	if tpm <= 0 || relayInfo.IsPlayground {
+		return nil
+	}
+	tokens = max(tokens, 1)
+	result, err := limiter.Take(c.Request.Context(), tokenTpmKeyPrefix+strconv.Itoa(relayInfo.TokenId),
+		limiter.WithCapacity(int64(tpm)), limiter.WithPeriod(time.Minute), limiter.WithRequested(int64(tokens)))
+	if err != nil {
+		common.SysError("token tpm limit failed: " + err.Error())
+		return nil
+	}
+	setRateLimitHeaders(c, "tokens", result)
+	if !result.Allowed {
+		if tokens > tpm {
+			return rateLimitError(fmt.Sprintf("请求预估 token 数 %d 超过令牌每分钟 token 数上限 %d", tokens, tpm))
+		}
+		return rateLimitError(fmt.Sprintf("令牌已达到每分钟 token 数上限 %d，请在 %s 后重试", tpm, retryAfter(result, int64(tokens), int64(tpm))))
+	}
+	relayInfo.TpmLimit = tpm
+	relayInfo.TpmReserved = tokens
+	return nil
+}
+
+// ReconcileTokenTPM 按实际使用的 token 数校正预占的用量，请求失败时 actual 为 0 即退还预占
+func ReconcileTokenTPM(relayInfo *relaycommon.RelayInfo, actual int) {
+	if relayInfo.TpmLimit <= 0 {
+		return
+	}
+	tpm, delta := relayInfo.TpmLimit, actual-relayInfo.TpmReserved
+	// 只校正一次
+	relayInfo.TpmLimit = 0
+	if delta == 0 {
+		return
+	}
+	key := tokenTpmKeyPrefix + strconv.Itoa(relayInfo.TokenId)
+	gopool.Go(func() {
+		_, err := limiter.Take(context.Background(), key, limiter.WithCapacity(int64(tpm)), limiter.WithPeriod(time.Minute),
+			limiter.WithRequested(int64(delta)), limiter.WithForce())
+		if err != nil {
+			common.SysError("token tpm reconcile failed: " + err.Error())
+		}
+	})
+}
+
+// retryAfter 估算桶中再次积累 requested 个令牌所需的时间
+func retryAfter(result *limiter.Result, requested int64, limit int64) time.Duration {
+	missing := requested - result.Remaining
+	if missing <= 0 {
+		return time.Second
+	}
+	wait := time.Duration(missing) * time.Minute / time.Duration(limit)
+	return max(wait.Round(time.Second), time.Second)
+}
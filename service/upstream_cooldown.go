@@ -0,0 +1,129 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+)
+
+// ParseUpstreamRetryAfter 从上游响应头中解析需要等待的时间，未提供时返回 0。
+// 支持：
+//   - Retry-After: 秒数或 HTTP-date
+//   - retry-after-ms: 毫秒（OpenAI / Azure）
+//   - x-ratelimit-reset-*: "1s" / "6m0s" / "20ms" 形式的时长、秒数或 unix 时间戳（OpenAI / Groq 等）
+//   - anthropic-ratelimit-*-reset: RFC 3339 时间
+func ParseUpstreamRetryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+	now := time.Now()
+
+	if v := strings.TrimSpace(header.Get("retry-after-ms")); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	if v := strings.TrimSpace(header.Get("Retry-After")); v != "" {
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil && seconds > 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+		if t, err := http.ParseTime(v); err == nil && t.After(now) {
+			return t.Sub(now)
+		}
+	}
+
+	// 优先使用剩余额度已耗尽的那一类限流的重置时间
+	var exhausted, others time.Duration
+	for name, values := range header {
+		if len(values) == 0 {
+			continue
+		}
+		lowerName := strings.ToLower(name)
+		var category string
+		switch {
+		case strings.HasPrefix(lowerName, "x-ratelimit-reset"):
+			category = strings.TrimPrefix(strings.TrimPrefix(lowerName, "x-ratelimit-reset"), "-")
+		case strings.HasPrefix(lowerName, "anthropic-ratelimit-") && strings.HasSuffix(lowerName, "-reset"):
+			category = strings.TrimSuffix(strings.TrimPrefix(lowerName, "anthropic-ratelimit-"), "-reset")
+		default:
+			continue
+		}
+		d := parseRateLimitReset(values[0], now)
+		if d <= 0 {
+			continue
+		}
+		if isRateLimitExhausted(header, lowerName, category) {
+			exhausted = max(exhausted, d)
+		} else {
+			others = max(others, d)
+		}
+	}
+	if exhausted > 0 {
+		return exhausted
+	}
+	return others
+}
+
+func isRateLimitExhausted(header http.Header, resetHeader string, category string) bool {
+	var remaining string
+	if strings.HasPrefix(resetHeader, "anthropic-") {
+		remaining = header.Get("anthropic-ratelimit-" + category + "-remaining")
+	} else if category != "" {
+		remaining = header.Get("x-ratelimit-remaining-" + category)
+	} else {
+		remaining = header.Get("x-ratelimit-remaining")
+	}
+	remaining = strings.TrimSpace(remaining)
+	return remaining == "0"
+}
+
+func parseRateLimitReset(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Sub(now)
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil && n > 0 {
+		// 较大的数值视为 unix 时间戳（秒），否则视为相对秒数
+		if n > 1e9 {
+			return time.Unix(int64(n), 0).Sub(now)
+		}
+		return time.Duration(n * float64(time.Second))
+	}
+	return 0
+}
+
+// ApplyUpstreamCooldown 根据上游 429 响应对使用的 key（多 key 渠道）或整个渠道设置临时冷却。
+// 冷却到期自动恢复，返回 true 时调用方不应再将该错误计入自动禁用。
+func ApplyUpstreamCooldown(channelError types.ChannelError, keyIndex int, err *types.NewAPIError) bool {
+	setting := operation_setting.GetUpstreamCooldownSetting()
+	if !setting.Enabled || err == nil || err.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+	// 余额 / 配额耗尽同样返回 429，但不会自行恢复，仍交给自动禁用处理
+	if err.ToOpenAIError().Type == "insufficient_quota" {
+		return false
+	}
+	duration := err.RetryAfter
+	if duration <= 0 {
+		duration = time.Duration(setting.DefaultSeconds) * time.Second
+	}
+	if duration <= 0 {
+		return false
+	}
+	if maxDuration := time.Duration(setting.MaxSeconds) * time.Second; maxDuration > 0 && duration > maxDuration {
+		duration = maxDuration
+	}
+	model.ApplyChannelCooldown(channelError.ChannelId, channelError.IsMultiKey, keyIndex, duration, err.MaskSensitiveError())
+	return true
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseUpstreamRetryAfter(t *testing.T) {
+	t.Run("no headers", func(t *testing.T) {
+		if d := ParseUpstreamRetryAfter(http.Header{}); d != 0 {
+			t.Fatalf("expected 0, got %v", d)
+		}
+	})
+
+	t.Run("retry-after seconds", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("Retry-After", "12")
+		if d := ParseUpstreamRetryAfter(h); d != 12*time.Second {
+			t.Fatalf("expected 12s, got %v", d)
+		}
+	})
+
+	t.Run("retry-after-ms wins", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("Retry-After", "12")
+		h.Set("retry-after-ms", "1500")
+		if d := ParseUpstreamRetryAfter(h); d != 1500*time.Millisecond {
+			t.Fatalf("expected 1.5s, got %v", d)
+		}
+	})
+
+	t.Run("openai reset of exhausted limit", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("x-ratelimit-remaining-requests", "0")
+		h.Set("x-ratelimit-reset-requests", "6m0s")
+		h.Set("x-ratelimit-remaining-tokens", "1000")
+		h.Set("x-ratelimit-reset-tokens", "20m")
+		if d := ParseUpstreamRetryAfter(h); d != 6*time.Minute {
+			t.Fatalf("expected 6m, got %v", d)
+		}
+	})
+
+	t.Run("anthropic rfc3339 reset", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("anthropic-ratelimit-tokens-remaining", "0")
+		h.Set("anthropic-ratelimit-tokens-reset", time.Now().Add(30*time.Second).UTC().Format(time.RFC3339))
+		d := ParseUpstreamRetryAfter(h)
+		if d < 28*time.Second || d > 31*time.Second {
+			t.Fatalf("expected about 30s, got %v", d)
+		}
+	})
+}
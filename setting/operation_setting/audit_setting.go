@@ -0,0 +1,27 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// AuditSetting 管理操作审计配置
+type AuditSetting struct {
+	Enabled bool `json:"enabled"`
+	// 每条审计记录额外推送到 webhook
+	WebhookEnabled bool   `json:"webhook_enabled"`
+	WebhookUrl     string `json:"webhook_url"`
+	// 用于签名 webhook 请求体，放在 X-Webhook-Signature 请求头
+	WebhookSecret string `json:"webhook_secret"`
+}
+
+// 默认配置
+var auditSetting = AuditSetting{
+	Enabled: true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("audit_setting", &auditSetting)
+}
+
+func GetAuditSetting() *AuditSetting {
+	return &auditSetting
+}
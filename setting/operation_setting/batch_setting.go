@@ -0,0 +1,37 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// BatchSetting Batch API 配置，批处理任务在主节点后台逐行通过正常的转发流程执行
+type BatchSetting struct {
+	Enabled bool `json:"enabled"`
+	// 同时执行的批处理任务数
+	MaxRunningBatches int `json:"max_running_batches"`
+	// 每个批处理任务同时执行的请求数
+	RequestConcurrency int `json:"request_concurrency"`
+	// 单个批处理任务的请求数上限
+	MaxRequestsPerBatch int `json:"max_requests_per_batch"`
+	// 请求被限流（429）时的最多重试次数
+	RateLimitRetries int `json:"rate_limit_retries"`
+	// 检查新任务和取消状态的间隔秒数
+	PollIntervalSeconds int `json:"poll_interval_seconds"`
+}
+
+// 默认配置
+var batchSetting = BatchSetting{
+	Enabled:             false,
+	MaxRunningBatches:   2,
+	RequestConcurrency:  4,
+	MaxRequestsPerBatch: 50000,
+	RateLimitRetries:    5,
+	PollIntervalSeconds: 5,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("batch_setting", &batchSetting)
+}
+
+func GetBatchSetting() *BatchSetting {
+	return &batchSetting
+}
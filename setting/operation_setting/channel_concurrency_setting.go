@@ -0,0 +1,35 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// ChannelConcurrencySetting 渠道 / key 并发上限的排队配置，并发上限本身在渠道设置中配置
+type ChannelConcurrencySetting struct {
+	// 单个节点最多排队等待的请求数，超出后直接返回 429
+	QueueSize int `json:"queue_size"`
+	// 排队等待的最长时间
+	QueueTimeoutSeconds int `json:"queue_timeout_seconds"`
+	// 并发槽的租约时长，请求进行中时定期续期，节点异常退出时未释放的槽到期后自动回收
+	LeaseSeconds int `json:"lease_seconds"`
+	// 渠道被标记为已满后，多久之后重新尝试（毫秒）
+	SaturatedRecheckMillis int `json:"saturated_recheck_millis"`
+	// Redis 模式下排队请求轮询空闲槽的间隔（毫秒），其他节点释放的槽只能通过轮询感知
+	PollIntervalMillis int `json:"poll_interval_millis"`
+}
+
+// 默认配置
+var channelConcurrencySetting = ChannelConcurrencySetting{
+	QueueSize:              200,
+	QueueTimeoutSeconds:    30,
+	LeaseSeconds:           600,
+	SaturatedRecheckMillis: 1000,
+	PollIntervalMillis:     200,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_concurrency_setting", &channelConcurrencySetting)
+}
+
+func GetChannelConcurrencySetting() *ChannelConcurrencySetting {
+	return &channelConcurrencySetting
+}
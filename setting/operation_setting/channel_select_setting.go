@@ -0,0 +1,64 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// 渠道选择模式
+const (
+	ChannelSelectModeWeight   = "weight"   // 仅按静态权重随机
+	ChannelSelectModeAdaptive = "adaptive" // 按延迟 / 首字时间 / 错误率动态调整权重
+)
+
+// ChannelSelectSetting 同优先级渠道的选择策略配置
+type ChannelSelectSetting struct {
+	// 默认选择模式：weight / adaptive
+	Mode string `json:"mode"`
+	// 分组级别覆盖，group -> mode
+	GroupModes map[string]string `json:"group_modes"`
+	// EWMA 平滑系数 (0, 1]，越大越偏向最近的请求
+	EwmaAlpha float64 `json:"ewma_alpha"`
+	// 样本数少于该值时不参与调整，按静态权重处理
+	MinSamples int `json:"min_samples"`
+	// 错误率惩罚指数，有效权重乘以 (1 - error_rate) ^ error_penalty
+	ErrorPenalty float64 `json:"error_penalty"`
+	// 延迟惩罚指数，有效权重乘以 (best_latency / latency) ^ latency_penalty
+	LatencyPenalty float64 `json:"latency_penalty"`
+	// 健康系数下限，保证不健康的渠道仍有少量流量用于恢复探测
+	MinHealthFactor float64 `json:"min_health_factor"`
+	// 超过该时长未更新的评分视为过期，不参与调整
+	ScoreTTLSeconds int `json:"score_ttl_seconds"`
+}
+
+// 默认配置
+var channelSelectSetting = ChannelSelectSetting{
+	Mode:            ChannelSelectModeWeight,
+	GroupModes:      map[string]string{},
+	EwmaAlpha:       0.2,
+	MinSamples:      5,
+	ErrorPenalty:    2,
+	LatencyPenalty:  1,
+	MinHealthFactor: 0.05,
+	ScoreTTLSeconds: 1800,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("channel_select_setting", &channelSelectSetting)
+}
+
+func GetChannelSelectSetting() *ChannelSelectSetting {
+	return &channelSelectSetting
+}
+
+// GetChannelSelectMode 返回分组实际使用的渠道选择模式，未知取值回退为 weight
+func GetChannelSelectMode(group string) string {
+	mode := channelSelectSetting.Mode
+	if groupMode, ok := channelSelectSetting.GroupModes[group]; ok && groupMode != "" {
+		mode = groupMode
+	}
+	switch mode {
+	case ChannelSelectModeAdaptive:
+		return ChannelSelectModeAdaptive
+	default:
+		return ChannelSelectModeWeight
+	}
+}
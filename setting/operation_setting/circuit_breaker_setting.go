@@ -0,0 +1,44 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// CircuitBreakerSetting 渠道 + 模型维度的熔断配置
+type CircuitBreakerSetting struct {
+	Enabled bool `json:"enabled"`
+	// 连续失败次数达到该值后熔断
+	FailureThreshold int `json:"failure_threshold"`
+	// 首次熔断时长，之后每次半开探测失败翻倍
+	OpenSeconds int `json:"open_seconds"`
+	// 熔断时长上限
+	MaxOpenSeconds int `json:"max_open_seconds"`
+	// 半开状态下连续成功多少次后恢复
+	HalfOpenSuccessThreshold int `json:"half_open_success_threshold"`
+	// 半开状态下两次探测请求的最小间隔
+	HalfOpenProbeIntervalSeconds int `json:"half_open_probe_interval_seconds"`
+	// 是否由后台主动发起测试请求进行半开探测
+	ActiveProbeEnabled bool `json:"active_probe_enabled"`
+}
+
+// 默认配置
+var circuitBreakerSetting = CircuitBreakerSetting{
+	Enabled:                      false,
+	FailureThreshold:             5,
+	OpenSeconds:                  60,
+	MaxOpenSeconds:               1800,
+	HalfOpenSuccessThreshold:     2,
+	HalfOpenProbeIntervalSeconds: 10,
+	ActiveProbeEnabled:           true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("circuit_breaker_setting", &circuitBreakerSetting)
+}
+
+func GetCircuitBreakerSetting() *CircuitBreakerSetting {
+	return &circuitBreakerSetting
+}
+
+func IsCircuitBreakerEnabled() bool {
+	return circuitBreakerSetting.Enabled
+}
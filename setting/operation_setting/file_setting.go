@@ -0,0 +1,40 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// FileSetting Files API 配置
+type FileSetting struct {
+	Enabled bool `json:"enabled"`
+	// 存储后端名称，默认 local
+	StorageBackend string `json:"storage_backend"`
+	// 存储后端配置，local 后端为保存文件的目录
+	StorageConfig string `json:"storage_config"`
+	// 单个文件大小上限（MB）
+	MaxFileSizeMB int `json:"max_file_size_mb"`
+	// 每个用户的文件总大小上限（MB），0 表示不限制
+	UserQuotaMB int `json:"user_quota_mb"`
+	// 请求中引用文件时内联为 base64 的大小上限（MB）
+	InlineMaxSizeMB int `json:"inline_max_size_mb"`
+	// 引用文件时 OpenAI 渠道先把文件上传到上游再替换为上游 file_id，关闭时所有渠道都内联文件内容
+	ReuploadToOpenAI bool `json:"reupload_to_openai"`
+}
+
+// 默认配置
+var fileSetting = FileSetting{
+	Enabled:          true,
+	StorageBackend:   "local",
+	StorageConfig:    "./data/files",
+	MaxFileSizeMB:    512,
+	UserQuotaMB:      1024,
+	InlineMaxSizeMB:  20,
+	ReuploadToOpenAI: true,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("file_setting", &fileSetting)
+}
+
+func GetFileSetting() *FileSetting {
+	return &fileSetting
+}
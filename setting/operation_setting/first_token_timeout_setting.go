@@ -0,0 +1,51 @@
+package operation_setting
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// FirstTokenTimeoutSetting 流式请求首字超时配置，超时前未向客户端输出任何内容时取消上游请求并换渠道重试。
+// 渠道设置中的 first_token_timeout_seconds 优先级最高
+type FirstTokenTimeoutSetting struct {
+	Enabled bool `json:"enabled"`
+	// 默认首字超时秒数，0 表示不限制
+	DefaultSeconds int `json:"default_seconds"`
+	// 模型级别覆盖，model -> seconds，0 表示该模型不限制
+	ModelSeconds map[string]int `json:"model_seconds"`
+}
+
+// 默认配置
+var firstTokenTimeoutSetting = FirstTokenTimeoutSetting{
+	Enabled:        true,
+	DefaultSeconds: 0,
+	ModelSeconds:   map[string]int{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("first_token_timeout_setting", &firstTokenTimeoutSetting)
+}
+
+func GetFirstTokenTimeoutSetting() *FirstTokenTimeoutSetting {
+	return &firstTokenTimeoutSetting
+}
+
+// GetFirstTokenTimeout 返回模型的首字超时时长，channelSeconds 为渠道设置的值（大于 0 时优先使用），0 表示不限制
+func GetFirstTokenTimeout(modelName string, channelSeconds int) time.Duration {
+	if !firstTokenTimeoutSetting.Enabled {
+		return 0
+	}
+	seconds := firstTokenTimeoutSetting.DefaultSeconds
+	if modelSeconds, ok := firstTokenTimeoutSetting.ModelSeconds[modelName]; ok {
+		seconds = modelSeconds
+	}
+	if channelSeconds > 0 {
+		seconds = channelSeconds
+	}
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
@@ -0,0 +1,64 @@
+package operation_setting
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// HedgeSetting 对冲请求配置：对延迟敏感的分组/模型同时（或等待一段时间后）向两个渠道发送同一请求，
+// 先返回首字（非流式为完整响应）的渠道胜出，另一个请求会被取消
+type HedgeSetting struct {
+	Enabled bool `json:"enabled"`
+	// 对冲策略，按顺序使用第一条匹配的策略
+	Policies []HedgePolicy `json:"policies"`
+}
+
+// HedgePolicy 分组/模型级别的对冲策略
+type HedgePolicy struct {
+	// 分组，* 匹配所有分组
+	Group string `json:"group"`
+	// 模型，* 匹配所有模型，以 * 结尾时按前缀匹配
+	Model string `json:"model"`
+	// 主请求发出后等待多少毫秒仍未胜出时发出对冲请求，0 表示同时发出
+	DelayMillis int `json:"delay_millis"`
+}
+
+// 默认配置
+var hedgeSetting = HedgeSetting{
+	Enabled:  false,
+	Policies: []HedgePolicy{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("hedge_setting", &hedgeSetting)
+}
+
+func GetHedgeSetting() *HedgeSetting {
+	return &hedgeSetting
+}
+
+// GetHedgePolicy 返回分组和模型匹配的对冲策略，未启用或没有匹配的策略时返回 nil
+func GetHedgePolicy(group string, modelName string) *HedgePolicy {
+	if !hedgeSetting.Enabled {
+		return nil
+	}
+	for i := range hedgeSetting.Policies {
+		policy := &hedgeSetting.Policies[i]
+		if matchHedgePattern(policy.Group, group) && matchHedgePattern(policy.Model, modelName) {
+			return policy
+		}
+	}
+	return nil
+}
+
+func matchHedgePattern(pattern string, value string) bool {
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(value, prefix)
+	}
+	return pattern == value
+}
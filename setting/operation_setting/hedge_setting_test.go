@@ -0,0 +1,31 @@
+package operation_setting
+
+import "testing"
+
+func TestGetHedgePolicy(t *testing.T) {
+	saved := hedgeSetting
+	defer func() { hedgeSetting = saved }()
+
+	hedgeSetting = HedgeSetting{
+		Enabled: true,
+		Policies: []HedgePolicy{
+			{Group: "vip", Model: "gpt-4o*", DelayMillis: 300},
+			{Group: "*", Model: "claude-sonnet-4", DelayMillis: 0},
+		},
+	}
+
+	if policy := GetHedgePolicy("vip", "gpt-4o-mini"); policy == nil || policy.DelayMillis != 300 {
+		t.Fatalf("expected prefix policy, got %+v", policy)
+	}
+	if policy := GetHedgePolicy("default", "claude-sonnet-4"); policy == nil || policy.DelayMillis != 0 {
+		t.Fatalf("expected wildcard group policy, got %+v", policy)
+	}
+	if policy := GetHedgePolicy("default", "gpt-4o"); policy != nil {
+		t.Fatalf("expected no policy, got %+v", policy)
+	}
+
+	hedgeSetting.Enabled = false
+	if policy := GetHedgePolicy("vip", "gpt-4o"); policy != nil {
+		t.Fatalf("expected no policy when disabled, got %+v", policy)
+	}
+}
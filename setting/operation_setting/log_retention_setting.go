@@ -0,0 +1,36 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// LogRetentionSetting 日志保留策略，由定时任务按日志类型清理过期日志
+type LogRetentionSetting struct {
+	Enabled bool `json:"enabled"`
+	// 各类型日志保留天数，键为 topup/consume/manage/system/error/refund，0 或不填表示永久保留
+	RetentionDays map[string]int `json:"retention_days"`
+	// 删除前把明细按天汇总到 log_daily_rollups
+	RollupEnabled bool `json:"rollup_enabled"`
+	// 删除前把过期日志写入 gzip 压缩的 NDJSON 归档文件
+	ArchiveEnabled bool   `json:"archive_enabled"`
+	ArchiveDir     string `json:"archive_dir"`
+}
+
+// 默认配置
+var logRetentionSetting = LogRetentionSetting{
+	Enabled: false,
+	RetentionDays: map[string]int{
+		"consume": 90,
+		"error":   14,
+	},
+	RollupEnabled:  true,
+	ArchiveEnabled: false,
+	ArchiveDir:     "./logs/archive",
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("log_retention_setting", &logRetentionSetting)
+}
+
+func GetLogRetentionSetting() *LogRetentionSetting {
+	return &logRetentionSetting
+}
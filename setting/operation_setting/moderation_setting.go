@@ -0,0 +1,90 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	// ModerationProviderOpenAI 调用 /v1/moderations，按 category_scores 判断
+	ModerationProviderOpenAI = "openai"
+	// ModerationProviderGuard 调用对话模型，按 Llama Guard 的 safe/unsafe 输出判断
+	ModerationProviderGuard = "guard"
+)
+
+const (
+	// ModerationActionBlock 拒绝请求
+	ModerationActionBlock = "block"
+	// ModerationActionFlag 放行，只记录到日志
+	ModerationActionFlag = "flag"
+	// ModerationActionAllow 不审核
+	ModerationActionAllow = "allow"
+)
+
+// ModerationSetting 使用内部渠道的审核模型对提示词和响应分类
+type ModerationSetting struct {
+	Enabled  bool   `json:"enabled"`
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// 选择审核模型渠道使用的分组，费用记在管理员名下，不向用户收取
+	ChannelGroup string `json:"channel_group"`
+	// 是否同时审核非流式响应
+	CheckCompletion bool `json:"check_completion"`
+	// 分类阈值，分数不低于阈值视为命中，* 为其余分类的默认阈值
+	Thresholds map[string]float64 `json:"thresholds"`
+	Action     string             `json:"action"`
+	// 按分组覆盖处理方式，值为 block、flag 或 allow
+	GroupActions map[string]string `json:"group_actions"`
+	// 审核请求失败时是否放行
+	FailOpen bool `json:"fail_open"`
+	// 送审文本的最大字符数，超出部分截断，0 表示不限制
+	MaxInputChars int `json:"max_input_chars"`
+}
+
+// 默认配置
+var moderationSetting = ModerationSetting{
+	Enabled:         false,
+	Provider:        ModerationProviderOpenAI,
+	Model:           "omni-moderation-latest",
+	ChannelGroup:    "default",
+	CheckCompletion: false,
+	Thresholds:      map[string]float64{"*": 0.5},
+	Action:          ModerationActionBlock,
+	GroupActions:    map[string]string{},
+	FailOpen:        true,
+	MaxInputChars:   20000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("moderation_setting", &moderationSetting)
+}
+
+func GetModerationSetting() *ModerationSetting {
+	return &moderationSetting
+}
+
+// ActionForGroup 返回分组的处理方式，未启用或不审核时返回空字符串
+func (s *ModerationSetting) ActionForGroup(group string) string {
+	if !s.Enabled || s.Model == "" {
+		return ""
+	}
+	action := s.Action
+	if groupAction, ok := s.GroupActions[group]; ok && groupAction != "" {
+		action = groupAction
+	}
+	switch action {
+	case ModerationActionBlock, ModerationActionFlag:
+		return action
+	default:
+		return ""
+	}
+}
+
+// Threshold 返回分类的阈值
+func (s *ModerationSetting) Threshold(category string) float64 {
+	if threshold, ok := s.Thresholds[category]; ok {
+		return threshold
+	}
+	if threshold, ok := s.Thresholds["*"]; ok {
+		return threshold
+	}
+	return 0.5
+}
@@ -0,0 +1,53 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	// OutputModerationActionMask 把命中的敏感词替换为 **###**
+	OutputModerationActionMask = "mask"
+	// OutputModerationActionAbort 中断响应
+	OutputModerationActionAbort = "abort"
+	// OutputModerationActionOff 不检测，只用于分组覆盖
+	OutputModerationActionOff = "off"
+)
+
+// OutputModerationSetting 对模型输出做敏感词检测，词库与提示词检测共用
+type OutputModerationSetting struct {
+	Enabled bool   `json:"enabled"`
+	Action  string `json:"action"`
+	// 按分组覆盖处理方式，值为 mask、abort 或 off
+	GroupActions map[string]string `json:"group_actions"`
+}
+
+// 默认配置
+var outputModerationSetting = OutputModerationSetting{
+	Enabled:      false,
+	Action:       OutputModerationActionMask,
+	GroupActions: map[string]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("output_moderation_setting", &outputModerationSetting)
+}
+
+func GetOutputModerationSetting() *OutputModerationSetting {
+	return &outputModerationSetting
+}
+
+// ActionForGroup 返回分组的处理方式，未启用时返回空字符串
+func (s *OutputModerationSetting) ActionForGroup(group string) string {
+	if !s.Enabled {
+		return ""
+	}
+	action := s.Action
+	if groupAction, ok := s.GroupActions[group]; ok && groupAction != "" {
+		action = groupAction
+	}
+	switch action {
+	case OutputModerationActionMask, OutputModerationActionAbort:
+		return action
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,40 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// ResponseCacheSetting 响应缓存配置：对开启了响应缓存的令牌，相同的对话和向量请求直接返回缓存的响应，不请求上游
+type ResponseCacheSetting struct {
+	Enabled bool `json:"enabled"`
+	// 令牌未设置缓存时间时的默认秒数
+	DefaultTTLSeconds int `json:"default_ttl_seconds"`
+	// 内存缓存的最大条目数，启用 Redis 时不生效
+	MaxEntries int `json:"max_entries"`
+	// 超过该大小（KB）的响应不缓存
+	MaxResponseKB int `json:"max_response_kb"`
+	// 命中缓存时的计费倍率，0 表示免费
+	HitRatio float64 `json:"hit_ratio"`
+	// 对话请求只在 temperature 为 0 时缓存
+	RequireZeroTemperature bool `json:"require_zero_temperature"`
+	// 不同用户的相同请求是否共享缓存
+	ShareAcrossUsers bool `json:"share_across_users"`
+}
+
+// 默认配置
+var responseCacheSetting = ResponseCacheSetting{
+	Enabled:                true,
+	DefaultTTLSeconds:      3600,
+	MaxEntries:             10000,
+	MaxResponseKB:          1024,
+	HitRatio:               0.1,
+	RequireZeroTemperature: true,
+	ShareAcrossUsers:       false,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("response_cache_setting", &responseCacheSetting)
+}
+
+func GetResponseCacheSetting() *ResponseCacheSetting {
+	return &responseCacheSetting
+}
@@ -0,0 +1,30 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// ResponsesStoreSetting 网关保存 Responses API 响应的配置。
+// 上游不支持 Responses 接口的渠道由网关保存响应，支持 previous_response_id 和查询、删除响应
+type ResponsesStoreSetting struct {
+	// 开启后网关会保存用户的输入和响应内容，默认关闭
+	Enabled bool `json:"enabled"`
+	// 响应保存时长（小时），0 表示不过期
+	RetentionHours int `json:"retention_hours"`
+	// 每个用户最多保存的响应数，超出时删除最早的响应，0 表示不限制
+	MaxResponsesPerUser int `json:"max_responses_per_user"`
+}
+
+// 默认配置
+var responsesStoreSetting = ResponsesStoreSetting{
+	Enabled:             false,
+	RetentionHours:      720,
+	MaxResponsesPerUser: 1000,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("responses_store_setting", &responsesStoreSetting)
+}
+
+func GetResponsesStoreSetting() *ResponsesStoreSetting {
+	return &responsesStoreSetting
+}
@@ -0,0 +1,26 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// StreamFailoverSetting 流式响应中途断开时换渠道续写的配置，仅对 chat completions 与 Claude messages 生效。
+// 续写时把已发送给客户端的文本作为 assistant 预填充发给下一个渠道，续写内容拼接到同一个响应流中
+type StreamFailoverSetting struct {
+	Enabled bool `json:"enabled"`
+	// 单个请求最多续写的次数
+	MaxFailovers int `json:"max_failovers"`
+}
+
+// 默认配置
+var streamFailoverSetting = StreamFailoverSetting{
+	Enabled:      false,
+	MaxFailovers: 1,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("stream_failover_setting", &streamFailoverSetting)
+}
+
+func GetStreamFailoverSetting() *StreamFailoverSetting {
+	return &streamFailoverSetting
+}
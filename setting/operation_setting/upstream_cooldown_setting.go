@@ -0,0 +1,28 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// UpstreamCooldownSetting 上游 429 限流冷却配置
+type UpstreamCooldownSetting struct {
+	Enabled bool `json:"enabled"`
+	// 上游返回 429 但未给出 Retry-After 等响应头时的默认冷却秒数，0 表示不冷却
+	DefaultSeconds int `json:"default_seconds"`
+	// 冷却时长上限，防止异常响应头导致渠道长时间不可用
+	MaxSeconds int `json:"max_seconds"`
+}
+
+// 默认配置
+var upstreamCooldownSetting = UpstreamCooldownSetting{
+	Enabled:        true,
+	DefaultSeconds: 0,
+	MaxSeconds:     300,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("upstream_cooldown_setting", &upstreamCooldownSetting)
+}
+
+func GetUpstreamCooldownSetting() *UpstreamCooldownSetting {
+	return &upstreamCooldownSetting
+}
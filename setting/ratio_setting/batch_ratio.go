@@ -0,0 +1,59 @@
+package ratio_setting
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// batchGroupRatio 批处理任务的分组折扣，在分组倍率基础上再相乘，未配置的分组不打折
+var batchGroupRatio = map[string]float64{}
+
+var batchGroupRatioMutex sync.RWMutex
+
+func BatchGroupRatio2JSONString() string {
+	batchGroupRatioMutex.RLock()
+	defer batchGroupRatioMutex.RUnlock()
+
+	jsonBytes, err := json.Marshal(batchGroupRatio)
+	if err != nil {
+		common.SysLog("error marshalling batch group ratio: " + err.Error())
+	}
+	return string(jsonBytes)
+}
+
+func UpdateBatchGroupRatioByJSONString(jsonStr string) error {
+	batchGroupRatioMutex.Lock()
+	defer batchGroupRatioMutex.Unlock()
+
+	batchGroupRatio = make(map[string]float64)
+	return json.Unmarshal([]byte(jsonStr), &batchGroupRatio)
+}
+
+// GetBatchGroupRatio 返回分组的批处理折扣，未配置时返回 1
+func GetBatchGroupRatio(name string) float64 {
+	batchGroupRatioMutex.RLock()
+	defer batchGroupRatioMutex.RUnlock()
+
+	ratio, ok := batchGroupRatio[name]
+	if !ok {
+		return 1
+	}
+	return ratio
+}
+
+func CheckBatchGroupRatio(jsonStr string) error {
+	checkBatchGroupRatio := make(map[string]float64)
+	err := json.Unmarshal([]byte(jsonStr), &checkBatchGroupRatio)
+	if err != nil {
+		return err
+	}
+	for name, ratio := range checkBatchGroupRatio {
+		if ratio < 0 {
+			return errors.New("batch group ratio must be not less than 0: " + name)
+		}
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/QuantumNous/new-api/common"
 )
@@ -38,17 +39,28 @@ const (
 type ErrorCode string
 
 const (
-	ErrorCodeInvalidRequest         ErrorCode = "invalid_request"
-	ErrorCodeSensitiveWordsDetected ErrorCode = "sensitive_words_detected"
+	ErrorCodeInvalidRequest           ErrorCode = "invalid_request"
+	ErrorCodeSensitiveWordsDetected   ErrorCode = "sensitive_words_detected"
+	ErrorCodeModerationFlagged        ErrorCode = "moderation_flagged"
+	ErrorCodeModerationFailed         ErrorCode = "moderation_failed"
+	ErrorCodePreviousResponseNotFound ErrorCode = "previous_response_not_found"
 
 	// new api error
-	ErrorCodeCountTokenFailed   ErrorCode = "count_token_failed"
-	ErrorCodeModelPriceError    ErrorCode = "model_price_error"
-	ErrorCodeInvalidApiType     ErrorCode = "invalid_api_type"
-	ErrorCodeJsonMarshalFailed  ErrorCode = "json_marshal_failed"
-	ErrorCodeDoRequestFailed    ErrorCode = "do_request_failed"
-	ErrorCodeGetChannelFailed   ErrorCode = "get_channel_failed"
-	ErrorCodeGenRelayInfoFailed ErrorCode = "gen_relay_info_failed"
+	ErrorCodeCountTokenFailed    ErrorCode = "count_token_failed"
+	ErrorCodeModelPriceError     ErrorCode = "model_price_error"
+	ErrorCodeInvalidApiType      ErrorCode = "invalid_api_type"
+	ErrorCodeJsonMarshalFailed   ErrorCode = "json_marshal_failed"
+	ErrorCodeDoRequestFailed     ErrorCode = "do_request_failed"
+	ErrorCodeGetChannelFailed    ErrorCode = "get_channel_failed"
+	ErrorCodeGenRelayInfoFailed  ErrorCode = "gen_relay_info_failed"
+	ErrorCodeUpstreamCoolingDown ErrorCode = "upstream_cooling_down"
+	ErrorCodeKeyRateLimited      ErrorCode = "key_rate_limited"
+	ErrorCodeRateLimitExceeded   ErrorCode = "rate_limit_exceeded"
+	ErrorCodeChannelSaturated    ErrorCode = "channel_saturated"
+	ErrorCodeFirstTokenTimeout   ErrorCode = "first_token_timeout"
+	ErrorCodeStreamInterrupted   ErrorCode = "stream_interrupted"
+	// 续写时选中的渠道不支持预填充
+	ErrorCodeStreamContinuationUnsupported ErrorCode = "stream_continuation_unsupported"
 
 	// channel error
 	ErrorCodeChannelNoAvailableKey        ErrorCode = "channel:no_available_key"
@@ -84,6 +96,7 @@ const (
 	// quota error
 	ErrorCodeInsufficientUserQuota      ErrorCode = "insufficient_user_quota"
 	ErrorCodePreConsumeTokenQuotaFailed ErrorCode = "pre_consume_token_quota_failed"
+	ErrorCodeSpendLimitExceeded         ErrorCode = "spend_limit_exceeded"
 )
 
 type NewAPIError struct {
@@ -95,6 +108,8 @@ type NewAPIError struct {
 	errorCode      ErrorCode
 	StatusCode     int
 	Metadata       json.RawMessage
+	// RetryAfter 上游通过 Retry-After / x-ratelimit-reset-* 等响应头给出的等待时间，0 表示未提供
+	RetryAfter time.Duration
 }
 
 // Unwrap enables errors.Is / errors.As to work with NewAPIError by exposing the underlying error.
@@ -59,6 +59,7 @@ const About = lazy(() => import('./pages/About'));
 const UserAgreement = lazy(() => import('./pages/UserAgreement'));
 const PrivacyPolicy = lazy(() => import('./pages/PrivacyPolicy'));
 const Fingerprint = lazy(() => import('./pages/Fingerprint'));
+const AuditLog = lazy(() => import('./pages/AuditLog'));
 const Ranking = lazy(() => import('./pages/Ranking'));
 const InvitationCode = lazy(() => import('./pages/InvitationCode'));
 const Blacklist = lazy(() => import('./pages/Blacklist'));
@@ -281,6 +282,16 @@ function App() {
             </AdminRoute>
           }
         />
+        <Route
+          path='/console/audit_log'
+          element={
+            <AdminRoute>
+              <Suspense fallback={<Loading></Loading>} key={location.pathname}>
+                <AuditLog />
+              </Suspense>
+            </AdminRoute>
+          }
+        />
         <Route
           path='/console/log'
           element={
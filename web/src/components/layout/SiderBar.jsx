@@ -54,6 +54,7 @@ const routerMap = {
   fingerprint: '/console/fingerprint',
   ranking: '/console/ranking',
   invitation_code: '/console/invitation_code',
+  audit_log: '/console/audit_log',
 };
 
 const SiderBar = ({ onNavigate = () => { } }) => {
@@ -217,6 +218,12 @@ const SiderBar = ({ onNavigate = () => { } }) => {
         to: '/console/invitation_code',
         className: isAdmin() ? '' : 'tableHiddle',
       },
+      {
+        text: t('审计日志'),
+        itemKey: 'audit_log',
+        to: '/console/audit_log',
+        className: isRoot() ? '' : 'tableHiddle',
+      },
       {
         text: t('系统设置'),
         itemKey: 'setting',
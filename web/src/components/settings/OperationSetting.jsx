@@ -23,6 +23,7 @@ import SettingsGeneral from '../../pages/Setting/Operation/SettingsGeneral';
 import SettingsHeaderNavModules from '../../pages/Setting/Operation/SettingsHeaderNavModules';
 import SettingsSidebarModulesAdmin from '../../pages/Setting/Operation/SettingsSidebarModulesAdmin';
 import SettingsSensitiveWords from '../../pages/Setting/Operation/SettingsSensitiveWords';
+import SettingsModeration from '../../pages/Setting/Operation/SettingsModeration';
 import SettingsLog from '../../pages/Setting/Operation/SettingsLog';
 import SettingsMonitoring from '../../pages/Setting/Operation/SettingsMonitoring';
 import SettingsCreditLimit from '../../pages/Setting/Operation/SettingsCreditLimit';
@@ -67,9 +68,27 @@ const OperationSetting = () => {
     CheckSensitiveEnabled: false,
     CheckSensitiveOnPromptEnabled: false,
     SensitiveWords: '',
+    'output_moderation_setting.enabled': false,
+    'output_moderation_setting.action': 'mask',
+    'output_moderation_setting.group_actions': '',
+    'moderation_setting.enabled': false,
+    'moderation_setting.provider': 'openai',
+    'moderation_setting.model': '',
+    'moderation_setting.channel_group': '',
+    'moderation_setting.check_completion': false,
+    'moderation_setting.thresholds': '',
+    'moderation_setting.action': 'block',
+    'moderation_setting.group_actions': '',
+    'moderation_setting.fail_open': true,
+    'moderation_setting.max_input_chars': 20000,
 
     /* 日志设置 */
     LogConsumeEnabled: false,
+    'log_retention_setting.enabled': false,
+    'log_retention_setting.retention_days': '',
+    'log_retention_setting.rollup_enabled': true,
+    'log_retention_setting.archive_enabled': false,
+    'log_retention_setting.archive_dir': '',
 
     /* 监控设置 */
     ChannelDisableThreshold: 0,
@@ -136,6 +155,10 @@ const OperationSetting = () => {
         <Card style={{ marginTop: '10px' }}>
           <SettingsSensitiveWords options={inputs} refresh={onRefresh} />
         </Card>
+        {/* 审核模型设置 */}
+        <Card style={{ marginTop: '10px' }}>
+          <SettingsModeration options={inputs} refresh={onRefresh} />
+        </Card>
         {/* 日志设置 */}
         <Card style={{ marginTop: '10px' }}>
           <SettingsLog options={inputs} refresh={onRefresh} />
@@ -36,6 +36,7 @@ const ChannelsActions = ({
   fixChannelsAbilities,
   updateAllChannelsBalance,
   deleteAllDisabledChannels,
+  setShowCircuitBreakerModal,
   compactMode,
   setCompactMode,
   idSort,
@@ -146,6 +147,16 @@ const ChannelsActions = ({
                     {t('更新所有已启用通道余额')}
                   </Button>
                 </Dropdown.Item>
+                <Dropdown.Item>
+                  <Button
+                    size='small'
+                    type='tertiary'
+                    className='w-full'
+                    onClick={() => setShowCircuitBreakerModal(true)}
+                  >
+                    {t('模型熔断状态')}
+                  </Button>
+                </Dropdown.Item>
                 <Dropdown.Item>
                   <Button
                     size='small'
@@ -33,6 +33,7 @@ import ColumnSelectorModal from './modals/ColumnSelectorModal';
 import EditChannelModal from './modals/EditChannelModal';
 import EditTagModal from './modals/EditTagModal';
 import MultiKeyManageModal from './modals/MultiKeyManageModal';
+import CircuitBreakerModal from './modals/CircuitBreakerModal';
 import { createCardProPagination } from '../../../helpers/utils';
 
 const ChannelsPage = () => {
@@ -63,6 +64,10 @@ const ChannelsPage = () => {
         channel={channelsData.currentMultiKeyChannel}
         onRefresh={channelsData.refresh}
       />
+      <CircuitBreakerModal
+        visible={channelsData.showCircuitBreakerModal}
+        onCancel={() => channelsData.setShowCircuitBreakerModal(false)}
+      />
 
       {/* Main Content */}
       {channelsData.globalPassThroughEnabled ? (
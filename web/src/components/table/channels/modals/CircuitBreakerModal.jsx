@@ -0,0 +1,202 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState } from 'react';
+import { useTranslation } from 'react-i18next';
+import {
+  Modal,
+  Button,
+  Table,
+  Tag,
+  Typography,
+  Popconfirm,
+  Space,
+} from '@douyinfe/semi-ui';
+import {
+  API,
+  showError,
+  showSuccess,
+  timestamp2string,
+} from '../../../../helpers';
+
+const { Text } = Typography;
+
+const stateColors = {
+  closed: 'green',
+  open: 'red',
+  half_open: 'orange',
+};
+
+const CircuitBreakerModal = ({ visible, onCancel }) => {
+  const { t } = useTranslation();
+  const [loading, setLoading] = useState(false);
+  const [items, setItems] = useState([]);
+  const [enabled, setEnabled] = useState(false);
+
+  const loadBreakers = async () => {
+    setLoading(true);
+    try {
+      const res = await API.get('/api/channel/circuit_breakers');
+      if (res.data.success) {
+        setItems(res.data.data.items || []);
+        setEnabled(!!res.data.data.setting?.enabled);
+      } else {
+        showError(res.data.message);
+      }
+    } catch (error) {
+      showError(t('获取熔断状态失败'));
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  const resetBreaker = async (channelId, modelName) => {
+    const params = new URLSearchParams();
+    if (channelId) {
+      params.set('channel_id', channelId);
+    } else {
+      params.set('all', 'true');
+    }
+    if (modelName) {
+      params.set('model_name', modelName);
+    }
+    const res = await API.delete(
+      `/api/channel/circuit_breakers?${params.toString()}`,
+    );
+    if (res.data.success) {
+      showSuccess(t('操作成功完成！'));
+      await loadBreakers();
+    } else {
+      showError(res.data.message);
+    }
+  };
+
+  useEffect(() => {
+    if (visible) {
+      loadBreakers();
+    }
+  }, [visible]);
+
+  const stateText = {
+    closed: t('正常'),
+    open: t('熔断中'),
+    half_open: t('半开探测'),
+  };
+
+  const columns = [
+    {
+      title: t('渠道'),
+      dataIndex: 'channel_id',
+      render: (text) => <Text>#{text}</Text>,
+    },
+    {
+      title: t('模型'),
+      dataIndex: 'model_name',
+    },
+    {
+      title: t('状态'),
+      dataIndex: 'state',
+      render: (text) => (
+        <Tag color={stateColors[text] || 'grey'} shape='circle'>
+          {stateText[text] || text}
+        </Tag>
+      ),
+    },
+    {
+      title: t('连续失败'),
+      dataIndex: 'consecutive_failures',
+    },
+    {
+      title: t('熔断次数'),
+      dataIndex: 'trip_count',
+    },
+    {
+      title: t('恢复时间'),
+      dataIndex: 'open_until',
+      render: (text) => (text ? timestamp2string(text) : '-'),
+    },
+    {
+      title: t('最近错误'),
+      dataIndex: 'last_error',
+      render: (text) => (
+        <Text ellipsis={{ showTooltip: true }} style={{ maxWidth: 240 }}>
+          {text || '-'}
+        </Text>
+      ),
+    },
+    {
+      title: '',
+      dataIndex: 'operate',
+      render: (_, record) => (
+        <Popconfirm
+          title={t('确定要重置该熔断器吗？')}
+          onConfirm={() => resetBreaker(record.channel_id, record.model_name)}
+        >
+          <Button size='small' type='tertiary'>
+            {t('重置')}
+          </Button>
+        </Popconfirm>
+      ),
+    },
+  ];
+
+  return (
+    <Modal
+      title={t('模型熔断状态')}
+      visible={visible}
+      onCancel={onCancel}
+      footer={
+        <Space>
+          <Button onClick={loadBreakers} loading={loading}>
+            {t('刷新')}
+          </Button>
+          <Popconfirm
+            title={t('确定要重置全部熔断器吗？')}
+            onConfirm={() => resetBreaker(0, '')}
+          >
+            <Button type='danger' disabled={items.length === 0}>
+              {t('全部重置')}
+            </Button>
+          </Popconfirm>
+        </Space>
+      }
+      width={960}
+      centered={true}
+      className='!rounded-lg'
+    >
+      {!enabled && (
+        <div className='mb-3'>
+          <Text type='warning'>
+            {t('熔断功能未开启，可在运营设置中开启')}
+          </Text>
+        </div>
+      )}
+      <Table
+        size='small'
+        loading={loading}
+        columns={columns}
+        dataSource={items}
+        rowKey={(record) => `${record.channel_id}:${record.model_name}`}
+        pagination={{ pageSize: 10 }}
+      />
+    </Modal>
+  );
+};
+
+export default CircuitBreakerModal;
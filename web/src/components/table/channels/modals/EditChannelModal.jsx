@@ -166,6 +166,10 @@ const EditChannelModal = (props) => {
     allow_service_tier: false,
     disable_store: false, // false = 允许透传（默认开启）
     allow_safety_identifier: false,
+    // 渠道最大并发请求数（存入 settings.max_concurrency），0 表示不限制
+    max_concurrency: 0,
+    // 流式首字超时秒数（存入 settings.first_token_timeout_seconds），0 表示使用全局配置
+    first_token_timeout_seconds: 0,
   };
   const [batch, setBatch] = useState(false);
   const [multiToSingle, setMultiToSingle] = useState(false);
@@ -628,6 +632,9 @@ const EditChannelModal = (props) => {
           data.disable_store = parsedSettings.disable_store || false;
           data.allow_safety_identifier =
             parsedSettings.allow_safety_identifier || false;
+          data.max_concurrency = parsedSettings.max_concurrency || 0;
+          data.first_token_timeout_seconds =
+            parsedSettings.first_token_timeout_seconds || 0;
         } catch (error) {
           console.error('解析其他设置失败:', error);
           data.azure_responses_version = '';
@@ -638,6 +645,8 @@ const EditChannelModal = (props) => {
           data.allow_service_tier = false;
           data.disable_store = false;
           data.allow_safety_identifier = false;
+          data.max_concurrency = 0;
+          data.first_token_timeout_seconds = 0;
         }
       } else {
         // 兼容历史数据：老渠道没有 settings 时，默认按 json 展示
@@ -647,6 +656,8 @@ const EditChannelModal = (props) => {
         data.allow_service_tier = false;
         data.disable_store = false;
         data.allow_safety_identifier = false;
+        data.max_concurrency = 0;
+        data.first_token_timeout_seconds = 0;
       }
 
       if (
@@ -1317,6 +1328,23 @@ const EditChannelModal = (props) => {
       }
     }
 
+    const maxConcurrency = parseInt(localInputs.max_concurrency, 10);
+    if (maxConcurrency > 0) {
+      settings.max_concurrency = maxConcurrency;
+    } else {
+      delete settings.max_concurrency;
+    }
+
+    const firstTokenTimeout = parseInt(
+      localInputs.first_token_timeout_seconds,
+      10,
+    );
+    if (firstTokenTimeout > 0) {
+      settings.first_token_timeout_seconds = firstTokenTimeout;
+    } else {
+      delete settings.first_token_timeout_seconds;
+    }
+
     localInputs.settings = JSON.stringify(settings);
 
     // 清理不需要发送到后端的字段
@@ -1336,6 +1364,8 @@ const EditChannelModal = (props) => {
     delete localInputs.allow_service_tier;
     delete localInputs.disable_store;
     delete localInputs.allow_safety_identifier;
+    delete localInputs.max_concurrency;
+    delete localInputs.first_token_timeout_seconds;
 
     let res;
     localInputs.auto_ban = localInputs.auto_ban ? 1 : 0;
@@ -2186,6 +2216,12 @@ const EditChannelModal = (props) => {
                           optionList={[
                             { label: t('随机'), value: 'random' },
                             { label: t('轮询'), value: 'polling' },
+                            {
+                              label: t('最久未使用'),
+                              value: 'least_recently_used',
+                            },
+                            { label: t('最少错误'), value: 'least_errors' },
+                            { label: t('余额优先'), value: 'quota_aware' },
                           ]}
                           style={{ width: '100%' }}
                           value={inputs.multi_key_mode || 'random'}
@@ -2194,6 +2230,15 @@ const EditChannelModal = (props) => {
                             handleInputChange('multi_key_mode', value);
                           }}
                         />
+                        {inputs.multi_key_mode === 'quota_aware' && (
+                          <Banner
+                            type='info'
+                            description={t(
+                              '余额优先模式依赖定时余额查询，仅支持可查询余额的渠道类型，未查询到余额的密钥排在最后',
+                            )}
+                            className='!rounded-lg mt-2'
+                          />
+                        )}
                         {inputs.multi_key_mode === 'polling' && (
                           <Banner
                             type='warning'
@@ -2820,6 +2865,34 @@ const EditChannelModal = (props) => {
                       </Col>
                     </Row>
 
+                    <Form.InputNumber
+                      field='max_concurrency'
+                      label={t('最大并发数')}
+                      placeholder={t('0 表示不限制')}
+                      min={0}
+                      onNumberChange={(value) =>
+                        handleInputChange('max_concurrency', value)
+                      }
+                      style={{ width: '100%' }}
+                      extraText={t(
+                        '同时进行中的请求数上限，达到上限后优先选择其他渠道，全部已满时排队等待',
+                      )}
+                    />
+
+                    <Form.InputNumber
+                      field='first_token_timeout_seconds'
+                      label={t('首字超时（秒）')}
+                      placeholder={t('0 表示使用全局配置')}
+                      min={0}
+                      onNumberChange={(value) =>
+                        handleInputChange('first_token_timeout_seconds', value)
+                      }
+                      style={{ width: '100%' }}
+                      extraText={t(
+                        '流式请求在该时间内未返回首个数据时取消请求并自动重试其他渠道',
+                      )}
+                    />
+
                     <Form.Switch
                       field='auto_ban'
                       label={t('是否自动禁用')}
@@ -2950,6 +3023,61 @@ const EditChannelModal = (props) => {
                       showClear
                     />
 
+                    <Form.TextArea
+                      field='response_override'
+                      label={t('响应覆盖')}
+                      placeholder={
+                        t(
+                          '此项可选，用于改写返回给客户端的响应，格式与参数覆盖相同，流式响应按分片应用',
+                        ) +
+                        '\n{\n  "operations": [\n    {\n      "path": "model",\n      "mode": "set",\n      "value": "gpt-4o"\n    }\n  ]\n}'
+                      }
+                      autosize
+                      onChange={(value) =>
+                        handleInputChange('response_override', value)
+                      }
+                      extraText={
+                        <div className='flex gap-2 flex-wrap'>
+                          <Text
+                            className='!text-semi-color-primary cursor-pointer'
+                            onClick={() =>
+                              handleInputChange(
+                                'response_override',
+                                JSON.stringify(
+                                  {
+                                    operations: [
+                                      {
+                                        path: 'model',
+                                        mode: 'set',
+                                        value: 'gpt-4o',
+                                      },
+                                      {
+                                        path: 'choices.0.finish_reason',
+                                        mode: 'set',
+                                        value: 'stop',
+                                        conditions: [
+                                          {
+                                            path: 'choices.0.finish_reason',
+                                            mode: 'full',
+                                            value: 'end_turn',
+                                          },
+                                        ],
+                                      },
+                                    ],
+                                  },
+                                  null,
+                                  2,
+                                ),
+                              )
+                            }
+                          >
+                            {t('填入模板')}
+                          </Text>
+                        </div>
+                      }
+                      showClear
+                    />
+
                     <JSONEditor
                       key={`status_code_mapping-${isEdit ? channelId : 'new'}`}
                       field='status_code_mapping'
@@ -72,6 +72,7 @@ const EditTagModal = (props) => {
     models: [],
     param_override: null,
     header_override: null,
+    response_override: null,
   };
   const [inputs, setInputs] = useState(originInputs);
   const formApiRef = useRef(null);
@@ -229,6 +230,26 @@ const EditTagModal = (props) => {
       }
       data.header_override = trimmedHeaderOverride;
     }
+    if (
+      formVals.response_override !== undefined &&
+      formVals.response_override !== null
+    ) {
+      if (typeof formVals.response_override !== 'string') {
+        showInfo('响应覆盖必须是合法的 JSON 格式！');
+        setLoading(false);
+        return;
+      }
+      const trimmedResponseOverride = formVals.response_override.trim();
+      if (
+        trimmedResponseOverride !== '' &&
+        !verifyJSON(trimmedResponseOverride)
+      ) {
+        showInfo('响应覆盖必须是合法的 JSON 格式！');
+        setLoading(false);
+        return;
+      }
+      data.response_override = trimmedResponseOverride;
+    }
     data.new_tag = formVals.new_tag;
     if (
       data.model_mapping === undefined &&
@@ -236,7 +257,8 @@ const EditTagModal = (props) => {
       data.models === undefined &&
       data.new_tag === undefined &&
       data.param_override === undefined &&
-      data.header_override === undefined
+      data.header_override === undefined &&
+      data.response_override === undefined
     ) {
       showWarning('没有任何修改！');
       setLoading(false);
@@ -680,6 +702,54 @@ const EditTagModal = (props) => {
                       </div>
                     }
                   />
+
+                  <Form.TextArea
+                    field='response_override'
+                    label={t('响应覆盖')}
+                    placeholder={t(
+                      '此项可选，用于改写返回给客户端的响应，格式与参数覆盖相同，流式响应按分片应用',
+                    )}
+                    autosize
+                    showClear
+                    onChange={(value) =>
+                      handleInputChange('response_override', value)
+                    }
+                    extraText={
+                      <div className='flex gap-2 flex-wrap items-center'>
+                        <Text
+                          className='!text-semi-color-primary cursor-pointer'
+                          onClick={() =>
+                            handleInputChange(
+                              'response_override',
+                              JSON.stringify(
+                                {
+                                  operations: [
+                                    {
+                                      path: 'model',
+                                      mode: 'set',
+                                      value: 'gpt-4o',
+                                    },
+                                  ],
+                                },
+                                null,
+                                2,
+                              ),
+                            )
+                          }
+                        >
+                          {t('填入模板')}
+                        </Text>
+                        <Text
+                          className='!text-semi-color-primary cursor-pointer'
+                          onClick={() =>
+                            handleInputChange('response_override', null)
+                          }
+                        >
+                          {t('不更改')}
+                        </Text>
+                      </div>
+                    }
+                  />
                 </div>
               </Card>
 
@@ -392,6 +392,54 @@ const MultiKeyManageModal = ({ visible, onCancel, channel, onRefresh }) => {
         );
       },
     },
+    {
+      title: t('用量'),
+      dataIndex: 'usage',
+      render: (usage, record) => {
+        if (!usage) {
+          return <Text type='quaternary'>-</Text>;
+        }
+        const limit = record.limit || {};
+        const rpmText = limit.rpm
+          ? `${usage.current_rpm}/${limit.rpm}`
+          : `${usage.current_rpm}`;
+        const tpmText = limit.tpm
+          ? `${usage.current_tpm}/${limit.tpm}`
+          : `${usage.current_tpm}`;
+        return (
+          <Tooltip
+            content={
+              <div>
+                <div>
+                  {t('请求数')}: {usage.requests}
+                </div>
+                <div>
+                  {t('错误数')}: {usage.errors}
+                </div>
+                <div>
+                  {t('近期错误')}: {usage.recent_errors.toFixed(2)}
+                </div>
+                {usage.balance !== undefined && usage.balance !== null && (
+                  <div>
+                    {t('余额')}: ${usage.balance.toFixed(2)}
+                  </div>
+                )}
+                {usage.last_used_at > 0 && (
+                  <div>
+                    {t('最近使用')}:{' '}
+                    {timestamp2string(Math.floor(usage.last_used_at / 1000))}
+                  </div>
+                )}
+              </div>
+            }
+          >
+            <Text style={{ fontSize: '12px' }}>
+              RPM {rpmText} · TPM {tpmText}
+            </Text>
+          </Tooltip>
+        );
+      },
+    },
     {
       title: t('禁用时间'),
       dataIndex: 'disabled_time',
@@ -467,9 +515,13 @@ const MultiKeyManageModal = ({ visible, onCancel, channel, onRefresh }) => {
           </Tag>
           {channel?.channel_info?.multi_key_mode && (
             <Tag size='small' shape='circle' color='white'>
-              {channel.channel_info.multi_key_mode === 'random'
-                ? t('随机模式')
-                : t('轮询模式')}
+              {{
+                random: t('随机模式'),
+                polling: t('轮询模式'),
+                least_recently_used: t('最久未使用模式'),
+                least_errors: t('最少错误模式'),
+                quota_aware: t('余额优先模式'),
+              }[channel.channel_info.multi_key_mode] || t('轮询模式')}
             </Tag>
           )}
         </Space>
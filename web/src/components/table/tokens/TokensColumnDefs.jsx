@@ -306,6 +306,44 @@ const renderQuotaUsage = (text, record, t) => {
   );
 };
 
+// Render spend limit column: current-window usage of each limited window
+const renderSpendLimits = (text, record, t) => {
+  const windows = record.spend_usage || [];
+  if (windows.length === 0) {
+    return (
+      <Tag color='white' shape='circle'>
+        {t('无限制')}
+      </Tag>
+    );
+  }
+  const labels = {
+    daily: t('每日'),
+    weekly: t('每周'),
+    monthly: t('每月'),
+  };
+  const windowText =
+    record.quota_limit_window === 'rolling'
+      ? t('滚动窗口')
+      : t('自然日/周/月');
+  return (
+    <Space vertical align='start' spacing={2}>
+      {windows.map((w) => {
+        const exceeded = w.used >= w.limit;
+        return (
+          <Tooltip
+            key={w.window}
+            content={`${windowText}，${t('重置时间')}: ${timestamp2string(w.reset_at)}`}
+          >
+            <Tag color={exceeded ? 'red' : 'white'} shape='circle'>
+              {`${labels[w.window] || w.window} ${renderQuota(Math.max(w.used, 0))} / ${renderQuota(w.limit)}`}
+            </Tag>
+          </Tooltip>
+        );
+      })}
+    </Space>
+  );
+};
+
 // Render operations column
 const renderOperations = (
   text,
@@ -451,6 +489,11 @@ export const getTokensColumns = ({
       key: 'quota_usage',
       render: (text, record) => renderQuotaUsage(text, record, t),
     },
+    {
+      title: t('消费上限'),
+      key: 'spend_limit',
+      render: (text, record) => renderSpendLimits(text, record, t),
+    },
     {
       title: t('分组'),
       dataIndex: 'group',
@@ -69,11 +69,19 @@ const EditTokenModal = (props) => {
     remain_quota: 0,
     expired_time: -1,
     unlimited_quota: true,
+    daily_quota_limit: 0,
+    weekly_quota_limit: 0,
+    monthly_quota_limit: 0,
+    quota_limit_window: '',
+    rpm_limit: 0,
+    tpm_limit: 0,
     model_limits_enabled: false,
     model_limits: [],
     allow_ips: '',
     group: '',
     cross_group_retry: false,
+    response_cache: false,
+    response_cache_ttl: 0,
     tokenCount: 1,
   });
 
@@ -516,6 +524,49 @@ const EditTokenModal = (props) => {
                       )}
                     />
                   </Col>
+                  <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                    <Form.InputNumber
+                      field='daily_quota_limit'
+                      label={t('每日消费上限')}
+                      min={0}
+                      extraText={renderQuotaWithPrompt(values.daily_quota_limit)}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                    <Form.InputNumber
+                      field='weekly_quota_limit'
+                      label={t('每周消费上限')}
+                      min={0}
+                      extraText={renderQuotaWithPrompt(values.weekly_quota_limit)}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col xs={24} sm={24} md={8} lg={8} xl={8}>
+                    <Form.InputNumber
+                      field='monthly_quota_limit'
+                      label={t('每月消费上限')}
+                      min={0}
+                      extraText={renderQuotaWithPrompt(
+                        values.monthly_quota_limit,
+                      )}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={24}>
+                    <Form.Select
+                      field='quota_limit_window'
+                      label={t('消费上限窗口')}
+                      optionList={[
+                        { value: '', label: t('自然日/周/月') },
+                        { value: 'rolling', label: t('滚动窗口') },
+                      ]}
+                      extraText={t(
+                        '为 0 时不限制。自然窗口在每天零点、每周一零点、每月一日零点重置；滚动窗口统计最近 24 小时、7 天、30 天的消费',
+                      )}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
                 </Row>
               </Card>
 
@@ -566,6 +617,46 @@ const EditTokenModal = (props) => {
                       style={{ width: '100%' }}
                     />
                   </Col>
+                  <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                    <Form.InputNumber
+                      field='rpm_limit'
+                      label={t('每分钟请求数上限（RPM）')}
+                      min={0}
+                      extraText={t('为 0 时不限制')}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col xs={24} sm={24} md={12} lg={12} xl={12}>
+                    <Form.InputNumber
+                      field='tpm_limit'
+                      label={t('每分钟 Token 数上限（TPM）')}
+                      min={0}
+                      extraText={t('按预估的输入 Token 数检查，请求完成后按实际用量校正')}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
+                  <Col span={24}>
+                    <Form.Switch
+                      field='response_cache'
+                      label={t('响应缓存')}
+                      size='default'
+                      extraText={t(
+                        '开启后，temperature 为 0 的相同对话请求和相同的向量请求直接返回缓存的响应，并按缓存命中倍率计费',
+                      )}
+                    />
+                  </Col>
+                  <Col
+                    span={24}
+                    style={{ display: values.response_cache ? 'block' : 'none' }}
+                  >
+                    <Form.InputNumber
+                      field='response_cache_ttl'
+                      label={t('响应缓存时间（秒）')}
+                      min={0}
+                      extraText={t('为 0 时使用系统默认的缓存时间')}
+                      style={{ width: '100%' }}
+                    />
+                  </Col>
                 </Row>
               </Card>
             </div>
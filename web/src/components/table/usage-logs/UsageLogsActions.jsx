@@ -18,7 +18,8 @@ For commercial licensing, please contact support@quantumnous.com
 */
 
 import React from 'react';
-import { Tag, Space, Skeleton } from '@douyinfe/semi-ui';
+import { Tag, Space, Skeleton, Dropdown, Button } from '@douyinfe/semi-ui';
+import { IconDownload } from '@douyinfe/semi-icons';
 import { renderQuota } from '../../../helpers';
 import CompactModeToggle from '../../common/ui/CompactModeToggle';
 import { useMinimumLoadingTime } from '../../../hooks/common/useMinimumLoadingTime';
@@ -29,6 +30,8 @@ const LogsActions = ({
   showStat,
   compactMode,
   setCompactMode,
+  exportLogs,
+  exporting,
   t,
 }) => {
   const showSkeleton = useMinimumLoadingTime(loadingStat);
@@ -83,11 +86,39 @@ const LogsActions = ({
         </Space>
       </Skeleton>
 
-      <CompactModeToggle
-        compactMode={compactMode}
-        setCompactMode={setCompactMode}
-        t={t}
-      />
+      <Space>
+        <Dropdown
+          trigger='click'
+          position='bottomRight'
+          menu={[
+            { node: 'item', name: 'CSV', onClick: () => exportLogs('csv') },
+            {
+              node: 'item',
+              name: 'NDJSON',
+              onClick: () => exportLogs('ndjson'),
+            },
+            {
+              node: 'item',
+              name: 'CSV (gzip)',
+              onClick: () => exportLogs('csv', true),
+            },
+          ]}
+        >
+          <Button
+            icon={<IconDownload />}
+            type='tertiary'
+            size='small'
+            loading={exporting}
+          >
+            {t('导出')}
+          </Button>
+        </Dropdown>
+        <CompactModeToggle
+          compactMode={compactMode}
+          setCompactMode={setCompactMode}
+          t={t}
+        />
+      </Space>
     </div>
   );
 };
@@ -78,6 +78,10 @@ const EditUserModal = (props) => {
     email: '',
     quota: 0,
     group: 'default',
+    daily_quota_limit: 0,
+    weekly_quota_limit: 0,
+    monthly_quota_limit: 0,
+    quota_limit_window: '',
     remark: '',
   });
 
@@ -306,6 +310,54 @@ const EditUserModal = (props) => {
                           />
                         </Form.Slot>
                       </Col>
+
+                      <Col span={8}>
+                        <Form.InputNumber
+                          field='daily_quota_limit'
+                          label={t('每日消费上限')}
+                          min={0}
+                          extraText={renderQuotaWithPrompt(
+                            values.daily_quota_limit || 0,
+                          )}
+                          style={{ width: '100%' }}
+                        />
+                      </Col>
+                      <Col span={8}>
+                        <Form.InputNumber
+                          field='weekly_quota_limit'
+                          label={t('每周消费上限')}
+                          min={0}
+                          extraText={renderQuotaWithPrompt(
+                            values.weekly_quota_limit || 0,
+                          )}
+                          style={{ width: '100%' }}
+                        />
+                      </Col>
+                      <Col span={8}>
+                        <Form.InputNumber
+                          field='monthly_quota_limit'
+                          label={t('每月消费上限')}
+                          min={0}
+                          extraText={renderQuotaWithPrompt(
+                            values.monthly_quota_limit || 0,
+                          )}
+                          style={{ width: '100%' }}
+                        />
+                      </Col>
+                      <Col span={24}>
+                        <Form.Select
+                          field='quota_limit_window'
+                          label={t('消费上限窗口')}
+                          optionList={[
+                            { value: '', label: t('自然日/周/月') },
+                            { value: 'rolling', label: t('滚动窗口') },
+                          ]}
+                          extraText={t(
+                            '为 0 时不限制。自然窗口在每天零点、每周一零点、每月一日零点重置；滚动窗口统计最近 24 小时、7 天、30 天的消费',
+                          )}
+                          style={{ width: '100%' }}
+                        />
+                      </Col>
                     </Row>
                   </Card>
                 )}
@@ -79,6 +79,7 @@ import {
   Server,
   Trophy,
   Ticket,
+  ScrollText,
 } from 'lucide-react';
 
 // 获取侧边栏Lucide图标组件
@@ -138,6 +139,8 @@ export function getLucideIcon(key, selected = false) {
       return <Trophy {...commonProps} color={iconColor} />;
     case 'invitation_code':
       return <Ticket {...commonProps} color={iconColor} />;
+    case 'audit_log':
+      return <ScrollText {...commonProps} color={iconColor} />;
     default:
       return <CircleUser {...commonProps} color={iconColor} />;
   }
@@ -114,6 +114,10 @@ export const useChannelsData = () => {
   const [showMultiKeyManageModal, setShowMultiKeyManageModal] = useState(false);
   const [currentMultiKeyChannel, setCurrentMultiKeyChannel] = useState(null);
 
+  // Circuit breaker states
+  const [showCircuitBreakerModal, setShowCircuitBreakerModal] =
+    useState(false);
+
   // Refs
   const requestCounter = useRef(0);
   const allSelectingRef = useRef(false);
@@ -1165,6 +1169,10 @@ export const useChannelsData = () => {
     currentMultiKeyChannel,
     setCurrentMultiKeyChannel,
 
+    // Circuit breaker states
+    showCircuitBreakerModal,
+    setShowCircuitBreakerModal,
+
     // Form
     formApi,
     setFormApi,
@@ -69,6 +69,7 @@ export const useSidebar = () => {
       user: true,
       fingerprint: true,
       invitation_code: true,
+      audit_log: true,
       setting: true,
     },
   };
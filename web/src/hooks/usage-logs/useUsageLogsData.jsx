@@ -66,6 +66,7 @@ export const useLogsData = () => {
   const [expandData, setExpandData] = useState({});
   const [showStat, setShowStat] = useState(false);
   const [loading, setLoading] = useState(false);
+  const [exporting, setExporting] = useState(false);
   const [loadingStat, setLoadingStat] = useState(false);
   const [activePage, setActivePage] = useState(1);
   const [logCount, setLogCount] = useState(0);
@@ -476,6 +477,14 @@ export const useLogsData = () => {
             value: other.reasoning_effort,
           });
         }
+        if (other?.response_cache_hit) {
+          expandDataLocal.push({
+            key: t('响应缓存'),
+            value: t('命中缓存，计费倍率 {{ratio}}', {
+              ratio: other.response_cache_ratio,
+            }),
+          });
+        }
       }
       if (other?.request_path) {
         expandDataLocal.push({
@@ -483,6 +492,31 @@ export const useLogsData = () => {
           value: other.request_path,
         });
       }
+      if (other?.moderation) {
+        const moderation = other.moderation;
+        const categories = [
+          ...(moderation.prompt_categories || []),
+          ...(moderation.completion_categories || []),
+        ];
+        expandDataLocal.push({
+          key: t('内容审核'),
+          value: t('{{action}}，命中分类：{{categories}}', {
+            action:
+              moderation.action === 'block' ? t('已拒绝') : t('仅记录'),
+            categories: [...new Set(categories)].join(', '),
+          }),
+        });
+      }
+      if (other?.output_moderation) {
+        const moderation = other.output_moderation;
+        expandDataLocal.push({
+          key: t('响应内容过滤'),
+          value: t('{{action}}，命中：{{words}}', {
+            action: moderation.aborted ? t('已中断响应') : t('已替换屏蔽词'),
+            words: [...new Set(moderation.words || [])].join(', '),
+          }),
+        });
+      }
       if (isAdminUser) {
         let localCountMode = '';
         if (other?.admin_info?.local_count_tokens) {
@@ -548,6 +582,53 @@ export const useLogsData = () => {
     setLoading(false);
   };
 
+  // Export logs with current filters, streamed by the server
+  const exportLogs = async (format = 'csv', gzip = false) => {
+    const {
+      username,
+      token_name,
+      model_name,
+      start_timestamp,
+      end_timestamp,
+      channel,
+      group,
+      logType: formLogType,
+    } = getFormValues();
+    const currentLogType = formLogType !== undefined ? formLogType : logType;
+    const params = {
+      type: currentLogType,
+      token_name,
+      model_name,
+      start_timestamp: Date.parse(start_timestamp) / 1000,
+      end_timestamp: Date.parse(end_timestamp) / 1000,
+      group,
+      format,
+      gzip,
+    };
+    if (isAdminUser) {
+      params.username = username;
+      params.channel = channel;
+    }
+    setExporting(true);
+    try {
+      const res = await API.get(
+        isAdminUser ? '/api/log/export' : '/api/log/self/export',
+        { params, responseType: 'blob' },
+      );
+      const disposition = res.headers['content-disposition'] || '';
+      const matched = disposition.match(/filename="?([^"]+)"?/);
+      const filename = matched ? matched[1] : `logs.${format}`;
+      const link = document.createElement('a');
+      link.href = URL.createObjectURL(res.data);
+      link.download = filename;
+      link.click();
+      URL.revokeObjectURL(link.href);
+    } catch (e) {
+      showError(t('导出失败'));
+    }
+    setExporting(false);
+  };
+
   // Page handlers
   const handlePageChange = (page) => {
     setActivePage(page);
@@ -649,6 +730,8 @@ export const useLogsData = () => {
 
     // Functions
     loadLogs,
+    exportLogs,
+    exporting,
     handlePageChange,
     handlePageSizeChange,
     refresh,
@@ -0,0 +1,322 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useCallback, useEffect, useMemo, useState } from 'react';
+import {
+  Button,
+  Card,
+  Input,
+  Modal,
+  Select,
+  Space,
+  Table,
+  Tag,
+  Typography,
+} from '@douyinfe/semi-ui';
+import { IconSearch, IconRefresh } from '@douyinfe/semi-icons';
+import { API, showError, timestamp2string } from '../../helpers';
+import { useTranslation } from 'react-i18next';
+
+const TARGET_COLORS = {
+  option: 'orange',
+  channel: 'blue',
+  user: 'green',
+  redemption: 'purple',
+};
+
+function parseJSON(str) {
+  if (!str) return null;
+  try {
+    return JSON.parse(str);
+  } catch (e) {
+    return null;
+  }
+}
+
+function formatValue(v) {
+  if (v === null || v === undefined) return '-';
+  if (typeof v === 'object') return JSON.stringify(v);
+  return String(v);
+}
+
+export default function AuditLogPage() {
+  const { t } = useTranslation();
+
+  const [loading, setLoading] = useState(false);
+  const [logs, setLogs] = useState([]);
+  const [total, setTotal] = useState(0);
+  const [page, setPage] = useState(1);
+  const [targetType, setTargetType] = useState('');
+  const [action, setAction] = useState('');
+  const [targetId, setTargetId] = useState('');
+  const [keyword, setKeyword] = useState('');
+
+  // 详情弹窗
+  const [detail, setDetail] = useState(null);
+
+  const pageSize = 20;
+
+  const loadLogs = useCallback(
+    async (p = 1) => {
+      setLoading(true);
+      try {
+        const res = await API.get('/api/audit_log/', {
+          params: {
+            p,
+            page_size: pageSize,
+            target_type: targetType,
+            action,
+            target_id: targetId,
+            keyword,
+          },
+        });
+        const { success, data, message } = res.data;
+        if (success) {
+          setLogs(data?.items || []);
+          setTotal(data?.total || 0);
+        } else {
+          showError(message || '加载失败');
+        }
+      } catch (e) {
+        showError(e.message || '请求失败');
+      } finally {
+        setLoading(false);
+      }
+    },
+    [targetType, action, targetId, keyword],
+  );
+
+  useEffect(() => {
+    loadLogs(1);
+  }, []);
+
+  const handleSearch = () => {
+    setPage(1);
+    loadLogs(1);
+  };
+
+  const renderDiff = (diffStr) => {
+    const diff = parseJSON(diffStr);
+    if (!diff || Object.keys(diff).length === 0) return '-';
+    const keys = Object.keys(diff);
+    return (
+      <div>
+        {keys.slice(0, 3).map((k) => (
+          <div key={k}>
+            <Typography.Text strong>{k}</Typography.Text>
+            {': '}
+            <Typography.Text type='tertiary' delete>
+              {formatValue(diff[k].before)}
+            </Typography.Text>
+            {' → '}
+            <Typography.Text>{formatValue(diff[k].after)}</Typography.Text>
+          </div>
+        ))}
+        {keys.length > 3 && (
+          <Typography.Text type='tertiary'>
+            {t('等 {{count}} 项', { count: keys.length })}
+          </Typography.Text>
+        )}
+      </div>
+    );
+  };
+
+  const columns = useMemo(
+    () => [
+      {
+        title: t('时间'),
+        dataIndex: 'created_at',
+        key: 'created_at',
+        width: 170,
+        render: (v) => timestamp2string(v),
+      },
+      {
+        title: t('操作人'),
+        dataIndex: 'actor_name',
+        key: 'actor_name',
+        width: 140,
+        render: (v, record) => `${v || '-'} (#${record.actor_id})`,
+      },
+      {
+        title: 'IP',
+        dataIndex: 'ip',
+        key: 'ip',
+        width: 130,
+      },
+      {
+        title: t('动作'),
+        dataIndex: 'action',
+        key: 'action',
+        width: 120,
+        render: (v) => <Tag>{v}</Tag>,
+      },
+      {
+        title: t('对象'),
+        key: 'target',
+        width: 220,
+        render: (_, record) => (
+          <Space>
+            <Tag color={TARGET_COLORS[record.target_type] || 'grey'}>
+              {record.target_type}
+            </Tag>
+            <Typography.Text
+              ellipsis={{ showTooltip: true }}
+              style={{ maxWidth: 140 }}
+            >
+              {record.target_id || '-'}
+            </Typography.Text>
+          </Space>
+        ),
+      },
+      {
+        title: t('变更'),
+        dataIndex: 'diff',
+        key: 'diff',
+        render: renderDiff,
+      },
+      {
+        title: t('操作'),
+        key: 'operate',
+        width: 90,
+        render: (_, record) => (
+          <Button size='small' onClick={() => setDetail(record)}>
+            {t('详情')}
+          </Button>
+        ),
+      },
+    ],
+    [t],
+  );
+
+  const renderJSONBlock = (str) => {
+    const obj = parseJSON(str);
+    return (
+      <pre
+        style={{
+          maxHeight: 360,
+          overflow: 'auto',
+          fontSize: 12,
+          margin: 0,
+          whiteSpace: 'pre-wrap',
+          wordBreak: 'break-all',
+        }}
+      >
+        {obj ? JSON.stringify(obj, null, 2) : '-'}
+      </pre>
+    );
+  };
+
+  return (
+    <div className='mt-[60px] px-2'>
+      <Card
+        className='!rounded-2xl'
+        title={t('审计日志')}
+        headerExtraContent={
+          <Button
+            icon={<IconRefresh />}
+            type='tertiary'
+            onClick={() => loadLogs(page)}
+            loading={loading}
+          >
+            {t('刷新')}
+          </Button>
+        }
+      >
+        <div className='mb-4'>
+          <Space wrap>
+            <Select
+              value={targetType}
+              onChange={setTargetType}
+              style={{ width: 140 }}
+              optionList={[
+                { label: t('全部对象'), value: '' },
+                { label: t('系统设置'), value: 'option' },
+                { label: t('渠道'), value: 'channel' },
+                { label: t('用户'), value: 'user' },
+                { label: t('兑换码'), value: 'redemption' },
+              ]}
+            />
+            <Input
+              placeholder={t('动作')}
+              value={action}
+              onChange={setAction}
+              onEnterPress={handleSearch}
+              style={{ width: 140 }}
+            />
+            <Input
+              placeholder={t('对象ID / 配置项')}
+              value={targetId}
+              onChange={setTargetId}
+              onEnterPress={handleSearch}
+              style={{ width: 180 }}
+            />
+            <Input
+              prefix={<IconSearch />}
+              placeholder={t('搜索操作人、对象或变更内容')}
+              value={keyword}
+              onChange={setKeyword}
+              onEnterPress={handleSearch}
+              style={{ width: 260 }}
+            />
+            <Button type='primary' onClick={handleSearch}>
+              {t('搜索')}
+            </Button>
+          </Space>
+        </div>
+        <Table
+          bordered
+          size='small'
+          loading={loading}
+          columns={columns}
+          dataSource={(logs || []).map((r) => ({ ...r, key: r.id }))}
+          pagination={{
+            currentPage: page,
+            pageSize,
+            total,
+            onPageChange: (p) => {
+              setPage(p);
+              loadLogs(p);
+            },
+          }}
+        />
+      </Card>
+
+      <Modal
+        title={t('审计详情')}
+        visible={!!detail}
+        onCancel={() => setDetail(null)}
+        footer={null}
+        width={900}
+      >
+        {detail && (
+          <div className='flex gap-4'>
+            <div className='flex-1 min-w-0'>
+              <Typography.Title heading={6}>{t('修改前')}</Typography.Title>
+              {renderJSONBlock(detail.before)}
+            </div>
+            <div className='flex-1 min-w-0'>
+              <Typography.Title heading={6}>{t('修改后')}</Typography.Title>
+              {renderJSONBlock(detail.after)}
+            </div>
+          </div>
+        )}
+      </Modal>
+    </div>
+  );
+}
@@ -32,6 +32,7 @@ import dayjs from 'dayjs';
 import { useTranslation } from 'react-i18next';
 import {
   compareObjects,
+  verifyJSON,
   API,
   showError,
   showSuccess,
@@ -46,6 +47,11 @@ export default function SettingsLog(props) {
   const [loadingCleanHistoryLog, setLoadingCleanHistoryLog] = useState(false);
   const [inputs, setInputs] = useState({
     LogConsumeEnabled: false,
+    'log_retention_setting.enabled': false,
+    'log_retention_setting.retention_days': '',
+    'log_retention_setting.rollup_enabled': true,
+    'log_retention_setting.archive_enabled': false,
+    'log_retention_setting.archive_dir': '',
     historyTimestamp: dayjs().subtract(1, 'month').toDate(),
   });
   const refForm = useRef();
@@ -57,6 +63,15 @@ export default function SettingsLog(props) {
     );
 
     if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    const retentionDays = inputs['log_retention_setting.retention_days'];
+    if (
+      updateArray.some(
+        (item) => item.key === 'log_retention_setting.retention_days',
+      ) &&
+      !verifyJSON(retentionDays)
+    ) {
+      return showError(t('日志保留天数不是合法的 JSON'));
+    }
     const requestQueue = updateArray.map((item) => {
       let value = '';
       if (typeof inputs[item.key] === 'boolean') {
@@ -248,6 +263,89 @@ export default function SettingsLog(props) {
               </Col>
             </Row>
 
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'log_retention_setting.enabled'}
+                  label={t('启用日志保留策略')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  extraText={t('每小时按日志类型删除超过保留天数的日志')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'log_retention_setting.enabled': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'log_retention_setting.rollup_enabled'}
+                  label={t('删除前按天汇总')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  extraText={t('按用户、令牌、模型、渠道和分组汇总，删除明细后仍可查看长期统计')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'log_retention_setting.rollup_enabled': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'log_retention_setting.archive_enabled'}
+                  label={t('删除前归档')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  extraText={t('写入 gzip 压缩的 NDJSON 文件')}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'log_retention_setting.archive_enabled': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={12} lg={12} xl={12}>
+                <Form.TextArea
+                  field={'log_retention_setting.retention_days'}
+                  label={t('各类型日志保留天数')}
+                  placeholder={'{"consume": 90, "error": 14}'}
+                  extraText={t(
+                    '可用类型：topup、consume、manage、system、error、refund，0 表示永久保留',
+                  )}
+                  autosize={{ minRows: 3, maxRows: 6 }}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'log_retention_setting.retention_days': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={12} lg={12} xl={12}>
+                <Form.Input
+                  field={'log_retention_setting.archive_dir'}
+                  label={t('归档目录')}
+                  placeholder='./logs/archive'
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'log_retention_setting.archive_dir': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+
             <Row>
               <Button size='default' onClick={onSubmit}>
                 {t('保存日志设置')}
@@ -0,0 +1,244 @@
+/*
+Copyright (C) 2025 QuantumNous
+
+This program is free software: you can redistribute it and/or modify
+it under the terms of the GNU Affero General Public License as
+published by the Free Software Foundation, either version 3 of the
+License, or (at your option) any later version.
+
+This program is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+GNU Affero General Public License for more details.
+
+You should have received a copy of the GNU Affero General Public License
+along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+For commercial licensing, please contact support@quantumnous.com
+*/
+
+import React, { useEffect, useState, useRef } from 'react';
+import { Button, Col, Form, Row, Spin } from '@douyinfe/semi-ui';
+import {
+  compareObjects,
+  verifyJSON,
+  API,
+  showError,
+  showSuccess,
+  showWarning,
+} from '../../../helpers';
+import { useTranslation } from 'react-i18next';
+
+const jsonFields = [
+  'moderation_setting.thresholds',
+  'moderation_setting.group_actions',
+];
+
+export default function SettingsModeration(props) {
+  const { t } = useTranslation();
+  const [loading, setLoading] = useState(false);
+  const [inputs, setInputs] = useState({
+    'moderation_setting.enabled': false,
+    'moderation_setting.provider': 'openai',
+    'moderation_setting.model': '',
+    'moderation_setting.channel_group': '',
+    'moderation_setting.check_completion': false,
+    'moderation_setting.thresholds': '',
+    'moderation_setting.action': 'block',
+    'moderation_setting.group_actions': '',
+    'moderation_setting.fail_open': true,
+    'moderation_setting.max_input_chars': 20000,
+  });
+  const refForm = useRef();
+  const [inputsRow, setInputsRow] = useState(inputs);
+
+  function onSubmit() {
+    const updateArray = compareObjects(inputs, inputsRow);
+    if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    for (const item of updateArray) {
+      if (jsonFields.includes(item.key) && !verifyJSON(inputs[item.key])) {
+        return showError(t('分类阈值或分组处理方式不是合法的 JSON'));
+      }
+    }
+    const requestQueue = updateArray.map((item) => {
+      return API.put('/api/option/', {
+        key: item.key,
+        value: String(inputs[item.key]),
+      });
+    });
+    setLoading(true);
+    Promise.all(requestQueue)
+      .then((res) => {
+        if (requestQueue.length === 1) {
+          if (res.includes(undefined)) return;
+        } else if (requestQueue.length > 1) {
+          if (res.includes(undefined))
+            return showError(t('部分保存失败，请重试'));
+        }
+        showSuccess(t('保存成功'));
+        props.refresh();
+      })
+      .catch(() => {
+        showError(t('保存失败，请重试'));
+      })
+      .finally(() => {
+        setLoading(false);
+      });
+  }
+
+  useEffect(() => {
+    const currentInputs = {};
+    for (let key in props.options) {
+      if (Object.keys(inputs).includes(key)) {
+        currentInputs[key] = props.options[key];
+      }
+    }
+    setInputs(currentInputs);
+    setInputsRow(structuredClone(currentInputs));
+    refForm.current.setValues(currentInputs);
+  }, [props.options]);
+
+  function handleFieldChange(fieldName) {
+    return (value) => setInputs((inputs) => ({ ...inputs, [fieldName]: value }));
+  }
+
+  return (
+    <>
+      <Spin spinning={loading}>
+        <Form
+          values={inputs}
+          getFormApi={(formAPI) => (refForm.current = formAPI)}
+          style={{ marginBottom: 15 }}
+        >
+          <Form.Section text={t('审核模型设置')}>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'moderation_setting.enabled'}
+                  label={t('启用审核模型')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  extraText={t(
+                    '通过内部渠道调用审核模型检查提示词，费用计入渠道用量，不向用户收取',
+                  )}
+                  onChange={handleFieldChange('moderation_setting.enabled')}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'moderation_setting.check_completion'}
+                  label={t('审核非流式响应')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={handleFieldChange(
+                    'moderation_setting.check_completion',
+                  )}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'moderation_setting.fail_open'}
+                  label={t('审核失败时放行')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  onChange={handleFieldChange('moderation_setting.fail_open')}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Select
+                  field={'moderation_setting.provider'}
+                  label={t('审核模型类型')}
+                  optionList={[
+                    { label: 'OpenAI Moderations', value: 'openai' },
+                    { label: 'Llama Guard', value: 'guard' },
+                  ]}
+                  onChange={handleFieldChange('moderation_setting.provider')}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Input
+                  field={'moderation_setting.model'}
+                  label={t('审核模型')}
+                  placeholder='omni-moderation-latest'
+                  onChange={handleFieldChange('moderation_setting.model')}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Input
+                  field={'moderation_setting.channel_group'}
+                  label={t('审核渠道分组')}
+                  placeholder='default'
+                  onChange={handleFieldChange(
+                    'moderation_setting.channel_group',
+                  )}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Select
+                  field={'moderation_setting.action'}
+                  label={t('命中后的处理方式')}
+                  optionList={[
+                    { label: t('拒绝请求'), value: 'block' },
+                    { label: t('仅记录'), value: 'flag' },
+                  ]}
+                  onChange={handleFieldChange('moderation_setting.action')}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.InputNumber
+                  field={'moderation_setting.max_input_chars'}
+                  label={t('送审最大字符数')}
+                  min={0}
+                  extraText={t('超出部分截断，0 表示不限制')}
+                  onChange={handleFieldChange(
+                    'moderation_setting.max_input_chars',
+                  )}
+                />
+              </Col>
+            </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={12} lg={12} xl={12}>
+                <Form.TextArea
+                  field={'moderation_setting.thresholds'}
+                  label={t('分类阈值')}
+                  placeholder={'{"*": 0.5, "violence": 0.3}'}
+                  extraText={t(
+                    '分数不低于阈值的分类视为命中，* 为其余分类的默认阈值；Llama Guard 命中的分类分数为 1',
+                  )}
+                  autosize={{ minRows: 3, maxRows: 6 }}
+                  onChange={handleFieldChange('moderation_setting.thresholds')}
+                />
+              </Col>
+              <Col xs={24} sm={12} md={12} lg={12} xl={12}>
+                <Form.TextArea
+                  field={'moderation_setting.group_actions'}
+                  label={t('分组处理方式')}
+                  placeholder={'{"vip": "flag", "internal": "allow"}'}
+                  extraText={t(
+                    '按分组覆盖处理方式，可选 block、flag、allow，allow 表示该分组不审核',
+                  )}
+                  autosize={{ minRows: 3, maxRows: 6 }}
+                  onChange={handleFieldChange(
+                    'moderation_setting.group_actions',
+                  )}
+                />
+              </Col>
+            </Row>
+            <Row>
+              <Button size='default' onClick={onSubmit}>
+                {t('保存审核模型设置')}
+              </Button>
+            </Row>
+          </Form.Section>
+        </Form>
+      </Spin>
+    </>
+  );
+}
@@ -21,6 +21,7 @@ import React, { useEffect, useState, useRef } from 'react';
 import { Button, Col, Form, Row, Spin, Tag } from '@douyinfe/semi-ui';
 import {
   compareObjects,
+  verifyJSON,
   API,
   showError,
   showSuccess,
@@ -35,6 +36,9 @@ export default function SettingsSensitiveWords(props) {
     CheckSensitiveEnabled: false,
     CheckSensitiveOnPromptEnabled: false,
     SensitiveWords: '',
+    'output_moderation_setting.enabled': false,
+    'output_moderation_setting.action': 'mask',
+    'output_moderation_setting.group_actions': '',
   });
   const refForm = useRef();
   const [inputsRow, setInputsRow] = useState(inputs);
@@ -42,6 +46,15 @@ export default function SettingsSensitiveWords(props) {
   function onSubmit() {
     const updateArray = compareObjects(inputs, inputsRow);
     if (!updateArray.length) return showWarning(t('你似乎并没有修改什么'));
+    const groupActions = inputs['output_moderation_setting.group_actions'];
+    if (
+      updateArray.some(
+        (item) => item.key === 'output_moderation_setting.group_actions',
+      ) &&
+      !verifyJSON(groupActions)
+    ) {
+      return showError(t('分组处理方式不是合法的 JSON'));
+    }
     const requestQueue = updateArray.map((item) => {
       let value = '';
       if (typeof inputs[item.key] === 'boolean') {
@@ -144,6 +157,61 @@ export default function SettingsSensitiveWords(props) {
                 />
               </Col>
             </Row>
+            <Row gutter={16}>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Switch
+                  field={'output_moderation_setting.enabled'}
+                  label={t('启用响应内容过滤')}
+                  size='default'
+                  checkedText='｜'
+                  uncheckedText='〇'
+                  extraText={t(
+                    '检查模型返回的内容，流式响应会保留最长屏蔽词长度的内容以检测跨分片的屏蔽词',
+                  )}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'output_moderation_setting.enabled': value,
+                    })
+                  }
+                />
+              </Col>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.Select
+                  field={'output_moderation_setting.action'}
+                  label={t('命中后的处理方式')}
+                  optionList={[
+                    { label: t('替换屏蔽词'), value: 'mask' },
+                    { label: t('中断响应'), value: 'abort' },
+                  ]}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'output_moderation_setting.action': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
+            <Row>
+              <Col xs={24} sm={12} md={8} lg={8} xl={8}>
+                <Form.TextArea
+                  field={'output_moderation_setting.group_actions'}
+                  label={t('分组处理方式')}
+                  placeholder={'{"vip": "off", "free": "abort"}'}
+                  extraText={t(
+                    '按分组覆盖处理方式，可选 mask、abort、off，off 表示该分组不过滤响应',
+                  )}
+                  autosize={{ minRows: 3, maxRows: 6 }}
+                  onChange={(value) =>
+                    setInputs({
+                      ...inputs,
+                      'output_moderation_setting.group_actions': value,
+                    })
+                  }
+                />
+              </Col>
+            </Row>
             <Row>
               <Button size='default' onClick={onSubmit}>
                 {t('保存屏蔽词过滤设置')}
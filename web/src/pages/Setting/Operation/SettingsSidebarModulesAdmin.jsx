@@ -70,6 +70,7 @@ export default function SettingsSidebarModulesAdmin(props) {
       user: true,
       fingerprint: true,
       invitation_code: true,
+      audit_log: true,
       setting: true,
     },
   });
@@ -135,6 +136,7 @@ export default function SettingsSidebarModulesAdmin(props) {
         user: true,
         fingerprint: true,
         invitation_code: true,
+        audit_log: true,
         setting: true,
       },
     };
@@ -208,6 +210,7 @@ export default function SettingsSidebarModulesAdmin(props) {
             user: true,
             fingerprint: true,
             invitation_code: true,
+            audit_log: true,
             setting: true,
           },
         };
@@ -287,6 +290,11 @@ export default function SettingsSidebarModulesAdmin(props) {
           title: t('注解码管理'),
           description: t('注册码/解封码管理'),
         },
+        {
+          key: 'audit_log',
+          title: t('审计日志'),
+          description: t('管理操作审计记录'),
+        },
         {
           key: 'setting',
           title: t('系统设置'),